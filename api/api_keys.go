@@ -0,0 +1,331 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// API keys look like chk_<prefix>_<secret>. The prefix is stored in clear
+// to find the key; only a hash of the whole key is stored.
+const (
+	apiKeyPrefix      = "chk_"
+	apiKeyIDBytes     = 4
+	apiKeyIDLength    = 2 * apiKeyIDBytes
+	apiKeySecretBytes = 32
+	apiKeyIDSeparator = "_"
+)
+
+type apiKeyResponse struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	UserID         *int64     `json:"user_id,omitempty"`
+	OrganizationID *int64     `json:"organization_id,omitempty"`
+	Scopes         []string   `json:"scopes"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(key db.ApiKey) apiKeyResponse {
+	rsp := apiKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.UserID.Valid {
+		rsp.UserID = &key.UserID.Int64
+	}
+	if key.OrganizationID.Valid {
+		rsp.OrganizationID = &key.OrganizationID.Int64
+	}
+	if key.LastUsedAt.Valid {
+		rsp.LastUsedAt = &key.LastUsedAt.Time
+	}
+	if key.ExpiresAt.Valid {
+		rsp.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.RevokedAt.Valid {
+		rsp.RevokedAt = &key.RevokedAt.Time
+	}
+	return rsp
+}
+
+// verifyAPIKey looks up an API key and stores its principal in the context.
+// On failure it aborts the request with 401 and returns false.
+func verifyAPIKey(c *gin.Context, store db.Store, key string) bool {
+	rest := strings.TrimPrefix(key, apiKeyPrefix)
+	if len(rest) <= apiKeyIDLength || !strings.HasPrefix(rest[apiKeyIDLength:], apiKeyIDSeparator) {
+		abortUnauthorized(c, authErrMalformedToken, "api key is malformed")
+		return false
+	}
+
+	ctx := c.Request.Context()
+
+	apiKey, err := store.GetAPIKeyByPrefix(ctx, rest[:apiKeyIDLength])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			abortUnauthorized(c, authErrInvalidToken, "api key is invalid")
+			return false
+		}
+		log.Printf("verifyAPIKey error: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify api key"})
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(util.HashToken(key)), []byte(apiKey.KeyHash)) != 1 {
+		abortUnauthorized(c, authErrInvalidToken, "api key is invalid")
+		return false
+	}
+	if apiKey.RevokedAt.Valid {
+		abortUnauthorized(c, authErrRevokedToken, "api key has been revoked")
+		return false
+	}
+	if apiKey.ExpiresAt.Valid && time.Now().After(apiKey.ExpiresAt.Time) {
+		abortUnauthorized(c, authErrExpiredToken, "api key has expired")
+		return false
+	}
+
+	if err := store.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		log.Printf("verifyAPIKey touch error: %v", err)
+	}
+
+	c.Set(authorizationPrincipalKey, &principal{
+		UserID:         apiKey.UserID.Int64,
+		OrganizationID: apiKey.OrganizationID.Int64,
+		Role:           apiKey.OwnerRole.String,
+		APIKeyID:       apiKey.ID,
+		Scopes:         apiKey.Scopes,
+	})
+	return true
+}
+
+// generateAPIKey returns a new API key and its lookup prefix. The prefix is
+// hex so that it never contains the separator.
+func generateAPIKey() (key string, prefix string, err error) {
+	id := make([]byte, apiKeyIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret, err := util.RandomToken(apiKeySecretBytes)
+	if err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(id)
+	return apiKeyPrefix + prefix + apiKeyIDSeparator + secret, prefix, nil
+}
+
+// createAPIKey issues a key owned by the current user or, for admins, by an
+// organization. The key itself is only returned by this call.
+func (s *Server) createAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateCreateAPIKeyRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p := authPrincipal(c)
+	params := db.CreateAPIKeyParams{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		CreatedBy: p.UserID,
+	}
+
+	if req.OrganizationID != nil {
+		if p.Role != util.AdminRole {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only admins can create organization keys"})
+			return
+		}
+		params.OrganizationID = pgtype.Int8{Int64: *req.OrganizationID, Valid: true}
+	} else {
+		for _, scope := range req.Scopes {
+			if !util.RoleAllowsScope(p.Role, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "your role cannot grant the " + scope + " scope"})
+				return
+			}
+		}
+		params.UserID = pgtype.Int8{Int64: p.UserID, Valid: true}
+	}
+
+	if req.ExpiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		log.Printf("createAPIKey generate error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+	params.Prefix = prefix
+	params.KeyHash = util.HashToken(key)
+
+	apiKey, err := s.store.CreateAPIKey(c.Request.Context(), params)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+			return
+		}
+		log.Printf("createAPIKey error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"key":     key,
+		"api_key": newAPIKeyResponse(apiKey),
+	})
+}
+
+// listAPIKeys lists the keys of the current user, or of an organization for
+// admins passing organization_id.
+func (s *Server) listAPIKeys(c *gin.Context) {
+	p := authPrincipal(c)
+
+	var (
+		keys []db.ApiKey
+		err  error
+	)
+	if orgStr := c.Query("organization_id"); orgStr != "" {
+		orgID, parseErr := strconv.ParseInt(orgStr, 10, 64)
+		if parseErr != nil || orgID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization id"})
+			return
+		}
+		if p.Role != util.AdminRole {
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			return
+		}
+		keys, err = s.store.ListOrganizationAPIKeys(c.Request.Context(), pgtype.Int8{Int64: orgID, Valid: true})
+	} else {
+		keys, err = s.store.ListUserAPIKeys(c.Request.Context(), pgtype.Int8{Int64: p.UserID, Valid: true})
+	}
+	if err != nil {
+		log.Printf("listAPIKeys error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list api keys"})
+		return
+	}
+
+	responses := make([]apiKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, newAPIKeyResponse(key))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// revokeAPIKey revokes a key of the current user. Admins can revoke any key.
+func (s *Server) revokeAPIKey(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	apiKey, err := s.store.GetAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+		log.Printf("revokeAPIKey get error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke api key"})
+		return
+	}
+
+	p := authPrincipal(c)
+	if p.Role != util.AdminRole && (!apiKey.UserID.Valid || apiKey.UserID.Int64 != p.UserID) {
+		// do not reveal keys of other users
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	_, err = s.store.RevokeAPIKey(ctx, id)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("revokeAPIKey error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke api key"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) createOrganization(c *gin.Context) {
+	var req createOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateCreateOrganizationRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := s.store.CreateOrganization(c.Request.Context(), req.Name)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "organization already exists"})
+			return
+		}
+		log.Printf("createOrganization error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+func (s *Server) listOrganizations(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit64, err := strconv.ParseInt(limitStr, 10, 32)
+	if err != nil || limit64 <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset64, err := strconv.ParseInt(offsetStr, 10, 32)
+	if err != nil || offset64 < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	orgs, err := s.store.ListOrganizations(c.Request.Context(), db.ListOrganizationsParams{
+		Limit:  int32(limit64),
+		Offset: int32(offset64),
+	})
+	if err != nil {
+		log.Printf("listOrganizations error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
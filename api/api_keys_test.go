@@ -0,0 +1,418 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestAPIKey returns a new key and its stored row, owned by a goal
+// manager with id 1.
+func newTestAPIKey(t *testing.T, scopes ...string) (string, db.GetAPIKeyByPrefixRow) {
+	t.Helper()
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("failed to generate api key: %v", err)
+	}
+	return key, db.GetAPIKeyByPrefixRow{
+		ID:        5,
+		UserID:    pgtype.Int8{Int64: 1, Valid: true},
+		OwnerRole: pgtype.Text{String: util.GoalManagerRole, Valid: true},
+		Name:      "ci",
+		Prefix:    prefix,
+		KeyHash:   util.HashToken(key),
+		Scopes:    scopes,
+		CreatedBy: 1,
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey failed: %v", err)
+	}
+	if len(prefix) != apiKeyIDLength || !strings.HasPrefix(key, apiKeyPrefix+prefix+apiKeyIDSeparator) {
+		t.Fatalf("key %q does not start with its prefix %q", key, prefix)
+	}
+
+	other, otherPrefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey failed: %v", err)
+	}
+	if other == key || otherPrefix == prefix {
+		t.Fatal("two generated keys are equal")
+	}
+}
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	key, apiKey := newTestAPIKey(t, util.GoalsWriteScope)
+
+	orgKey, orgAPIKey := newTestAPIKey(t, util.DonationsReadScope)
+	orgAPIKey.UserID = pgtype.Int8{}
+	orgAPIKey.OwnerRole = pgtype.Text{}
+	orgAPIKey.OrganizationID = pgtype.Int8{Int64: 3, Valid: true}
+
+	revoked := apiKey
+	revoked.RevokedAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+	expired := apiKey
+	expired.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+
+	// same prefix, different secret
+	forged := key[:len(apiKeyPrefix)+apiKeyIDLength+len(apiKeyIDSeparator)] + strings.Repeat("A", 43)
+
+	testCases := []struct {
+		name          string
+		key           string
+		acceptKeys    bool
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "UserKey",
+			key:        key,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), apiKey.Prefix).Times(1).Return(apiKey, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), apiKey.ID).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, principal{
+					UserID:   1,
+					Role:     util.GoalManagerRole,
+					APIKeyID: apiKey.ID,
+					Scopes:   []string{util.GoalsWriteScope},
+				})
+			},
+		},
+		{
+			name:       "OrganizationKey",
+			key:        orgKey,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), orgAPIKey.Prefix).Times(1).Return(orgAPIKey, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), orgAPIKey.ID).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, principal{
+					OrganizationID: 3,
+					APIKeyID:       orgAPIKey.ID,
+					Scopes:         []string{util.DonationsReadScope},
+				})
+			},
+		},
+		{
+			name:       "TouchError",
+			key:        key,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), apiKey.Prefix).Times(1).Return(apiKey, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), apiKey.ID).Times(1).Return(errors.New("db down"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:       "NotAccepted",
+			key:        key,
+			acceptKeys: false,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name:       "NoSeparator",
+			key:        apiKeyPrefix + apiKey.Prefix + "secret",
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrMalformedToken)
+			},
+		},
+		{
+			name:       "TooShort",
+			key:        apiKeyPrefix + "abcd",
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrMalformedToken)
+			},
+		},
+		{
+			name:       "UnknownPrefix",
+			key:        key,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), apiKey.Prefix).Times(1).Return(db.GetAPIKeyByPrefixRow{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name:       "WrongSecret",
+			key:        forged,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), apiKey.Prefix).Times(1).Return(apiKey, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name:       "Revoked",
+			key:        key,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), apiKey.Prefix).Times(1).Return(revoked, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrRevokedToken)
+			},
+		},
+		{
+			name:       "Expired",
+			key:        key,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), apiKey.Prefix).Times(1).Return(expired, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrExpiredToken)
+			},
+		},
+		{
+			name:       "InternalError",
+			key:        key,
+			acceptKeys: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), apiKey.Prefix).Times(1).Return(db.GetAPIKeyByPrefixRow{}, errors.New("db down"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			router := gin.New()
+			router.GET(authTestPath, authMiddleware(newTestTokenMaker(t), store, tc.acceptKeys), func(c *gin.Context) {
+				c.JSON(http.StatusOK, authPrincipal(c))
+			})
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodGet, authTestPath, nil)
+			request.Header.Set(authorizationHeaderKey, "Bearer "+tc.key)
+			router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	testCases := []struct {
+		name       string
+		principal  principal
+		scope      string
+		wantStatus int
+	}{
+		{
+			name:       "AccessToken",
+			principal:  principal{UserID: 1, Role: util.DonorRole},
+			scope:      util.GoalsWriteScope,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "KeyWithScope",
+			principal:  principal{UserID: 1, Role: util.GoalManagerRole, APIKeyID: 5, Scopes: []string{util.GoalsWriteScope}},
+			scope:      util.GoalsWriteScope,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "KeyWithoutScope",
+			principal:  principal{UserID: 1, Role: util.GoalManagerRole, APIKeyID: 5, Scopes: []string{util.DonationsReadScope}},
+			scope:      util.GoalsWriteScope,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			// the owner was demoted after the key was created
+			name:       "OwnerLostRole",
+			principal:  principal{UserID: 1, Role: util.DonorRole, APIKeyID: 5, Scopes: []string{util.GoalsWriteScope}},
+			scope:      util.GoalsWriteScope,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "OrganizationKeyWithScope",
+			principal:  principal{OrganizationID: 3, APIKeyID: 5, Scopes: []string{util.DonationsWriteScope}},
+			scope:      util.DonationsWriteScope,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "OrganizationKeyWithoutScope",
+			principal:  principal{OrganizationID: 3, APIKeyID: 5, Scopes: []string{util.DonationsReadScope}},
+			scope:      util.DonationsWriteScope,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET(authTestPath, func(c *gin.Context) {
+				c.Set(authorizationPrincipalKey, &tc.principal)
+			}, requireScope(tc.scope), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodGet, authTestPath, nil)
+			router.ServeHTTP(recorder, request)
+			requireStatus(t, recorder.Code, tc.wantStatus, recorder.Body)
+		})
+	}
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	manager := randomUser(t, util.GoalManagerRole, "secret-password")
+	admin := randomUser(t, util.AdminRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: manager,
+			body: gin.H{"name": "ci", "scopes": []string{util.GoalsWriteScope}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Cond(func(arg db.CreateAPIKeyParams) bool {
+						return arg.UserID.Int64 == manager.ID && !arg.OrganizationID.Valid &&
+							len(arg.Prefix) == apiKeyIDLength && arg.KeyHash != ""
+					})).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+						return db.ApiKey{ID: 5, UserID: arg.UserID, Name: arg.Name, Prefix: arg.Prefix, KeyHash: arg.KeyHash, Scopes: arg.Scopes}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+
+				var rsp struct {
+					Key    string         `json:"key"`
+					APIKey apiKeyResponse `json:"api_key"`
+				}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &rsp); err != nil {
+					t.Fatalf("failed to decode response body: %v", err)
+				}
+				if !strings.HasPrefix(rsp.Key, apiKeyPrefix+rsp.APIKey.Prefix+apiKeyIDSeparator) {
+					t.Fatalf("key %q does not match its prefix %q", rsp.Key, rsp.APIKey.Prefix)
+				}
+				if strings.Contains(recorder.Body.String(), "key_hash") {
+					t.Fatal("response exposes the key hash")
+				}
+			},
+		},
+		{
+			name: "ScopeAboveRole",
+			user: manager,
+			body: gin.H{"name": "ci", "scopes": []string{util.DonationsWriteScope}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "UnsupportedScope",
+			user: admin,
+			body: gin.H{"name": "ci", "scopes": []string{"users:write"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "OrganizationKey",
+			user: admin,
+			body: gin.H{"name": "partner", "scopes": []string{util.DonationsWriteScope}, "organization_id": 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Cond(func(arg db.CreateAPIKeyParams) bool {
+						return !arg.UserID.Valid && arg.OrganizationID.Int64 == 3 && arg.CreatedBy == admin.ID
+					})).
+					Times(1).
+					Return(db.ApiKey{ID: 6, OrganizationID: pgtype.Int8{Int64: 3, Valid: true}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "OrganizationKeyNotAdmin",
+			user: manager,
+			body: gin.H{"name": "partner", "scopes": []string{util.DonationsReadScope}, "organization_id": 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, manager, admin)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/api-keys", tc.body)
+			addAuthorization(t, request, server.tokenMaker, tc.user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
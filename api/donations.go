@@ -1,48 +1,342 @@
 package api
 
 import (
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	db "charity/db/sqlc"
+	"charity/payments"
+	"charity/util"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// donationConfirmationTokenBytes is the entropy of the token that lets the
+// creator of a donation without a donor confirm it.
+const donationConfirmationTokenBytes = 32
+
+type createDonationResponse struct {
+	db.DonationTxResult
+	// ConfirmationToken is returned once, for donations without a donor.
+	ConfirmationToken string `json:"confirmation_token,omitempty"`
+}
+
 func (s *Server) createDonation(c *gin.Context) {
 	var req createDonationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
-	if err := validateCreateDonationRequest(req); err != nil {
+	if err := validateCreateDonationRequest(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	params := db.DonationTxParams{
-		UserID: pgtype.Int8{
-			Int64: req.UserID,
-			Valid: true,
-		},
 		GoalID:      req.GoalID,
 		Amount:      req.Amount,
 		Currency:    req.Currency,
 		IsAnonymous: req.IsAnonymous,
 	}
 
+	caller, authenticated := optionalAuthPrincipal(c)
+	if authenticated && caller.isAPIKey() {
+		// integrations record donations on behalf of donors
+		if !caller.hasScope(util.DonationsWriteScope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks the " + util.DonationsWriteScope + " scope"})
+			return
+		}
+		if req.UserID != nil {
+			if _, err := s.store.GetUser(c.Request.Context(), *req.UserID); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+					return
+				}
+				log.Printf("createDonation error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create donation"})
+				return
+			}
+			params.UserID = pgtype.Int8{Int64: *req.UserID, Valid: true}
+		} else {
+			params.IsAnonymous = true
+		}
+	} else if authenticated {
+		donor, ok := s.currentUser(c)
+		if !ok {
+			return
+		}
+		if req.UserID != nil && *req.UserID != donor.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot donate on behalf of another user"})
+			return
+		}
+		// unverified accounts may only make small donations
+		if !donor.IsEmailVerified && req.Amount > s.config.UnverifiedDonationLimit {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("verify your email to donate more than %d", s.config.UnverifiedDonationLimit),
+			})
+			return
+		}
+		params.UserID = pgtype.Int8{Int64: donor.ID, Valid: true}
+	} else {
+		// without a token the donation cannot be attributed to anyone
+		if req.UserID != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot donate on behalf of another user"})
+			return
+		}
+		params.IsAnonymous = true
+	}
+
+	if _, err := s.store.GetGoal(c.Request.Context(), req.GoalID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+			return
+		}
+		log.Printf("createDonation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create donation"})
+		return
+	}
+
+	// a retried request must not donate twice, so its response is stored
+	// together with the donation
+	idempotent, ok := idempotentRequestFromContext(c)
+	if ok {
+		params.IdempotencyKey = idempotent.completion(http.StatusOK)
+	}
+
+	// the goal is only credited once the payment has been collected
+	intentParams := payments.CreateIntentParams{
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Metadata: map[string]string{"goal_id": strconv.FormatInt(req.GoalID, 10)},
+	}
+	if ok {
+		intentParams.IdempotencyKey = idempotent.key.Scope + ":" + idempotent.key.Key
+	}
+	intent, err := s.payments.CreateIntent(c.Request.Context(), intentParams)
+	if err != nil {
+		log.Printf("createDonation error: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to start payment"})
+		return
+	}
+	params.Provider = s.payments.Name()
+	params.PaymentIntentID = intent.ID
+	params.ClientSecret = intent.ClientSecret
+
+	// nobody can be authenticated as the donor of a donation without one,
+	// so it is confirmed with a token handed to its creator instead
+	var confirmationToken string
+	if !params.UserID.Valid {
+		confirmationToken, err = util.RandomToken(donationConfirmationTokenBytes)
+		if err != nil {
+			log.Printf("createDonation error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create donation"})
+			return
+		}
+		params.ConfirmationTokenHash = pgtype.Text{String: util.HashToken(confirmationToken), Valid: true}
+	}
+
 	result, err := s.store.DonationTx(c.Request.Context(), params)
 	if err != nil {
+		if errors.Is(err, db.ErrIdempotencyKeyLost) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+			return
+		}
 		log.Printf("createDonation error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create donation"})
 		return
 	}
+	if ok {
+		idempotent.completed = true
+	}
+
+	// the provider's webhook may have overtaken the donation
+	s.applyDeferredPaymentEvents(c.Request.Context(), params.Provider, pgtype.Text{String: params.PaymentIntentID, Valid: true})
+
+	c.JSON(http.StatusOK, createDonationResponse{DonationTxResult: result, ConfirmationToken: confirmationToken})
+}
+
+// publicDonationResponse is a donation as shown to anyone. It leaves out
+// the payment details and the donor of anonymous donations.
+type publicDonationResponse struct {
+	ID          int64      `json:"id"`
+	UserID      *int64     `json:"user_id,omitempty"`
+	GoalID      int64      `json:"goal_id"`
+	Amount      int64      `json:"amount"`
+	Currency    string     `json:"currency"`
+	IsAnonymous bool       `json:"is_anonymous"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+func newPublicDonationResponse(donation db.Donation) publicDonationResponse {
+	rsp := publicDonationResponse{
+		ID:          donation.ID,
+		GoalID:      donation.GoalID,
+		Amount:      donation.Amount,
+		Currency:    donation.Currency,
+		IsAnonymous: donation.IsAnonymous,
+		Status:      donation.Status,
+		CreatedAt:   donation.CreatedAt,
+	}
+	if donation.UserID.Valid && !donation.IsAnonymous {
+		userID := donation.UserID.Int64
+		rsp.UserID = &userID
+	}
+	if donation.ConfirmedAt.Valid {
+		confirmedAt := donation.ConfirmedAt.Time
+		rsp.ConfirmedAt = &confirmedAt
+	}
+	return rsp
+}
+
+type confirmDonationResponse struct {
+	Donation      db.Donation           `json:"donation"`
+	PaymentStatus payments.IntentStatus `json:"payment_status"`
+}
+
+// confirmDonation pays for a pending donation with a payment method, such
+// as a card tokenized by the provider in the donor's browser. Donations
+// without a donor are confirmed with the token returned when they were
+// created.
+func (s *Server) confirmDonation(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid donation id"})
+		return
+	}
 
-	c.JSON(http.StatusOK, result)
+	var req confirmDonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateConfirmDonationRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if caller, ok := optionalAuthPrincipal(c); ok && caller.isAPIKey() && !caller.hasScope(util.DonationsWriteScope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks the " + util.DonationsWriteScope + " scope"})
+		return
+	}
+
+	donation, err := s.store.GetDonation(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "donation not found"})
+			return
+		}
+		log.Printf("confirmDonation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm donation"})
+		return
+	}
+
+	// do not reveal other donors' donations
+	if !s.canConfirmDonation(c, donation, req.ConfirmationToken) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "donation not found"})
+		return
+	}
+
+	if donation.Status != db.DonationPending && donation.Status != db.DonationFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "donation is already " + donation.Status})
+		return
+	}
+	if !donation.PaymentIntentID.Valid || donation.Provider != s.payments.Name() {
+		c.JSON(http.StatusConflict, gin.H{"error": "donation cannot be paid through " + s.payments.Name()})
+		return
+	}
+
+	intent, err := s.payments.ConfirmIntent(c.Request.Context(), donation.PaymentIntentID.String, req.PaymentMethod)
+	if err != nil {
+		if payments.IsDeclined(err) {
+			failed, ok := s.failDonation(c, donation, err.Error())
+			if !ok {
+				return
+			}
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "payment was declined", "donation": failed})
+			return
+		}
+		log.Printf("confirmDonation error: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to confirm payment"})
+		return
+	}
+
+	// collect payments that were only authorized
+	if intent.Status == payments.IntentRequiresCapture {
+		intent, err = s.payments.CaptureIntent(c.Request.Context(), intent.ID)
+		if err != nil {
+			log.Printf("confirmDonation error: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to capture payment"})
+			return
+		}
+	}
+
+	switch intent.Status {
+	case payments.IntentSucceeded:
+		result, err := s.store.ConfirmDonationTx(c.Request.Context(), db.ConfirmDonationTxParams{
+			DonationID: donation.ID,
+			Amount:     intent.Amount,
+			Currency:   intent.Currency,
+		})
+		if err != nil {
+			log.Printf("confirmDonation error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm donation"})
+			return
+		}
+		c.JSON(http.StatusOK, confirmDonationResponse{Donation: result.Donation, PaymentStatus: intent.Status})
+	case payments.IntentCanceled:
+		failed, ok := s.failDonation(c, donation, "payment was canceled")
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, confirmDonationResponse{Donation: failed, PaymentStatus: intent.Status})
+	default:
+		// e.g. the donor has to authenticate the payment with their bank;
+		// the outcome is reported by the provider later
+		c.JSON(http.StatusAccepted, confirmDonationResponse{Donation: donation, PaymentStatus: intent.Status})
+	}
+}
+
+// canConfirmDonation reports whether the caller may pay for a donation:
+// its donor, an admin or organization key, or anyone holding the token
+// returned when a donation without a donor was created.
+func (s *Server) canConfirmDonation(c *gin.Context, donation db.Donation, confirmationToken string) bool {
+	if p, ok := optionalAuthPrincipal(c); ok {
+		if p.Role == util.AdminRole || p.OrganizationID != 0 ||
+			(donation.UserID.Valid && donation.UserID.Int64 == p.UserID) {
+			return true
+		}
+	}
+	return confirmationToken != "" && donation.ConfirmationTokenHash.Valid &&
+		subtle.ConstantTimeCompare([]byte(util.HashToken(confirmationToken)), []byte(donation.ConfirmationTokenHash.String)) == 1
+}
+
+// failDonation records why the payment of a pending donation failed. On
+// error it writes the response and returns false.
+func (s *Server) failDonation(c *gin.Context, donation db.Donation, reason string) (db.Donation, bool) {
+	failed, err := s.store.FailDonation(c.Request.Context(), db.FailDonationParams{
+		ID:            donation.ID,
+		FailureReason: pgtype.Text{String: reason, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// already failed, or settled concurrently
+			return donation, true
+		}
+		log.Printf("confirmDonation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm donation"})
+		return db.Donation{}, false
+	}
+	return failed, true
 }
 
 func (s *Server) getDonation(c *gin.Context) {
@@ -64,7 +358,7 @@ func (s *Server) getDonation(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, donation)
+	c.JSON(http.StatusOK, newPublicDonationResponse(donation))
 }
 
 func (s *Server) listDonationsByGoal(c *gin.Context) {
@@ -99,7 +393,11 @@ func (s *Server) listDonationsByGoal(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, donations)
+	rsp := make([]publicDonationResponse, 0, len(donations))
+	for _, donation := range donations {
+		rsp = append(rsp, newPublicDonationResponse(donation))
+	}
+	c.JSON(http.StatusOK, rsp)
 }
 
 func (s *Server) listDonationsByUser(c *gin.Context) {
@@ -123,6 +421,13 @@ func (s *Server) listDonationsByUser(c *gin.Context) {
 		return
 	}
 
+	// donors may only see their own donation history
+	caller := authPrincipal(c)
+	if caller.Role != util.AdminRole && caller.OrganizationID == 0 && caller.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
 	donations, err := s.store.ListDonationsByUser(c.Request.Context(), db.ListDonationsByUserParams{
 		UserID: pgtype.Int8{Int64: userID, Valid: true},
 		Limit:  int32(limit64),
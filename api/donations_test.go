@@ -0,0 +1,968 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/payments"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+// addAPIKeyAuthorization adds a freshly generated API key to request and
+// returns the row the store should return for it.
+func addAPIKeyAuthorization(t *testing.T, request *http.Request, owner pgtype.Int8, organization pgtype.Int8, role string, scopes ...string) db.GetAPIKeyByPrefixRow {
+	t.Helper()
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("failed to generate api key: %v", err)
+	}
+	request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+key)
+
+	return db.GetAPIKeyByPrefixRow{
+		ID:             1,
+		UserID:         owner,
+		OrganizationID: organization,
+		Name:           "reconciliation",
+		Prefix:         prefix,
+		KeyHash:        util.HashToken(key),
+		Scopes:         scopes,
+		CreatedAt:      time.Now(),
+		OwnerRole:      pgtype.Text{String: role, Valid: role != ""},
+	}
+}
+
+func TestCreateDonation(t *testing.T) {
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	unverified := randomUser(t, util.DonorRole, "secret-password")
+	unverified.IsEmailVerified = false
+	goal := randomGoal()
+	donation := randomDonation(goal.ID, donor.ID)
+	anonymous := randomDonation(goal.ID, 0)
+	anonymous.IsAnonymous = true
+
+	orgKey := pgtype.Int8{Int64: 7, Valid: true}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Donor",
+			body: gin.H{"goal_id": goal.ID, "amount": donation.Amount, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					DonationTx(gomock.Any(), db.DonationTxParams{
+						UserID:          pgtype.Int8{Int64: donor.ID, Valid: true},
+						GoalID:          goal.ID,
+						Amount:          donation.Amount,
+						Currency:        "USD",
+						Provider:        "fake",
+						PaymentIntentID: "pi_fake_1",
+						ClientSecret:    "pi_fake_1_secret",
+					}).
+					Times(1).
+					Return(db.DonationTxResult{Donation: donation, ClientSecret: "pi_fake_1_secret"}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, db.DonationTxResult{Donation: donation, ClientSecret: "pi_fake_1_secret"})
+			},
+		},
+		{
+			// the webhook reporting the payment arrived before the donation
+			name: "AppliesDeferredEvents",
+			body: gin.H{"goal_id": goal.ID, "amount": donation.Amount, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				intentID := pgtype.Text{String: "pi_fake_1", Valid: true}
+				deferred := db.PaymentEvent{
+					ID:              9,
+					Provider:        "fake",
+					EventID:         "evt_1",
+					EventType:       string(payments.EventIntentSucceeded),
+					PaymentIntentID: intentID,
+					Payload:         []byte(`{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_fake_1","amount":500,"currency":"usd","status":"succeeded"}}}`),
+					Status:          db.PaymentEventDeferred,
+				}
+				processed := deferred
+				processed.Status = db.PaymentEventProcessed
+
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().
+					ListDeferredPaymentEvents(gomock.Any(), db.ListDeferredPaymentEventsParams{
+						Provider:        "fake",
+						PaymentIntentID: intentID,
+					}).
+					Times(1).
+					Return([]db.PaymentEvent{deferred}, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), db.ApplyPaymentEventTxParams{
+						EventID:        deferred.ID,
+						DonationStatus: db.DonationSucceeded,
+						Amount:         500,
+						Currency:       "USD",
+					}).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{Event: processed}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "Anonymous",
+			body: gin.H{"goal_id": goal.ID, "amount": anonymous.Amount, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					DonationTx(gomock.Any(), gomock.Cond(func(arg db.DonationTxParams) bool {
+						return !arg.UserID.Valid && arg.GoalID == goal.ID && arg.Amount == anonymous.Amount &&
+							arg.IsAnonymous && arg.PaymentIntentID == "pi_fake_1" && arg.ConfirmationTokenHash.Valid
+					})).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.DonationTxParams) (db.DonationTxResult, error) {
+						donation := anonymous
+						donation.ConfirmationTokenHash = arg.ConfirmationTokenHash
+						return db.DonationTxResult{Donation: donation}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				var rsp createDonationResponse
+				if err := json.Unmarshal(recorder.Body.Bytes(), &rsp); err != nil {
+					t.Fatalf("failed to decode response body: %v", err)
+				}
+				if rsp.ConfirmationToken == "" {
+					t.Fatalf("expected a confirmation token: %s", recorder.Body)
+				}
+				if strings.Contains(recorder.Body.String(), util.HashToken(rsp.ConfirmationToken)) {
+					t.Fatalf("response exposes the confirmation token hash: %s", recorder.Body)
+				}
+			},
+		},
+		{
+			name: "AnonymousClaimsUser",
+			body: gin.H{"user_id": donor.ID, "goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "DonorClaimsOtherUser",
+			body: gin.H{"user_id": donor.ID + 1, "goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "UnverifiedAboveLimit",
+			body: gin.H{"goal_id": goal.ID, "amount": 20000, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, unverified)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), unverified.ID).Times(1).Return(unverified, nil)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "DeletedDonor",
+			body: gin.H{"goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "APIKeyOnBehalfOfDonor",
+			body: gin.H{"user_id": donor.ID, "goal_id": goal.ID, "amount": donation.Amount, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				row := addAPIKeyAuthorization(t, request, pgtype.Int8{}, orgKey, "", util.DonationsWriteScope)
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), row.Prefix).Times(1).Return(row, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), row.ID).Times(1).Return(nil)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					DonationTx(gomock.Any(), db.DonationTxParams{
+						UserID:          pgtype.Int8{Int64: donor.ID, Valid: true},
+						GoalID:          goal.ID,
+						Amount:          donation.Amount,
+						Currency:        "USD",
+						Provider:        "fake",
+						PaymentIntentID: "pi_fake_1",
+						ClientSecret:    "pi_fake_1_secret",
+					}).
+					Times(1).
+					Return(db.DonationTxResult{Donation: donation}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "APIKeyWithoutScope",
+			body: gin.H{"goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				row := addAPIKeyAuthorization(t, request, pgtype.Int8{}, orgKey, "", util.DonationsReadScope)
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), row.Prefix).Times(1).Return(row, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), row.ID).Times(1).Return(nil)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "APIKeyUnknownDonor",
+			body: gin.H{"user_id": donor.ID, "goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				row := addAPIKeyAuthorization(t, request, pgtype.Int8{}, orgKey, "", util.DonationsWriteScope)
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), row.Prefix).Times(1).Return(row, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), row.ID).Times(1).Return(nil)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name: "RevokedAPIKey",
+			body: gin.H{"goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				row := addAPIKeyAuthorization(t, request, pgtype.Int8{}, orgKey, "", util.DonationsWriteScope)
+				row.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), row.Prefix).Times(1).Return(row, nil)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "AmountTooSmall",
+			body: gin.H{"goal_id": goal.ID, "amount": minDonationAmount - 1, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "MissingCurrency",
+			body: gin.H{"goal_id": goal.ID, "amount": 500},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InvalidCurrency",
+			body: gin.H{"goal_id": goal.ID, "amount": 500, "currency": "US$"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					DonationTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DonationTxResult{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name: "GoalNotFound",
+			body: gin.H{"goal_id": goal.ID, "amount": 500, "currency": "USD"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(db.Goal{}, pgx.ErrNoRows)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, donor, unverified)
+			store.EXPECT().ListDeferredPaymentEvents(gomock.Any(), gomock.Any()).AnyTimes()
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPost, "/donations", tc.body)
+			tc.setupAuth(t, request, server.tokenMaker, store)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetDonation(t *testing.T) {
+	donation := randomDonation(1, 2)
+	anonymous := randomDonation(1, 2)
+	anonymous.IsAnonymous = true
+	anonymous.Provider = "fake"
+	anonymous.PaymentIntentID = pgtype.Text{String: "pi_1", Valid: true}
+	anonymous.FailureReason = pgtype.Text{String: "Your card was declined.", Valid: true}
+
+	testCases := []struct {
+		name          string
+		donationID    any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			donationID: donation.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newPublicDonationResponse(donation))
+			},
+		},
+		{
+			name:       "AnonymousHidesDonorAndPayment",
+			donationID: anonymous.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDonation(gomock.Any(), anonymous.ID).Times(1).Return(anonymous, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				var body map[string]any
+				if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+					t.Fatalf("failed to decode response body: %v", err)
+				}
+				for _, field := range []string{"user_id", "payment_intent_id", "failure_reason", "provider", "subscription_id"} {
+					if _, ok := body[field]; ok {
+						t.Fatalf("response exposes %s: %s", field, recorder.Body)
+					}
+				}
+			},
+		},
+		{
+			name:       "NotFound",
+			donationID: donation.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(db.Donation{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:       "InternalError",
+			donationID: donation.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(db.Donation{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name:       "InvalidID",
+			donationID: -1,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDonation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, fmt.Sprintf("/donations/%v", tc.donationID), nil)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListDonationsByGoal(t *testing.T) {
+	goal := randomGoal()
+	donations := []db.Donation{randomDonation(goal.ID, 1), randomDonation(goal.ID, 0)}
+
+	testCases := []struct {
+		name          string
+		goalID        any
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			goalID: goal.ID,
+			query:  "limit=2&offset=4",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListDonationsByGoal(gomock.Any(), db.ListDonationsByGoalParams{GoalID: goal.ID, Limit: 2, Offset: 4}).
+					Times(1).
+					Return(donations, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, []publicDonationResponse{
+					newPublicDonationResponse(donations[0]),
+					newPublicDonationResponse(donations[1]),
+				})
+			},
+		},
+		{
+			name:   "InvalidGoalID",
+			goalID: "abc",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidLimit",
+			goalID: goal.ID,
+			query:  "limit=abc",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidOffset",
+			goalID: goal.ID,
+			query:  "offset=-3",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			goalID: goal.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListDonationsByGoal(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, fmt.Sprintf("/donations/by_goal/%v?%s", tc.goalID, tc.query), nil)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListDonationsByUser(t *testing.T) {
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	admin.ID = donor.ID + 1
+	donations := []db.Donation{randomDonation(1, donor.ID), randomDonation(2, donor.ID)}
+
+	testCases := []struct {
+		name          string
+		userID        any
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OwnHistory",
+			userID: donor.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListDonationsByUser(gomock.Any(), db.ListDonationsByUserParams{
+						UserID: pgtype.Int8{Int64: donor.ID, Valid: true},
+						Limit:  20,
+						Offset: 0,
+					}).
+					Times(1).
+					Return(donations, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, donations)
+			},
+		},
+		{
+			name:   "Admin",
+			userID: donor.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByUser(gomock.Any(), gomock.Any()).Times(1).Return(donations, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "OtherDonor",
+			userID: donor.ID + 1,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name:   "UserAPIKey",
+			userID: donor.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				owner := pgtype.Int8{Int64: donor.ID, Valid: true}
+				row := addAPIKeyAuthorization(t, request, owner, pgtype.Int8{}, donor.Role, util.DonationsReadScope)
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), row.Prefix).Times(1).Return(row, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), row.ID).Times(1).Return(nil)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByUser(gomock.Any(), gomock.Any()).Times(1).Return(donations, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "NoAuthorization",
+			userID: donor.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidUserID",
+			userID: 0,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListDonationsByUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			userID: donor.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, store *mockdb.MockStore) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListDonationsByUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, donor, admin)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, fmt.Sprintf("/donations/by_user/%v", tc.userID), nil)
+			tc.setupAuth(t, request, server.tokenMaker, store)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestConfirmDonation(t *testing.T) {
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	other := randomUser(t, util.DonorRole, "secret-password")
+	other.ID = donor.ID + 1
+	goal := randomGoal()
+
+	const confirmationToken = "confirmation-token"
+	anonymous := func(donation *db.Donation) {
+		donation.UserID = pgtype.Int8{}
+		donation.IsAnonymous = true
+		donation.ConfirmationTokenHash = pgtype.Text{String: util.HashToken(confirmationToken), Valid: true}
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		user          *db.User
+		status        string
+		setup         func(donation *db.Donation)
+		buildStubs    func(store *mockdb.MockStore, donation db.Donation)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation)
+	}{
+		{
+			name:   "OK",
+			body:   gin.H{"payment_method": payments.FakeCardSucceeds},
+			user:   &donor,
+			status: db.DonationPending,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				succeeded := donation
+				succeeded.Status = db.DonationSucceeded
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().
+					ConfirmDonationTx(gomock.Any(), db.ConfirmDonationTxParams{
+						DonationID: donation.ID,
+						Amount:     donation.Amount,
+						Currency:   donation.Currency,
+					}).
+					Times(1).
+					Return(db.ConfirmDonationTxResult{Donation: succeeded, Credited: true}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				donation.Status = db.DonationSucceeded
+				requireBodyMatch(t, recorder.Body, confirmDonationResponse{Donation: donation, PaymentStatus: payments.IntentSucceeded})
+			},
+		},
+		{
+			name:   "Declined",
+			body:   gin.H{"payment_method": payments.FakeCardDeclined},
+			user:   &donor,
+			status: db.DonationPending,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				failed := donation
+				failed.Status = db.DonationFailed
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().
+					FailDonation(gomock.Any(), gomock.Cond(func(arg db.FailDonationParams) bool {
+						return arg.ID == donation.ID && arg.FailureReason.Valid
+					})).
+					Times(1).
+					Return(failed, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusPaymentRequired, recorder.Body)
+			},
+		},
+		{
+			name:   "RequiresAction",
+			body:   gin.H{"payment_method": payments.FakeCardAuthenticationRequired},
+			user:   &donor,
+			status: db.DonationPending,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusAccepted, recorder.Body)
+				requireBodyMatch(t, recorder.Body, confirmDonationResponse{Donation: donation, PaymentStatus: payments.IntentRequiresAction})
+			},
+		},
+		{
+			name:   "OtherDonor",
+			body:   gin.H{"payment_method": payments.FakeCardSucceeds},
+			user:   &other,
+			status: db.DonationPending,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "AlreadySucceeded",
+			body:   gin.H{"payment_method": payments.FakeCardSucceeds},
+			user:   &donor,
+			status: db.DonationSucceeded,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+			},
+		},
+		{
+			name:   "AnonymousWithToken",
+			body:   gin.H{"payment_method": payments.FakeCardSucceeds, "confirmation_token": confirmationToken},
+			status: db.DonationPending,
+			setup:  anonymous,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().
+					ConfirmDonationTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ConfirmDonationTxResult{Donation: donation, Credited: true}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "AnonymousWrongToken",
+			body:   gin.H{"payment_method": payments.FakeCardSucceeds, "confirmation_token": "guessed"},
+			status: db.DonationPending,
+			setup:  anonymous,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "NoAuthNoToken",
+			body:   gin.H{"payment_method": payments.FakeCardSucceeds},
+			status: db.DonationPending,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "MissingPaymentMethod",
+			body:   gin.H{},
+			user:   &donor,
+			status: db.DonationPending,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, donation db.Donation) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			intent, err := server.payments.CreateIntent(context.Background(), payments.CreateIntentParams{
+				Amount:   500,
+				Currency: "USD",
+			})
+			if err != nil {
+				t.Fatalf("failed to create payment intent: %v", err)
+			}
+			donation := randomDonation(goal.ID, donor.ID)
+			donation.Status = tc.status
+			donation.Provider = server.payments.Name()
+			donation.PaymentIntentID = pgtype.Text{String: intent.ID, Valid: true}
+			if tc.setup != nil {
+				tc.setup(&donation)
+			}
+			tc.buildStubs(store, donation)
+			stubUserRoles(store, donor, other)
+
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/donations/%d/confirm", donation.ID)
+			request := newJSONRequest(t, http.MethodPost, url, tc.body)
+			if tc.user != nil {
+				addAuthorization(t, request, server.tokenMaker, *tc.user)
+			}
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, donation)
+		})
+	}
+}
+
+// TestConfirmLowerCaseCurrencyDonation creates a donation in a lower-case
+// currency and pays for it through the fake provider, which reports the
+// currency back upper-case.
+func TestConfirmLowerCaseCurrencyDonation(t *testing.T) {
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	goal := randomGoal()
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	var donation db.Donation
+	store.EXPECT().GetUser(gomock.Any(), donor.ID).AnyTimes().Return(donor, nil)
+	store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+	store.EXPECT().ListDeferredPaymentEvents(gomock.Any(), gomock.Any()).AnyTimes()
+	stubUserRoles(store, donor)
+	store.EXPECT().
+		DonationTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.DonationTxParams) (db.DonationTxResult, error) {
+			donation = db.Donation{
+				ID:              1,
+				UserID:          arg.UserID,
+				GoalID:          arg.GoalID,
+				Amount:          arg.Amount,
+				Currency:        arg.Currency,
+				Status:          db.DonationPending,
+				Provider:        arg.Provider,
+				PaymentIntentID: pgtype.Text{String: arg.PaymentIntentID, Valid: true},
+			}
+			return db.DonationTxResult{Donation: donation, ClientSecret: arg.ClientSecret}, nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request := newJSONRequest(t, http.MethodPost, "/donations", gin.H{"goal_id": goal.ID, "amount": 500, "currency": "usd"})
+	addAuthorization(t, request, server.tokenMaker, donor)
+	server.router.ServeHTTP(recorder, request)
+	requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+	if donation.Currency != "USD" {
+		t.Fatalf("currency was stored as %q, want USD", donation.Currency)
+	}
+
+	store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).DoAndReturn(func(context.Context, int64) (db.Donation, error) {
+		return donation, nil
+	})
+	store.EXPECT().
+		ConfirmDonationTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.ConfirmDonationTxParams) (db.ConfirmDonationTxResult, error) {
+			// as checked by the store before crediting the goal
+			if arg.Amount != donation.Amount || arg.Currency != donation.Currency {
+				return db.ConfirmDonationTxResult{}, db.ErrPaymentMismatch
+			}
+			succeeded := donation
+			succeeded.Status = db.DonationSucceeded
+			return db.ConfirmDonationTxResult{Donation: succeeded, Credited: true}, nil
+		})
+
+	recorder = httptest.NewRecorder()
+	request = newJSONRequest(t, http.MethodPost, fmt.Sprintf("/donations/%d/confirm", donation.ID), gin.H{"payment_method": payments.FakeCardSucceeds})
+	addAuthorization(t, request, server.tokenMaker, donor)
+	server.router.ServeHTTP(recorder, request)
+	requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+}
@@ -0,0 +1,410 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListGoals(t *testing.T) {
+	goals := []db.Goal{randomGoal(), randomGoal()}
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "limit=5&offset=10",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListGoals(gomock.Any(), db.ListGoalsParams{Limit: 5, Offset: 10}).
+					Times(1).
+					Return(goals, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, goals)
+			},
+		},
+		{
+			name:  "Active",
+			query: "active=true",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListActiveGoals(gomock.Any(), db.ListActiveGoalsParams{Limit: 20, Offset: 0}).
+					Times(1).
+					Return(goals, nil)
+				store.EXPECT().ListGoals(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, goals)
+			},
+		},
+		{
+			name:  "InvalidLimit",
+			query: "limit=0",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListGoals(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:  "InvalidOffset",
+			query: "offset=-1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListGoals(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListGoals(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, "/goals?"+tc.query, nil)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetGoal(t *testing.T) {
+	goal := randomGoal()
+
+	testCases := []struct {
+		name          string
+		goalID        any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			goalID: goal.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, goal)
+			},
+		},
+		{
+			name:   "NotFound",
+			goalID: goal.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(db.Goal{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			goalID: goal.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(db.Goal{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidID",
+			goalID: "abc",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, fmt.Sprintf("/goals/%v", tc.goalID), nil)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateGoal(t *testing.T) {
+	manager := randomUser(t, util.GoalManagerRole, "secret-password")
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	goal := randomGoal()
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"title":         goal.Title,
+				"description":   goal.Description.String,
+				"target_amount": goal.TargetAmount.Int64,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, manager)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateGoal(gomock.Any(), db.CreateGoalParams{
+						Title:        goal.Title,
+						Description:  goal.Description,
+						TargetAmount: goal.TargetAmount,
+					}).
+					Times(1).
+					Return(goal, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, goal)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: gin.H{"title": goal.Title, "target_amount": 100},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "Donor",
+			body: gin.H{"title": goal.Title, "target_amount": 100},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, donor)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "MissingTitle",
+			body: gin.H{"target_amount": 100},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, manager)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InvalidTargetAmount",
+			body: gin.H{"title": goal.Title, "target_amount": 0},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, manager)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"title": goal.Title, "target_amount": 100},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, manager)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateGoal(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Goal{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, manager, donor)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPost, "/goals", tc.body)
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateGoal(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	goal := randomGoal()
+
+	testCases := []struct {
+		name          string
+		goalID        any
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			goalID: goal.ID,
+			body:   gin.H{"title": "New title", "is_active": false},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateGoal(gomock.Any(), db.UpdateGoalParams{
+						ID:       goal.ID,
+						Title:    pgtype.Text{String: "New title", Valid: true},
+						IsActive: pgtype.Bool{Bool: false, Valid: true},
+					}).
+					Times(1).
+					Return(goal, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, goal)
+			},
+		},
+		{
+			name:   "NotFound",
+			goalID: goal.ID,
+			body:   gin.H{"title": "New title"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateGoal(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Goal{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			goalID: goal.ID,
+			body:   gin.H{"title": "New title"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateGoal(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Goal{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidID",
+			goalID: 0,
+			body:   gin.H{"title": "New title"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "NoFields",
+			goalID: goal.ID,
+			body:   gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidTargetAmount",
+			goalID: goal.ID,
+			body:   gin.H{"target_amount": -5},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateGoal(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, admin)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPatch, fmt.Sprintf("/goals/%v", tc.goalID), tc.body)
+			addAuthorization(t, request, server.tokenMaker, admin)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
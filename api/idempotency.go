@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	db "charity/db/sqlc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotentRequestKey      = "idempotent_request"
+
+	maxIdempotencyKeyLength = 255
+	// minAnonymousIdempotencyKeyLength keeps the keys of anonymous callers,
+	// which share a scope, long enough that they cannot be guessed, e.g. a
+	// UUID.
+	minAnonymousIdempotencyKeyLength = 32
+	anonymousIdempotencyScope        = "anonymous"
+	// idempotencyLockTimeout is how long a key stays reserved by a request
+	// that never finished, e.g. because the server crashed while handling it.
+	idempotencyLockTimeout = time.Minute
+)
+
+// idempotentRequest is the reservation held by a request sent with an
+// Idempotency-Key header.
+type idempotentRequest struct {
+	key db.IdempotencyKey
+	ttl time.Duration
+	// completed is set by handlers that stored their response in the same
+	// transaction as their changes.
+	completed bool
+}
+
+// completion returns the parameters that store a response with the given
+// status for the reserved key. The handler fills in the body.
+func (r *idempotentRequest) completion(status int) db.CompleteIdempotencyKeyParams {
+	return db.CompleteIdempotencyKeyParams{
+		ID:             r.key.ID,
+		LockID:         r.key.LockID,
+		ResponseStatus: pgtype.Int4{Int32: int32(status), Valid: true},
+		ExpiresAt:      time.Now().Add(r.ttl),
+	}
+}
+
+// idempotencyMiddleware makes a mutating endpoint safe to retry. The first
+// request with a given Idempotency-Key reserves it and its response is
+// stored; later requests with the same key and body get that response
+// replayed, while a different body is rejected with 422. Requests without
+// the header are passed through. Keys are scoped to the caller, so it must
+// be registered after any auth middleware; anonymous callers share a scope
+// and must send keys too long to guess. Stored responses never carry
+// secrets, so a replay reveals nothing the caller could not look up.
+func idempotencyMiddleware(store db.Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			return
+		}
+		scope, ok := idempotencyScope(c)
+		if !ok {
+			if len(key) < minAnonymousIdempotencyKeyLength {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("idempotency keys of unauthenticated requests must be at least %d characters", minAnonymousIdempotencyKeyLength),
+				})
+				return
+			}
+			scope = anonymousIdempotencyScope
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := requestFingerprint(c.Request, body)
+
+		record, err := store.ReserveIdempotencyKey(c.Request.Context(), db.ReserveIdempotencyKeyParams{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: requestHash,
+			LockID:      pgtype.UUID{Bytes: uuid.New(), Valid: true},
+			ExpiresAt:   time.Now().Add(idempotencyLockTimeout),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			existing, err := store.GetIdempotencyKey(c.Request.Context(), db.GetIdempotencyKeyParams{
+				Scope: scope,
+				Key:   key,
+			})
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					// released by a failed request in the meantime
+					c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+					return
+				}
+				log.Printf("idempotencyMiddleware error: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+				return
+			}
+			replayIdempotentResponse(c, existing, requestHash)
+			return
+		}
+		if err != nil {
+			log.Printf("idempotencyMiddleware error: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+			return
+		}
+
+		req := &idempotentRequest{key: record, ttl: ttl}
+		c.Set(idempotentRequestKey, req)
+		writer := &responseCapture{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		if req.completed {
+			return
+		}
+
+		// the client may have gone away; the key must be settled regardless
+		ctx := context.WithoutCancel(c.Request.Context())
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			// let the client retry a request that failed on our side
+			if err := store.ReleaseIdempotencyKey(ctx, db.ReleaseIdempotencyKeyParams{
+				ID:     record.ID,
+				LockID: record.LockID,
+			}); err != nil {
+				log.Printf("idempotencyMiddleware error: %v", err)
+			}
+			return
+		}
+
+		arg := req.completion(status)
+		arg.ResponseBody = writer.body.Bytes()
+		if _, err := store.CompleteIdempotencyKey(ctx, arg); err != nil {
+			log.Printf("idempotencyMiddleware error: %v", err)
+		}
+	}
+}
+
+// replayIdempotentResponse answers a request whose idempotency key is
+// already taken by an earlier request.
+func replayIdempotentResponse(c *gin.Context, record db.IdempotencyKey, requestHash string) {
+	if record.RequestHash != requestHash {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was already used with a different request"})
+		return
+	}
+	if !record.ResponseStatus.Valid {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+		return
+	}
+
+	c.Header(idempotencyReplayedHeader, "true")
+	c.Data(int(record.ResponseStatus.Int32), "application/json; charset=utf-8", record.ResponseBody)
+	c.Abort()
+}
+
+// idempotencyScope names the caller an idempotency key belongs to, so that
+// clients cannot observe each other's responses. It returns false for
+// anonymous callers.
+func idempotencyScope(c *gin.Context) (string, bool) {
+	p, ok := optionalAuthPrincipal(c)
+	switch {
+	case !ok:
+		return "", false
+	case p.isAPIKey():
+		return fmt.Sprintf("api_key:%d", p.APIKeyID), true
+	default:
+		return fmt.Sprintf("user:%d", p.UserID), true
+	}
+}
+
+// requestFingerprint hashes the parts of a request that must match for a
+// replay to be allowed.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotentRequestFromContext returns the reservation held by the request,
+// if it was sent with an Idempotency-Key header.
+func idempotentRequestFromContext(c *gin.Context) (*idempotentRequest, bool) {
+	value, ok := c.Get(idempotentRequestKey)
+	if !ok {
+		return nil, false
+	}
+	return value.(*idempotentRequest), true
+}
+
+// responseCapture keeps a copy of the response body so it can be stored for
+// replay.
+type responseCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCapture) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// pruneIdempotencyKeys deletes expired idempotency keys every interval until
+// ctx is done. Requests do not depend on it, since an expired key is taken
+// over when it is reserved again; it only keeps the table small.
+func pruneIdempotencyKeys(ctx context.Context, store db.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := store.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+			log.Printf("idempotency key prune error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
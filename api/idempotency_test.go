@@ -0,0 +1,388 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateDonationIdempotency(t *testing.T) {
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	goal := randomGoal()
+	donation := randomDonation(goal.ID, donor.ID)
+	body := gin.H{"goal_id": goal.ID, "amount": donation.Amount, "currency": "USD"}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to marshal request body: %v", err)
+	}
+	requestHash := requestFingerprint(httptest.NewRequest(http.MethodPost, "/donations", nil), data)
+
+	reserved := db.IdempotencyKey{
+		ID:          42,
+		Scope:       fmt.Sprintf("user:%d", donor.ID),
+		Key:         "retry-1",
+		RequestHash: requestHash,
+		LockID:      pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		ExpiresAt:   time.Now().Add(idempotencyLockTimeout),
+	}
+	response, err := json.Marshal(db.DonationTxResult{Donation: donation})
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+	completed := reserved
+	completed.LockID = pgtype.UUID{}
+	completed.ResponseStatus = pgtype.Int4{Int32: http.StatusOK, Valid: true}
+	completed.ResponseBody = response
+
+	anonymousKey := uuid.NewString()
+	anonymousReserved := reserved
+	anonymousReserved.ID = 43
+	anonymousReserved.Scope = anonymousIdempotencyScope
+	anonymousReserved.Key = anonymousKey
+	anonymousCompleted := completed
+	anonymousCompleted.ID = anonymousReserved.ID
+	anonymousCompleted.Scope = anonymousIdempotencyScope
+	anonymousCompleted.Key = anonymousKey
+
+	testCases := []struct {
+		name          string
+		key           string
+		anonymous     bool
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "FirstRequest",
+			key:  reserved.Key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReserveIdempotencyKey(gomock.Any(), gomock.Cond(func(arg db.ReserveIdempotencyKeyParams) bool {
+						return arg.Scope == reserved.Scope && arg.Key == reserved.Key && arg.RequestHash == requestHash
+					})).
+					Times(1).
+					Return(reserved, nil)
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					DonationTx(gomock.Any(), gomock.Cond(func(arg db.DonationTxParams) bool {
+						key := arg.IdempotencyKey
+						return key.ID == reserved.ID && key.LockID == reserved.LockID &&
+							key.ResponseStatus.Int32 == http.StatusOK && key.ExpiresAt.After(time.Now().Add(time.Hour))
+					})).
+					Times(1).
+					Return(db.DonationTxResult{Donation: donation}, nil)
+				// completed by DonationTx, not by the middleware
+				store.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, db.DonationTxResult{Donation: donation})
+			},
+		},
+		{
+			name: "Replay",
+			key:  reserved.Key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, pgx.ErrNoRows)
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), db.GetIdempotencyKeyParams{Scope: reserved.Scope, Key: reserved.Key}).
+					Times(1).
+					Return(completed, nil)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				if recorder.Header().Get(idempotencyReplayedHeader) != "true" {
+					t.Fatalf("expected %s header", idempotencyReplayedHeader)
+				}
+				requireBodyMatch(t, recorder.Body, db.DonationTxResult{Donation: donation})
+			},
+		},
+		{
+			name: "DifferentRequest",
+			key:  reserved.Key,
+			buildStubs: func(store *mockdb.MockStore) {
+				other := completed
+				other.RequestHash = "0000"
+				store.EXPECT().
+					ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, pgx.ErrNoRows)
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(other, nil)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnprocessableEntity, recorder.Body)
+			},
+		},
+		{
+			name: "InProgress",
+			key:  reserved.Key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, pgx.ErrNoRows)
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(reserved, nil)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+			},
+		},
+		{
+			name: "ReleasedOnError",
+			key:  reserved.Key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(reserved, nil)
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					DonationTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DonationTxResult{}, errors.New("connection reset"))
+				store.EXPECT().
+					ReleaseIdempotencyKey(gomock.Any(), db.ReleaseIdempotencyKeyParams{ID: reserved.ID, LockID: reserved.LockID}).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			// anonymous callers share a scope, keyed by a long random key
+			name:      "Anonymous",
+			key:       anonymousKey,
+			anonymous: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReserveIdempotencyKey(gomock.Any(), gomock.Cond(func(arg db.ReserveIdempotencyKeyParams) bool {
+						return arg.Scope == anonymousIdempotencyScope && arg.Key == anonymousKey && arg.RequestHash == requestHash
+					})).
+					Times(1).
+					Return(anonymousReserved, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					DonationTx(gomock.Any(), gomock.Cond(func(arg db.DonationTxParams) bool {
+						return arg.IdempotencyKey.ID == anonymousReserved.ID && !arg.UserID.Valid
+					})).
+					Times(1).
+					Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:      "AnonymousRetry",
+			key:       anonymousKey,
+			anonymous: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, pgx.ErrNoRows)
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), db.GetIdempotencyKeyParams{Scope: anonymousIdempotencyScope, Key: anonymousKey}).
+					Times(1).
+					Return(anonymousCompleted, nil)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				if recorder.Header().Get(idempotencyReplayedHeader) != "true" {
+					t.Fatalf("expected the %s header", idempotencyReplayedHeader)
+				}
+			},
+		},
+		{
+			// short keys could be guessed to replay another caller's response
+			name:      "AnonymousKeyTooShort",
+			key:       reserved.Key,
+			anonymous: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "KeyTooLong",
+			key:  string(make([]byte, maxIdempotencyKeyLength+1)),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().ListDeferredPaymentEvents(gomock.Any(), gomock.Any()).AnyTimes()
+			tc.buildStubs(store)
+			stubUserRoles(store, donor)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPost, "/donations", body)
+			request.Header.Set(idempotencyKeyHeader, tc.key)
+			if !tc.anonymous {
+				addAuthorization(t, request, server.tokenMaker, donor)
+			}
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestIdempotencyMiddlewareStoresResponse(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	reserved := db.IdempotencyKey{
+		ID:     7,
+		Key:    "org-1",
+		LockID: pgtype.UUID{Bytes: uuid.New(), Valid: true},
+	}
+	org := db.Organization{ID: 3, Name: "Acme", CreatedAt: time.Now().UTC().Truncate(time.Second)}
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	// expired keys are pruned periodically, not on every request
+	store.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any()).Times(0)
+	store.EXPECT().
+		ReserveIdempotencyKey(gomock.Any(), gomock.Cond(func(arg db.ReserveIdempotencyKeyParams) bool {
+			return arg.Scope == fmt.Sprintf("user:%d", admin.ID) && arg.Key == reserved.Key
+		})).
+		Times(1).
+		Return(reserved, nil)
+	store.EXPECT().CreateOrganization(gomock.Any(), "Acme").Times(1).Return(org, nil)
+	store.EXPECT().
+		CompleteIdempotencyKey(gomock.Any(), gomock.Cond(func(arg db.CompleteIdempotencyKeyParams) bool {
+			var stored db.Organization
+			if err := json.Unmarshal(arg.ResponseBody, &stored); err != nil {
+				return false
+			}
+			return arg.ID == reserved.ID && arg.LockID == reserved.LockID &&
+				arg.ResponseStatus.Int32 == http.StatusOK && stored.ID == org.ID
+		})).
+		Times(1).
+		Return(db.IdempotencyKey{}, nil)
+	stubUserRoles(store, admin)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request := newJSONRequest(t, http.MethodPost, "/organizations", gin.H{"name": "Acme"})
+	request.Header.Set(idempotencyKeyHeader, reserved.Key)
+	addAuthorization(t, request, server.tokenMaker, admin)
+	server.router.ServeHTTP(recorder, request)
+
+	requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+	requireBodyMatch(t, recorder.Body, org)
+}
+
+func TestCreatePayoutCompletesIdempotencyKey(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	goal := randomGoal()
+	reserved := db.IdempotencyKey{
+		ID:     9,
+		Key:    "payout-1",
+		LockID: pgtype.UUID{Bytes: uuid.New(), Valid: true},
+	}
+	payout := db.Payout{ID: 5, GoalID: goal.ID, Amount: 500, Currency: "USD", Provider: "fake", Reference: "wire-42"}
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any()).Times(0)
+	store.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(reserved, nil)
+	store.EXPECT().
+		PayoutTx(gomock.Any(), gomock.Cond(func(arg db.PayoutTxParams) bool {
+			key := arg.IdempotencyKey
+			return key.ID == reserved.ID && key.LockID == reserved.LockID && key.ResponseStatus.Int32 == http.StatusOK
+		})).
+		Times(1).
+		Return(db.PayoutTxResult{Payout: payout}, nil)
+	// completed by PayoutTx, not by the middleware
+	store.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+	stubUserRoles(store, admin)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/goals/%d/payouts", goal.ID)
+	request := newJSONRequest(t, http.MethodPost, url, gin.H{"amount": 500, "currency": "USD", "reference": "wire-42"})
+	request.Header.Set(idempotencyKeyHeader, reserved.Key)
+	addAuthorization(t, request, server.tokenMaker, admin)
+	server.router.ServeHTTP(recorder, request)
+
+	requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+	requireBodyMatch(t, recorder.Body, payout)
+}
+
+func TestPruneIdempotencyKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	store.EXPECT().
+		DeleteExpiredIdempotencyKeys(gomock.Any()).
+		MinTimes(2).
+		DoAndReturn(func(context.Context) error {
+			calls++
+			if calls == 2 {
+				cancel()
+			}
+			return errors.New("db down")
+		})
+
+	done := make(chan struct{})
+	go func() {
+		// errors are logged and pruning goes on
+		pruneIdempotencyKeys(ctx, store, time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pruning did not stop when the context was done")
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonWebKey is an Ed25519 public key in JWK format (RFC 8037).
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	X         string `json:"x"`
+}
+
+// getPublicKeys publishes the keys that verify our tokens so that other
+// services do not need a shared secret.
+func (s *Server) getPublicKeys(c *gin.Context) {
+	publicKeys := s.publicKeys.PublicKeys()
+
+	keys := make([]jsonWebKey, 0, len(publicKeys))
+	for _, key := range publicKeys {
+		keys = append(keys, jsonWebKey{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+			X:         base64.RawURLEncoding.EncodeToString(key.Key),
+		})
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
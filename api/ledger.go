@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "charity/db/sqlc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type goalReconciliation struct {
+	db.ReconcileGoalsRow
+	// Reconciled is set when collected_amount matches the ledger.
+	Reconciled bool `json:"reconciled"`
+}
+
+// reconcileLedger reports, per goal, whether collected_amount matches the
+// donations and refunds recorded in the ledger.
+func (s *Server) reconcileLedger(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "100")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit64, err := strconv.ParseInt(limitStr, 10, 32)
+	if err != nil || limit64 <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset64, err := strconv.ParseInt(offsetStr, 10, 32)
+	if err != nil || offset64 < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	rows, err := s.store.ReconcileGoals(c.Request.Context(), db.ReconcileGoalsParams{
+		Limit:  int32(limit64),
+		Offset: int32(offset64),
+	})
+	if err != nil {
+		log.Printf("reconcileLedger error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reconcile ledger"})
+		return
+	}
+
+	goals := make([]goalReconciliation, 0, len(rows))
+	mismatched := 0
+	for _, row := range rows {
+		reconciled := row.CollectedAmount == row.LedgerCollected
+		if !reconciled {
+			mismatched++
+		}
+		goals = append(goals, goalReconciliation{ReconcileGoalsRow: row, Reconciled: reconciled})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goals":      goals,
+		"mismatched": mismatched,
+	})
+}
+
+// createPayout records funds paid out to the beneficiary of a goal.
+func (s *Server) createPayout(c *gin.Context) {
+	idStr := c.Param("id")
+	goalID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || goalID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal id"})
+		return
+	}
+
+	var req createPayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateCreatePayoutRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// funds can only be paid out of a clearing account that donations are
+	// credited to
+	provider := req.Provider
+	switch provider {
+	case "":
+		provider = s.payments.Name()
+	case db.ManualProvider, s.payments.Name():
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("provider must be %s or %s", s.payments.Name(), db.ManualProvider),
+		})
+		return
+	}
+
+	params := db.PayoutTxParams{
+		GoalID:    goalID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Provider:  provider,
+		Reference: req.Reference,
+		CreatedBy: authPayload(c).UserID,
+	}
+
+	// a retried request must not pay out twice
+	idempotent, ok := idempotentRequestFromContext(c)
+	if ok {
+		params.IdempotencyKey = idempotent.completion(http.StatusOK)
+	}
+
+	result, err := s.store.PayoutTx(c.Request.Context(), params)
+	if err != nil {
+		if errors.Is(err, db.ErrIdempotencyKeyLost) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+			return
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+			return
+		}
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			// names the goal or the clearing account short of funds
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("createPayout error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payout"})
+		return
+	}
+	if ok {
+		idempotent.completed = true
+	}
+
+	c.JSON(http.StatusOK, result.Payout)
+}
+
+func (s *Server) listPayouts(c *gin.Context) {
+	idStr := c.Param("id")
+	goalID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || goalID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal id"})
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+	limit64, err := strconv.ParseInt(limitStr, 10, 32)
+	if err != nil || limit64 <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset64, err := strconv.ParseInt(offsetStr, 10, 32)
+	if err != nil || offset64 < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	payouts, err := s.store.ListPayoutsByGoal(c.Request.Context(), db.ListPayoutsByGoalParams{
+		GoalID: goalID,
+		Limit:  int32(limit64),
+		Offset: int32(offset64),
+	})
+	if err != nil {
+		log.Printf("listPayouts error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list payouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payouts)
+}
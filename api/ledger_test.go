@@ -0,0 +1,236 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreatePayout(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	manager := randomUser(t, util.GoalManagerRole, "secret-password")
+	goal := randomGoal()
+	payout := db.Payout{
+		ID:        rand.Int64N(1000) + 1,
+		GoalID:    goal.ID,
+		Amount:    500,
+		Currency:  "USD",
+		Provider:  "fake",
+		Reference: "wire-42",
+		CreatedBy: admin.ID,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	testCases := []struct {
+		name          string
+		goalID        int64
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 500, "currency": "USD", "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					PayoutTx(gomock.Any(), db.PayoutTxParams{
+						GoalID:    goal.ID,
+						Amount:    500,
+						Currency:  "USD",
+						Provider:  "fake",
+						Reference: "wire-42",
+						CreatedBy: admin.ID,
+					}).
+					Times(1).
+					Return(db.PayoutTxResult{Payout: payout}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, payout)
+			},
+		},
+		{
+			name:   "ManualProvider",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 500, "currency": "USD", "provider": db.ManualProvider, "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					PayoutTx(gomock.Any(), gomock.Cond(func(arg db.PayoutTxParams) bool {
+						return arg.Provider == db.ManualProvider
+					})).
+					Times(1).
+					Return(db.PayoutTxResult{Payout: payout}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "UnknownProvider",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 500, "currency": "USD", "provider": "paypal", "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PayoutTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "Forbidden",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 500, "currency": "USD", "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, manager)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PayoutTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidAmount",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 0, "currency": "USD", "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PayoutTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "GoalNotFound",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 500, "currency": "USD", "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					PayoutTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PayoutTxResult{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "InsufficientFunds",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 500, "currency": "USD", "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					PayoutTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PayoutTxResult{}, fmt.Errorf("goal %d: %w", goal.ID, db.ErrInsufficientFunds))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnprocessableEntity, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			goalID: goal.ID,
+			body:   gin.H{"amount": 500, "currency": "USD", "reference": "wire-42"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, admin)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					PayoutTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PayoutTxResult{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, admin, manager)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/goals/%d/payouts", tc.goalID)
+			request := newJSONRequest(t, http.MethodPost, url, tc.body)
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestReconcileLedger(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	rows := []db.ReconcileGoalsRow{
+		{GoalID: 1, CollectedAmount: 300, LedgerCollected: 300, LedgerPaidOut: 100, LedgerBalance: 200},
+		{GoalID: 2, CollectedAmount: 500, LedgerCollected: 450, LedgerBalance: 450},
+	}
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ReconcileGoals(gomock.Any(), db.ReconcileGoalsParams{Limit: 100, Offset: 0}).
+		Times(1).
+		Return(rows, nil)
+	stubUserRoles(store, admin)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request := newJSONRequest(t, http.MethodGet, "/ledger/reconciliation", nil)
+	addAuthorization(t, request, server.tokenMaker, admin)
+	server.router.ServeHTTP(recorder, request)
+
+	requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+	requireBodyMatch(t, recorder.Body, struct {
+		Goals      []goalReconciliation `json:"goals"`
+		Mismatched int                  `json:"mismatched"`
+	}{
+		Goals: []goalReconciliation{
+			{ReconcileGoalsRow: rows[0], Reconciled: true},
+			{ReconcileGoalsRow: rows[1], Reconciled: false},
+		},
+		Mismatched: 1,
+	})
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"charity/lockout"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+func emailLockoutKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginAttempt is a login counted as failed against both the account and
+// the client address before its outcome is known, so that concurrent
+// guesses cannot all get in before the lockout triggers.
+type loginAttempt struct {
+	email *lockout.Attempt
+	ip    *lockout.Attempt
+}
+
+// beginLogin counts a login for email from the client address. If either
+// is locked out it responds with 429, or 500 if the counters could not be
+// updated, and returns false.
+func (s *Server) beginLogin(c *gin.Context, email string) (*loginAttempt, bool) {
+	ctx := c.Request.Context()
+
+	emailAttempt, emailWait, err := s.emailLockout.Begin(ctx, emailLockoutKey(email))
+	if err != nil {
+		log.Printf("login begin attempt error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return nil, false
+	}
+	if emailWait > 0 {
+		abortTooManyAttempts(c, emailWait)
+		return nil, false
+	}
+
+	ipAttempt, ipWait, err := s.ipLockout.Begin(ctx, ipLockoutKey(c.ClientIP()))
+	if err != nil || ipWait > 0 {
+		if releaseErr := emailAttempt.Release(ctx); releaseErr != nil {
+			log.Printf("login release attempt error: %v", releaseErr)
+		}
+		if err != nil {
+			log.Printf("login begin attempt error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+			return nil, false
+		}
+		abortTooManyAttempts(c, ipWait)
+		return nil, false
+	}
+
+	return &loginAttempt{email: emailAttempt, ip: ipAttempt}, true
+}
+
+// release takes back a login that did not fail, either because the user
+// proved their identity or because the login could not be completed.
+func (a *loginAttempt) release(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := a.email.Release(ctx); err != nil {
+		log.Printf("login release attempt error: %v", err)
+	}
+	if err := a.ip.Release(ctx); err != nil {
+		log.Printf("login release attempt error: %v", err)
+	}
+}
+
+// rejectFailedLogin settles a failed login and responds with 429 if it
+// triggered a lockout, or 401 with message otherwise.
+func (s *Server) rejectFailedLogin(c *gin.Context, attempt *loginAttempt, message string) {
+	if wait := max(attempt.email.Fail(), attempt.ip.Fail()); wait > 0 {
+		abortTooManyAttempts(c, wait)
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+func abortTooManyAttempts(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": "too many failed login attempts, try again later",
+	})
+}
+
+// unlockUser clears the failed login counter of an account so that its owner
+// can log in again immediately. Per address counters are left alone.
+func (s *Server) unlockUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	user, err := s.store.GetUser(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("unlockUser get user error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock user"})
+		return
+	}
+
+	if err := s.emailLockout.Reset(c.Request.Context(), emailLockoutKey(user.Email)); err != nil {
+		log.Printf("unlockUser error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock user"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mockdb "charity/db/mock"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLoginUserLockout(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	maxFailures := int(server.config.LoginMaxFailuresPerEmail)
+
+	// a locked out account is not even looked up
+	store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(maxFailures).Return(user, nil)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := newJSONRequest(t, http.MethodPost, "/users/login", gin.H{"email": user.Email, "password": password})
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for i := 1; i < maxFailures; i++ {
+		recorder := login("wrong-password")
+		requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+	}
+
+	recorder := login("wrong-password")
+	requireStatus(t, recorder.Code, http.StatusTooManyRequests, recorder.Body)
+	if recorder.Header().Get("Retry-After") != fmt.Sprint(int(server.config.LoginLockoutBase.Seconds())) {
+		t.Fatalf("unexpected Retry-After: %q", recorder.Header().Get("Retry-After"))
+	}
+
+	// the right password does not help while the account is locked
+	recorder = login("secret-password")
+	requireStatus(t, recorder.Code, http.StatusTooManyRequests, recorder.Body)
+}
+
+func TestUnlockUser(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	user := randomUser(t, util.DonorRole, "secret-password")
+	user.ID = admin.ID + 1
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	for i := int32(0); i < server.config.LoginMaxFailuresPerEmail; i++ {
+		attempt, wait, err := server.emailLockout.Begin(context.Background(), emailLockoutKey(user.Email))
+		if err != nil || wait > 0 {
+			t.Fatalf("Begin failed: %v, wait %v", err, wait)
+		}
+		attempt.Fail()
+	}
+
+	store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+	stubUserRoles(store, admin)
+
+	recorder := httptest.NewRecorder()
+	request := newJSONRequest(t, http.MethodPost, fmt.Sprintf("/users/%d/unlock", user.ID), nil)
+	addAuthorization(t, request, server.tokenMaker, admin)
+	server.router.ServeHTTP(recorder, request)
+	requireStatus(t, recorder.Code, http.StatusNoContent, recorder.Body)
+
+	attempt, wait, err := server.emailLockout.Begin(context.Background(), emailLockoutKey(user.Email))
+	if err != nil || wait > 0 || attempt == nil {
+		t.Fatalf("account is still locked: %v, wait %v", err, wait)
+	}
+}
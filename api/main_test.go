@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"charity/config"
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/lockout"
+	"charity/mail"
+	"charity/payments"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testPasswordHasher keeps hashing cheap. The test server hashes with it
+// too, so hashes made by randomUser do not need rehashing.
+var testPasswordHasher = util.BcryptHasher{Cost: bcrypt.MinCost}
+
+func newTestServer(t *testing.T, store db.Store) *Server {
+	t.Helper()
+
+	cfg := config.Config{
+		TokenSymmetricKey:        "12345678901234567890123456789012",
+		AccessTokenDuration:      time.Minute,
+		RefreshTokenDuration:     time.Hour,
+		VerifyEmailDuration:      time.Hour,
+		PasswordResetDuration:    time.Hour,
+		MFAPendingTokenDuration:  time.Minute,
+		UnverifiedDonationLimit:  10000,
+		LoginMaxFailuresPerEmail: 5,
+		LoginMaxFailuresPerIP:    20,
+		LoginLockoutBase:         time.Minute,
+		LoginLockoutMax:          time.Hour,
+		LoginFailureWindow:       15 * time.Minute,
+		DonorRefundWindow:        30 * 24 * time.Hour,
+		IdempotencyKeyTTL:        24 * time.Hour,
+	}
+
+	tokenMaker, err := token.NewPasetoMaker(cfg.TokenSymmetricKey)
+	if err != nil {
+		t.Fatalf("failed to create token maker: %v", err)
+	}
+	passwordPolicy, err := util.NewPasswordPolicy(8, "")
+	if err != nil {
+		t.Fatalf("failed to create password policy: %v", err)
+	}
+
+	return NewServer(cfg, store, tokenMaker, mail.NewLogSender(), lockout.NewMemoryStore(), testPasswordHasher, passwordPolicy, nil, payments.NewFakeProvider("whsec_test"), nil)
+}
+
+// randomUser returns a verified user with the given role whose password is
+// password.
+func randomUser(t *testing.T, role string, password string) db.User {
+	t.Helper()
+
+	hashedPassword, err := util.HashPassword(testPasswordHasher, password)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	id := rand.Int64N(1000) + 1
+	return db.User{
+		ID:              id,
+		Email:           fmt.Sprintf("user%d@example.com", id),
+		Name:            pgtype.Text{String: "User", Valid: true},
+		Password:        pgtype.Text{String: hashedPassword, Valid: true},
+		CreatedAt:       time.Now(),
+		Role:            role,
+		IsEmailVerified: true,
+	}
+}
+
+func randomGoal() db.Goal {
+	return db.Goal{
+		ID:           rand.Int64N(1000) + 1,
+		Title:        "Clean water",
+		Description:  pgtype.Text{String: "Wells for villages", Valid: true},
+		TargetAmount: pgtype.Int8{Int64: 1000000, Valid: true},
+		IsActive:     true,
+		CreatedAt:    time.Now(),
+	}
+}
+
+func randomDonation(goalID int64, userID int64) db.Donation {
+	return db.Donation{
+		ID:        rand.Int64N(1000) + 1,
+		UserID:    pgtype.Int8{Int64: userID, Valid: userID != 0},
+		GoalID:    goalID,
+		Amount:    500,
+		Currency:  "USD",
+		CreatedAt: time.Now(),
+	}
+}
+
+// stubUserRoles lets the auth middleware load the current role of users.
+// Like other catch-all stubs it must be set up after the test's own stubs.
+func stubUserRoles(store *mockdb.MockStore, users ...db.User) {
+	for _, user := range users {
+		store.EXPECT().GetUserRole(gomock.Any(), user.ID).AnyTimes().Return(user.Role, nil)
+	}
+}
+
+// addAuthorization signs an access token for user and adds it to request.
+func addAuthorization(t *testing.T, request *http.Request, tokenMaker token.Maker, user db.User) {
+	t.Helper()
+
+	accessToken, _, err := tokenMaker.CreateToken(user.ID, user.Email, user.Role, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+	request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+accessToken)
+}
+
+func newJSONRequest(t *testing.T, method string, url string, body any) *http.Request {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	return request
+}
+
+func requireStatus(t *testing.T, got int, want int, body *bytes.Buffer) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("unexpected status: got %d, want %d, body %s", got, want, body.String())
+	}
+}
+
+func requireBodyMatch[T any](t *testing.T, body *bytes.Buffer, want T) {
+	t.Helper()
+
+	var got T
+	if err := json.Unmarshal(body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("failed to marshal expected body: %v", err)
+	}
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("failed to marshal response body: %v", err)
+	}
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Fatalf("unexpected body:\n got %s\nwant %s", gotJSON, wantJSON)
+	}
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// totpSkew is how many 30 second steps of clock drift are tolerated
+	// either way.
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes are issued on enrollment.
+	recoveryCodeCount = 10
+)
+
+// requireSecondFactor answers a correct password for a user with TOTP
+// enabled. Instead of a session, the client gets a short-lived token to
+// present with a code at /users/login/mfa.
+func (s *Server) requireSecondFactor(c *gin.Context, user db.User) {
+	mfaToken, mfaPayload, err := s.tokenMaker.CreateToken(user.ID, user.Email, user.Role, uuid.Nil, s.config.MFAPendingTokenDuration, token.TokenTypeMFAPendingToken)
+	if err != nil {
+		log.Printf("loginUser create mfa token error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create mfa token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mfa_required":         true,
+		"mfa_token":            mfaToken,
+		"mfa_token_expires_at": mfaPayload.ExpiredAt,
+	})
+}
+
+// loginUserMFA completes a login started with a password by checking a TOTP
+// code or a recovery code. Failures count towards the account lockout.
+func (s *Server) loginUserMFA(c *gin.Context) {
+	var req loginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateLoginMFARequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload, err := s.tokenMaker.VerifyToken(req.MFAToken, token.TokenTypeMFAPendingToken)
+	if err != nil {
+		if errors.Is(err, token.ErrExpiredToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa token has expired", "code": authErrExpiredToken})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa token is invalid", "code": authErrInvalidToken})
+		return
+	}
+
+	attempt, ok := s.beginLogin(c, payload.Name)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := s.store.GetUser(ctx, payload.UserID)
+	if err != nil {
+		attempt.release(c)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user no longer exists", "code": authErrInvalidToken})
+			return
+		}
+		log.Printf("loginUserMFA get user error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+
+	if req.Code != "" {
+		ok, err = s.useTOTPCode(c, user.ID, req.Code)
+	} else {
+		ok, err = s.useRecoveryCode(c, user.ID, req.RecoveryCode)
+	}
+	if err != nil {
+		attempt.release(c)
+		log.Printf("loginUserMFA error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+	if !ok {
+		s.rejectFailedLogin(c, attempt, "invalid verification code")
+		return
+	}
+	attempt.release(c)
+
+	s.completeLogin(c, user)
+}
+
+// useTOTPCode reports whether code is valid for the user's confirmed TOTP
+// secret and was not accepted before.
+func (s *Server) useTOTPCode(c *gin.Context, userID int64, code string) (bool, error) {
+	ctx := c.Request.Context()
+
+	totp, err := s.store.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !totp.ConfirmedAt.Valid {
+		return false, nil
+	}
+
+	step, ok := util.ValidateTOTP(totp.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+
+	// recording the step fails if this or a later code was already used
+	_, err = s.store.UseUserTOTPStep(ctx, db.UseUserTOTPStepParams{
+		Step:   step,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// useRecoveryCode reports whether code matches one of the user's unused
+// recovery codes, and marks it used.
+func (s *Server) useRecoveryCode(c *gin.Context, userID int64, code string) (bool, error) {
+	ctx := c.Request.Context()
+
+	codes, err := s.store.ListUnusedMFARecoveryCodes(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	code = util.NormalizeRecoveryCode(code)
+	for _, recoveryCode := range codes {
+		if util.CheckPassword(code, recoveryCode.CodeHash) != nil {
+			continue
+		}
+
+		_, err := s.store.UseMFARecoveryCode(ctx, recoveryCode.ID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// enrollTOTP generates a new TOTP secret for the current user. It only takes
+// effect once confirmed with a first code.
+func (s *Server) enrollTOTP(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		log.Printf("enrollTOTP secret error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll"})
+		return
+	}
+
+	_, err = s.store.UpsertUserTOTP(c.Request.Context(), db.UpsertUserTOTPParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if err != nil {
+		// the upsert skips rows that are already confirmed
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		log.Printf("enrollTOTP error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": util.TOTPURI(s.config.MFAIssuer, user.Email, secret),
+	})
+}
+
+// confirmTOTP enables TOTP for the current user after checking a first code
+// and returns a new set of recovery codes. They are only shown once.
+func (s *Server) confirmTOTP(c *gin.Context) {
+	var req confirmTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateConfirmTOTPRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	totp, err := s.store.GetUserTOTP(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no two-factor enrollment in progress"})
+			return
+		}
+		log.Printf("confirmTOTP get totp error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm enrollment"})
+		return
+	}
+	if totp.ConfirmedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+		return
+	}
+
+	step, ok := util.ValidateTOTP(totp.Secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid verification code"})
+		return
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := util.RandomRecoveryCode()
+		if err != nil {
+			log.Printf("confirmTOTP recovery code error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm enrollment"})
+			return
+		}
+		hash, err := util.HashPassword(s.passwordHasher, code)
+		if err != nil {
+			log.Printf("confirmTOTP hash error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm enrollment"})
+			return
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+
+	_, err = s.store.ConfirmTOTPTx(ctx, db.ConfirmTOTPTxParams{
+		UserID:             user.ID,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		log.Printf("confirmTOTP error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm enrollment"})
+		return
+	}
+
+	// the confirmation code must not be usable for a login
+	_, err = s.store.UseUserTOTPStep(ctx, db.UseUserTOTPStepParams{
+		Step:   step,
+		UserID: user.ID,
+	})
+	if err != nil {
+		log.Printf("confirmTOTP use step error: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// disableTOTP turns two-factor authentication off for the current user. The
+// password is asked again since the access token alone may have been stolen.
+func (s *Server) disableTOTP(c *gin.Context) {
+	var req disableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateDisableTOTPRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.Password.Valid || util.CheckPassword(req.Password, user.Password.String) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "password is incorrect"})
+		return
+	}
+
+	if err := s.store.DisableTOTPTx(c.Request.Context(), user.ID); err != nil {
+		log.Printf("disableTOTP error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
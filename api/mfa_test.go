@@ -0,0 +1,252 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLoginUserMFA(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("failed to generate totp secret: %v", err)
+	}
+	totp := db.UserTotp{UserID: user.ID, Secret: secret, ConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}
+	unconfirmed := db.UserTotp{UserID: user.ID, Secret: secret}
+
+	totpCode := func(t *testing.T, offset int64) (string, int64) {
+		step := util.TOTPStep(time.Now()) + offset
+		code, err := util.TOTPCode(secret, step)
+		if err != nil {
+			t.Fatalf("failed to create totp code: %v", err)
+		}
+		return code, step
+	}
+
+	const recoveryCode = "abcd-efgh"
+	recoveryCodeHash, err := util.HashPassword(testPasswordHasher, recoveryCode)
+	if err != nil {
+		t.Fatalf("failed to hash recovery code: %v", err)
+	}
+	otherCodeHash, err := util.HashPassword(testPasswordHasher, "ijkl-mnop")
+	if err != nil {
+		t.Fatalf("failed to hash recovery code: %v", err)
+	}
+	recoveryCodes := []db.MfaRecoveryCode{
+		{ID: 1, UserID: user.ID, CodeHash: otherCodeHash},
+		{ID: 2, UserID: user.ID, CodeHash: recoveryCodeHash},
+	}
+
+	expectSession := func(store *mockdb.MockStore) {
+		store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{UserID: user.ID}, nil)
+	}
+
+	testCases := []struct {
+		name       string
+		body       func(t *testing.T, mfaToken string) gin.H
+		buildStubs func(t *testing.T, store *mockdb.MockStore)
+		wantStatus int
+	}{
+		{
+			name: "TOTP",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				code, _ := totpCode(t, 0)
+				return gin.H{"mfa_token": mfaToken, "code": code}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				_, step := totpCode(t, 0)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(totp, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Cond(func(arg db.UseUserTOTPStepParams) bool {
+						// the step may have moved on since the code was made
+						return arg.UserID == user.ID && arg.Step >= step-1 && arg.Step <= step+1
+					})).
+					Times(1).
+					Return(totp, nil)
+				expectSession(store)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "TOTPWithinSkew",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				code, _ := totpCode(t, 1)
+				return gin.H{"mfa_token": mfaToken, "code": code}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				_, step := totpCode(t, 1)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(totp, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), db.UseUserTOTPStepParams{Step: step, UserID: user.ID}).
+					Times(1).
+					Return(totp, nil)
+				expectSession(store)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "TOTPBeyondSkew",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				code, _ := totpCode(t, -3)
+				return gin.H{"mfa_token": mfaToken, "code": code}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(totp, nil)
+				store.EXPECT().UseUserTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "TOTPReplay",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				code, _ := totpCode(t, 0)
+				return gin.H{"mfa_token": mfaToken, "code": code}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(totp, nil)
+				// the step was already used
+				store.EXPECT().UseUserTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "TOTPNotConfirmed",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				code, _ := totpCode(t, 0)
+				return gin.H{"mfa_token": mfaToken, "code": code}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(unconfirmed, nil)
+				store.EXPECT().UseUserTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "RecoveryCode",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				// recovery codes are accepted however they are typed
+				return gin.H{"mfa_token": mfaToken, "recovery_code": "ABCD EFGH"}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().ListUnusedMFARecoveryCodes(gomock.Any(), user.ID).Times(1).Return(recoveryCodes, nil)
+				store.EXPECT().UseMFARecoveryCode(gomock.Any(), int64(2)).Times(1).Return(recoveryCodes[1], nil)
+				expectSession(store)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "RecoveryCodeUnknown",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": "zzzz-zzzz"}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().ListUnusedMFARecoveryCodes(gomock.Any(), user.ID).Times(1).Return(recoveryCodes, nil)
+				store.EXPECT().UseMFARecoveryCode(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "RecoveryCodeUsedConcurrently",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().ListUnusedMFARecoveryCodes(gomock.Any(), user.ID).Times(1).Return(recoveryCodes, nil)
+				store.EXPECT().UseMFARecoveryCode(gomock.Any(), int64(2)).Times(1).Return(db.MfaRecoveryCode{}, pgx.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "CodeAndRecoveryCode",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				code, _ := totpCode(t, 0)
+				return gin.H{"mfa_token": mfaToken, "code": code, "recovery_code": recoveryCode}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "InvalidMFAToken",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				return gin.H{"mfa_token": "not-a-token", "recovery_code": recoveryCode}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "InternalError",
+			body: func(t *testing.T, mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode}
+			},
+			buildStubs: func(t *testing.T, store *mockdb.MockStore) {
+				store.EXPECT().ListUnusedMFARecoveryCodes(gomock.Any(), user.ID).Times(1).Return(nil, errors.New("db down"))
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(t, store)
+			store.EXPECT().GetUser(gomock.Any(), user.ID).AnyTimes().Return(user, nil)
+
+			server := newTestServer(t, store)
+			mfaToken, _, err := server.tokenMaker.CreateToken(user.ID, user.Email, user.Role, uuid.Nil, time.Minute, token.TokenTypeMFAPendingToken)
+			if err != nil {
+				t.Fatalf("failed to create mfa token: %v", err)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/users/login/mfa", tc.body(t, mfaToken))
+			server.router.ServeHTTP(recorder, request)
+			requireStatus(t, recorder.Code, tc.wantStatus, recorder.Body)
+		})
+	}
+}
+
+func TestLoginUserMFARejectsAccessToken(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	accessToken, _, err := server.tokenMaker.CreateToken(user.ID, user.Email, user.Role, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+
+	// an access token must not skip the second factor
+	recorder := httptest.NewRecorder()
+	request := newJSONRequest(t, http.MethodPost, "/users/login/mfa", gin.H{"mfa_token": accessToken, "code": "123456"})
+	server.router.ServeHTTP(recorder, request)
+	requireAuthErrorCode(t, recorder, authErrInvalidToken)
+}
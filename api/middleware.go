@@ -0,0 +1,217 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	authorizationHeaderKey    = "authorization"
+	authorizationTypeBearer   = "bearer"
+	authorizationPrincipalKey = "authorization_principal"
+)
+
+// Error codes returned alongside 401 responses so clients can tell apart
+// a missing header from a token that needs to be refreshed.
+const (
+	authErrMissingToken   = "missing_token"
+	authErrMalformedToken = "malformed_token"
+	authErrInvalidToken   = "invalid_token"
+	authErrExpiredToken   = "expired_token"
+	authErrRevokedToken   = "revoked_token"
+	authErrOutdatedToken  = "outdated_token"
+)
+
+// principal is the authenticated caller of a request: a user holding an
+// access token, or an integration holding an API key.
+type principal struct {
+	// UserID is the user the token was issued to or who owns the API key.
+	// It is zero for keys owned by an organization.
+	UserID         int64
+	OrganizationID int64
+	// Role is the current role of the user, which may differ from the role
+	// in an access token issued before it changed. It is empty for
+	// organization keys.
+	Role string
+	// Token is the access token payload, if authenticated by token.
+	Token *token.Payload
+	// APIKeyID and Scopes are set if authenticated by API key.
+	APIKeyID int64
+	Scopes   []string
+}
+
+func (p *principal) isAPIKey() bool {
+	return p.APIKeyID != 0
+}
+
+func abortUnauthorized(c *gin.Context, code string, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error": message,
+		"code":  code,
+	})
+}
+
+// authMiddleware verifies the bearer credential in the Authorization header
+// and stores the resulting principal in the gin context. The principal's
+// role is loaded from store, so a demoted or deleted user loses access
+// before their tokens expire. API keys are only accepted when acceptAPIKeys
+// is set; routes that accept them must also check a scope with requireScope.
+func authMiddleware(tokenMaker token.Maker, store db.Store, acceptAPIKeys bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorizationHeader := c.GetHeader(authorizationHeaderKey)
+		if authorizationHeader == "" {
+			abortUnauthorized(c, authErrMissingToken, "authorization header is not provided")
+			return
+		}
+
+		if !verifyAuthorizationHeader(c, tokenMaker, store, acceptAPIKeys, authorizationHeader) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// optionalAuthMiddleware behaves like authMiddleware when an Authorization
+// header is sent and lets the request through unauthenticated otherwise.
+// Handlers use optionalAuthPrincipal to tell the two cases apart.
+func optionalAuthMiddleware(tokenMaker token.Maker, store db.Store, acceptAPIKeys bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorizationHeader := c.GetHeader(authorizationHeaderKey)
+		if authorizationHeader != "" && !verifyAuthorizationHeader(c, tokenMaker, store, acceptAPIKeys, authorizationHeader) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// verifyAuthorizationHeader parses and verifies a bearer access token or API
+// key and stores the principal in the context. On failure it aborts the
+// request with 401 and returns false.
+func verifyAuthorizationHeader(c *gin.Context, tokenMaker token.Maker, store db.Store, acceptAPIKeys bool, authorizationHeader string) bool {
+	fields := strings.Fields(authorizationHeader)
+	if len(fields) != 2 {
+		abortUnauthorized(c, authErrMalformedToken, "invalid authorization header format")
+		return false
+	}
+
+	authorizationType := strings.ToLower(fields[0])
+	if authorizationType != authorizationTypeBearer {
+		abortUnauthorized(c, authErrMalformedToken, "unsupported authorization type")
+		return false
+	}
+
+	if strings.HasPrefix(fields[1], apiKeyPrefix) {
+		if !acceptAPIKeys {
+			abortUnauthorized(c, authErrInvalidToken, "api keys are not accepted for this endpoint")
+			return false
+		}
+		return verifyAPIKey(c, store, fields[1])
+	}
+
+	payload, err := tokenMaker.VerifyToken(fields[1], token.TokenTypeAccessToken)
+	if err != nil {
+		if errors.Is(err, token.ErrExpiredToken) {
+			abortUnauthorized(c, authErrExpiredToken, "access token has expired")
+			return false
+		}
+		if errors.Is(err, token.ErrRevokedToken) {
+			abortUnauthorized(c, authErrRevokedToken, "access token has been revoked")
+			return false
+		}
+		if errors.Is(err, token.ErrOutdatedToken) {
+			abortUnauthorized(c, authErrOutdatedToken, "access token format is outdated, renew it")
+			return false
+		}
+		abortUnauthorized(c, authErrInvalidToken, "access token is invalid")
+		return false
+	}
+
+	// the role in the token is the one the user had when they logged in
+	role, err := store.GetUserRole(c.Request.Context(), payload.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			abortUnauthorized(c, authErrInvalidToken, "user no longer exists")
+			return false
+		}
+		log.Printf("verifyAuthorizationHeader error: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify access token"})
+		return false
+	}
+
+	c.Set(authorizationPrincipalKey, &principal{
+		UserID: payload.UserID,
+		Role:   role,
+		Token:  payload,
+	})
+	return true
+}
+
+// authorize rejects requests whose principal does not hold one of the given
+// roles. Organization API keys hold no role and are only limited by their
+// scopes, so routes accepting them must also use requireScope. It must be
+// registered after authMiddleware.
+func authorize(allowedRoles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := authPrincipal(c)
+		if p.OrganizationID != 0 || slices.Contains(allowedRoles, p.Role) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+	}
+}
+
+// requireScope rejects API keys that were not granted scope, or whose owner
+// no longer has a role allowing it. Access tokens are not restricted by
+// scopes. It must be registered after authMiddleware.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authPrincipal(c).hasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (p *principal) hasScope(scope string) bool {
+	if !p.isAPIKey() {
+		return true
+	}
+	if !slices.Contains(p.Scopes, scope) {
+		return false
+	}
+	return p.OrganizationID != 0 || util.RoleAllowsScope(p.Role, scope)
+}
+
+// authPrincipal returns the principal stored by authMiddleware.
+// It must only be called from handlers registered behind the middleware.
+func authPrincipal(c *gin.Context) *principal {
+	return c.MustGet(authorizationPrincipalKey).(*principal)
+}
+
+// optionalAuthPrincipal returns the principal stored by
+// optionalAuthMiddleware, if the request was authenticated.
+func optionalAuthPrincipal(c *gin.Context) (*principal, bool) {
+	value, ok := c.Get(authorizationPrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	return value.(*principal), true
+}
+
+// authPayload returns the access token payload of the request. It must only
+// be called from handlers behind an authMiddleware that rejects API keys.
+func authPayload(c *gin.Context) *token.Payload {
+	return authPrincipal(c).Token
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/o1egl/paseto"
+	"go.uber.org/mock/gomock"
+)
+
+const (
+	authTestPath     = "/auth"
+	testSymmetricKey = "12345678901234567890123456789012"
+)
+
+func newTestTokenMaker(t *testing.T) token.Maker {
+	t.Helper()
+
+	tokenMaker, err := token.NewPasetoMaker(testSymmetricKey)
+	if err != nil {
+		t.Fatalf("failed to create token maker: %v", err)
+	}
+	return tokenMaker
+}
+
+// createTestToken signs a token for a donor with id 1.
+func createTestToken(t *testing.T, tokenMaker token.Maker, duration time.Duration, tokenType token.TokenType) string {
+	t.Helper()
+
+	tok, _, err := tokenMaker.CreateToken(1, "user1@example.com", util.DonorRole, uuid.New(), duration, tokenType)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	return tok
+}
+
+// createV1TestToken encrypts a version 1 payload, which named the user but
+// carried no user or session ID.
+func createV1TestToken(t *testing.T, tokenType token.TokenType) string {
+	t.Helper()
+
+	tok, err := paseto.NewV2().Encrypt([]byte(testSymmetricKey), gin.H{
+		"id":         uuid.New(),
+		"token_type": tokenType,
+		"name":       "user1@example.com",
+		"role":       util.DonorRole,
+		"issued_at":  time.Now(),
+		"expired_at": time.Now().Add(time.Minute),
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	return tok
+}
+
+// requireAuthErrorCode checks the code of a 401 response body.
+func requireAuthErrorCode(t *testing.T, recorder *httptest.ResponseRecorder, want string) {
+	t.Helper()
+
+	requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if body.Code != want {
+		t.Fatalf("unexpected error code: got %q, want %q", body.Code, want)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	tokenMaker := newTestTokenMaker(t)
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, time.Minute, token.TokenTypeAccessToken))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserRole(gomock.Any(), int64(1)).Times(1).Return(util.DonorRole, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, gin.H{"user_id": 1, "role": util.DonorRole})
+			},
+		},
+		{
+			name: "RoleChanged",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, time.Minute, token.TokenTypeAccessToken))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserRole(gomock.Any(), int64(1)).Times(1).Return(util.GoalManagerRole, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, gin.H{"user_id": 1, "role": util.GoalManagerRole})
+			},
+		},
+		{
+			name: "DeletedUser",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, time.Minute, token.TokenTypeAccessToken))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserRole(gomock.Any(), int64(1)).Times(1).Return("", pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name: "InternalError",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, time.Minute, token.TokenTypeAccessToken))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserRole(gomock.Any(), int64(1)).Times(1).Return("", errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrMissingToken)
+			},
+		},
+		{
+			name: "UnsupportedAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Basic "+createTestToken(t, tokenMaker, time.Minute, token.TokenTypeAccessToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrMalformedToken)
+			},
+		},
+		{
+			name: "InvalidAuthorizationFormat",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, createTestToken(t, tokenMaker, time.Minute, token.TokenTypeAccessToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrMalformedToken)
+			},
+		},
+		{
+			name: "InvalidToken",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer not-a-token")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name: "RefreshToken",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, time.Minute, token.TokenTypeRefreshToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, -time.Minute, token.TokenTypeAccessToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrExpiredToken)
+			},
+		},
+		{
+			name: "OutdatedToken",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createV1TestToken(t, token.TokenTypeAccessToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrOutdatedToken)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			if tc.buildStubs != nil {
+				tc.buildStubs(store)
+			}
+
+			router := gin.New()
+			router.GET(authTestPath, authMiddleware(tokenMaker, store, false), func(c *gin.Context) {
+				p := authPrincipal(c)
+				c.JSON(http.StatusOK, gin.H{"user_id": p.UserID, "role": p.Role})
+			})
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodGet, authTestPath, nil)
+			tc.setupAuth(t, request)
+			router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	testCases := []struct {
+		name         string
+		principal    principal
+		allowedRoles []string
+		wantStatus   int
+	}{
+		{
+			name:         "Admin",
+			principal:    principal{UserID: 1, Role: util.AdminRole},
+			allowedRoles: []string{util.AdminRole},
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "GoalManager",
+			principal:    principal{UserID: 1, Role: util.GoalManagerRole},
+			allowedRoles: []string{util.AdminRole, util.GoalManagerRole},
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "GoalManagerNotAdmin",
+			principal:    principal{UserID: 1, Role: util.GoalManagerRole},
+			allowedRoles: []string{util.AdminRole},
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			name:         "Donor",
+			principal:    principal{UserID: 1, Role: util.DonorRole},
+			allowedRoles: []string{util.AdminRole, util.GoalManagerRole},
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			name:         "NoRole",
+			principal:    principal{UserID: 1},
+			allowedRoles: []string{util.AdminRole},
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			// organization keys are limited by their scopes instead
+			name:         "OrganizationKey",
+			principal:    principal{OrganizationID: 3, APIKeyID: 5, Scopes: []string{util.GoalsWriteScope}},
+			allowedRoles: []string{util.AdminRole},
+			wantStatus:   http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET(authTestPath, func(c *gin.Context) {
+				c.Set(authorizationPrincipalKey, &tc.principal)
+			}, authorize(tc.allowedRoles...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodGet, authTestPath, nil)
+			router.ServeHTTP(recorder, request)
+			requireStatus(t, recorder.Code, tc.wantStatus, recorder.Body)
+		})
+	}
+}
+
+// TestAuthorizeDemotedUser checks that a user demoted after logging in
+// loses access while their access token is still valid.
+func TestAuthorizeDemotedUser(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUserRole(gomock.Any(), admin.ID).Times(1).Return(util.DonorRole, nil)
+	store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	request := newJSONRequest(t, http.MethodGet, "/users", nil)
+	addAuthorization(t, request, server.tokenMaker, admin)
+	server.router.ServeHTTP(recorder, request)
+	requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	tokenMaker := newTestTokenMaker(t)
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Authenticated",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, time.Minute, token.TokenTypeAccessToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, gin.H{"authenticated": true, "user_id": 1})
+			},
+		},
+		{
+			name:      "Anonymous",
+			setupAuth: func(t *testing.T, request *http.Request) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, gin.H{"authenticated": false, "user_id": 0})
+			},
+		},
+		{
+			// a bad credential is rejected rather than treated as anonymous
+			name: "ExpiredToken",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+createTestToken(t, tokenMaker, -time.Minute, token.TokenTypeAccessToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrExpiredToken)
+			},
+		},
+		{
+			name: "MalformedHeader",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				request.Header.Set(authorizationHeaderKey, "Bearer")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrMalformedToken)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUserRole(gomock.Any(), int64(1)).AnyTimes().Return(util.DonorRole, nil)
+
+			router := gin.New()
+			router.GET(authTestPath, optionalAuthMiddleware(tokenMaker, store, false), func(c *gin.Context) {
+				var userID int64
+				p, ok := optionalAuthPrincipal(c)
+				if ok {
+					userID = p.UserID
+				}
+				c.JSON(http.StatusOK, gin.H{"authenticated": ok, "user_id": userID})
+			})
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodGet, authTestPath, nil)
+			tc.setupAuth(t, request)
+			router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
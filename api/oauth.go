@@ -0,0 +1,172 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/oauth"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// oauthRandomBytes is the entropy of the state, PKCE code verifier and nonce
+// generated for each social login. 32 bytes encode to a 43 character code
+// verifier, the minimum RFC 7636 allows.
+const oauthRandomBytes = 32
+
+// oauthStateCookie binds a social login to the browser that started it.
+// Without it, an attacker could send a victim to the callback with the
+// attacker's own code and state and sign the victim into the attacker's
+// account.
+const oauthStateCookie = "oauth_state"
+
+func (s *Server) oauthProvider(c *gin.Context) (string, oauth.Provider, bool) {
+	name := c.Param("provider")
+	provider, ok := s.oauthProviders[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown identity provider"})
+		return "", nil, false
+	}
+	return name, provider, true
+}
+
+// oauthLogin starts a social login by redirecting the user to the identity
+// provider. The state, PKCE code verifier and nonce are kept server side
+// until the provider redirects back to oauthCallback, and the state is also
+// set in a cookie the callback checks.
+func (s *Server) oauthLogin(c *gin.Context) {
+	name, provider, ok := s.oauthProvider(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := s.store.DeleteExpiredOAuthStates(ctx); err != nil {
+		log.Printf("oauthLogin delete expired states error: %v", err)
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := util.RandomToken(oauthRandomBytes)
+		if err != nil {
+			log.Printf("oauthLogin random error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+			return
+		}
+		values[i] = value
+	}
+	state, codeVerifier, nonce := values[0], values[1], values[2]
+
+	_, err := s.store.CreateOAuthState(ctx, db.CreateOAuthStateParams{
+		StateHash:    util.HashToken(state),
+		Provider:     name,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(s.config.OAuthStateDuration),
+	})
+	if err != nil {
+		log.Printf("oauthLogin create state error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+
+	setOAuthStateCookie(c, name, state, int(s.config.OAuthStateDuration.Seconds()))
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, codeVerifier, nonce))
+}
+
+// setOAuthStateCookie sets the state cookie for a login with the named
+// provider, or clears it when maxAge is negative. SameSite=Lax still sends
+// it on the provider's top-level redirect back to the callback.
+func setOAuthStateCookie(c *gin.Context, name string, state string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/oauth/" + name + "/callback",
+		MaxAge:   maxAge,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oauthCallback completes a social login. The user is found by provider
+// identity, linked by verified email, or registered without a password.
+func (s *Server) oauthCallback(c *gin.Context) {
+	name, provider, ok := s.oauthProvider(c)
+	if !ok {
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "identity provider denied the login: " + providerErr})
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state query parameters are required"})
+		return
+	}
+
+	// the login must come back to the browser that started it
+	cookie, err := c.Cookie(oauthStateCookie)
+	setOAuthStateCookie(c, name, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired login state"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	oauthState, err := s.store.ConsumeOAuthState(ctx, db.ConsumeOAuthStateParams{
+		StateHash: util.HashToken(state),
+		Provider:  name,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired login state"})
+			return
+		}
+		log.Printf("oauthCallback consume state error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+
+	identity, err := provider.Exchange(ctx, code, oauthState.CodeVerifier, oauthState.Nonce)
+	if err != nil {
+		log.Printf("oauthCallback %s exchange error: %v", name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to authenticate with identity provider"})
+		return
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "identity provider did not return a verified email"})
+		return
+	}
+
+	result, err := s.store.OAuthLoginTx(ctx, db.OAuthLoginTxParams{
+		Provider: name,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		Name:     pgtype.Text{String: identity.Name, Valid: identity.Name != ""},
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrUnverifiedAccount) {
+			c.JSON(http.StatusConflict, gin.H{"error": "an account with this email exists but is not verified, verify it or log in with your password first"})
+			return
+		}
+		log.Printf("oauthCallback login error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+
+	s.continueLogin(c, result.User)
+}
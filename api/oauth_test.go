@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/oauth"
+	"charity/util"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/mock/gomock"
+)
+
+// stubOAuthProvider redirects to a fixed URL and never completes a login.
+type stubOAuthProvider struct{}
+
+func (stubOAuthProvider) AuthCodeURL(state string, codeVerifier string, nonce string) string {
+	return "https://idp.example.com/authorize?state=" + url.QueryEscape(state)
+}
+
+func (stubOAuthProvider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (oauth.Identity, error) {
+	return oauth.Identity{}, nil
+}
+
+func TestOAuthLoginSetsStateCookie(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	var stateHash string
+	store.EXPECT().DeleteExpiredOAuthStates(gomock.Any()).Times(1).Return(nil)
+	store.EXPECT().
+		CreateOAuthState(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateOAuthStateParams) (db.OauthState, error) {
+			stateHash = arg.StateHash
+			return db.OauthState{}, nil
+		})
+
+	server := newTestServer(t, store)
+	server.oauthProviders = map[string]oauth.Provider{"stub": stubOAuthProvider{}}
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/oauth/stub/login", nil)
+	server.router.ServeHTTP(recorder, request)
+
+	requireStatus(t, recorder.Code, http.StatusFound, recorder.Body)
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	state := location.Query().Get("state")
+
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected one cookie, got %d", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != oauthStateCookie || cookie.Value != state || util.HashToken(cookie.Value) != stateHash {
+		t.Fatalf("cookie does not carry the login state: %+v", cookie)
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/oauth/stub/callback" {
+		t.Fatalf("unexpected cookie attributes: %+v", cookie)
+	}
+}
+
+func TestOAuthCallbackRequiresStateCookie(t *testing.T) {
+	const state = "login-state"
+
+	testCases := []struct {
+		name       string
+		cookie     string
+		buildStubs func(store *mockdb.MockStore)
+	}{
+		{
+			name:   "MatchingCookie",
+			cookie: state,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ConsumeOAuthState(gomock.Any(), db.ConsumeOAuthStateParams{StateHash: util.HashToken(state), Provider: "stub"}).
+					Times(1).
+					Return(db.OauthState{}, pgx.ErrNoRows)
+			},
+		},
+		{
+			name: "NoCookie",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ConsumeOAuthState(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name:   "OtherBrowser",
+			cookie: "attacker-state",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ConsumeOAuthState(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.oauthProviders = map[string]oauth.Provider{"stub": stubOAuthProvider{}}
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/oauth/stub/callback?code=abc&state="+state, nil)
+			if tc.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: tc.cookie})
+			}
+			server.router.ServeHTTP(recorder, request)
+
+			requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+		})
+	}
+}
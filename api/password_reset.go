@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// passwordResetTokenBytes is the amount of randomness in a reset token.
+const passwordResetTokenBytes = 32
+
+func (s *Server) requestPasswordReset(c *gin.Context) {
+	var req requestPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateRequestPasswordResetRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The response must not reveal whether the email is registered, so it
+	// is the same for unknown addresses and failures are only logged. The
+	// token is issued in the background to keep response times similar.
+	user, err := s.store.GetUserByEmail(c.Request.Context(), req.Email)
+	switch {
+	case err == nil:
+		go s.issuePasswordReset(user)
+	case !errors.Is(err, pgx.ErrNoRows):
+		log.Printf("requestPasswordReset get user error: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if an account with that email exists, a password reset link has been sent",
+	})
+}
+
+// issuePasswordReset stores a new reset token for user and emails it.
+func (s *Server) issuePasswordReset(user db.User) {
+	resetToken, err := util.RandomToken(passwordResetTokenBytes)
+	if err != nil {
+		log.Printf("issuePasswordReset token error: %v", err)
+		return
+	}
+
+	reset, err := s.store.CreatePasswordReset(context.Background(), db.CreatePasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: util.HashToken(resetToken),
+		ExpiredAt: time.Now().Add(s.config.PasswordResetDuration),
+	})
+	if err != nil {
+		log.Printf("issuePasswordReset create error: %v", err)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("token", resetToken)
+	link := fmt.Sprintf("%s/password-reset?%s", s.config.AppBaseURL, query.Encode())
+
+	subject := "Reset your password"
+	content := fmt.Sprintf(`Hello,<br/>
+We received a request to reset the password for your account.<br/>
+Please <a href="%s">click here</a> to choose a new password.<br/>
+The link can be used once and expires at %s.<br/>
+If you did not request a password reset you can ignore this email.<br/>`,
+		html.EscapeString(link), reset.ExpiredAt.Format(time.RFC1123))
+
+	if err := s.mailer.SendEmail(subject, content, []string{user.Email}); err != nil {
+		log.Printf("issuePasswordReset send error: %v", err)
+	}
+}
+
+func (s *Server) confirmPasswordReset(c *gin.Context) {
+	var req confirmPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateConfirmPasswordResetRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.passwordPolicy.Validate(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(s.passwordHasher, req.NewPassword)
+	if err != nil {
+		log.Printf("confirmPasswordReset hash error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process password"})
+		return
+	}
+
+	now := time.Now()
+	result, err := s.store.ResetPasswordTx(c.Request.Context(), db.ResetPasswordTxParams{
+		TokenHash:           util.HashToken(req.Token),
+		HashedPassword:      hashedPassword,
+		RevokedAt:           now,
+		RevocationExpiresAt: now.Add(max(s.config.AccessTokenDuration, s.config.RefreshTokenDuration)),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+			return
+		}
+		log.Printf("confirmPasswordReset error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	s.revocations.add(result.Revocation)
+
+	c.Status(http.StatusNoContent)
+}
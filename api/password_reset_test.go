@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequestPasswordReset(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore, issued chan<- struct{})
+		wantIssued bool
+	}{
+		{
+			name: "KnownEmail",
+			buildStubs: func(store *mockdb.MockStore, issued chan<- struct{}) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Cond(func(arg db.CreatePasswordResetParams) bool {
+						return arg.UserID == user.ID && arg.TokenHash != "" && time.Until(arg.ExpiredAt) > 0
+					})).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+						close(issued)
+						return db.PasswordReset{UserID: arg.UserID, TokenHash: arg.TokenHash, ExpiredAt: arg.ExpiredAt}, nil
+					})
+			},
+			wantIssued: true,
+		},
+		{
+			name: "UnknownEmail",
+			buildStubs: func(store *mockdb.MockStore, issued chan<- struct{}) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().CreatePasswordReset(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore, issued chan<- struct{}) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(db.User{}, errors.New("db down"))
+				store.EXPECT().CreatePasswordReset(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			issued := make(chan struct{})
+			tc.buildStubs(store, issued)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/users/password-reset/request", gin.H{"email": user.Email})
+			server.router.ServeHTTP(recorder, request)
+
+			// the response does not reveal whether the email is registered
+			requireStatus(t, recorder.Code, http.StatusAccepted, recorder.Body)
+			if tc.wantIssued {
+				select {
+				case <-issued:
+				case <-time.After(5 * time.Second):
+					t.Fatal("password reset was not issued")
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+	const resetToken = "reset-token"
+	const newPassword = "new-secret-password"
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Cond(func(arg db.ResetPasswordTxParams) bool {
+						return arg.TokenHash == util.HashToken(resetToken) &&
+							util.CheckPassword(newPassword, arg.HashedPassword) == nil &&
+							arg.RevocationExpiresAt.After(arg.RevokedAt)
+					})).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+						return db.ResetPasswordTxResult{
+							User: user,
+							Revocation: db.TokenRevocation{
+								UserID:    user.ID,
+								RevokedAt: arg.RevokedAt,
+								ExpiresAt: arg.RevocationExpiresAt,
+							},
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireStatus(t, recorder.Code, http.StatusNoContent, recorder.Body)
+				// sessions started before the reset are signed out
+				if !server.revocations.revokedForUser(user.ID, time.Now().Add(-time.Minute)) {
+					t.Fatal("existing tokens were not revoked")
+				}
+			},
+		},
+		{
+			name: "InvalidOrExpiredToken",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ResetPasswordTxResult{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "WeakPassword",
+			body: gin.H{"token": resetToken, "new_password": "short"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "MissingToken",
+			body: gin.H{"new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ResetPasswordTxResult{}, errors.New("db down"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/users/password-reset/confirm", tc.body)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server)
+		})
+	}
+}
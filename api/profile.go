@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (s *Server) getCurrentUser(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+func (s *Server) updateCurrentUser(c *gin.Context) {
+	var req updateCurrentUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateUpdateCurrentUserRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	params := db.UpdateUserTxParams{
+		UpdateUserParams: db.UpdateUserParams{ID: user.ID},
+	}
+	if req.Name != nil {
+		params.Name = pgtype.Text{String: *req.Name, Valid: true}
+	}
+	if req.Email != nil && *req.Email != user.Email {
+		code, err := util.RandomToken(verifyEmailCodeBytes)
+		if err != nil {
+			log.Printf("updateCurrentUser verification code error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+			return
+		}
+		params.Email = pgtype.Text{String: *req.Email, Valid: true}
+		params.SecretCode = code
+		params.VerifyEmailExpiresAt = time.Now().Add(s.config.VerifyEmailDuration)
+	}
+
+	result, err := s.store.UpdateUserTx(c.Request.Context(), params)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+			return
+		}
+		log.Printf("updateCurrentUser error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+		return
+	}
+
+	if result.VerifyEmail != nil {
+		if err := s.sendVerifyEmail(result.User, *result.VerifyEmail); err != nil {
+			log.Printf("updateCurrentUser send verification email error: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(result.User))
+}
+
+func (s *Server) changePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateChangePasswordRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.passwordPolicy.Validate(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.Password.Valid || util.CheckPassword(req.OldPassword, user.Password.String) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "old password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(s.passwordHasher, req.NewPassword)
+	if err != nil {
+		log.Printf("changePassword hash error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process password"})
+		return
+	}
+
+	_, err = s.store.UpdateUserPassword(c.Request.Context(), db.UpdateUserPasswordParams{
+		ID:       user.ID,
+		Password: pgtype.Text{String: hashedPassword, Valid: true},
+	})
+	if err != nil {
+		log.Printf("changePassword error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
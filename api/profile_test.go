@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetCurrentUser(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(user))
+			},
+		},
+		{
+			name: "UserDeleted",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(db.User{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, user)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodGet, "/users/me", nil)
+			addAuthorization(t, request, server.tokenMaker, user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateCurrentUser(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+	const newEmail = "new@example.com"
+
+	renamed := user
+	renamed.Name = pgtype.Text{String: "Renamed", Valid: true}
+	moved := user
+	moved.Email = newEmail
+	moved.IsEmailVerified = false
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender)
+	}{
+		{
+			name: "Name",
+			body: gin.H{"name": "Renamed"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), db.UpdateUserTxParams{
+						UpdateUserParams: db.UpdateUserParams{ID: user.ID, Name: renamed.Name},
+					}).
+					Times(1).
+					Return(db.UpdateUserTxResult{User: renamed}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(renamed))
+				if len(sender.contents) != 0 {
+					t.Fatal("unexpected verification email")
+				}
+			},
+		},
+		{
+			name: "Email",
+			body: gin.H{"email": newEmail},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Cond(func(arg db.UpdateUserTxParams) bool {
+						return arg.ID == user.ID && arg.Email.String == newEmail && arg.SecretCode != ""
+					})).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						return db.UpdateUserTxResult{
+							User:        moved,
+							VerifyEmail: &db.VerifyEmail{ID: 4, UserID: user.ID, Email: newEmail, SecretCode: arg.SecretCode},
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(moved))
+				// the new address must be verified before it counts
+				if len(sender.to) != 1 || sender.to[0][0] != newEmail {
+					t.Fatalf("expected a verification email to %s, got %v", newEmail, sender.to)
+				}
+			},
+		},
+		{
+			name: "DuplicateEmail",
+			body: gin.H{"email": newEmail},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(1).Return(db.UpdateUserTxResult{}, &pgconn.PgError{Code: "23505"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+			tc.buildStubs(store)
+			stubUserRoles(store, user)
+
+			server := newTestServer(t, store)
+			sender := &recordingSender{}
+			server.mailer = sender
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPatch, "/users/me", tc.body)
+			addAuthorization(t, request, server.tokenMaker, user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, sender)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+	oauthUser := randomUser(t, util.DonorRole, "secret-password")
+	oauthUser.Password = pgtype.Text{}
+	const newPassword = "new-secret-password"
+
+	testCases := []struct {
+		name       string
+		user       db.User
+		body       gin.H
+		buildStubs func(store *mockdb.MockStore)
+		wantStatus int
+	}{
+		{
+			name: "OK",
+			user: user,
+			body: gin.H{"old_password": "secret-password", "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Cond(func(arg db.UpdateUserPasswordParams) bool {
+						return arg.ID == user.ID && util.CheckPassword(newPassword, arg.Password.String) == nil
+					})).
+					Times(1).
+					Return(user, nil)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "WrongOldPassword",
+			user: user,
+			body: gin.H{"old_password": "wrong-password", "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "NoPassword",
+			user: oauthUser,
+			body: gin.H{"old_password": "secret-password", "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), oauthUser.ID).Times(1).Return(oauthUser, nil)
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "WeakPassword",
+			user: user,
+			body: gin.H{"old_password": "secret-password", "new_password": "short"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, user, oauthUser)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPut, "/users/me/password", tc.body)
+			addAuthorization(t, request, server.tokenMaker, tc.user)
+			server.router.ServeHTTP(recorder, request)
+			requireStatus(t, recorder.Code, tc.wantStatus, recorder.Body)
+		})
+	}
+}
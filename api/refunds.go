@@ -0,0 +1,251 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/payments"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// createRefund refunds all or part of a donation. Admins may refund any
+// donation for any reason; donors may refund their own donations within
+// the refund window.
+func (s *Server) createRefund(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid donation id"})
+		return
+	}
+
+	var req createRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateCreateRefundRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	donation, err := s.store.GetDonation(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "donation not found"})
+			return
+		}
+		log.Printf("createRefund error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refund donation"})
+		return
+	}
+
+	p := authPrincipal(c)
+	isAdmin := p.Role == util.AdminRole
+	if !isAdmin {
+		// do not reveal other donors' donations
+		if p.OrganizationID != 0 || !donation.UserID.Valid || donation.UserID.Int64 != p.UserID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "donation not found"})
+			return
+		}
+		if req.Reason != db.RefundReasonRequestedByDonor && req.Reason != db.RefundReasonDuplicate {
+			c.JSON(http.StatusForbidden, gin.H{"error": "donors may only refund with reason requested_by_donor or duplicate"})
+			return
+		}
+		paidAt := donation.CreatedAt
+		if donation.ConfirmedAt.Valid {
+			paidAt = donation.ConfirmedAt.Time
+		}
+		if time.Since(paidAt) > s.config.DonorRefundWindow {
+			c.JSON(http.StatusForbidden, gin.H{"error": "donation can no longer be refunded by its donor"})
+			return
+		}
+	}
+
+	if donation.Status != db.DonationSucceeded && donation.Status != db.DonationRefunded {
+		c.JSON(http.StatusConflict, gin.H{"error": "donation is " + donation.Status})
+		return
+	}
+
+	// check the amount before asking the provider; RefundTx checks it again
+	// with the donation locked
+	refunded, err := s.store.GetDonationRefundedAmount(c.Request.Context(), donation.ID)
+	if err != nil {
+		log.Printf("createRefund error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refund donation"})
+		return
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = donation.Amount - refunded
+	}
+	if amount <= 0 || amount > donation.Amount-refunded {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": db.ErrRefundExceedsDonation.Error()})
+		return
+	}
+	// funds already paid out to the goal's beneficiary cannot be refunded
+	funds, err := s.store.GetGoalLedgerFunds(c.Request.Context(), db.GetGoalLedgerFundsParams{
+		GoalID:   pgtype.Int8{Int64: donation.GoalID, Valid: true},
+		Currency: donation.Currency,
+	})
+	if err != nil {
+		log.Printf("createRefund error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refund donation"})
+		return
+	}
+	if funds < amount {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "goal has insufficient funds for this refund"})
+		return
+	}
+
+	params := db.RefundTxParams{
+		DonationID: donation.ID,
+		Amount:     amount,
+		Reason:     req.Reason,
+		Note:       pgtype.Text{String: req.Note, Valid: req.Note != ""},
+		CreatedBy:  pgtype.Int8{Int64: p.UserID, Valid: p.UserID != 0},
+		Status:     db.RefundSucceeded,
+	}
+
+	// a retried request must not refund twice
+	idempotent, ok := idempotentRequestFromContext(c)
+	if ok {
+		params.IdempotencyKey = idempotent.completion(http.StatusOK)
+	}
+
+	switch donation.Provider {
+	case db.ManualProvider:
+		// the money is returned outside of any payment provider
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only admins may refund donations settled manually"})
+			return
+		}
+	case s.payments.Name():
+		refundParams := payments.RefundParams{
+			IntentID: donation.PaymentIntentID.String,
+			Amount:   amount,
+			Reason:   providerRefundReason(req.Reason),
+			Metadata: map[string]string{"donation_id": strconv.FormatInt(donation.ID, 10)},
+		}
+		if ok {
+			refundParams.IdempotencyKey = idempotent.key.Scope + ":" + idempotent.key.Key
+		}
+		refund, err := s.payments.Refund(c.Request.Context(), refundParams)
+		if err == nil && refundStatus(refund.Status) == db.RefundFailed {
+			err = errors.New("refund " + refund.ID + " failed")
+		}
+		if err != nil {
+			log.Printf("createRefund error: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to refund payment"})
+			return
+		}
+		params.ProviderRefundID = refund.ID
+		params.Status = refundStatus(refund.Status)
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "donation cannot be refunded through " + s.payments.Name()})
+		return
+	}
+
+	result, err := s.store.RefundTx(c.Request.Context(), params)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrIdempotencyKeyLost):
+			c.JSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+		case errors.Is(err, db.ErrRefundExceedsDonation):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, db.ErrInsufficientFunds):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "goal has insufficient funds for this refund"})
+		case errors.Is(err, db.ErrDonationNotRefundable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("createRefund error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refund donation"})
+		}
+		return
+	}
+	if ok {
+		idempotent.completed = true
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) listRefunds(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid donation id"})
+		return
+	}
+
+	donation, err := s.store.GetDonation(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "donation not found"})
+			return
+		}
+		log.Printf("listRefunds error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list refunds"})
+		return
+	}
+
+	p := authPrincipal(c)
+	if p.Role != util.AdminRole && p.OrganizationID == 0 &&
+		(!donation.UserID.Valid || donation.UserID.Int64 != p.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "donation not found"})
+		return
+	}
+
+	refunds, err := s.store.ListRefundsByDonation(c.Request.Context(), donation.ID)
+	if err != nil {
+		log.Printf("listRefunds error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, refunds)
+}
+
+// providerRefundReason maps a refund reason code to the reason sent to the
+// payment provider, which knows fewer reasons.
+func providerRefundReason(reason string) string {
+	switch reason {
+	case db.RefundReasonDuplicate, db.RefundReasonFraudulent:
+		return reason
+	case db.RefundReasonRequestedByDonor:
+		return "requested_by_customer"
+	}
+	return ""
+}
+
+// refundReasonFromProvider maps the reason of a refund issued at the
+// payment provider to a refund reason code.
+func refundReasonFromProvider(reason string) string {
+	switch reason {
+	case "duplicate":
+		return db.RefundReasonDuplicate
+	case "fraudulent":
+		return db.RefundReasonFraudulent
+	case "requested_by_customer":
+		return db.RefundReasonRequestedByDonor
+	}
+	return db.RefundReasonOther
+}
+
+func refundStatus(status payments.RefundStatus) string {
+	switch status {
+	case payments.RefundSucceeded:
+		return db.RefundSucceeded
+	case payments.RefundFailed, payments.RefundCanceled:
+		return db.RefundFailed
+	}
+	return db.RefundPending
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/payments"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateRefund(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	other := randomUser(t, util.DonorRole, "secret-password")
+	goal := randomGoal()
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		user          db.User
+		setup         func(donation *db.Donation)
+		buildStubs    func(store *mockdb.MockStore, donation db.Donation)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "AdminFullRefund",
+			body: gin.H{"reason": db.RefundReasonFraudulent, "note": "stolen card"},
+			user: admin,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().GetDonationRefundedAmount(gomock.Any(), donation.ID).Times(1).Return(int64(0), nil)
+				store.EXPECT().GetGoalLedgerFunds(gomock.Any(), gomock.Any()).Times(1).Return(donation.Amount, nil)
+				store.EXPECT().
+					RefundTx(gomock.Any(), gomock.Cond(func(arg db.RefundTxParams) bool {
+						return arg.DonationID == donation.ID && arg.Amount == donation.Amount &&
+							arg.Reason == db.RefundReasonFraudulent && arg.Note.String == "stolen card" &&
+							arg.CreatedBy.Int64 == admin.ID && arg.ProviderRefundID != "" &&
+							arg.Status == db.RefundSucceeded
+					})).
+					Times(1).
+					Return(db.RefundTxResult{Refund: db.Refund{ID: 1, DonationID: donation.ID, Amount: donation.Amount}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "DonorPartialRefund",
+			body: gin.H{"amount": 200, "reason": db.RefundReasonRequestedByDonor},
+			user: donor,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().GetDonationRefundedAmount(gomock.Any(), donation.ID).Times(1).Return(int64(100), nil)
+				store.EXPECT().GetGoalLedgerFunds(gomock.Any(), gomock.Any()).Times(1).Return(donation.Amount, nil)
+				store.EXPECT().
+					RefundTx(gomock.Any(), gomock.Cond(func(arg db.RefundTxParams) bool {
+						return arg.Amount == 200 && arg.CreatedBy.Int64 == donor.ID
+					})).
+					Times(1).
+					Return(db.RefundTxResult{Refund: db.Refund{ID: 1, DonationID: donation.ID, Amount: 200}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "ManualDonation",
+			body: gin.H{"reason": db.RefundReasonGoalCanceled},
+			user: admin,
+			setup: func(donation *db.Donation) {
+				donation.Provider = db.ManualProvider
+				donation.PaymentIntentID = pgtype.Text{}
+			},
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().GetDonationRefundedAmount(gomock.Any(), donation.ID).Times(1).Return(int64(0), nil)
+				store.EXPECT().GetGoalLedgerFunds(gomock.Any(), gomock.Any()).Times(1).Return(donation.Amount, nil)
+				store.EXPECT().
+					RefundTx(gomock.Any(), gomock.Cond(func(arg db.RefundTxParams) bool {
+						return arg.ProviderRefundID == "" && arg.Status == db.RefundSucceeded
+					})).
+					Times(1).
+					Return(db.RefundTxResult{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "ExceedsRemaining",
+			body: gin.H{"amount": 200, "reason": db.RefundReasonRequestedByDonor},
+			user: donor,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().GetDonationRefundedAmount(gomock.Any(), donation.ID).Times(1).Return(int64(400), nil)
+				store.EXPECT().RefundTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnprocessableEntity, recorder.Body)
+			},
+		},
+		{
+			name: "InsufficientFunds",
+			body: gin.H{"reason": db.RefundReasonFraudulent},
+			user: admin,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().GetDonationRefundedAmount(gomock.Any(), donation.ID).Times(1).Return(int64(0), nil)
+				store.EXPECT().
+					GetGoalLedgerFunds(gomock.Any(), gomock.Cond(func(arg db.GetGoalLedgerFundsParams) bool {
+						return arg.GoalID.Int64 == donation.GoalID && arg.Currency == donation.Currency
+					})).
+					Times(1).
+					Return(donation.Amount-1, nil)
+				store.EXPECT().RefundTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnprocessableEntity, recorder.Body)
+			},
+		},
+		{
+			name: "PaidOutConcurrently",
+			body: gin.H{"reason": db.RefundReasonGoalCanceled},
+			user: admin,
+			setup: func(donation *db.Donation) {
+				donation.Provider = db.ManualProvider
+				donation.PaymentIntentID = pgtype.Text{}
+			},
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().GetDonationRefundedAmount(gomock.Any(), donation.ID).Times(1).Return(int64(0), nil)
+				store.EXPECT().GetGoalLedgerFunds(gomock.Any(), gomock.Any()).Times(1).Return(donation.Amount, nil)
+				store.EXPECT().
+					RefundTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RefundTxResult{}, fmt.Errorf("goal %d: %w", donation.GoalID, db.ErrInsufficientFunds))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnprocessableEntity, recorder.Body)
+			},
+		},
+		{
+			name: "DonorReasonNotAllowed",
+			body: gin.H{"reason": db.RefundReasonFraudulent},
+			user: donor,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().RefundTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "DonorWindowExpired",
+			body: gin.H{"reason": db.RefundReasonRequestedByDonor},
+			user: donor,
+			setup: func(donation *db.Donation) {
+				donation.ConfirmedAt = pgtype.Timestamptz{Time: time.Now().Add(-60 * 24 * time.Hour), Valid: true}
+			},
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().RefundTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "OtherDonor",
+			body: gin.H{"reason": db.RefundReasonRequestedByDonor},
+			user: other,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().RefundTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name: "NotPaid",
+			body: gin.H{"reason": db.RefundReasonRequestedByDonor},
+			user: donor,
+			setup: func(donation *db.Donation) {
+				donation.Status = db.DonationPending
+			},
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), donation.ID).Times(1).Return(donation, nil)
+				store.EXPECT().RefundTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+			},
+		},
+		{
+			name: "InvalidReason",
+			body: gin.H{"reason": "changed_my_mind"},
+			user: donor,
+			buildStubs: func(store *mockdb.MockStore, donation db.Donation) {
+				store.EXPECT().GetDonation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			intent, err := server.payments.CreateIntent(context.Background(), payments.CreateIntentParams{
+				Amount:   500,
+				Currency: "USD",
+			})
+			if err != nil {
+				t.Fatalf("failed to create payment intent: %v", err)
+			}
+			if _, err := server.payments.ConfirmIntent(context.Background(), intent.ID, payments.FakeCardSucceeds); err != nil {
+				t.Fatalf("failed to confirm payment intent: %v", err)
+			}
+			donation := randomDonation(goal.ID, donor.ID)
+			donation.Status = db.DonationSucceeded
+			donation.Provider = server.payments.Name()
+			donation.PaymentIntentID = pgtype.Text{String: intent.ID, Valid: true}
+			donation.ConfirmedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+			if tc.setup != nil {
+				tc.setup(&donation)
+			}
+			tc.buildStubs(store, donation)
+			stubUserRoles(store, admin, donor, other)
+
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/donations/%d/refunds", donation.ID)
+			request := newJSONRequest(t, http.MethodPost, url, tc.body)
+			addAuthorization(t, request, server.tokenMaker, tc.user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
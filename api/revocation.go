@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/token"
+
+	"github.com/google/uuid"
+)
+
+// revocationSyncOverlap is subtracted from the newest created_at seen when
+// polling, so that revocations committed slightly out of order by other
+// instances are not skipped. Re-reading a revocation is harmless.
+const revocationSyncOverlap = time.Minute
+
+// revocationCache keeps every unexpired token revocation in memory so that
+// the auth path can check them without a database round trip. Revocations
+// made by this process are added directly; those made by other instances
+// are picked up by run.
+type revocationCache struct {
+	store db.Store
+
+	mu sync.RWMutex
+	// tokens maps a revoked token ID to the time its record expires.
+	tokens map[uuid.UUID]time.Time
+	// users maps a user ID to its latest user-wide revocation.
+	users map[int64]userRevocation
+	// syncedUntil is the newest created_at loaded from the database.
+	syncedUntil time.Time
+}
+
+type userRevocation struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+var _ token.RevocationChecker = (*revocationCache)(nil)
+
+func newRevocationCache(store db.Store) *revocationCache {
+	return &revocationCache{
+		store:  store,
+		tokens: make(map[uuid.UUID]time.Time),
+		users:  make(map[int64]userRevocation),
+	}
+}
+
+// IsRevoked reports whether the token itself was revoked or was issued to
+// its user before a user-wide revocation. Version 1 refresh tokens carry no
+// user ID; renewAccessToken checks them with revokedForUser once it has
+// loaded their session.
+func (rc *revocationCache) IsRevoked(payload *token.Payload) bool {
+	rc.mu.RLock()
+	_, ok := rc.tokens[payload.ID]
+	rc.mu.RUnlock()
+
+	return ok || rc.revokedForUser(payload.UserID, payload.IssuedAt)
+}
+
+// revokedForUser reports whether tokens issued to userID at issuedAt were
+// revoked by a user-wide revocation.
+func (rc *revocationCache) revokedForUser(userID int64, issuedAt time.Time) bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	rev, ok := rc.users[userID]
+	return ok && !issuedAt.After(rev.revokedAt)
+}
+
+// add records a revocation made by this process.
+func (rc *revocationCache) add(revocation db.TokenRevocation) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.addLocked(revocation)
+}
+
+func (rc *revocationCache) addLocked(revocation db.TokenRevocation) {
+	if revocation.TokenID.Valid {
+		rc.tokens[revocation.TokenID.Bytes] = revocation.ExpiresAt
+		return
+	}
+	prev, ok := rc.users[revocation.UserID]
+	if !ok || revocation.RevokedAt.After(prev.revokedAt) {
+		rc.users[revocation.UserID] = userRevocation{revokedAt: revocation.RevokedAt, expiresAt: revocation.ExpiresAt}
+	}
+}
+
+// sync loads revocations recorded since the last sync and drops entries
+// whose tokens have all expired.
+func (rc *revocationCache) sync(ctx context.Context) error {
+	rc.mu.RLock()
+	createdAfter := rc.syncedUntil.Add(-revocationSyncOverlap)
+	rc.mu.RUnlock()
+
+	rows, err := rc.store.ListTokenRevocationsCreatedAfter(ctx, createdAfter)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for _, revocation := range rows {
+		rc.addLocked(revocation)
+		if revocation.CreatedAt.After(rc.syncedUntil) {
+			rc.syncedUntil = revocation.CreatedAt
+		}
+	}
+	for id, expiresAt := range rc.tokens {
+		if now.After(expiresAt) {
+			delete(rc.tokens, id)
+		}
+	}
+	for userID, rev := range rc.users {
+		if now.After(rev.expiresAt) {
+			delete(rc.users, userID)
+		}
+	}
+
+	return nil
+}
+
+// run syncs the cache every interval until ctx is done. Expired records are
+// pruned from the database on the same schedule.
+func (rc *revocationCache) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := rc.sync(ctx); err != nil {
+			log.Printf("revocation cache sync error: %v", err)
+		}
+		if err := rc.store.DeleteExpiredTokenRevocations(ctx); err != nil {
+			log.Printf("revocation cache prune error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
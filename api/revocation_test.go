@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRevocationCacheIsRevoked(t *testing.T) {
+	now := time.Now()
+	revokedTokenID := uuid.New()
+
+	cache := newRevocationCache(nil)
+	cache.add(db.TokenRevocation{
+		UserID:    1,
+		TokenID:   pgtype.UUID{Bytes: revokedTokenID, Valid: true},
+		RevokedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	})
+	cache.add(db.TokenRevocation{
+		UserID:    2,
+		RevokedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	})
+
+	testCases := []struct {
+		name    string
+		payload token.Payload
+		want    bool
+	}{
+		{
+			name:    "RevokedToken",
+			payload: token.Payload{ID: revokedTokenID, UserID: 1, IssuedAt: now.Add(-time.Minute)},
+			want:    true,
+		},
+		{
+			name:    "OtherTokenOfUser",
+			payload: token.Payload{ID: uuid.New(), UserID: 1, IssuedAt: now.Add(-time.Minute)},
+			want:    false,
+		},
+		{
+			name:    "IssuedBeforeUserRevocation",
+			payload: token.Payload{ID: uuid.New(), UserID: 2, IssuedAt: now.Add(-time.Minute)},
+			want:    true,
+		},
+		{
+			name:    "IssuedAtUserRevocation",
+			payload: token.Payload{ID: uuid.New(), UserID: 2, IssuedAt: now},
+			want:    true,
+		},
+		{
+			name:    "IssuedAfterUserRevocation",
+			payload: token.Payload{ID: uuid.New(), UserID: 2, IssuedAt: now.Add(time.Minute)},
+			want:    false,
+		},
+		{
+			name:    "OtherUser",
+			payload: token.Payload{ID: uuid.New(), UserID: 3, IssuedAt: now.Add(-time.Minute)},
+			want:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cache.IsRevoked(&tc.payload); got != tc.want {
+				t.Fatalf("IsRevoked() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRevocationCacheKeepsLatestUserRevocation(t *testing.T) {
+	now := time.Now()
+	cache := newRevocationCache(nil)
+
+	cache.add(db.TokenRevocation{UserID: 1, RevokedAt: now, ExpiresAt: now.Add(time.Hour)})
+	// an older revocation synced late must not move the cutoff back
+	cache.add(db.TokenRevocation{UserID: 1, RevokedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)})
+
+	payload := &token.Payload{ID: uuid.New(), UserID: 1, IssuedAt: now.Add(-time.Minute)}
+	if !cache.IsRevoked(payload) {
+		t.Fatal("token issued before the latest revocation is not revoked")
+	}
+}
+
+func TestRevocationCacheSync(t *testing.T) {
+	now := time.Now()
+	tokenID := uuid.New()
+	expiredTokenID := uuid.New()
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	cache := newRevocationCache(store)
+
+	first := []db.TokenRevocation{
+		{
+			UserID:    1,
+			TokenID:   pgtype.UUID{Bytes: tokenID, Valid: true},
+			RevokedAt: now,
+			ExpiresAt: now.Add(time.Hour),
+			CreatedAt: now,
+		},
+		{
+			UserID:    2,
+			RevokedAt: now,
+			ExpiresAt: now.Add(time.Hour),
+			CreatedAt: now.Add(time.Second),
+		},
+	}
+	gomock.InOrder(
+		store.EXPECT().
+			ListTokenRevocationsCreatedAfter(gomock.Any(), time.Time{}.Add(-revocationSyncOverlap)).
+			Times(1).
+			Return(first, nil),
+		// the next sync starts shortly before the newest revocation seen
+		store.EXPECT().
+			ListTokenRevocationsCreatedAfter(gomock.Any(), now.Add(time.Second).Add(-revocationSyncOverlap)).
+			Times(1).
+			Return([]db.TokenRevocation{{
+				UserID:    3,
+				TokenID:   pgtype.UUID{Bytes: expiredTokenID, Valid: true},
+				RevokedAt: now.Add(-2 * time.Hour),
+				ExpiresAt: now.Add(-time.Hour),
+				CreatedAt: now.Add(time.Second),
+			}}, nil),
+	)
+
+	if err := cache.sync(context.Background()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if !cache.IsRevoked(&token.Payload{ID: tokenID, UserID: 1, IssuedAt: now}) {
+		t.Fatal("synced token revocation is not applied")
+	}
+	if !cache.IsRevoked(&token.Payload{ID: uuid.New(), UserID: 2, IssuedAt: now.Add(-time.Minute)}) {
+		t.Fatal("synced user revocation is not applied")
+	}
+
+	if err := cache.sync(context.Background()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	// revocations whose tokens have all expired are dropped
+	if _, ok := cache.tokens[expiredTokenID]; ok {
+		t.Fatal("expired revocation was kept")
+	}
+	if len(cache.tokens) != 1 || len(cache.users) != 1 {
+		t.Fatalf("unexpected cache size: %d tokens, %d users", len(cache.tokens), len(cache.users))
+	}
+}
+
+func TestAuthMiddlewareRevokedToken(t *testing.T) {
+	cache := newRevocationCache(nil)
+	tokenMaker := token.NewRevocationMaker(newTestTokenMaker(t), cache)
+
+	accessToken, payload, err := tokenMaker.CreateToken(1, "user1@example.com", util.DonorRole, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	cache.add(db.TokenRevocation{
+		UserID:    payload.UserID,
+		TokenID:   pgtype.UUID{Bytes: payload.ID, Valid: true},
+		RevokedAt: time.Now(),
+		ExpiresAt: payload.ExpiredAt,
+	})
+
+	router := gin.New()
+	router.GET(authTestPath, authMiddleware(tokenMaker, nil, false), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	request := newJSONRequest(t, http.MethodGet, authTestPath, nil)
+	request.Header.Set(authorizationHeaderKey, "Bearer "+accessToken)
+	router.ServeHTTP(recorder, request)
+	requireAuthErrorCode(t, recorder, authErrRevokedToken)
+}
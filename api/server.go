@@ -1,42 +1,131 @@
 package api
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"time"
 
+	"charity/billing"
+	"charity/config"
 	db "charity/db/sqlc"
+	"charity/lockout"
+	"charity/mail"
+	"charity/oauth"
+	"charity/payments"
 	"charity/token"
+	"charity/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	router               *gin.Engine
-	store                *db.Store
-	tokenMaker           token.Maker
-	accessTokenDuration  time.Duration
-	refreshTokenDuration time.Duration
+	config     config.Config
+	router     *gin.Engine
+	store      db.Store
+	tokenMaker token.Maker
+	// publicKeys is set when tokenMaker signs tokens asymmetrically.
+	publicKeys  token.PublicKeyProvider
+	revocations *revocationCache
+	mailer      mail.EmailSender
+	// passwordHasher hashes new passwords and recovery codes.
+	passwordHasher util.PasswordHasher
+	// passwordPolicy checks new passwords.
+	passwordPolicy *util.PasswordPolicy
+	// dummyPasswordHash is checked against when a login has no password
+	// hash to check, so that it takes as long as one that does.
+	dummyPasswordHash string
+	// oauthProviders are the identity providers for social login, by name.
+	oauthProviders map[string]oauth.Provider
+	// payments collects donations.
+	payments payments.Provider
+	// subscriptions settles subscription charges that were still
+	// processing when they were made, if set.
+	subscriptions *billing.Scheduler
+
+	// emailLockout and ipLockout throttle failed logins per account and
+	// per client address.
+	emailLockout *lockout.Limiter
+	ipLockout    *lockout.Limiter
 }
 
-func NewServer(store *db.Store, tokenMaker token.Maker, accessTokenDuration, refreshTokenDuration time.Duration) *Server {
+func NewServer(cfg config.Config, store db.Store, tokenMaker token.Maker, mailer mail.EmailSender, loginAttempts lockout.Store, passwordHasher util.PasswordHasher, passwordPolicy *util.PasswordPolicy, oauthProviders map[string]oauth.Provider, paymentProvider payments.Provider, subscriptions *billing.Scheduler) *Server {
 	r := gin.Default()
+	revocations := newRevocationCache(store)
 	s := &Server{
-		router:               r,
-		store:                store,
-		tokenMaker:           tokenMaker,
-		accessTokenDuration:  accessTokenDuration,
-		refreshTokenDuration: refreshTokenDuration,
+		config:      cfg,
+		router:      r,
+		store:       store,
+		tokenMaker:  token.NewRevocationMaker(tokenMaker, revocations),
+		revocations: revocations,
+		mailer:      mailer,
+		emailLockout: lockout.NewLimiter(loginAttempts, lockout.Policy{
+			MaxFailures: cfg.LoginMaxFailuresPerEmail,
+			BaseLockout: cfg.LoginLockoutBase,
+			MaxLockout:  cfg.LoginLockoutMax,
+			Window:      cfg.LoginFailureWindow,
+		}),
+		ipLockout: lockout.NewLimiter(loginAttempts, lockout.Policy{
+			MaxFailures: cfg.LoginMaxFailuresPerIP,
+			BaseLockout: cfg.LoginLockoutBase,
+			MaxLockout:  cfg.LoginLockoutMax,
+			Window:      cfg.LoginFailureWindow,
+		}),
+		passwordHasher: passwordHasher,
+		passwordPolicy: passwordPolicy,
+		oauthProviders: oauthProviders,
+		payments:       paymentProvider,
+		subscriptions:  subscriptions,
+	}
+
+	if provider, ok := tokenMaker.(token.PublicKeyProvider); ok {
+		s.publicKeys = provider
 	}
 
+	dummyPasswordHash, err := util.HashPassword(s.passwordHasher, "dummy-password")
+	if err != nil {
+		log.Printf("failed to hash dummy password: %v", err)
+	}
+	s.dummyPasswordHash = dummyPasswordHash
+
 	s.registerRoutes()
 
 	return s
 }
 
-func (s *Server) Start(address string) error {
-	log.Printf("starting HTTP server on %s", address)
-	return s.router.Run(address)
+// shutdownTimeout bounds how long in-flight requests may take to finish
+// once the server is stopped.
+const shutdownTimeout = 30 * time.Second
+
+// Start serves HTTP on address until ctx is canceled, then waits for
+// in-flight requests to finish.
+func (s *Server) Start(ctx context.Context, address string) error {
+	go s.revocations.run(ctx, s.config.RevocationSyncInterval)
+	go pruneIdempotencyKeys(ctx, s.store, s.config.IdempotencyKeyPruneInterval)
+
+	server := &http.Server{Addr: address, Handler: s.router}
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("starting HTTP server on %s", address)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// idempotent lets clients safely retry a mutating request by sending an
+// Idempotency-Key header.
+func (s *Server) idempotent() gin.HandlerFunc {
+	return idempotencyMiddleware(s.store, s.config.IdempotencyKeyTTL)
 }
 
 func (s *Server) registerRoutes() {
@@ -47,22 +136,74 @@ func (s *Server) registerRoutes() {
 		})
 	})
 
-	donations := s.router.Group("/donations")
-	donations.POST("", s.createDonation)
-	donations.GET(":id", s.getDonation)
-	donations.GET("by_goal/:goal_id", s.listDonationsByGoal)
-	donations.GET("by_user/:user_id", s.listDonationsByUser)
-
-	goals := s.router.Group("/goals")
-	goals.POST("", s.createGoal)
-	goals.GET("", s.listGoals)
-	goals.GET(":id", s.getGoal)
-	goals.PATCH(":id", s.updateGoal)
-
-	users := s.router.Group("/users")
-	users.POST("", s.createUser)
-	users.POST("/login", s.loginUser)
-	users.GET("", s.listUsers)
-	users.GET(":id", s.getUser)
-	users.GET("/by-email", s.getUserByEmail)
+	if s.publicKeys != nil {
+		s.router.GET("/.well-known/jwks.json", s.getPublicKeys)
+	}
+
+	// public routes
+	s.router.POST("/users", s.createUser)
+	s.router.POST("/users/login", s.loginUser)
+	s.router.POST("/users/login/mfa", s.loginUserMFA)
+	s.router.GET("/users/verify-email", s.verifyEmail)
+	s.router.POST("/users/password-reset/request", s.requestPasswordReset)
+	s.router.POST("/users/password-reset/confirm", s.confirmPasswordReset)
+	s.router.POST("/tokens/renew", s.renewAccessToken)
+	s.router.GET("/oauth/:provider/login", s.oauthLogin)
+	s.router.GET("/oauth/:provider/callback", s.oauthCallback)
+	s.router.POST("/webhooks/payments", s.receivePaymentWebhook)
+
+	s.router.GET("/goals", s.listGoals)
+	s.router.GET("/goals/:id", s.getGoal)
+
+	s.router.POST("/donations", optionalAuthMiddleware(s.tokenMaker, s.store, true), s.idempotent(), s.createDonation)
+	s.router.POST("/donations/:id/confirm", optionalAuthMiddleware(s.tokenMaker, s.store, true), s.confirmDonation)
+	s.router.GET("/donations/:id", s.getDonation)
+	s.router.GET("/donations/by_goal/:goal_id", s.listDonationsByGoal)
+
+	// routes that accept an access token or a scoped API key
+	keyRoutes := s.router.Group("/").Use(authMiddleware(s.tokenMaker, s.store, true))
+
+	keyRoutes.GET("/donations/by_user/:user_id", requireScope(util.DonationsReadScope), s.listDonationsByUser)
+	keyRoutes.POST("/donations/:id/refunds", requireScope(util.DonationsWriteScope), s.idempotent(), s.createRefund)
+	keyRoutes.GET("/donations/:id/refunds", requireScope(util.DonationsReadScope), s.listRefunds)
+
+	keyRoutes.POST("/goals", authorize(util.AdminRole, util.GoalManagerRole), requireScope(util.GoalsWriteScope), s.idempotent(), s.createGoal)
+	keyRoutes.PATCH("/goals/:id", authorize(util.AdminRole, util.GoalManagerRole), requireScope(util.GoalsWriteScope), s.idempotent(), s.updateGoal)
+
+	// routes that require a valid access token
+	authRoutes := s.router.Group("/").Use(authMiddleware(s.tokenMaker, s.store, false))
+
+	authRoutes.POST("/api-keys", s.createAPIKey)
+	authRoutes.GET("/api-keys", s.listAPIKeys)
+	authRoutes.DELETE("/api-keys/:id", s.revokeAPIKey)
+	authRoutes.POST("/organizations", authorize(util.AdminRole), s.idempotent(), s.createOrganization)
+	authRoutes.GET("/organizations", authorize(util.AdminRole), s.listOrganizations)
+
+	authRoutes.POST("/users/logout", s.logoutUser)
+	authRoutes.POST("/users/logout-all", s.logoutAllSessions)
+	authRoutes.POST("/users/verify-email/resend", s.resendVerifyEmail)
+	authRoutes.GET("/users/me", s.getCurrentUser)
+	authRoutes.PATCH("/users/me", s.updateCurrentUser)
+	authRoutes.PUT("/users/me/password", s.changePassword)
+	authRoutes.POST("/users/me/mfa/totp", authorize(util.AdminRole, util.GoalManagerRole), s.enrollTOTP)
+	authRoutes.POST("/users/me/mfa/totp/confirm", authorize(util.AdminRole, util.GoalManagerRole), s.confirmTOTP)
+	authRoutes.DELETE("/users/me/mfa/totp", s.disableTOTP)
+	authRoutes.GET("/users", authorize(util.AdminRole), s.listUsers)
+	authRoutes.GET("/users/:id", s.getUser)
+	authRoutes.GET("/users/by-email", authorize(util.AdminRole), s.getUserByEmail)
+	authRoutes.PATCH("/users/:id/role", authorize(util.AdminRole), s.updateUserRole)
+	authRoutes.POST("/users/:id/unlock", authorize(util.AdminRole), s.unlockUser)
+	authRoutes.POST("/goals/:id/payouts", authorize(util.AdminRole), s.idempotent(), s.createPayout)
+	authRoutes.GET("/goals/:id/payouts", authorize(util.AdminRole), s.listPayouts)
+	authRoutes.GET("/ledger/reconciliation", authorize(util.AdminRole), s.reconcileLedger)
+	authRoutes.POST("/subscriptions", s.idempotent(), s.createSubscription)
+	authRoutes.GET("/subscriptions", s.listSubscriptions)
+	authRoutes.POST("/subscriptions/:id/pause", s.pauseSubscription)
+	authRoutes.POST("/subscriptions/:id/resume", s.resumeSubscription)
+	authRoutes.POST("/subscriptions/:id/cancel", s.cancelSubscription)
+	authRoutes.GET("/payment-events", authorize(util.AdminRole), s.listPaymentEvents)
+	authRoutes.POST("/payment-events/:id/replay", authorize(util.AdminRole), s.replayPaymentEvent)
+
+	// process metrics, including database transaction retries
+	authRoutes.GET("/debug/vars", authorize(util.AdminRole), gin.WrapH(expvar.Handler()))
 }
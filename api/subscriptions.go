@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// createSubscription sets up a recurring donation. The first charge is
+// made by the subscription scheduler on its next run.
+func (s *Server) createSubscription(c *gin.Context) {
+	var req createSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateCreateSubscriptionRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	donor, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	// unverified accounts may only make small donations
+	if !donor.IsEmailVerified && req.Amount > s.config.UnverifiedDonationLimit {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": fmt.Sprintf("verify your email to donate more than %d", s.config.UnverifiedDonationLimit),
+		})
+		return
+	}
+
+	if _, err := s.store.GetGoal(c.Request.Context(), req.GoalID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+			return
+		}
+		log.Printf("createSubscription error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create subscription"})
+		return
+	}
+
+	subscription, err := s.store.CreateSubscription(c.Request.Context(), db.CreateSubscriptionParams{
+		UserID:        donor.ID,
+		GoalID:        req.GoalID,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		Interval:      req.Interval,
+		PaymentMethod: req.PaymentMethod,
+		NextChargeAt:  time.Now(),
+	})
+	if err != nil {
+		log.Printf("createSubscription error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+func (s *Server) listSubscriptions(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+	limit64, err := strconv.ParseInt(limitStr, 10, 32)
+	if err != nil || limit64 <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset64, err := strconv.ParseInt(offsetStr, 10, 32)
+	if err != nil || offset64 < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	subscriptions, err := s.store.ListSubscriptionsByUser(c.Request.Context(), db.ListSubscriptionsByUserParams{
+		UserID: authPrincipal(c).UserID,
+		Limit:  int32(limit64),
+		Offset: int32(offset64),
+	})
+	if err != nil {
+		log.Printf("listSubscriptions error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (s *Server) pauseSubscription(c *gin.Context) {
+	s.changeSubscription(c, "pauseSubscription", func(subscription db.Subscription) (db.Subscription, error) {
+		return s.store.PauseSubscription(c.Request.Context(), subscription.ID)
+	})
+}
+
+func (s *Server) resumeSubscription(c *gin.Context) {
+	s.changeSubscription(c, "resumeSubscription", func(subscription db.Subscription) (db.Subscription, error) {
+		return s.store.ResumeSubscription(c.Request.Context(), db.ResumeSubscriptionParams{
+			ID:  subscription.ID,
+			Now: time.Now(),
+		})
+	})
+}
+
+func (s *Server) cancelSubscription(c *gin.Context) {
+	s.changeSubscription(c, "cancelSubscription", func(subscription db.Subscription) (db.Subscription, error) {
+		return s.store.CancelSubscription(c.Request.Context(), subscription.ID)
+	})
+}
+
+// changeSubscription loads the subscription named in the URL and applies a
+// status change to it. The change returns pgx.ErrNoRows when the
+// subscription's status does not allow it.
+func (s *Server) changeSubscription(c *gin.Context, name string, change func(db.Subscription) (db.Subscription, error)) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription id"})
+		return
+	}
+
+	subscription, err := s.store.GetSubscription(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
+		log.Printf("%s error: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update subscription"})
+		return
+	}
+
+	// do not reveal other donors' subscriptions
+	p := authPrincipal(c)
+	if p.Role != util.AdminRole && subscription.UserID != p.UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+
+	changed, err := change(subscription)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "subscription is " + subscription.Status})
+			return
+		}
+		log.Printf("%s error: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, changed)
+}
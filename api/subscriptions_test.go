@@ -0,0 +1,243 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/payments"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateSubscription(t *testing.T) {
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	unverified := randomUser(t, util.DonorRole, "secret-password")
+	unverified.IsEmailVerified = false
+	goal := randomGoal()
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"goal_id":        goal.ID,
+				"amount":         1000,
+				"currency":       "USD",
+				"interval":       db.SubscriptionMonthly,
+				"payment_method": payments.FakeCardSucceeds,
+			},
+			user: donor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(goal, nil)
+				store.EXPECT().
+					CreateSubscription(gomock.Any(), gomock.Cond(func(arg db.CreateSubscriptionParams) bool {
+						return arg.UserID == donor.ID && arg.GoalID == goal.ID && arg.Amount == 1000 &&
+							arg.Interval == db.SubscriptionMonthly && arg.PaymentMethod == payments.FakeCardSucceeds &&
+							time.Since(arg.NextChargeAt) < time.Minute
+					})).
+					Times(1).
+					Return(db.Subscription{ID: 1, UserID: donor.ID, Status: db.SubscriptionActive}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "InvalidInterval",
+			body: gin.H{
+				"goal_id":        goal.ID,
+				"amount":         1000,
+				"currency":       "USD",
+				"interval":       "daily",
+				"payment_method": payments.FakeCardSucceeds,
+			},
+			user: donor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "UnverifiedOverLimit",
+			body: gin.H{
+				"goal_id":        goal.ID,
+				"amount":         20000,
+				"currency":       "USD",
+				"interval":       db.SubscriptionWeekly,
+				"payment_method": payments.FakeCardSucceeds,
+			},
+			user: unverified,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), unverified.ID).Times(1).Return(unverified, nil)
+				store.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name: "GoalNotFound",
+			body: gin.H{
+				"goal_id":        goal.ID,
+				"amount":         1000,
+				"currency":       "USD",
+				"interval":       db.SubscriptionMonthly,
+				"payment_method": payments.FakeCardSucceeds,
+			},
+			user: donor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), donor.ID).Times(1).Return(donor, nil)
+				store.EXPECT().GetGoal(gomock.Any(), goal.ID).Times(1).Return(db.Goal{}, pgx.ErrNoRows)
+				store.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, donor, unverified)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/subscriptions", tc.body)
+			addAuthorization(t, request, server.tokenMaker, tc.user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestChangeSubscription(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	other := randomUser(t, util.DonorRole, "secret-password")
+	other.ID = donor.ID + 1
+
+	subscription := db.Subscription{
+		ID:       3,
+		UserID:   donor.ID,
+		GoalID:   5,
+		Amount:   1000,
+		Currency: "USD",
+		Interval: db.SubscriptionMonthly,
+		Status:   db.SubscriptionActive,
+	}
+
+	testCases := []struct {
+		name          string
+		action        string
+		user          db.User
+		status        string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Pause",
+			action: "pause",
+			user:   donor,
+			status: db.SubscriptionActive,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PauseSubscription(gomock.Any(), subscription.ID).Times(1).Return(db.Subscription{ID: subscription.ID, Status: db.SubscriptionPaused}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "Resume",
+			action: "resume",
+			user:   donor,
+			status: db.SubscriptionPaused,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResumeSubscription(gomock.Any(), gomock.Cond(func(arg db.ResumeSubscriptionParams) bool {
+						return arg.ID == subscription.ID && time.Since(arg.Now) < time.Minute
+					})).
+					Times(1).
+					Return(db.Subscription{ID: subscription.ID, Status: db.SubscriptionActive}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "ResumeActive",
+			action: "resume",
+			user:   donor,
+			status: db.SubscriptionActive,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResumeSubscription(gomock.Any(), gomock.Any()).Times(1).Return(db.Subscription{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+			},
+		},
+		{
+			name:   "AdminCancel",
+			action: "cancel",
+			user:   admin,
+			status: db.SubscriptionPastDue,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CancelSubscription(gomock.Any(), subscription.ID).Times(1).Return(db.Subscription{ID: subscription.ID, Status: db.SubscriptionCanceled}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "OtherDonor",
+			action: "cancel",
+			user:   other,
+			status: db.SubscriptionActive,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CancelSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+
+			current := subscription
+			current.Status = tc.status
+			store.EXPECT().GetSubscription(gomock.Any(), subscription.ID).Times(1).Return(current, nil)
+			tc.buildStubs(store)
+			stubUserRoles(store, admin, donor, other)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/subscriptions/%d/%s", subscription.ID, tc.action)
+			request := newJSONRequest(t, http.MethodPost, url, nil)
+			addAuthorization(t, request, server.tokenMaker, tc.user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/token"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Server) renewAccessToken(c *gin.Context) {
+	var req renewAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateRenewAccessTokenRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	refreshPayload, err := s.tokenMaker.VerifyToken(req.RefreshToken, token.TokenTypeRefreshToken)
+	if err != nil {
+		if errors.Is(err, token.ErrExpiredToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has expired", "code": authErrExpiredToken})
+			return
+		}
+		if errors.Is(err, token.ErrRevokedToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has been revoked", "code": authErrRevokedToken})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token is invalid", "code": authErrInvalidToken})
+		return
+	}
+
+	// version 1 refresh tokens carry no session ID: their session was keyed
+	// by the token ID
+	sessionID := refreshPayload.SessionID
+	if refreshPayload.Version < token.PayloadVersion {
+		sessionID = refreshPayload.ID
+	}
+
+	session, err := s.store.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found", "code": authErrInvalidToken})
+			return
+		}
+		log.Printf("renewAccessToken get session error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to renew access token"})
+		return
+	}
+
+	if session.IsBlocked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session is blocked", "code": authErrInvalidToken})
+		return
+	}
+	// version 1 tokens escaped the user-wide revocation check in
+	// VerifyToken, having no user ID
+	if refreshPayload.Version < token.PayloadVersion && s.revocations.revokedForUser(session.UserID, refreshPayload.IssuedAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has been revoked", "code": authErrRevokedToken})
+		return
+	}
+	if session.RefreshToken != req.RefreshToken {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "mismatched session token", "code": authErrInvalidToken})
+		return
+	}
+	if session.RotatedAt.Valid {
+		// A rotated refresh token is being presented again: either the client
+		// is buggy or the token was stolen. Revoke every session descending
+		// from the same login.
+		s.blockSessionFamily(c, session.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has already been used", "code": authErrInvalidToken})
+		return
+	}
+	if time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session has expired", "code": authErrExpiredToken})
+		return
+	}
+
+	user, err := s.store.GetUser(ctx, session.UserID)
+	if err != nil {
+		log.Printf("renewAccessToken get user error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to renew access token"})
+		return
+	}
+
+	newSessionID, err := uuid.NewRandom()
+	if err != nil {
+		log.Printf("renewAccessToken session id error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to renew access token"})
+		return
+	}
+
+	accessToken, accessPayload, err := s.tokenMaker.CreateToken(user.ID, user.Email, user.Role, newSessionID, s.config.AccessTokenDuration, token.TokenTypeAccessToken)
+	if err != nil {
+		log.Printf("renewAccessToken create access token error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create access token"})
+		return
+	}
+
+	refreshToken, newRefreshPayload, err := s.tokenMaker.CreateToken(user.ID, user.Email, user.Role, newSessionID, s.config.RefreshTokenDuration, token.TokenTypeRefreshToken)
+	if err != nil {
+		log.Printf("renewAccessToken create refresh token error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create refresh token"})
+		return
+	}
+
+	_, err = s.store.RenewSessionTx(ctx, db.RenewSessionTxParams{
+		SessionID: session.ID,
+		NewSession: db.CreateSessionParams{
+			ID:           newSessionID,
+			UserID:       session.UserID,
+			FamilyID:     session.FamilyID,
+			RefreshToken: refreshToken,
+			UserAgent:    c.Request.UserAgent(),
+			ClientIp:     c.ClientIP(),
+			IsBlocked:    false,
+			ExpiresAt:    newRefreshPayload.ExpiredAt,
+		},
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrSessionReused) {
+			s.blockSessionFamily(c, session.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has already been used", "code": authErrInvalidToken})
+			return
+		}
+		log.Printf("renewAccessToken rotate session error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to renew access token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":               newSessionID,
+		"access_token":             accessToken,
+		"access_token_expires_at":  accessPayload.ExpiredAt,
+		"refresh_token":            refreshToken,
+		"refresh_token_expires_at": newRefreshPayload.ExpiredAt,
+	})
+}
+
+// blockSessionFamily revokes every session rotated from the same login.
+// Failures are only logged: the caller rejects the request either way.
+func (s *Server) blockSessionFamily(c *gin.Context, familyID uuid.UUID) {
+	if err := s.store.BlockSessionFamily(c.Request.Context(), familyID); err != nil {
+		log.Printf("block session family %s error: %v", familyID, err)
+	}
+}
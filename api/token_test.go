@@ -0,0 +1,232 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRenewAccessToken(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+	sessionID := uuid.New()
+	familyID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		buildSession  func(session *db.Session)
+		buildStubs    func(store *mockdb.MockStore, session db.Session)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "OK",
+			buildSession: func(session *db.Session) {},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+				store.EXPECT().
+					RenewSessionTx(gomock.Any(), gomock.Cond(func(arg db.RenewSessionTxParams) bool {
+						return arg.SessionID == session.ID && arg.NewSession.ID != session.ID &&
+							arg.NewSession.UserID == user.ID && arg.NewSession.FamilyID == familyID &&
+							arg.NewSession.RefreshToken != session.RefreshToken
+					})).
+					Times(1).
+					Return(db.RenewSessionTxResult{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:         "SessionNotFound",
+			buildSession: func(session *db.Session) {},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().GetSession(gomock.Any(), session.ID).Times(1).Return(db.Session{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name: "BlockedSession",
+			buildSession: func(session *db.Session) {
+				session.IsBlocked = true
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name: "MismatchedToken",
+			buildSession: func(session *db.Session) {
+				session.RefreshToken = "another-token"
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name: "ReusedToken",
+			buildSession: func(session *db.Session) {
+				session.RotatedAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().BlockSessionFamily(gomock.Any(), familyID).Times(1).Return(nil)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name:         "ConcurrentReuse",
+			buildSession: func(session *db.Session) {},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(1).Return(db.RenewSessionTxResult{}, db.ErrSessionReused)
+				store.EXPECT().BlockSessionFamily(gomock.Any(), familyID).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrInvalidToken)
+			},
+		},
+		{
+			name: "ExpiredSession",
+			buildSession: func(session *db.Session) {
+				session.ExpiresAt = time.Now().Add(-time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireAuthErrorCode(t, recorder, authErrExpiredToken)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			refreshToken, payload, err := server.tokenMaker.CreateToken(user.ID, user.Email, user.Role, sessionID, time.Hour, token.TokenTypeRefreshToken)
+			if err != nil {
+				t.Fatalf("failed to create refresh token: %v", err)
+			}
+			session := db.Session{
+				ID:           sessionID,
+				UserID:       user.ID,
+				FamilyID:     familyID,
+				RefreshToken: refreshToken,
+				ExpiresAt:    payload.ExpiredAt,
+			}
+			tc.buildSession(&session)
+
+			tc.buildStubs(store, session)
+			store.EXPECT().GetSession(gomock.Any(), sessionID).AnyTimes().Return(session, nil)
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/tokens/renew", gin.H{"refresh_token": refreshToken})
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// v1TokenMaker accepts any refresh token as the given version 1 payload,
+// which carries no user ID.
+type v1TokenMaker struct {
+	token.Maker
+	payload *token.Payload
+}
+
+func (maker v1TokenMaker) VerifyToken(string, token.TokenType) (*token.Payload, error) {
+	return maker.payload, nil
+}
+
+func TestRenewAccessTokenV1UserRevocation(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+	const refreshToken = "v1-refresh-token"
+	issuedAt := time.Now().Add(-time.Hour)
+
+	payload := &token.Payload{
+		ID:        uuid.New(),
+		Version:   1,
+		Type:      token.TokenTypeRefreshToken,
+		Name:      user.Email,
+		IssuedAt:  issuedAt,
+		ExpiredAt: issuedAt.Add(24 * time.Hour),
+	}
+	session := db.Session{
+		ID:           payload.ID,
+		UserID:       user.ID,
+		FamilyID:     payload.ID,
+		RefreshToken: refreshToken,
+		ExpiresAt:    payload.ExpiredAt,
+	}
+
+	testCases := []struct {
+		name       string
+		revokedAt  time.Time
+		buildStubs func(store *mockdb.MockStore)
+		wantStatus int
+	}{
+		{
+			name:      "RevokedAfterIssue",
+			revokedAt: time.Now(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "RevokedBeforeIssue",
+			revokedAt: issuedAt.Add(-time.Hour),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(1).Return(db.RenewSessionTxResult{}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetSession(gomock.Any(), session.ID).Times(1).Return(session, nil)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.tokenMaker = token.NewRevocationMaker(v1TokenMaker{Maker: server.tokenMaker, payload: payload}, server.revocations)
+			server.revocations.add(db.TokenRevocation{
+				UserID:    user.ID,
+				RevokedAt: tc.revokedAt,
+				ExpiresAt: time.Now().Add(24 * time.Hour),
+			})
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/tokens/renew", gin.H{"refresh_token": refreshToken})
+			server.router.ServeHTTP(recorder, request)
+			requireStatus(t, recorder.Code, tc.wantStatus, recorder.Body)
+		})
+	}
+}
@@ -12,16 +12,19 @@ import (
 	"charity/util"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type userResponse struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email"`
-	Name      *string   `json:"name,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              int64     `json:"id"`
+	Email           string    `json:"email"`
+	Name            *string   `json:"name,omitempty"`
+	Role            string    `json:"role"`
+	IsEmailVerified bool      `json:"is_email_verified"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 func newUserResponse(user db.User) userResponse {
@@ -32,13 +35,31 @@ func newUserResponse(user db.User) userResponse {
 	}
 
 	return userResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Name:      namePtr,
-		CreatedAt: user.CreatedAt,
+		ID:              user.ID,
+		Email:           user.Email,
+		Name:            namePtr,
+		Role:            user.Role,
+		IsEmailVerified: user.IsEmailVerified,
+		CreatedAt:       user.CreatedAt,
 	}
 }
 
+// currentUser loads the user the access token was issued to. On failure it
+// writes the error response and returns false.
+func (s *Server) currentUser(c *gin.Context) (db.User, bool) {
+	user, err := s.store.GetUser(c.Request.Context(), authPrincipal(c).UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user no longer exists", "code": authErrInvalidToken})
+			return db.User{}, false
+		}
+		log.Printf("currentUser error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return db.User{}, false
+	}
+	return user, true
+}
+
 func (s *Server) createUser(c *gin.Context) {
 	var req createUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -50,13 +71,25 @@ func (s *Server) createUser(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := util.HashPassword(req.Password)
+	if err := s.passwordPolicy.Validate(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(s.passwordHasher, req.Password)
 	if err != nil {
 		log.Printf("createUser hash error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process password"})
 		return
 	}
 
+	code, err := util.RandomToken(verifyEmailCodeBytes)
+	if err != nil {
+		log.Printf("createUser verification code error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		return
+	}
+
 	params := db.CreateUserParams{
 		Email: req.Email,
 		Name: pgtype.Text{
@@ -71,7 +104,11 @@ func (s *Server) createUser(c *gin.Context) {
 		params.Name.String = *req.Name
 	}
 
-	user, err := s.store.CreateUser(c.Request.Context(), params)
+	result, err := s.store.CreateUserTx(c.Request.Context(), db.CreateUserTxParams{
+		CreateUserParams:     params,
+		SecretCode:           code,
+		VerifyEmailExpiresAt: time.Now().Add(s.config.VerifyEmailDuration),
+	})
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
@@ -83,7 +120,13 @@ func (s *Server) createUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, newUserResponse(user))
+	// The account exists either way; a failed delivery can be retried
+	// through the resend endpoint.
+	if err := s.sendVerifyEmail(result.User, result.VerifyEmail); err != nil {
+		log.Printf("createUser send verification email error: %v", err)
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(result.User))
 }
 
 func (s *Server) getUser(c *gin.Context) {
@@ -105,6 +148,12 @@ func (s *Server) getUser(c *gin.Context) {
 		return
 	}
 
+	p := authPrincipal(c)
+	if p.Role != util.AdminRole && p.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
 	c.JSON(http.StatusOK, newUserResponse(user))
 }
 
@@ -162,6 +211,48 @@ func (s *Server) listUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, responses)
 }
 
+func (s *Server) updateUserRole(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req updateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateUpdateUserRoleRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// tokens carrying the old role are revoked; the user logs in again
+	now := time.Now()
+	result, err := s.store.UpdateUserRoleTx(c.Request.Context(), db.UpdateUserRoleTxParams{
+		UpdateUserRoleParams: db.UpdateUserRoleParams{
+			ID:   id,
+			Role: req.Role,
+		},
+		RevokedAt:           now,
+		RevocationExpiresAt: now.Add(max(s.config.AccessTokenDuration, s.config.RefreshTokenDuration)),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("updateUserRole error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user role"})
+		return
+	}
+	s.revocations.add(result.Revocation)
+
+	c.JSON(http.StatusOK, newUserResponse(result.User))
+}
+
 func (s *Server) loginUser(c *gin.Context) {
 	var req loginUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -173,44 +264,108 @@ func (s *Server) loginUser(c *gin.Context) {
 		return
 	}
 
+	attempt, ok := s.beginLogin(c, req.Email)
+	if !ok {
+		return
+	}
+
 	user, err := s.store.GetUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+			// do not reveal through timing that the account does not exist
+			_ = util.CheckPassword(req.Password, s.dummyPasswordHash)
+			s.rejectFailedLogin(c, attempt, "invalid email or password")
 			return
 		}
+		attempt.release(c)
 		log.Printf("loginUser get user error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
 		return
 	}
 
+	// users who signed up with an identity provider have no password
 	if !user.Password.Valid {
-		log.Printf("loginUser missing password for user %d", user.ID)
+		_ = util.CheckPassword(req.Password, s.dummyPasswordHash)
+		s.rejectFailedLogin(c, attempt, "invalid email or password")
+		return
+	}
+	if err := util.CheckPassword(req.Password, user.Password.String); err != nil {
+		s.rejectFailedLogin(c, attempt, "invalid email or password")
+		return
+	}
+	attempt.release(c)
+
+	if s.passwordHasher.NeedsRehash(user.Password.String) {
+		s.rehashPassword(c, user.ID, req.Password)
+	}
+
+	s.continueLogin(c, user)
+}
+
+// continueLogin proceeds with a login once the user has proven their
+// identity with a password or an identity provider. Users with TOTP enabled
+// still have to present a code.
+func (s *Server) continueLogin(c *gin.Context, user db.User) {
+	totp, err := s.store.GetUserTOTP(c.Request.Context(), user.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("login get totp error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
 		return
 	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		s.requireSecondFactor(c, user)
+		return
+	}
 
-	if err := util.CheckPassword(req.Password, user.Password.String); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+	s.completeLogin(c, user)
+}
+
+// completeLogin starts a new session for an authenticated user and responds
+// with its access and refresh tokens.
+func (s *Server) completeLogin(c *gin.Context, user db.User) {
+	if err := s.emailLockout.Reset(c.Request.Context(), emailLockoutKey(user.Email)); err != nil {
+		log.Printf("completeLogin reset lockout error: %v", err)
+	}
+
+	sessionID, err := uuid.NewRandom()
+	if err != nil {
+		log.Printf("completeLogin session id error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
 		return
 	}
 
-	const userRole = "user"
-	accessToken, accessPayload, err := s.tokenMaker.CreateToken(user.Email, userRole, s.accessTokenDuration, token.TokenTypeAccessToken)
+	accessToken, accessPayload, err := s.tokenMaker.CreateToken(user.ID, user.Email, user.Role, sessionID, s.config.AccessTokenDuration, token.TokenTypeAccessToken)
 	if err != nil {
-		log.Printf("loginUser create access token error: %v", err)
+		log.Printf("completeLogin create access token error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create access token"})
 		return
 	}
 
-	refreshToken, refreshPayload, err := s.tokenMaker.CreateToken(user.Email, userRole, s.refreshTokenDuration, token.TokenTypeRefreshToken)
+	refreshToken, refreshPayload, err := s.tokenMaker.CreateToken(user.ID, user.Email, user.Role, sessionID, s.config.RefreshTokenDuration, token.TokenTypeRefreshToken)
 	if err != nil {
-		log.Printf("loginUser create refresh token error: %v", err)
+		log.Printf("completeLogin create refresh token error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create refresh token"})
 		return
 	}
 
+	_, err = s.store.CreateSession(c.Request.Context(), db.CreateSessionParams{
+		ID:           sessionID,
+		UserID:       user.ID,
+		FamilyID:     sessionID,
+		RefreshToken: refreshToken,
+		UserAgent:    c.Request.UserAgent(),
+		ClientIp:     c.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		log.Printf("completeLogin create session error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
+		"session_id":               sessionID,
 		"user":                     newUserResponse(user),
 		"access_token":             accessToken,
 		"access_token_expires_at":  accessPayload.ExpiredAt,
@@ -218,3 +373,77 @@ func (s *Server) loginUser(c *gin.Context) {
 		"refresh_token_expires_at": refreshPayload.ExpiredAt,
 	})
 }
+
+// logoutUser ends the session the access token was issued for, together
+// with every session rotated from the same login.
+func (s *Server) logoutUser(c *gin.Context) {
+	payload := authPayload(c)
+
+	session, err := s.store.GetSession(c.Request.Context(), payload.SessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found", "code": authErrInvalidToken})
+			return
+		}
+		log.Printf("logoutUser get session error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+
+	result, err := s.store.LogoutTx(c.Request.Context(), db.LogoutTxParams{
+		UserID:    session.UserID,
+		FamilyID:  pgtype.UUID{Bytes: session.FamilyID, Valid: true},
+		TokenID:   pgtype.UUID{Bytes: payload.ID, Valid: true},
+		RevokedAt: time.Now(),
+		ExpiresAt: payload.ExpiredAt,
+	})
+	if err != nil {
+		log.Printf("logoutUser error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+	s.revocations.add(result.Revocation)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) logoutAllSessions(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	// every token issued so far expires within the longest token lifetime
+	now := time.Now()
+	result, err := s.store.LogoutTx(c.Request.Context(), db.LogoutTxParams{
+		UserID:    user.ID,
+		RevokedAt: now,
+		ExpiresAt: now.Add(max(s.config.AccessTokenDuration, s.config.RefreshTokenDuration)),
+	})
+	if err != nil {
+		log.Printf("logoutAllSessions error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+	s.revocations.add(result.Revocation)
+
+	c.Status(http.StatusNoContent)
+}
+
+// rehashPassword replaces a stored password hash made with an outdated
+// algorithm or cost. Failures are only logged: the old hash still works.
+func (s *Server) rehashPassword(c *gin.Context, userID int64, password string) {
+	hashedPassword, err := util.HashPassword(s.passwordHasher, password)
+	if err != nil {
+		log.Printf("rehash password for user %d error: %v", userID, err)
+		return
+	}
+
+	_, err = s.store.UpdateUserPassword(c.Request.Context(), db.UpdateUserPasswordParams{
+		ID:       userID,
+		Password: pgtype.Text{String: hashedPassword, Valid: true},
+	})
+	if err != nil {
+		log.Printf("rehash password for user %d error: %v", userID, err)
+	}
+}
@@ -0,0 +1,944 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/token"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+// eqCreateUserTxParams matches CreateUserTxParams whose password hash is a
+// hash of password, since the hash itself is salted.
+type eqCreateUserTxParams struct {
+	email    string
+	password string
+}
+
+func (e eqCreateUserTxParams) Matches(x any) bool {
+	arg, ok := x.(db.CreateUserTxParams)
+	if !ok {
+		return false
+	}
+	if arg.Email != e.email || arg.SecretCode == "" || !arg.Password.Valid {
+		return false
+	}
+	return util.CheckPassword(e.password, arg.Password.String) == nil
+}
+
+func (e eqCreateUserTxParams) String() string {
+	return fmt.Sprintf("matches email %s and password %s", e.email, e.password)
+}
+
+func TestCreateUser(t *testing.T) {
+	password := "secret-password"
+	user := randomUser(t, util.DonorRole, password)
+	user.IsEmailVerified = false
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"email": user.Email, "name": user.Name.String, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), eqCreateUserTxParams{email: user.Email, password: password}).
+					Times(1).
+					Return(db.CreateUserTxResult{User: user, VerifyEmail: db.VerifyEmail{ID: 1, UserID: user.ID, Email: user.Email}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(user))
+			},
+		},
+		{
+			name: "DuplicateEmail",
+			body: gin.H{"email": user.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateUserTxResult{}, &pgconn.PgError{Code: "23505"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+			},
+		},
+		{
+			name: "MissingEmail",
+			body: gin.H{"password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "ShortPassword",
+			body: gin.H{"email": user.Email, "password": "short"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InvalidBody",
+			body: gin.H{"email": 42},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"email": user.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateUserTxResult{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPost, "/users", tc.body)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+	other := randomUser(t, util.DonorRole, "secret-password")
+	other.ID = user.ID + 1
+	admin := randomUser(t, util.AdminRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		userID        any
+		caller        db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Self",
+			userID: user.ID,
+			caller: user,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(user))
+			},
+		},
+		{
+			name:   "Admin",
+			userID: user.ID,
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "OtherUser",
+			userID: other.ID,
+			caller: user,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), other.ID).Times(1).Return(other, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name:   "NotFound",
+			userID: user.ID,
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(db.User{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			userID: user.ID,
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(db.User{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidID",
+			userID: 0,
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, user, other, admin)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, fmt.Sprintf("/users/%v", tc.userID), nil)
+			addAuthorization(t, request, server.tokenMaker, tc.caller)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetUserByEmail(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+	admin := randomUser(t, util.AdminRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		query         string
+		caller        db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			query:  "email=" + user.Email,
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(user))
+			},
+		},
+		{
+			name:   "NotAdmin",
+			query:  "email=" + user.Email,
+			caller: user,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name:   "MissingEmail",
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "NotFound",
+			query:  "email=" + user.Email,
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(db.User{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			query:  "email=" + user.Email,
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(db.User{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, user, admin)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, "/users/by-email?"+tc.query, nil)
+			addAuthorization(t, request, server.tokenMaker, tc.caller)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	donor := randomUser(t, util.DonorRole, "secret-password")
+	users := []db.User{admin, donor}
+
+	testCases := []struct {
+		name          string
+		query         string
+		caller        db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			query:  "limit=2&offset=0",
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsers(gomock.Any(), db.ListUsersParams{Limit: 2, Offset: 0}).
+					Times(1).
+					Return(users, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, []userResponse{newUserResponse(admin), newUserResponse(donor)})
+			},
+		},
+		{
+			name:   "NotAdmin",
+			caller: donor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidLimit",
+			query:  "limit=-1",
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidOffset",
+			query:  "offset=x",
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsers(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, admin, donor)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodGet, "/users?"+tc.query, nil)
+			addAuthorization(t, request, server.tokenMaker, tc.caller)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	user := randomUser(t, util.DonorRole, "secret-password")
+	updated := user
+	updated.Role = util.GoalManagerRole
+
+	testCases := []struct {
+		name          string
+		userID        any
+		body          gin.H
+		caller        db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			userID: user.ID,
+			body:   gin.H{"role": util.GoalManagerRole},
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserRoleTx(gomock.Any(), gomock.Cond(func(arg db.UpdateUserRoleTxParams) bool {
+						return arg.ID == user.ID && arg.Role == util.GoalManagerRole &&
+							arg.RevocationExpiresAt.After(arg.RevokedAt)
+					})).
+					Times(1).
+					Return(db.UpdateUserRoleTxResult{User: updated}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(updated))
+			},
+		},
+		{
+			name:   "NotAdmin",
+			userID: user.ID,
+			body:   gin.H{"role": util.AdminRole},
+			caller: user,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+		{
+			name:   "UnsupportedRole",
+			userID: user.ID,
+			body:   gin.H{"role": "superuser"},
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "InvalidID",
+			userID: "abc",
+			body:   gin.H{"role": util.DonorRole},
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:   "NotFound",
+			userID: user.ID,
+			body:   gin.H{"role": util.DonorRole},
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserRoleTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserRoleTxResult{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "InternalError",
+			userID: user.ID,
+			body:   gin.H{"role": util.DonorRole},
+			caller: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserRoleTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserRoleTxResult{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, admin, user)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPatch, fmt.Sprintf("/users/%v/role", tc.userID), tc.body)
+			addAuthorization(t, request, server.tokenMaker, tc.caller)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestUpdateUserRoleRevokesTokens checks that tokens issued before a role
+// change stop working once it is made.
+func TestUpdateUserRoleRevokesTokens(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	user := randomUser(t, util.GoalManagerRole, "secret-password")
+	user.ID = admin.ID + 1
+	demoted := user
+	demoted.Role = util.DonorRole
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		UpdateUserRoleTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.UpdateUserRoleTxParams) (db.UpdateUserRoleTxResult, error) {
+			return db.UpdateUserRoleTxResult{
+				User: demoted,
+				Revocation: db.TokenRevocation{
+					ID:        1,
+					UserID:    arg.ID,
+					RevokedAt: arg.RevokedAt,
+					ExpiresAt: arg.RevocationExpiresAt,
+				},
+			}, nil
+		})
+	stubUserRoles(store, admin, demoted)
+
+	server := newTestServer(t, store)
+	userRequest := newJSONRequest(t, http.MethodGet, "/users/me", nil)
+	addAuthorization(t, userRequest, server.tokenMaker, user)
+
+	recorder := httptest.NewRecorder()
+	request := newJSONRequest(t, http.MethodPatch, fmt.Sprintf("/users/%d/role", user.ID), gin.H{"role": util.DonorRole})
+	addAuthorization(t, request, server.tokenMaker, admin)
+	server.router.ServeHTTP(recorder, request)
+	requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, userRequest)
+	requireAuthErrorCode(t, recorder, authErrRevokedToken)
+}
+
+func TestLoginUser(t *testing.T) {
+	password := "secret-password"
+	user := randomUser(t, util.DonorRole, password)
+	socialUser := randomUser(t, util.DonorRole, password)
+	socialUser.Password = pgtype.Text{}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"email": user.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateSessionParams) (db.Session, error) {
+						if arg.UserID != user.ID || arg.ID != arg.FamilyID {
+							t.Errorf("unexpected session params: %+v", arg)
+						}
+						return db.Session{ID: arg.ID, UserID: arg.UserID, FamilyID: arg.FamilyID}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "MFARequired",
+			body: gin.H{"email": user.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), user.ID).
+					Times(1).
+					Return(db.UserTotp{UserID: user.ID, ConfirmedAt: pgtype.Timestamptz{Valid: true}}, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+
+				var rsp struct {
+					MFARequired bool   `json:"mfa_required"`
+					MFAToken    string `json:"mfa_token"`
+					AccessToken string `json:"access_token"`
+				}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &rsp); err != nil {
+					t.Fatalf("failed to decode response body: %v", err)
+				}
+				if !rsp.MFARequired || rsp.MFAToken == "" || rsp.AccessToken != "" {
+					t.Fatalf("expected an mfa token instead of access tokens, got %s", recorder.Body.String())
+				}
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: gin.H{"email": user.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "WrongPassword",
+			body: gin.H{"email": user.Email, "password": "wrong-password"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "NoPassword",
+			body: gin.H{"email": socialUser.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), socialUser.Email).Times(1).Return(socialUser, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "MissingPassword",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"email": user.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), user.Email).
+					Times(1).
+					Return(db.User{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name: "CreateSessionError",
+			body: gin.H{"email": user.Email, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPost, "/users/login", tc.body)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserRehashesPassword(t *testing.T) {
+	password := "secret-password"
+	user := randomUser(t, util.DonorRole, password)
+
+	testCases := []struct {
+		name       string
+		hasher     util.PasswordHasher
+		wantRehash bool
+	}{
+		{
+			name:       "SameParams",
+			hasher:     testPasswordHasher,
+			wantRehash: false,
+		},
+		{
+			name:       "CostChanged",
+			hasher:     util.BcryptHasher{Cost: testPasswordHasher.Cost + 1},
+			wantRehash: true,
+		},
+		{
+			name:       "AlgorithmChanged",
+			hasher:     util.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+			wantRehash: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+			store.EXPECT().GetUserTOTP(gomock.Any(), user.ID).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+			store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{UserID: user.ID}, nil)
+
+			times := 0
+			if tc.wantRehash {
+				times = 1
+			}
+			store.EXPECT().
+				UpdateUserPassword(gomock.Any(), gomock.Cond(func(arg db.UpdateUserPasswordParams) bool {
+					return arg.ID == user.ID &&
+						!tc.hasher.NeedsRehash(arg.Password.String) &&
+						util.CheckPassword(password, arg.Password.String) == nil
+				})).
+				Times(times).
+				Return(user, nil)
+
+			server := newTestServer(t, store)
+			server.passwordHasher = tc.hasher
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/users/login", gin.H{"email": user.Email, "password": password})
+			server.router.ServeHTTP(recorder, request)
+			requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+		})
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, user)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{UserID: user.ID}, nil)
+				store.EXPECT().
+					LogoutTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LogoutTxResult{Revocation: db.TokenRevocation{UserID: user.ID}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNoContent, recorder.Body)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().LogoutTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "SessionNotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, user)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{}, pgx.ErrNoRows)
+				store.EXPECT().LogoutTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, user)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{UserID: user.ID}, nil)
+				store.EXPECT().
+					LogoutTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LogoutTxResult{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, user)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPost, "/users/logout", nil)
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLogoutAllSessions(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+				store.EXPECT().
+					LogoutTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.LogoutTxParams) (db.LogoutTxResult, error) {
+						if arg.UserID != user.ID || arg.FamilyID.Valid || arg.TokenID.Valid {
+							t.Errorf("unexpected logout params: %+v", arg)
+						}
+						return db.LogoutTxResult{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNoContent, recorder.Body)
+			},
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().LogoutTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusUnauthorized, recorder.Body)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.ID).Times(1).Return(user, nil)
+				store.EXPECT().
+					LogoutTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LogoutTxResult{}, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, user)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newJSONRequest(t, http.MethodPost, "/users/logout-all", nil)
+			addAuthorization(t, request, server.tokenMaker, user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -1,9 +1,33 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/util"
+)
 
 const minDonationAmount = 100
 
+const maxRefundNoteLength = 500
+
+// normalizeCurrency upper-cases an ISO 4217 currency code in place. Payment
+// providers report currencies upper-case, and ledger account names include
+// the currency, so the stored code must not depend on how the client sent it.
+func normalizeCurrency(currency *string) error {
+	if *currency == "" {
+		return fmt.Errorf("currency is required")
+	}
+	code := strings.ToUpper(*currency)
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fmt.Errorf("currency must be a 3-letter ISO 4217 code")
+	}
+	*currency = code
+	return nil
+}
+
 type createGoalRequest struct {
 	Title        string  `json:"title"`
 	Description  *string `json:"description"`
@@ -18,13 +42,28 @@ type updateGoalRequest struct {
 }
 
 type createDonationRequest struct {
-	UserID      int64  `json:"user_id"`
+	// UserID is optional; when sent it must match the authenticated donor.
+	UserID      *int64 `json:"user_id"`
 	GoalID      int64  `json:"goal_id"`
 	Amount      int64  `json:"amount"`
 	Currency    string `json:"currency"`
 	IsAnonymous bool   `json:"is_anonymous"`
 }
 
+type confirmDonationRequest struct {
+	// PaymentMethod is the provider's ID for the donor's card or account.
+	PaymentMethod string `json:"payment_method"`
+	// ConfirmationToken authorizes confirming a donation without a donor.
+	ConfirmationToken string `json:"confirmation_token"`
+}
+
+func validateConfirmDonationRequest(req confirmDonationRequest) error {
+	if req.PaymentMethod == "" {
+		return fmt.Errorf("payment_method is required")
+	}
+	return nil
+}
+
 type createUserRequest struct {
 	Email    string  `json:"email"`
 	Name     *string `json:"name"`
@@ -41,6 +80,85 @@ func validateCreateUserRequest(req createUserRequest) error {
 	return nil
 }
 
+type loginMFARequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func validateLoginMFARequest(req loginMFARequest) error {
+	if req.MFAToken == "" {
+		return fmt.Errorf("mfa_token is required")
+	}
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		return fmt.Errorf("exactly one of code or recovery_code is required")
+	}
+	return nil
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+func validateConfirmTOTPRequest(req confirmTOTPRequest) error {
+	if req.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	return nil
+}
+
+type disableTOTPRequest struct {
+	Password string `json:"password"`
+}
+
+func validateDisableTOTPRequest(req disableTOTPRequest) error {
+	if req.Password == "" {
+		return fmt.Errorf("password is required")
+	}
+	return nil
+}
+
+type updateCurrentUserRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+func validateUpdateCurrentUserRequest(req updateCurrentUserRequest) error {
+	if req.Name == nil && req.Email == nil {
+		return fmt.Errorf("no fields to update")
+	}
+	if req.Email != nil && *req.Email == "" {
+		return fmt.Errorf("email must not be empty")
+	}
+	return nil
+}
+
+type changePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+func validateChangePasswordRequest(req changePasswordRequest) error {
+	if req.OldPassword == "" {
+		return fmt.Errorf("old_password is required")
+	}
+	if req.NewPassword == "" {
+		return fmt.Errorf("new_password is required")
+	}
+	return nil
+}
+
+type updateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func validateUpdateUserRoleRequest(req updateUserRoleRequest) error {
+	if !util.IsSupportedRole(req.Role) {
+		return fmt.Errorf("role must be one of %s, %s or %s", util.AdminRole, util.GoalManagerRole, util.DonorRole)
+	}
+	return nil
+}
+
 type loginUserRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -56,6 +174,43 @@ func validateLoginUserRequest(req loginUserRequest) error {
 	return nil
 }
 
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func validateRenewAccessTokenRequest(req renewAccessTokenRequest) error {
+	if req.RefreshToken == "" {
+		return fmt.Errorf("refresh_token is required")
+	}
+	return nil
+}
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+func validateRequestPasswordResetRequest(req requestPasswordResetRequest) error {
+	if req.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	return nil
+}
+
+type confirmPasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func validateConfirmPasswordResetRequest(req confirmPasswordResetRequest) error {
+	if req.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	if req.NewPassword == "" {
+		return fmt.Errorf("new_password is required")
+	}
+	return nil
+}
+
 func validateCreateGoalRequest(req createGoalRequest) error {
 	if req.Title == "" {
 		return fmt.Errorf("title is required")
@@ -78,7 +233,10 @@ func validateUpdateGoalRequest(req updateGoalRequest) error {
 	return nil
 }
 
-func validateCreateDonationRequest(req createDonationRequest) error {
+func validateCreateDonationRequest(req *createDonationRequest) error {
+	if req.UserID != nil && *req.UserID <= 0 {
+		return fmt.Errorf("user_id must be positive")
+	}
 	if req.GoalID <= 0 {
 		return fmt.Errorf("goal_id must be positive")
 	}
@@ -86,8 +244,124 @@ func validateCreateDonationRequest(req createDonationRequest) error {
 	if req.Amount < minDonationAmount {
 		return fmt.Errorf("amount must be at least %d", minDonationAmount)
 	}
-	if req.Currency == "" {
-		return fmt.Errorf("currency is required")
+	if err := normalizeCurrency(&req.Currency); err != nil {
+		return err
+	}
+	return nil
+}
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// OrganizationID makes the key owned by an organization instead of
+	// the caller. Only admins may set it.
+	OrganizationID *int64     `json:"organization_id"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+func validateCreateAPIKeyRequest(req createAPIKeyRequest) error {
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("scopes is required")
+	}
+	for _, scope := range req.Scopes {
+		if !util.IsSupportedScope(scope) {
+			return fmt.Errorf("unsupported scope %q", scope)
+		}
+	}
+	if req.OrganizationID != nil && *req.OrganizationID <= 0 {
+		return fmt.Errorf("organization_id must be positive")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+
+type createOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+func validateCreateOrganizationRequest(req createOrganizationRequest) error {
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+type createPayoutRequest struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// Provider is the clearing account paid out from: the configured
+	// payment provider, which is the default, or manual.
+	Provider  string `json:"provider"`
+	Reference string `json:"reference"`
+}
+
+func validateCreatePayoutRequest(req *createPayoutRequest) error {
+	if req.Amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if err := normalizeCurrency(&req.Currency); err != nil {
+		return err
+	}
+	if req.Reference == "" {
+		return fmt.Errorf("reference is required")
+	}
+	return nil
+}
+
+type createRefundRequest struct {
+	// Amount is the amount to refund; zero refunds whatever is left of the
+	// donation.
+	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
+	Note   string `json:"note"`
+}
+
+func validateCreateRefundRequest(req createRefundRequest) error {
+	if req.Amount < 0 {
+		return fmt.Errorf("amount must not be negative")
+	}
+	switch req.Reason {
+	case db.RefundReasonDuplicate, db.RefundReasonFraudulent, db.RefundReasonRequestedByDonor, db.RefundReasonGoalCanceled, db.RefundReasonOther:
+	default:
+		return fmt.Errorf("reason must be one of duplicate, fraudulent, requested_by_donor, goal_canceled or other")
+	}
+	if len(req.Note) > maxRefundNoteLength {
+		return fmt.Errorf("note must be at most %d characters", maxRefundNoteLength)
+	}
+	return nil
+}
+
+type createSubscriptionRequest struct {
+	GoalID   int64  `json:"goal_id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// Interval is weekly or monthly.
+	Interval string `json:"interval"`
+	// PaymentMethod is the provider's ID for the card or account charged
+	// on every interval.
+	PaymentMethod string `json:"payment_method"`
+}
+
+func validateCreateSubscriptionRequest(req *createSubscriptionRequest) error {
+	if req.GoalID <= 0 {
+		return fmt.Errorf("goal_id must be positive")
+	}
+	if req.Amount < minDonationAmount {
+		return fmt.Errorf("amount must be at least %d", minDonationAmount)
+	}
+	if err := normalizeCurrency(&req.Currency); err != nil {
+		return err
+	}
+	if req.Interval != db.SubscriptionWeekly && req.Interval != db.SubscriptionMonthly {
+		return fmt.Errorf("interval must be weekly or monthly")
+	}
+	if req.PaymentMethod == "" {
+		return fmt.Errorf("payment_method is required")
 	}
 	return nil
 }
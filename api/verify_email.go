@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// verifyEmailCodeBytes is the amount of randomness in a verification code.
+const verifyEmailCodeBytes = 32
+
+func (s *Server) verifyEmail(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Query("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid verification id"})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code query parameter is required"})
+		return
+	}
+
+	result, err := s.store.VerifyEmailTx(c.Request.Context(), db.VerifyEmailTxParams{
+		EmailID:    id,
+		SecretCode: code,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired verification code"})
+			return
+		}
+		log.Printf("verifyEmail error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(result.User))
+}
+
+func (s *Server) resendVerifyEmail(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.IsEmailVerified {
+		c.JSON(http.StatusConflict, gin.H{"error": "email is already verified"})
+		return
+	}
+
+	code, err := util.RandomToken(verifyEmailCodeBytes)
+	if err != nil {
+		log.Printf("resendVerifyEmail code error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+
+	verifyEmail, err := s.store.CreateVerifyEmail(c.Request.Context(), db.CreateVerifyEmailParams{
+		UserID:     user.ID,
+		Email:      user.Email,
+		SecretCode: code,
+		ExpiredAt:  time.Now().Add(s.config.VerifyEmailDuration),
+	})
+	if err != nil {
+		log.Printf("resendVerifyEmail create error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+
+	if err := s.sendVerifyEmail(user, verifyEmail); err != nil {
+		log.Printf("resendVerifyEmail send error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// sendVerifyEmail emails the user a link that consumes verifyEmail.
+func (s *Server) sendVerifyEmail(user db.User, verifyEmail db.VerifyEmail) error {
+	query := url.Values{}
+	query.Set("id", strconv.FormatInt(verifyEmail.ID, 10))
+	query.Set("code", verifyEmail.SecretCode)
+	link := fmt.Sprintf("%s/users/verify-email?%s", s.config.AppBaseURL, query.Encode())
+
+	name := user.Email
+	if user.Name.Valid {
+		name = user.Name.String
+	}
+
+	subject := "Please verify your email address"
+	content := fmt.Sprintf(`Hello %s,<br/>
+Thank you for registering with us!<br/>
+Please <a href="%s">click here</a> to verify your email address.<br/>
+The link expires at %s.<br/>`,
+		html.EscapeString(name), html.EscapeString(link), verifyEmail.ExpiredAt.Format(time.RFC1123))
+
+	return s.mailer.SendEmail(subject, content, []string{verifyEmail.Email})
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/util"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/mock/gomock"
+)
+
+// recordingSender keeps the emails it is asked to send.
+type recordingSender struct {
+	contents []string
+	to       [][]string
+}
+
+func (sender *recordingSender) SendEmail(subject string, content string, to []string) error {
+	sender.contents = append(sender.contents, content)
+	sender.to = append(sender.to, to)
+	return nil
+}
+
+func TestVerifyEmail(t *testing.T) {
+	user := randomUser(t, util.DonorRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "id=7&code=secret",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), db.VerifyEmailTxParams{EmailID: 7, SecretCode: "secret"}).
+					Times(1).
+					Return(db.VerifyEmailTxResult{User: user}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+				requireBodyMatch(t, recorder.Body, newUserResponse(user))
+			},
+		},
+		{
+			name:  "InvalidID",
+			query: "id=abc&code=secret",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:  "MissingCode",
+			query: "id=7",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:  "InvalidOrExpiredCode",
+			query: "id=7&code=wrong",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(db.VerifyEmailTxResult{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "id=7&code=secret",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(db.VerifyEmailTxResult{}, errors.New("db down"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodGet, "/users/verify-email?"+tc.query, nil)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestResendVerifyEmail(t *testing.T) {
+	verified := randomUser(t, util.DonorRole, "secret-password")
+	unverified := randomUser(t, util.DonorRole, "secret-password")
+	unverified.IsEmailVerified = false
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender)
+	}{
+		{
+			name: "OK",
+			user: unverified,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), unverified.ID).Times(1).Return(unverified, nil)
+				store.EXPECT().
+					CreateVerifyEmail(gomock.Any(), gomock.Cond(func(arg db.CreateVerifyEmailParams) bool {
+						return arg.UserID == unverified.ID && arg.Email == unverified.Email &&
+							len(arg.SecretCode) > 0 && time.Until(arg.ExpiredAt) > 0
+					})).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+						return db.VerifyEmail{ID: 9, UserID: arg.UserID, Email: arg.Email, SecretCode: arg.SecretCode, ExpiredAt: arg.ExpiredAt}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender) {
+				requireStatus(t, recorder.Code, http.StatusAccepted, recorder.Body)
+				if len(sender.contents) != 1 || sender.to[0][0] != unverified.Email {
+					t.Fatalf("expected one email to %s, got %v", unverified.Email, sender.to)
+				}
+				if !strings.Contains(sender.contents[0], "id=9") {
+					t.Fatalf("email does not link to the verification: %s", sender.contents[0])
+				}
+			},
+		},
+		{
+			name: "AlreadyVerified",
+			user: verified,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), verified.ID).Times(1).Return(verified, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+				if len(sender.contents) != 0 {
+					t.Fatalf("unexpected email sent")
+				}
+			},
+		},
+		{
+			name: "InternalError",
+			user: unverified,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), unverified.ID).Times(1).Return(unverified, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.VerifyEmail{}, errors.New("db down"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *recordingSender) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubUserRoles(store, verified, unverified)
+
+			server := newTestServer(t, store)
+			sender := &recordingSender{}
+			server.mailer = sender
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, "/users/verify-email/resend", nil)
+			addAuthorization(t, request, server.tokenMaker, tc.user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, sender)
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "charity/db/sqlc"
+	"charity/payments"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxWebhookSize bounds the size of a webhook request body.
+const maxWebhookSize = 1 << 20
+
+// receivePaymentWebhook stores an event sent by the payment provider and
+// applies it to the donation it concerns. Deliveries are verified with the
+// provider's signature, and an event delivered again is applied only once.
+func (s *Server) receivePaymentWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	event, err := s.payments.ParseWebhook(payload, c.GetHeader(payments.SignatureHeader))
+	if err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) || errors.Is(err, payments.ErrExpiredSignature) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event"})
+		return
+	}
+
+	var intentID string
+	switch {
+	case event.Intent != nil:
+		intentID = event.Intent.ID
+	case event.Refund != nil:
+		intentID = event.Refund.IntentID
+	}
+
+	stored, err := s.store.CreatePaymentEvent(c.Request.Context(), db.CreatePaymentEventParams{
+		Provider:        s.payments.Name(),
+		EventID:         event.ID,
+		EventType:       string(event.Type),
+		PaymentIntentID: pgtype.Text{String: intentID, Valid: intentID != ""},
+		Payload:         payload,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the provider delivers an event again when it did not get our
+		// response the first time
+		stored, err = s.store.GetPaymentEventByEventID(c.Request.Context(), db.GetPaymentEventByEventIDParams{
+			Provider: s.payments.Name(),
+			EventID:  event.ID,
+		})
+	}
+	if err != nil {
+		log.Printf("receivePaymentWebhook error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store event"})
+		return
+	}
+
+	if stored.Status == db.PaymentEventProcessed || stored.Status == db.PaymentEventIgnored {
+		c.JSON(http.StatusOK, gin.H{"id": stored.ID, "status": stored.Status})
+		return
+	}
+
+	stored, err = s.applyPaymentEvent(c.Request.Context(), stored)
+	if err != nil {
+		// the event is kept as failed; the provider retries the delivery
+		// and an admin can replay it
+		log.Printf("receivePaymentWebhook error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": stored.ID, "status": stored.Status})
+}
+
+func (s *Server) listPaymentEvents(c *gin.Context) {
+	status := c.DefaultQuery("status", db.PaymentEventFailed)
+	switch status {
+	case db.PaymentEventReceived, db.PaymentEventProcessed, db.PaymentEventIgnored, db.PaymentEventDeferred, db.PaymentEventFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+	limit64, err := strconv.ParseInt(limitStr, 10, 32)
+	if err != nil || limit64 <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset64, err := strconv.ParseInt(offsetStr, 10, 32)
+	if err != nil || offset64 < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	events, err := s.store.ListPaymentEventsByStatus(c.Request.Context(), db.ListPaymentEventsByStatusParams{
+		Status: status,
+		Limit:  int32(limit64),
+		Offset: int32(offset64),
+	})
+	if err != nil {
+		log.Printf("listPaymentEvents error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list payment events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// replayPaymentEvent applies a stored event again, e.g. once the cause of
+// its failure has been fixed.
+func (s *Server) replayPaymentEvent(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment event id"})
+		return
+	}
+
+	stored, err := s.store.GetPaymentEvent(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "payment event not found"})
+			return
+		}
+		log.Printf("replayPaymentEvent error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to replay payment event"})
+		return
+	}
+	if stored.Status == db.PaymentEventProcessed || stored.Status == db.PaymentEventIgnored {
+		c.JSON(http.StatusConflict, gin.H{"error": "payment event is already " + stored.Status})
+		return
+	}
+
+	stored, err = s.applyPaymentEvent(c.Request.Context(), stored)
+	if err != nil {
+		if errors.Is(err, db.ErrPaymentMismatch) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "event": stored})
+			return
+		}
+		log.Printf("replayPaymentEvent error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to replay payment event", "event": stored})
+		return
+	}
+
+	c.JSON(http.StatusOK, stored)
+}
+
+// applyPaymentEvent applies a stored event and then any events for the
+// same payment that were deferred waiting for it, and settles the
+// subscription charge it completed, if any. When the event cannot be
+// applied the error is recorded on it and returned.
+func (s *Server) applyPaymentEvent(ctx context.Context, stored db.PaymentEvent) (db.PaymentEvent, error) {
+	event, err := s.payments.ParseEvent(stored.Payload)
+	if err == nil {
+		var result db.ApplyPaymentEventTxResult
+		result, err = s.store.ApplyPaymentEventTx(ctx, paymentEventParams(stored.ID, event))
+		if err == nil {
+			if result.Event.Status == db.PaymentEventProcessed && result.Event.PaymentIntentID.Valid {
+				s.applyDeferredPaymentEvents(ctx, result.Event.Provider, result.Event.PaymentIntentID)
+			}
+			if result.Donation != nil && s.subscriptions != nil {
+				// the scheduler also settles charges it finds settled, so
+				// a failure here only delays the subscription
+				if err := s.subscriptions.Settle(ctx, *result.Donation); err != nil {
+					log.Printf("applyPaymentEvent settle subscription error: %v", err)
+				}
+			}
+			return result.Event, nil
+		}
+	}
+
+	failed, updateErr := s.store.UpdatePaymentEventStatus(ctx, db.UpdatePaymentEventStatusParams{
+		ID:        stored.ID,
+		Status:    db.PaymentEventFailed,
+		LastError: pgtype.Text{String: err.Error(), Valid: true},
+	})
+	if updateErr != nil {
+		log.Printf("applyPaymentEvent error: %v", updateErr)
+		return stored, err
+	}
+	return failed, err
+}
+
+// applyDeferredPaymentEvents retries the deferred events for a payment, once
+// its donation has been recorded or another of its events processed.
+// Failures are recorded on the events.
+func (s *Server) applyDeferredPaymentEvents(ctx context.Context, provider string, intentID pgtype.Text) {
+	deferred, err := s.store.ListDeferredPaymentEvents(ctx, db.ListDeferredPaymentEventsParams{
+		Provider:        provider,
+		PaymentIntentID: intentID,
+	})
+	if err != nil {
+		log.Printf("applyDeferredPaymentEvents error: %v", err)
+		return
+	}
+
+	for _, event := range deferred {
+		if _, err := s.applyPaymentEvent(ctx, event); err != nil {
+			log.Printf("applyDeferredPaymentEvents error: event %d: %v", event.ID, err)
+		}
+	}
+}
+
+// paymentEventParams describes what a provider event means for the
+// donation paid by its payment intent.
+func paymentEventParams(id int64, event payments.Event) db.ApplyPaymentEventTxParams {
+	params := db.ApplyPaymentEventTxParams{EventID: id}
+
+	switch {
+	case event.Intent != nil:
+		params.Amount = event.Intent.Amount
+		params.Currency = event.Intent.Currency
+		switch event.Type {
+		case payments.EventIntentSucceeded:
+			params.DonationStatus = db.DonationSucceeded
+		case payments.EventIntentFailed:
+			params.DonationStatus = db.DonationFailed
+			params.FailureReason = event.Intent.FailureMessage
+			if params.FailureReason == "" {
+				params.FailureReason = "payment failed"
+			}
+		case payments.EventIntentCanceled:
+			params.DonationStatus = db.DonationFailed
+			params.FailureReason = "payment was canceled"
+		}
+	case event.Refund != nil:
+		params.DonationStatus = db.DonationRefunded
+		params.Amount = event.Refund.Amount
+		params.Currency = event.Refund.Currency
+		params.RefundID = event.Refund.ID
+		params.RefundStatus = refundStatus(event.Refund.Status)
+		params.RefundReason = refundReasonFromProvider(event.Refund.Reason)
+	}
+	return params
+}
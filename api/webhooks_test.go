@@ -0,0 +1,362 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"charity/billing"
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/payments"
+	"charity/util"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+// succeededIntentEvent pays a new intent with the server's fake provider and
+// returns the signed webhook reporting it.
+func succeededIntentEvent(t *testing.T, server *Server, eventID string) (payments.Intent, []byte, string) {
+	t.Helper()
+
+	fake := server.payments.(*payments.FakeProvider)
+	intent, err := fake.CreateIntent(context.Background(), payments.CreateIntentParams{Amount: 500, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("failed to create payment intent: %v", err)
+	}
+	intent, err = fake.ConfirmIntent(context.Background(), intent.ID, payments.FakeCardSucceeds)
+	if err != nil {
+		t.Fatalf("failed to confirm payment intent: %v", err)
+	}
+	payload, signature, err := fake.IntentEvent(eventID, payments.EventIntentSucceeded, intent.ID)
+	if err != nil {
+		t.Fatalf("failed to create webhook event: %v", err)
+	}
+	return intent, payload, signature
+}
+
+func TestReceivePaymentWebhook(t *testing.T) {
+	testCases := []struct {
+		name          string
+		signature     func(signature string, payload []byte) string
+		buildStubs    func(store *mockdb.MockStore, stored db.PaymentEvent)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				processed := stored
+				processed.Status = db.PaymentEventProcessed
+				store.EXPECT().
+					CreatePaymentEvent(gomock.Any(), gomock.Cond(func(arg db.CreatePaymentEventParams) bool {
+						return arg.Provider == "fake" && arg.EventID == stored.EventID &&
+							arg.PaymentIntentID == stored.PaymentIntentID && bytes.Equal(arg.Payload, stored.Payload)
+					})).
+					Times(1).
+					Return(stored, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), db.ApplyPaymentEventTxParams{
+						EventID:        stored.ID,
+						DonationStatus: db.DonationSucceeded,
+						Amount:         500,
+						Currency:       "USD",
+					}).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{Event: processed}, nil)
+				store.EXPECT().ListDeferredPaymentEvents(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "AppliesDeferredEvents",
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				processed := stored
+				processed.Status = db.PaymentEventProcessed
+				refund := stored
+				refund.ID = stored.ID + 1
+				refund.EventID = "evt_refund"
+				refund.EventType = string(payments.EventRefundCreated)
+				refund.Status = db.PaymentEventDeferred
+				refund.Payload = []byte(`{"id":"evt_refund","type":"refund.created","data":{"object":{"id":"re_1","amount":500,"currency":"usd","payment_intent":"` + stored.PaymentIntentID.String + `","status":"succeeded"}}}`)
+				processedRefund := refund
+				processedRefund.Status = db.PaymentEventProcessed
+
+				store.EXPECT().CreatePaymentEvent(gomock.Any(), gomock.Any()).Times(1).Return(stored, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), gomock.Cond(func(arg db.ApplyPaymentEventTxParams) bool { return arg.EventID == stored.ID })).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{Event: processed}, nil)
+				store.EXPECT().
+					ListDeferredPaymentEvents(gomock.Any(), db.ListDeferredPaymentEventsParams{
+						Provider:        stored.Provider,
+						PaymentIntentID: stored.PaymentIntentID,
+					}).
+					Times(1).
+					Return([]db.PaymentEvent{refund}, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), db.ApplyPaymentEventTxParams{
+						EventID:        refund.ID,
+						DonationStatus: db.DonationRefunded,
+						Amount:         500,
+						Currency:       "USD",
+						RefundID:       "re_1",
+						RefundStatus:   db.RefundSucceeded,
+						RefundReason:   db.RefundReasonOther,
+					}).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{Event: processedRefund}, nil)
+				store.EXPECT().ListDeferredPaymentEvents(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "SettlesSubscriptionCharge",
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				processed := stored
+				processed.Status = db.PaymentEventProcessed
+				donation := db.Donation{
+					ID:             11,
+					Status:         db.DonationSucceeded,
+					SubscriptionID: pgtype.Int8{Int64: 7, Valid: true},
+				}
+				store.EXPECT().CreatePaymentEvent(gomock.Any(), gomock.Any()).Times(1).Return(stored, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{Event: processed, Donation: &donation}, nil)
+				store.EXPECT().ListDeferredPaymentEvents(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+				store.EXPECT().
+					ClearSubscriptionPendingCharge(gomock.Any(), db.ClearSubscriptionPendingChargeParams{
+						ID:                7,
+						PendingDonationID: pgtype.Int8{Int64: 11, Valid: true},
+					}).
+					Times(1).
+					Return(db.Subscription{ID: 7, Interval: db.SubscriptionMonthly, BillingAnchor: time.Now()}, nil)
+				store.EXPECT().
+					AdvanceSubscription(gomock.Any(), gomock.Cond(func(arg db.AdvanceSubscriptionParams) bool { return arg.ID == 7 })).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "Redelivered",
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				processed := stored
+				processed.Status = db.PaymentEventProcessed
+				store.EXPECT().CreatePaymentEvent(gomock.Any(), gomock.Any()).Times(1).Return(db.PaymentEvent{}, pgx.ErrNoRows)
+				store.EXPECT().
+					GetPaymentEventByEventID(gomock.Any(), db.GetPaymentEventByEventIDParams{Provider: "fake", EventID: stored.EventID}).
+					Times(1).
+					Return(processed, nil)
+				store.EXPECT().ApplyPaymentEventTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name: "ApplyFails",
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				failed := stored
+				failed.Status = db.PaymentEventFailed
+				store.EXPECT().CreatePaymentEvent(gomock.Any(), gomock.Any()).Times(1).Return(stored, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{}, db.ErrPaymentMismatch)
+				store.EXPECT().
+					UpdatePaymentEventStatus(gomock.Any(), db.UpdatePaymentEventStatusParams{
+						ID:        stored.ID,
+						Status:    db.PaymentEventFailed,
+						LastError: pgtype.Text{String: db.ErrPaymentMismatch.Error(), Valid: true},
+					}).
+					Times(1).
+					Return(failed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name: "InvalidSignature",
+			signature: func(signature string, payload []byte) string {
+				return payments.SignPayload("whsec_other", payload, time.Now())
+			},
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				store.EXPECT().CreatePaymentEvent(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+		{
+			name: "ExpiredSignature",
+			signature: func(signature string, payload []byte) string {
+				return payments.SignPayload("whsec_test", payload, time.Now().Add(-time.Hour))
+			},
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				store.EXPECT().CreatePaymentEvent(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusBadRequest, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+			server.subscriptions = billing.NewScheduler(store, server.payments, billing.Policy{BatchSize: 10, RetryDelay: time.Hour, MaxRetries: 3})
+
+			intent, payload, signature := succeededIntentEvent(t, server, "evt_1")
+			if tc.signature != nil {
+				signature = tc.signature(signature, payload)
+			}
+			stored := db.PaymentEvent{
+				ID:              1,
+				Provider:        "fake",
+				EventID:         "evt_1",
+				EventType:       string(payments.EventIntentSucceeded),
+				PaymentIntentID: pgtype.Text{String: intent.ID, Valid: true},
+				Payload:         payload,
+				Status:          db.PaymentEventReceived,
+				CreatedAt:       time.Now(),
+			}
+			tc.buildStubs(store, stored)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/webhooks/payments", bytes.NewReader(payload))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			request.Header.Set(payments.SignatureHeader, signature)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestReplayPaymentEvent(t *testing.T) {
+	admin := randomUser(t, util.AdminRole, "secret-password")
+	donor := randomUser(t, util.DonorRole, "secret-password")
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		status        string
+		buildStubs    func(store *mockdb.MockStore, stored db.PaymentEvent)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			user:   admin,
+			status: db.PaymentEventFailed,
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				processed := stored
+				processed.Status = db.PaymentEventProcessed
+				store.EXPECT().GetPaymentEvent(gomock.Any(), stored.ID).Times(1).Return(stored, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), gomock.Cond(func(arg db.ApplyPaymentEventTxParams) bool { return arg.EventID == stored.ID })).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{Event: processed}, nil)
+				store.EXPECT().ListDeferredPaymentEvents(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusOK, recorder.Body)
+			},
+		},
+		{
+			name:   "StillFailing",
+			user:   admin,
+			status: db.PaymentEventFailed,
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				store.EXPECT().GetPaymentEvent(gomock.Any(), stored.ID).Times(1).Return(stored, nil)
+				store.EXPECT().
+					ApplyPaymentEventTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApplyPaymentEventTxResult{}, errors.New("connection reset"))
+				store.EXPECT().UpdatePaymentEventStatus(gomock.Any(), gomock.Any()).Times(1).Return(stored, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusInternalServerError, recorder.Body)
+			},
+		},
+		{
+			name:   "AlreadyProcessed",
+			user:   admin,
+			status: db.PaymentEventProcessed,
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				store.EXPECT().GetPaymentEvent(gomock.Any(), stored.ID).Times(1).Return(stored, nil)
+				store.EXPECT().ApplyPaymentEventTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusConflict, recorder.Body)
+			},
+		},
+		{
+			name:   "NotFound",
+			user:   admin,
+			status: db.PaymentEventFailed,
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				store.EXPECT().GetPaymentEvent(gomock.Any(), stored.ID).Times(1).Return(db.PaymentEvent{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusNotFound, recorder.Body)
+			},
+		},
+		{
+			name:   "NotAdmin",
+			user:   donor,
+			status: db.PaymentEventFailed,
+			buildStubs: func(store *mockdb.MockStore, stored db.PaymentEvent) {
+				store.EXPECT().GetPaymentEvent(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireStatus(t, recorder.Code, http.StatusForbidden, recorder.Body)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			intent, payload, _ := succeededIntentEvent(t, server, "evt_1")
+			stored := db.PaymentEvent{
+				ID:              7,
+				Provider:        "fake",
+				EventID:         "evt_1",
+				EventType:       string(payments.EventIntentSucceeded),
+				PaymentIntentID: pgtype.Text{String: intent.ID, Valid: true},
+				Payload:         payload,
+				Status:          tc.status,
+				CreatedAt:       time.Now(),
+			}
+			tc.buildStubs(store, stored)
+			stubUserRoles(store, admin, donor)
+
+			recorder := httptest.NewRecorder()
+			request := newJSONRequest(t, http.MethodPost, fmt.Sprintf("/payment-events/%d/replay", stored.ID), nil)
+			addAuthorization(t, request, server.tokenMaker, tc.user)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
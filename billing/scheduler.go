@@ -0,0 +1,333 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	db "charity/db/sqlc"
+	"charity/payments"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Policy decides how subscriptions are charged and retried.
+type Policy struct {
+	// BatchSize is the most subscriptions claimed at once.
+	BatchSize int32
+	// Lease is how long a claimed subscription is reserved for its charge.
+	// A charge interrupted e.g. by a crash is retried once it expires.
+	Lease time.Duration
+	// RetryDelay is how long after a failed charge it is retried. Every
+	// further failure doubles it. After MaxRetries failed retries the
+	// subscription is canceled.
+	RetryDelay time.Duration
+	MaxRetries int32
+}
+
+// retryDelay returns how long to wait before retrying a charge that has
+// failed the given number of times in a row.
+func (p Policy) retryDelay(failures int32) time.Duration {
+	delay := p.RetryDelay
+	for i := int32(1); i < failures; i++ {
+		delay *= 2
+	}
+	return delay
+}
+
+// Scheduler charges the subscriptions that are due. Several schedulers may
+// run against the same database; each subscription is claimed by one.
+type Scheduler struct {
+	store    db.Store
+	payments payments.Provider
+	policy   Policy
+	now      func() time.Time
+}
+
+// NewScheduler creates a new Scheduler
+func NewScheduler(store db.Store, provider payments.Provider, policy Policy) *Scheduler {
+	return &Scheduler{
+		store:    store,
+		payments: provider,
+		policy:   policy,
+		now:      time.Now,
+	}
+}
+
+// Run charges due subscriptions every interval until ctx is canceled. It
+// returns once the charge in progress, if any, has finished.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.settleCharges(ctx)
+
+		// keep going while there is a backlog
+		for {
+			claimed, err := s.RunOnce(ctx)
+			if err != nil {
+				log.Printf("subscription scheduler error: %v", err)
+			}
+			if err != nil || claimed < int(s.policy.BatchSize) {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims a batch of due subscriptions and charges them. It returns
+// the number of subscriptions claimed. A charge that fails to reach the
+// provider or the database is logged and retried once its lease expires.
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	now := s.now()
+	subscriptions, err := s.store.ClaimDueSubscriptions(ctx, db.ClaimDueSubscriptionsParams{
+		LockedUntil: pgtype.Timestamptz{Time: now.Add(s.policy.Lease), Valid: true},
+		Now:         now,
+		BatchSize:   s.policy.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, subscription := range subscriptions {
+		// a charge is not interrupted once it has started, so it is not
+		// left between the provider and the database on shutdown. The
+		// subscriptions not reached yet are claimed again once their
+		// lease expires.
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.charge(context.WithoutCancel(ctx), subscription); err != nil {
+			log.Printf("subscription scheduler error: subscription %d: %v", subscription.ID, err)
+		}
+	}
+	return len(subscriptions), nil
+}
+
+// charge collects one payment of a subscription.
+func (s *Scheduler) charge(ctx context.Context, subscription db.Subscription) error {
+	// every attempt at the same charge uses the same key, so a charge
+	// retried after a crash does not take the money twice
+	intent, err := s.payments.CreateIntent(ctx, payments.CreateIntentParams{
+		Amount:   subscription.Amount,
+		Currency: subscription.Currency,
+		Metadata: map[string]string{
+			"goal_id":         strconv.FormatInt(subscription.GoalID, 10),
+			"subscription_id": strconv.FormatInt(subscription.ID, 10),
+		},
+		IdempotencyKey: fmt.Sprintf("subscription:%d:%d", subscription.ID, subscription.NextChargeAt.Unix()),
+	})
+	if err != nil {
+		return err
+	}
+
+	donation, err := s.donation(ctx, subscription, intent)
+	if err != nil {
+		return err
+	}
+
+	if intent.Status == payments.IntentRequiresPaymentMethod || intent.Status == payments.IntentRequiresConfirmation {
+		intent, err = s.payments.ConfirmIntent(ctx, intent.ID, subscription.PaymentMethod)
+		if err != nil {
+			if payments.IsDeclined(err) {
+				return s.fail(ctx, subscription, donation, err.Error())
+			}
+			return err
+		}
+	}
+	if intent.Status == payments.IntentRequiresCapture {
+		intent, err = s.payments.CaptureIntent(ctx, intent.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch intent.Status {
+	case payments.IntentSucceeded:
+		if _, err := s.store.ConfirmDonationTx(ctx, db.ConfirmDonationTxParams{
+			DonationID: donation.ID,
+			Amount:     intent.Amount,
+			Currency:   intent.Currency,
+		}); err != nil {
+			return err
+		}
+	case payments.IntentProcessing:
+		// the provider reports the outcome with a webhook, and Settle
+		// advances the subscription or retries the charge then
+		_, err = s.store.SetSubscriptionPendingCharge(ctx, db.SetSubscriptionPendingChargeParams{
+			ID:                subscription.ID,
+			PendingDonationID: pgtype.Int8{Int64: donation.ID, Valid: true},
+		})
+		return err
+	case payments.IntentCanceled:
+		return s.fail(ctx, subscription, donation, "payment was canceled")
+	default:
+		// the donor is not there to authenticate the payment
+		return s.fail(ctx, subscription, donation, "payment requires the donor: "+string(intent.Status))
+	}
+
+	_, err = s.store.AdvanceSubscription(ctx, db.AdvanceSubscriptionParams{
+		ID:           subscription.ID,
+		NextChargeAt: s.nextChargeAt(subscription),
+	})
+	return err
+}
+
+// Settle finishes a charge the provider was still processing once its
+// donation has succeeded or failed: the subscription is advanced, or the
+// charge is retried as a declined one would be. Donations that are still
+// pending, or that no subscription is waiting for, are ignored.
+func (s *Scheduler) Settle(ctx context.Context, donation db.Donation) error {
+	if !donation.SubscriptionID.Valid || donation.Status == db.DonationPending {
+		return nil
+	}
+
+	subscription, err := s.store.ClearSubscriptionPendingCharge(ctx, db.ClearSubscriptionPendingChargeParams{
+		ID:                donation.SubscriptionID.Int64,
+		PendingDonationID: pgtype.Int8{Int64: donation.ID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// settled already, or charged without being held
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if donation.Status == db.DonationFailed {
+		reason := donation.FailureReason.String
+		if reason == "" {
+			reason = "payment failed"
+		}
+		return s.fail(ctx, subscription, donation, reason)
+	}
+	_, err = s.store.AdvanceSubscription(ctx, db.AdvanceSubscriptionParams{
+		ID:           subscription.ID,
+		NextChargeAt: s.nextChargeAt(subscription),
+	})
+	return err
+}
+
+// settleCharges settles held charges whose donation has settled, in case
+// Settle did not run when the provider reported the outcome.
+func (s *Scheduler) settleCharges(ctx context.Context) {
+	donations, err := s.store.ListSettledSubscriptionCharges(ctx, s.policy.BatchSize)
+	if err != nil {
+		log.Printf("subscription scheduler error: %v", err)
+		return
+	}
+	for _, donation := range donations {
+		if err := s.Settle(ctx, donation); err != nil {
+			log.Printf("subscription scheduler error: subscription %d: %v", donation.SubscriptionID.Int64, err)
+		}
+	}
+}
+
+// donation returns the donation paid by intent, recording it as pending if
+// an earlier attempt at the charge did not get that far.
+func (s *Scheduler) donation(ctx context.Context, subscription db.Subscription, intent payments.Intent) (db.Donation, error) {
+	donation, err := s.store.GetDonationByPaymentIntent(ctx, db.GetDonationByPaymentIntentParams{
+		Provider:        s.payments.Name(),
+		PaymentIntentID: pgtype.Text{String: intent.ID, Valid: true},
+	})
+	if err == nil {
+		return donation, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return db.Donation{}, err
+	}
+
+	result, err := s.store.DonationTx(ctx, db.DonationTxParams{
+		UserID:          pgtype.Int8{Int64: subscription.UserID, Valid: true},
+		GoalID:          subscription.GoalID,
+		Amount:          subscription.Amount,
+		Currency:        subscription.Currency,
+		Provider:        s.payments.Name(),
+		PaymentIntentID: intent.ID,
+		SubscriptionID:  pgtype.Int8{Int64: subscription.ID, Valid: true},
+	})
+	return result.Donation, err
+}
+
+// fail records a declined charge and schedules its retry, or cancels the
+// subscription once the retries are used up.
+func (s *Scheduler) fail(ctx context.Context, subscription db.Subscription, donation db.Donation, reason string) error {
+	if _, err := s.store.FailDonation(ctx, db.FailDonationParams{
+		ID:            donation.ID,
+		FailureReason: pgtype.Text{String: reason, Valid: true},
+	}); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	failures := subscription.FailedAttempts + 1
+	if _, err := s.store.RecordSubscriptionFailure(ctx, db.RecordSubscriptionFailureParams{
+		ID:           subscription.ID,
+		NextChargeAt: s.now().Add(s.policy.retryDelay(failures)),
+		LastError:    pgtype.Text{String: reason, Valid: true},
+	}); err != nil {
+		return err
+	}
+
+	if failures > s.policy.MaxRetries {
+		if _, err := s.store.CancelSubscription(ctx, subscription.ID); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextChargeAt returns when the charge after a successful one is due: the
+// first billing date after now. The schedule keeps to the subscription's
+// anchor, so a charge that only succeeded on a retry, or that the scheduler
+// made late, does not move later charges.
+func (s *Scheduler) nextChargeAt(subscription db.Subscription) time.Time {
+	return nextBillingDate(subscription.BillingAnchor, subscription.Interval, s.now())
+}
+
+// nextBillingDate returns the first billing date after t of a subscription
+// anchored at anchor. Weekly subscriptions are billed on the anchor's
+// weekday; monthly ones on its day of the month, or on the last day of
+// months too short for it.
+func nextBillingDate(anchor time.Time, interval string, t time.Time) time.Time {
+	if t.Before(anchor) {
+		return anchor
+	}
+
+	// start from an estimate that falls at most one period short
+	var n int
+	if interval == db.SubscriptionWeekly {
+		n = int(t.Sub(anchor).Hours()/(7*24)) - 1
+	} else {
+		local := t.In(anchor.Location())
+		n = (local.Year()-anchor.Year())*12 + int(local.Month()-anchor.Month()) - 1
+	}
+	for n = max(n, 1); ; n++ {
+		next := billingDate(anchor, interval, n)
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// billingDate returns the n-th billing date after anchor.
+func billingDate(anchor time.Time, interval string, n int) time.Time {
+	if interval == db.SubscriptionWeekly {
+		return anchor.AddDate(0, 0, 7*n)
+	}
+	year, month, day := anchor.Date()
+	// AddDate would carry a day missing from the month into the next one
+	first := time.Date(year, month+time.Month(n), 1, anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), anchor.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
@@ -0,0 +1,492 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mockdb "charity/db/mock"
+	db "charity/db/sqlc"
+	"charity/payments"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/mock/gomock"
+)
+
+var testPolicy = Policy{
+	BatchSize:  10,
+	Lease:      10 * time.Minute,
+	RetryDelay: 24 * time.Hour,
+	MaxRetries: 3,
+}
+
+func TestSchedulerRunOnce(t *testing.T) {
+	now := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+	due := now.Add(-time.Minute)
+
+	testCases := []struct {
+		name         string
+		subscription func(*db.Subscription)
+		buildStubs   func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation)
+	}{
+		{
+			name: "Charged",
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().
+					DonationTx(gomock.Any(), gomock.Cond(func(arg db.DonationTxParams) bool {
+						return arg.UserID.Int64 == subscription.UserID && arg.GoalID == subscription.GoalID &&
+							arg.Amount == subscription.Amount && arg.SubscriptionID.Int64 == subscription.ID &&
+							arg.PaymentIntentID != ""
+					})).
+					Times(1).
+					Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().
+					ConfirmDonationTx(gomock.Any(), db.ConfirmDonationTxParams{
+						DonationID: donation.ID,
+						Amount:     subscription.Amount,
+						Currency:   subscription.Currency,
+					}).
+					Times(1).
+					Return(db.ConfirmDonationTxResult{Credited: true}, nil)
+				store.EXPECT().
+					AdvanceSubscription(gomock.Any(), db.AdvanceSubscriptionParams{
+						ID:           subscription.ID,
+						NextChargeAt: time.Date(2026, time.February, 28, 8, 59, 0, 0, time.UTC),
+					}).
+					Times(1).
+					Return(db.Subscription{}, nil)
+			},
+		},
+		{
+			name: "ChargedWeekly",
+			subscription: func(subscription *db.Subscription) {
+				subscription.Interval = db.SubscriptionWeekly
+			},
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().
+					AdvanceSubscription(gomock.Any(), db.AdvanceSubscriptionParams{
+						ID:           subscription.ID,
+						NextChargeAt: due.AddDate(0, 0, 7),
+					}).
+					Times(1)
+			},
+		},
+		{
+			// the retry does not move the schedule off its anchor
+			name: "ChargedOnRetry",
+			subscription: func(subscription *db.Subscription) {
+				subscription.Status = db.SubscriptionPastDue
+				subscription.FailedAttempts = 2
+				subscription.BillingAnchor = time.Date(2025, time.December, 28, 9, 0, 0, 0, time.UTC)
+			},
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().
+					AdvanceSubscription(gomock.Any(), db.AdvanceSubscriptionParams{
+						ID:           subscription.ID,
+						NextChargeAt: time.Date(2026, time.February, 28, 9, 0, 0, 0, time.UTC),
+					}).
+					Times(1)
+			},
+		},
+		{
+			name: "Processing",
+			subscription: func(subscription *db.Subscription) {
+				subscription.PaymentMethod = payments.FakeBankAccountProcessing
+			},
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().
+					SetSubscriptionPendingCharge(gomock.Any(), db.SetSubscriptionPendingChargeParams{
+						ID:                subscription.ID,
+						PendingDonationID: pgtype.Int8{Int64: donation.ID, Valid: true},
+					}).
+					Times(1)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AdvanceSubscription(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RecordSubscriptionFailure(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name: "Declined",
+			subscription: func(subscription *db.Subscription) {
+				subscription.PaymentMethod = payments.FakeCardDeclined
+			},
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().
+					FailDonation(gomock.Any(), gomock.Cond(func(arg db.FailDonationParams) bool {
+						return arg.ID == donation.ID && arg.FailureReason.Valid
+					})).
+					Times(1)
+				store.EXPECT().
+					RecordSubscriptionFailure(gomock.Any(), gomock.Cond(func(arg db.RecordSubscriptionFailureParams) bool {
+						return arg.ID == subscription.ID && arg.NextChargeAt.Equal(now.Add(24*time.Hour)) && arg.LastError.Valid
+					})).
+					Times(1)
+				store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AdvanceSubscription(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CancelSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name: "DeclinedAgain",
+			subscription: func(subscription *db.Subscription) {
+				subscription.PaymentMethod = payments.FakeCardDeclined
+				subscription.Status = db.SubscriptionPastDue
+				subscription.FailedAttempts = 2
+			},
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().FailDonation(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().
+					RecordSubscriptionFailure(gomock.Any(), gomock.Cond(func(arg db.RecordSubscriptionFailureParams) bool {
+						return arg.NextChargeAt.Equal(now.Add(4 * 24 * time.Hour))
+					})).
+					Times(1)
+				store.EXPECT().CancelSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name: "RetriesExhausted",
+			subscription: func(subscription *db.Subscription) {
+				subscription.PaymentMethod = payments.FakeCardDeclined
+				subscription.Status = db.SubscriptionPastDue
+				subscription.FailedAttempts = 3
+			},
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().FailDonation(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().RecordSubscriptionFailure(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().CancelSubscription(gomock.Any(), subscription.ID).Times(1)
+			},
+		},
+		{
+			name: "AuthenticationRequired",
+			subscription: func(subscription *db.Subscription) {
+				subscription.PaymentMethod = payments.FakeCardAuthenticationRequired
+			},
+			buildStubs: func(store *mockdb.MockStore, subscription db.Subscription, donation db.Donation) {
+				store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.DonationTxResult{Donation: donation}, nil)
+				store.EXPECT().FailDonation(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().RecordSubscriptionFailure(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().AdvanceSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+
+			scheduler := NewScheduler(store, payments.NewFakeProvider("whsec_test"), testPolicy)
+			scheduler.now = func() time.Time { return now }
+
+			subscription := db.Subscription{
+				ID:            7,
+				UserID:        3,
+				GoalID:        5,
+				Amount:        1000,
+				Currency:      "USD",
+				Interval:      db.SubscriptionMonthly,
+				PaymentMethod: payments.FakeCardSucceeds,
+				Status:        db.SubscriptionActive,
+				NextChargeAt:  due,
+				BillingAnchor: due,
+			}
+			if tc.subscription != nil {
+				tc.subscription(&subscription)
+			}
+			donation := db.Donation{ID: 11, GoalID: subscription.GoalID, Amount: subscription.Amount, Currency: subscription.Currency}
+
+			store.EXPECT().
+				ClaimDueSubscriptions(gomock.Any(), db.ClaimDueSubscriptionsParams{
+					LockedUntil: pgtype.Timestamptz{Time: now.Add(testPolicy.Lease), Valid: true},
+					Now:         now,
+					BatchSize:   testPolicy.BatchSize,
+				}).
+				Times(1).
+				Return([]db.Subscription{subscription}, nil)
+			store.EXPECT().
+				GetDonationByPaymentIntent(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.Donation{}, pgx.ErrNoRows)
+			tc.buildStubs(store, subscription, donation)
+
+			claimed, err := scheduler.RunOnce(context.Background())
+			if err != nil {
+				t.Fatalf("RunOnce failed: %v", err)
+			}
+			if claimed != 1 {
+				t.Fatalf("expected 1 subscription to be claimed, got %d", claimed)
+			}
+		})
+	}
+}
+
+// TestSchedulerRetriedCharge checks that a charge interrupted after the
+// donation was recorded reuses the payment and the donation.
+func TestSchedulerRetriedCharge(t *testing.T) {
+	now := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	provider := payments.NewFakeProvider("whsec_test")
+
+	scheduler := NewScheduler(store, provider, testPolicy)
+	scheduler.now = func() time.Time { return now }
+
+	subscription := db.Subscription{
+		ID:            7,
+		UserID:        3,
+		GoalID:        5,
+		Amount:        1000,
+		Currency:      "USD",
+		Interval:      db.SubscriptionMonthly,
+		PaymentMethod: payments.FakeCardSucceeds,
+		Status:        db.SubscriptionActive,
+		NextChargeAt:  now,
+		BillingAnchor: now,
+	}
+
+	var intentID string
+	store.EXPECT().ClaimDueSubscriptions(gomock.Any(), gomock.Any()).Times(2).Return([]db.Subscription{subscription}, nil)
+	gomock.InOrder(
+		store.EXPECT().
+			GetDonationByPaymentIntent(gomock.Any(), gomock.Any()).
+			Return(db.Donation{}, pgx.ErrNoRows),
+		store.EXPECT().
+			GetDonationByPaymentIntent(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, arg db.GetDonationByPaymentIntentParams) (db.Donation, error) {
+				if arg.PaymentIntentID.String != intentID {
+					t.Errorf("expected payment intent %s to be reused, got %s", intentID, arg.PaymentIntentID.String)
+				}
+				return db.Donation{ID: 11, Status: db.DonationPending}, nil
+			}),
+	)
+	store.EXPECT().
+		DonationTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.DonationTxParams) (db.DonationTxResult, error) {
+			intentID = arg.PaymentIntentID
+			return db.DonationTxResult{Donation: db.Donation{ID: 11}}, nil
+		})
+	gomock.InOrder(
+		store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Return(db.ConfirmDonationTxResult{}, context.DeadlineExceeded),
+		store.EXPECT().ConfirmDonationTx(gomock.Any(), gomock.Any()).Return(db.ConfirmDonationTxResult{}, nil),
+	)
+	store.EXPECT().AdvanceSubscription(gomock.Any(), gomock.Any()).Times(1)
+
+	// the first run stops before the subscription is advanced
+	for range 2 {
+		if _, err := scheduler.RunOnce(context.Background()); err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+	}
+}
+
+// TestSchedulerRunStops checks that Run returns once its context is canceled
+// and does not start the charges left in the batch.
+func TestSchedulerRunStops(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	scheduler := NewScheduler(store, payments.NewFakeProvider("whsec_test"), testPolicy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store.EXPECT().ListSettledSubscriptionCharges(gomock.Any(), testPolicy.BatchSize).Times(1)
+	store.EXPECT().
+		ClaimDueSubscriptions(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(context.Context, db.ClaimDueSubscriptionsParams) ([]db.Subscription, error) {
+			cancel()
+			return []db.Subscription{{ID: 7}, {ID: 8}}, nil
+		})
+	store.EXPECT().DonationTx(gomock.Any(), gomock.Any()).Times(0)
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was canceled")
+	}
+}
+
+func TestNextBillingDate(t *testing.T) {
+	endOfJanuary := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		anchor   time.Time
+		interval string
+		t        time.Time
+		want     time.Time
+	}{
+		{
+			name:     "BeforeAnchor",
+			anchor:   endOfJanuary,
+			interval: db.SubscriptionMonthly,
+			t:        endOfJanuary.Add(-time.Hour),
+			want:     endOfJanuary,
+		},
+		{
+			name:     "ShortMonth",
+			anchor:   endOfJanuary,
+			interval: db.SubscriptionMonthly,
+			t:        endOfJanuary,
+			want:     time.Date(2026, time.February, 28, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// the day clamped in February is not carried into March
+			name:     "AfterShortMonth",
+			anchor:   endOfJanuary,
+			interval: db.SubscriptionMonthly,
+			t:        time.Date(2026, time.February, 28, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, time.March, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "LeapYear",
+			anchor:   time.Date(2027, time.December, 30, 9, 0, 0, 0, time.UTC),
+			interval: db.SubscriptionMonthly,
+			t:        time.Date(2028, time.February, 1, 0, 0, 0, 0, time.UTC),
+			want:     time.Date(2028, time.February, 29, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "LateInMonth",
+			anchor:   endOfJanuary,
+			interval: db.SubscriptionMonthly,
+			t:        time.Date(2026, time.April, 30, 10, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, time.May, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "YearsLater",
+			anchor:   time.Date(2024, time.March, 15, 9, 0, 0, 0, time.UTC),
+			interval: db.SubscriptionMonthly,
+			t:        time.Date(2026, time.January, 15, 8, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, time.January, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Weekly",
+			anchor:   endOfJanuary,
+			interval: db.SubscriptionWeekly,
+			t:        time.Date(2026, time.February, 9, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, time.February, 14, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "WeeklyOnBillingDate",
+			anchor:   endOfJanuary,
+			interval: db.SubscriptionWeekly,
+			t:        time.Date(2026, time.February, 7, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, time.February, 14, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := nextBillingDate(tc.anchor, tc.interval, tc.t)
+			if !got.Equal(tc.want) {
+				t.Fatalf("unexpected billing date: got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerSettle(t *testing.T) {
+	now := time.Date(2026, time.February, 3, 9, 0, 0, 0, time.UTC)
+	subscription := db.Subscription{
+		ID:                7,
+		Interval:          db.SubscriptionMonthly,
+		Status:            db.SubscriptionActive,
+		NextChargeAt:      time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC),
+		BillingAnchor:     time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC),
+		PendingDonationID: pgtype.Int8{Int64: 11, Valid: true},
+	}
+	cleared := subscription
+	cleared.PendingDonationID = pgtype.Int8{}
+
+	testCases := []struct {
+		name       string
+		status     string
+		buildStubs func(store *mockdb.MockStore)
+	}{
+		{
+			name:   "Succeeded",
+			status: db.DonationSucceeded,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ClearSubscriptionPendingCharge(gomock.Any(), db.ClearSubscriptionPendingChargeParams{
+						ID:                subscription.ID,
+						PendingDonationID: subscription.PendingDonationID,
+					}).
+					Times(1).
+					Return(cleared, nil)
+				store.EXPECT().
+					AdvanceSubscription(gomock.Any(), db.AdvanceSubscriptionParams{
+						ID:           subscription.ID,
+						NextChargeAt: time.Date(2026, time.February, 28, 9, 0, 0, 0, time.UTC),
+					}).
+					Times(1)
+			},
+		},
+		{
+			name:   "Failed",
+			status: db.DonationFailed,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClearSubscriptionPendingCharge(gomock.Any(), gomock.Any()).Times(1).Return(cleared, nil)
+				// the webhook already failed the donation
+				store.EXPECT().FailDonation(gomock.Any(), gomock.Any()).Times(1).Return(db.Donation{}, pgx.ErrNoRows)
+				store.EXPECT().
+					RecordSubscriptionFailure(gomock.Any(), gomock.Cond(func(arg db.RecordSubscriptionFailureParams) bool {
+						return arg.ID == subscription.ID && arg.NextChargeAt.Equal(now.Add(testPolicy.RetryDelay)) &&
+							arg.LastError.String == "insufficient funds"
+					})).
+					Times(1)
+				store.EXPECT().AdvanceSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name:   "AlreadySettled",
+			status: db.DonationSucceeded,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClearSubscriptionPendingCharge(gomock.Any(), gomock.Any()).Times(1).Return(db.Subscription{}, pgx.ErrNoRows)
+				store.EXPECT().AdvanceSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name:   "StillPending",
+			status: db.DonationPending,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ClearSubscriptionPendingCharge(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			scheduler := NewScheduler(store, payments.NewFakeProvider("whsec_test"), testPolicy)
+			scheduler.now = func() time.Time { return now }
+
+			donation := db.Donation{
+				ID:             subscription.PendingDonationID.Int64,
+				Status:         tc.status,
+				FailureReason:  pgtype.Text{String: "insufficient funds", Valid: true},
+				SubscriptionID: pgtype.Int8{Int64: subscription.ID, Valid: true},
+			}
+			if err := scheduler.Settle(context.Background(), donation); err != nil {
+				t.Fatalf("Settle failed: %v", err)
+			}
+		})
+	}
+}
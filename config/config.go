@@ -15,6 +15,137 @@ type Config struct {
 	TokenSymmetricKey    string        `mapstructure:"token_symmetric_key"`
 	AccessTokenDuration  time.Duration `mapstructure:"access_token_duration"`
 	RefreshTokenDuration time.Duration `mapstructure:"refresh_token_duration"`
+
+	// DBMaxConns and DBMinConns bound the size of the database connection
+	// pool. Connections idle for longer than DBMaxConnIdleTime are closed
+	// down to DBMinConns, and idle connections are health checked every
+	// DBHealthCheckPeriod.
+	DBMaxConns          int32         `mapstructure:"db_max_conns"`
+	DBMinConns          int32         `mapstructure:"db_min_conns"`
+	DBMaxConnIdleTime   time.Duration `mapstructure:"db_max_conn_idle_time"`
+	DBHealthCheckPeriod time.Duration `mapstructure:"db_health_check_period"`
+
+	// TokenFormat selects the token format: "paseto" or "jwt".
+	TokenFormat string `mapstructure:"token_format"`
+	// TokenKeySet is the path of an Ed25519 key set file. When set, tokens
+	// are signed asymmetrically (PASETO v4.public or EdDSA JWT) and
+	// TokenSymmetricKey is not needed.
+	TokenKeySet string `mapstructure:"token_key_set"`
+
+	// RevocationSyncInterval controls how often revoked tokens recorded by
+	// other server instances are pulled into the in-process cache.
+	RevocationSyncInterval time.Duration `mapstructure:"revocation_sync_interval"`
+
+	// EmailSender selects how emails are delivered: "smtp", "file" (written
+	// to MailDir) or "log".
+	EmailSender        string `mapstructure:"email_sender"`
+	EmailSenderName    string `mapstructure:"email_sender_name"`
+	EmailSenderAddress string `mapstructure:"email_sender_address"`
+	SMTPHost           string `mapstructure:"smtp_host"`
+	SMTPPort           int    `mapstructure:"smtp_port"`
+	SMTPUsername       string `mapstructure:"smtp_username"`
+	SMTPPassword       string `mapstructure:"smtp_password"`
+	MailDir            string `mapstructure:"mail_dir"`
+
+	// AppBaseURL is used to build links sent by email.
+	AppBaseURL          string        `mapstructure:"app_base_url"`
+	VerifyEmailDuration time.Duration `mapstructure:"verify_email_duration"`
+	// PasswordResetDuration is how long a password reset link stays valid.
+	PasswordResetDuration time.Duration `mapstructure:"password_reset_duration"`
+	// MFAPendingTokenDuration is how long a user has to enter their second
+	// factor after a correct password.
+	MFAPendingTokenDuration time.Duration `mapstructure:"mfa_pending_token_duration"`
+	// MFAIssuer names this service in authenticator apps.
+	MFAIssuer string `mapstructure:"mfa_issuer"`
+	// UnverifiedDonationLimit is the largest donation, in the smallest
+	// currency unit, accepted from a user whose email is not verified.
+	UnverifiedDonationLimit int64 `mapstructure:"unverified_donation_limit"`
+
+	// PasswordHasher selects the algorithm for new password hashes:
+	// "bcrypt" or "argon2id". Stored hashes made with other settings are
+	// upgraded on the next successful login.
+	PasswordHasher    string `mapstructure:"password_hasher"`
+	BcryptCost        int    `mapstructure:"bcrypt_cost"`
+	Argon2Memory      uint32 `mapstructure:"argon2_memory"` // KiB
+	Argon2Iterations  uint32 `mapstructure:"argon2_iterations"`
+	Argon2Parallelism uint8  `mapstructure:"argon2_parallelism"`
+	PasswordMinLength int    `mapstructure:"password_min_length"`
+	PasswordDenylist  string `mapstructure:"password_denylist"`
+
+	// OAuthProviders configures social login, keyed by the provider name
+	// used in /oauth/:provider routes.
+	OAuthProviders map[string]OAuthProviderConfig `mapstructure:"oauth_providers"`
+	// OAuthStateDuration is how long a user has to complete a social login.
+	OAuthStateDuration time.Duration `mapstructure:"oauth_state_duration"`
+
+	// LoginLockoutStore selects where failed login counters are kept:
+	// "postgres" (shared and persistent) or "memory".
+	LoginLockoutStore string `mapstructure:"login_lockout_store"`
+	// LoginMaxFailuresPerEmail and LoginMaxFailuresPerIP are the numbers of
+	// consecutive failed logins after which an account or a client address
+	// is locked out for LoginLockoutBase, doubling on every further failure
+	// up to LoginLockoutMax.
+	LoginMaxFailuresPerEmail int32         `mapstructure:"login_max_failures_per_email"`
+	LoginMaxFailuresPerIP    int32         `mapstructure:"login_max_failures_per_ip"`
+	LoginLockoutBase         time.Duration `mapstructure:"login_lockout_base"`
+	LoginLockoutMax          time.Duration `mapstructure:"login_lockout_max"`
+	// LoginFailureWindow is how long a failed login is remembered.
+	LoginFailureWindow time.Duration `mapstructure:"login_failure_window"`
+
+	// PaymentProvider selects the service donations are collected through:
+	// "stripe" or "fake", an in-process stand-in for local development.
+	// It has no default and must be set.
+	PaymentProvider string `mapstructure:"payment_provider"`
+	// StripeAPIKey is the secret key used with the Stripe API, which is
+	// served from StripeAPIURL.
+	StripeAPIKey string `mapstructure:"stripe_api_key"`
+	StripeAPIURL string `mapstructure:"stripe_api_url"`
+	// PaymentWebhookSecret signs the webhooks sent by the payment provider.
+	// It is required for every provider.
+	PaymentWebhookSecret string `mapstructure:"payment_webhook_secret"`
+	// PaymentWebhookTolerance is how long after it was signed a webhook
+	// is still accepted, limiting the replay of captured requests.
+	PaymentWebhookTolerance time.Duration `mapstructure:"payment_webhook_tolerance"`
+
+	// DonorRefundWindow is how long after a donation was paid its donor
+	// may refund it themselves. Admins may refund at any time.
+	DonorRefundWindow time.Duration `mapstructure:"donor_refund_window"`
+
+	// SubscriptionScheduleInterval is how often due subscriptions are
+	// charged, in batches of SubscriptionBatchSize. A failed charge is
+	// retried after SubscriptionRetryDelay, doubling on every further
+	// failure, and the subscription is canceled after
+	// SubscriptionMaxRetries failed retries.
+	SubscriptionScheduleInterval time.Duration `mapstructure:"subscription_schedule_interval"`
+	SubscriptionBatchSize        int32         `mapstructure:"subscription_batch_size"`
+	SubscriptionRetryDelay       time.Duration `mapstructure:"subscription_retry_delay"`
+	SubscriptionMaxRetries       int32         `mapstructure:"subscription_max_retries"`
+	// SubscriptionChargeLease is how long a claimed subscription is
+	// reserved; a charge interrupted by a crash is retried after it.
+	SubscriptionChargeLease time.Duration `mapstructure:"subscription_charge_lease"`
+
+	// IdempotencyKeyTTL is how long the response to a request sent with an
+	// Idempotency-Key header is kept for replay.
+	IdempotencyKeyTTL time.Duration `mapstructure:"idempotency_key_ttl"`
+	// IdempotencyKeyPruneInterval controls how often expired idempotency
+	// keys are deleted.
+	IdempotencyKeyPruneInterval time.Duration `mapstructure:"idempotency_key_prune_interval"`
+}
+
+// OAuthProviderConfig describes an identity provider. For "oidc" providers
+// the endpoints are discovered from IssuerURL unless AuthURL, TokenURL and
+// JWKSURL are all given, e.g. to point at a local mock provider.
+type OAuthProviderConfig struct {
+	// Type is "oidc" or "github".
+	Type         string   `mapstructure:"type"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`
+	IssuerURL    string   `mapstructure:"issuer_url"`
+	AuthURL      string   `mapstructure:"auth_url"`
+	TokenURL     string   `mapstructure:"token_url"`
+	JWKSURL      string   `mapstructure:"jwks_url"`
+	APIURL       string   `mapstructure:"api_url"`
 }
 
 // Load reads configuration from config.yaml (if present) and environment variables.
@@ -38,8 +169,43 @@ func Load() (*Config, error) {
 
 	// Defaults
 	v.SetDefault("server_address", ":8080")
+	v.SetDefault("db_max_conns", 10)
+	v.SetDefault("db_max_conn_idle_time", "30m")
+	v.SetDefault("db_health_check_period", "1m")
+	v.SetDefault("token_format", "paseto")
 	v.SetDefault("access_token_duration", "15m")
 	v.SetDefault("refresh_token_duration", "720h") // 30 days
+	v.SetDefault("revocation_sync_interval", "30s")
+	v.SetDefault("email_sender", "log")
+	v.SetDefault("payment_webhook_tolerance", "5m")
+	v.SetDefault("donor_refund_window", "720h") // 30 days
+	v.SetDefault("subscription_schedule_interval", "1m")
+	v.SetDefault("subscription_batch_size", 50)
+	v.SetDefault("subscription_retry_delay", "24h")
+	v.SetDefault("subscription_max_retries", 3)
+	v.SetDefault("subscription_charge_lease", "10m")
+	v.SetDefault("email_sender_name", "Charity")
+	v.SetDefault("smtp_port", 587)
+	v.SetDefault("mail_dir", "tmp/mail")
+	v.SetDefault("app_base_url", "http://localhost:8080")
+	v.SetDefault("verify_email_duration", "24h")
+	v.SetDefault("password_reset_duration", "15m")
+	v.SetDefault("mfa_pending_token_duration", "5m")
+	v.SetDefault("mfa_issuer", "Charity")
+	v.SetDefault("unverified_donation_limit", 10000)
+	v.SetDefault("password_hasher", "bcrypt")
+	v.SetDefault("bcrypt_cost", 10)
+	v.SetDefault("argon2_memory", 64*1024)
+	v.SetDefault("argon2_iterations", 3)
+	v.SetDefault("argon2_parallelism", 2)
+	v.SetDefault("password_min_length", 8)
+	v.SetDefault("oauth_state_duration", "10m")
+	v.SetDefault("login_lockout_store", "postgres")
+	v.SetDefault("login_max_failures_per_email", 5)
+	v.SetDefault("login_max_failures_per_ip", 20)
+	v.SetDefault("login_lockout_base", "1m")
+	v.SetDefault("login_lockout_max", "1h")
+	v.SetDefault("login_failure_window", "15m")
 
 	// Load config file if present; it's optional
 	if err := v.ReadInConfig(); err != nil {
@@ -69,8 +235,8 @@ func Load() (*Config, error) {
 	}
 	if cfg.TokenSymmetricKey == "" {
 		cfg.TokenSymmetricKey = os.Getenv("TOKEN_SYMMETRIC_KEY")
-		if cfg.TokenSymmetricKey == "" {
-			return nil, fmt.Errorf("token_symmetric_key / TOKEN_SYMMETRIC_KEY is required")
+		if cfg.TokenSymmetricKey == "" && cfg.TokenKeySet == "" {
+			return nil, fmt.Errorf("token_symmetric_key / TOKEN_SYMMETRIC_KEY or token_key_set is required")
 		}
 	}
 
@@ -83,6 +249,136 @@ func Load() (*Config, error) {
 	if cfg.RefreshTokenDuration == 0 {
 		cfg.RefreshTokenDuration = 720 * time.Hour
 	}
+	cfg.DBMaxConnIdleTime = v.GetDuration("db_max_conn_idle_time")
+	if cfg.DBMaxConnIdleTime == 0 {
+		cfg.DBMaxConnIdleTime = 30 * time.Minute
+	}
+	cfg.DBHealthCheckPeriod = v.GetDuration("db_health_check_period")
+	if cfg.DBHealthCheckPeriod == 0 {
+		cfg.DBHealthCheckPeriod = time.Minute
+	}
+	cfg.RevocationSyncInterval = v.GetDuration("revocation_sync_interval")
+	if cfg.RevocationSyncInterval == 0 {
+		cfg.RevocationSyncInterval = 30 * time.Second
+	}
+	cfg.VerifyEmailDuration = v.GetDuration("verify_email_duration")
+	if cfg.VerifyEmailDuration == 0 {
+		cfg.VerifyEmailDuration = 24 * time.Hour
+	}
+	cfg.PasswordResetDuration = v.GetDuration("password_reset_duration")
+	if cfg.PasswordResetDuration == 0 {
+		cfg.PasswordResetDuration = 15 * time.Minute
+	}
+	cfg.MFAPendingTokenDuration = v.GetDuration("mfa_pending_token_duration")
+	if cfg.MFAPendingTokenDuration == 0 {
+		cfg.MFAPendingTokenDuration = 5 * time.Minute
+	}
+	cfg.OAuthStateDuration = v.GetDuration("oauth_state_duration")
+	if cfg.OAuthStateDuration == 0 {
+		cfg.OAuthStateDuration = 10 * time.Minute
+	}
+	cfg.LoginLockoutBase = v.GetDuration("login_lockout_base")
+	if cfg.LoginLockoutBase == 0 {
+		cfg.LoginLockoutBase = time.Minute
+	}
+	cfg.LoginLockoutMax = v.GetDuration("login_lockout_max")
+	if cfg.LoginLockoutMax == 0 {
+		cfg.LoginLockoutMax = time.Hour
+	}
+	cfg.LoginFailureWindow = v.GetDuration("login_failure_window")
+	if cfg.LoginFailureWindow == 0 {
+		cfg.LoginFailureWindow = 15 * time.Minute
+	}
+	cfg.PaymentWebhookTolerance = v.GetDuration("payment_webhook_tolerance")
+	if cfg.PaymentWebhookTolerance == 0 {
+		cfg.PaymentWebhookTolerance = 5 * time.Minute
+	}
+	cfg.DonorRefundWindow = v.GetDuration("donor_refund_window")
+	if cfg.DonorRefundWindow == 0 {
+		cfg.DonorRefundWindow = 720 * time.Hour
+	}
+	cfg.SubscriptionScheduleInterval = v.GetDuration("subscription_schedule_interval")
+	cfg.SubscriptionRetryDelay = v.GetDuration("subscription_retry_delay")
+	cfg.SubscriptionChargeLease = v.GetDuration("subscription_charge_lease")
+	cfg.IdempotencyKeyTTL = v.GetDuration("idempotency_key_ttl")
+	if cfg.IdempotencyKeyTTL == 0 {
+		cfg.IdempotencyKeyTTL = 24 * time.Hour
+	}
+	cfg.IdempotencyKeyPruneInterval = v.GetDuration("idempotency_key_prune_interval")
+	if cfg.IdempotencyKeyPruneInterval == 0 {
+		cfg.IdempotencyKeyPruneInterval = time.Hour
+	}
+
+	if cfg.DBMaxConns <= 0 {
+		return nil, fmt.Errorf("db_max_conns must be positive")
+	}
+	if cfg.DBMinConns < 0 || cfg.DBMinConns > cfg.DBMaxConns {
+		return nil, fmt.Errorf("db_min_conns must be between 0 and db_max_conns")
+	}
+
+	switch cfg.TokenFormat {
+	case "paseto", "jwt":
+	default:
+		return nil, fmt.Errorf("token_format must be one of paseto or jwt")
+	}
+
+	switch cfg.EmailSender {
+	case "smtp", "file", "log":
+	default:
+		return nil, fmt.Errorf("email_sender must be one of smtp, file or log")
+	}
+
+	// there is no default provider, so a deployment never takes fake
+	// payments by accident
+	switch cfg.PaymentProvider {
+	case "stripe":
+		if cfg.StripeAPIKey == "" {
+			return nil, fmt.Errorf("stripe_api_key is required for the stripe payment provider")
+		}
+	case "fake":
+	default:
+		return nil, fmt.Errorf("payment_provider must be set to one of stripe or fake")
+	}
+	if cfg.PaymentWebhookSecret == "" {
+		return nil, fmt.Errorf("payment_webhook_secret is required")
+	}
+
+	switch cfg.PasswordHasher {
+	case "bcrypt", "argon2id":
+	default:
+		return nil, fmt.Errorf("password_hasher must be one of bcrypt or argon2id")
+	}
+
+	for name, provider := range cfg.OAuthProviders {
+		switch provider.Type {
+		case "oidc":
+			if provider.IssuerURL == "" {
+				return nil, fmt.Errorf("oauth provider %s: issuer_url is required", name)
+			}
+		case "github":
+		default:
+			return nil, fmt.Errorf("oauth provider %s: type must be one of oidc or github", name)
+		}
+		if provider.ClientID == "" {
+			return nil, fmt.Errorf("oauth provider %s: client_id is required", name)
+		}
+	}
+
+	if cfg.SubscriptionScheduleInterval <= 0 || cfg.SubscriptionRetryDelay <= 0 || cfg.SubscriptionChargeLease <= 0 {
+		return nil, fmt.Errorf("subscription_schedule_interval, subscription_retry_delay and subscription_charge_lease must be positive")
+	}
+	if cfg.SubscriptionBatchSize <= 0 || cfg.SubscriptionMaxRetries <= 0 {
+		return nil, fmt.Errorf("subscription_batch_size and subscription_max_retries must be positive")
+	}
+
+	switch cfg.LoginLockoutStore {
+	case "postgres", "memory":
+	default:
+		return nil, fmt.Errorf("login_lockout_store must be one of postgres or memory")
+	}
+	if cfg.LoginMaxFailuresPerEmail <= 0 || cfg.LoginMaxFailuresPerIP <= 0 {
+		return nil, fmt.Errorf("login_max_failures_per_email and login_max_failures_per_ip must be positive")
+	}
 
 	return &cfg, nil
 }
@@ -3,7 +3,9 @@ CREATE TABLE "users" (
   "email" varchar UNIQUE NOT NULL,
   "name" varchar,
   "password" varchar,
-  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+  "created_at" timestamptz NOT NULL DEFAULT 'now()',
+  "role" varchar NOT NULL DEFAULT 'donor',
+  "is_email_verified" boolean NOT NULL DEFAULT false
 );
 
 CREATE TABLE "goals" (
@@ -23,6 +25,213 @@ CREATE TABLE "donations" (
   "amount" bigint NOT NULL,
   "currency" varchar NOT NULL DEFAULT 'USD',
   "is_anonymous" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()',
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "provider" varchar NOT NULL DEFAULT 'manual',
+  "payment_intent_id" varchar,
+  "failure_reason" varchar,
+  "confirmed_at" timestamptz,
+  "confirmation_token_hash" varchar,
+  "subscription_id" bigint
+);
+
+CREATE TABLE "sessions" (
+  "id" uuid PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "family_id" uuid NOT NULL,
+  "refresh_token" varchar NOT NULL,
+  "user_agent" varchar NOT NULL,
+  "client_ip" varchar NOT NULL,
+  "is_blocked" boolean NOT NULL DEFAULT false,
+  "rotated_at" timestamptz,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "token_revocations" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "token_id" uuid,
+  "revoked_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "verify_emails" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "email" varchar NOT NULL,
+  "secret_code" varchar NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()',
+  "expired_at" timestamptz NOT NULL
+);
+
+CREATE TABLE "password_resets" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()',
+  "expired_at" timestamptz NOT NULL
+);
+
+CREATE TABLE "login_attempts" (
+  "key" varchar PRIMARY KEY,
+  "failures" integer NOT NULL,
+  "last_failed_at" timestamptz NOT NULL,
+  "locked_until" timestamptz
+);
+
+CREATE TABLE "user_totps" (
+  "user_id" bigint PRIMARY KEY,
+  "secret" varchar NOT NULL,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "confirmed_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "mfa_recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "user_identities" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "provider" varchar NOT NULL,
+  "subject" varchar NOT NULL,
+  "email" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "oauth_states" (
+  "state_hash" varchar PRIMARY KEY,
+  "provider" varchar NOT NULL,
+  "code_verifier" varchar NOT NULL,
+  "nonce" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "organizations" (
+  "id" bigserial PRIMARY KEY,
+  "name" varchar UNIQUE NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "api_keys" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint,
+  "organization_id" bigint,
+  "name" varchar NOT NULL,
+  "prefix" varchar UNIQUE NOT NULL,
+  "key_hash" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "created_by" bigint NOT NULL,
+  "last_used_at" timestamptz,
+  "expires_at" timestamptz,
+  "revoked_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "ledger_accounts" (
+  "id" bigserial PRIMARY KEY,
+  "code" varchar UNIQUE NOT NULL,
+  "kind" varchar NOT NULL,
+  "goal_id" bigint,
+  "currency" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "payouts" (
+  "id" bigserial PRIMARY KEY,
+  "goal_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "provider" varchar NOT NULL,
+  "reference" varchar NOT NULL,
+  "created_by" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "ledger_entries" (
+  "id" bigserial PRIMARY KEY,
+  "kind" varchar NOT NULL,
+  "currency" varchar NOT NULL,
+  "donation_id" bigint,
+  "payout_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()',
+  "refund_id" bigint
+);
+
+CREATE TABLE "ledger_postings" (
+  "id" bigserial PRIMARY KEY,
+  "entry_id" bigint NOT NULL,
+  "account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "idempotency_keys" (
+  "id" bigserial PRIMARY KEY,
+  "scope" varchar NOT NULL,
+  "key" varchar NOT NULL,
+  "request_hash" varchar NOT NULL,
+  "lock_id" uuid,
+  "response_status" integer,
+  "response_body" bytea,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "payment_events" (
+  "id" bigserial PRIMARY KEY,
+  "provider" varchar NOT NULL,
+  "event_id" varchar NOT NULL,
+  "event_type" varchar NOT NULL,
+  "payment_intent_id" varchar,
+  "payload" bytea NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'received',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_error" varchar,
+  "processed_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "refunds" (
+  "id" bigserial PRIMARY KEY,
+  "donation_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "reason" varchar NOT NULL,
+  "note" varchar,
+  "status" varchar NOT NULL,
+  "provider" varchar NOT NULL,
+  "provider_refund_id" varchar,
+  "created_by" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "subscriptions" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "goal_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "interval" varchar NOT NULL,
+  "payment_method" varchar NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "next_charge_at" timestamptz NOT NULL,
+  "billing_anchor" timestamptz NOT NULL,
+  "pending_donation_id" bigint,
+  "failed_attempts" integer NOT NULL DEFAULT 0,
+  "last_error" varchar,
+  "locked_until" timestamptz,
+  "canceled_at" timestamptz,
   "created_at" timestamptz NOT NULL DEFAULT 'now()'
 );
 
@@ -32,10 +241,210 @@ CREATE INDEX ON "donations" ("goal_id");
 
 CREATE INDEX ON "donations" ("user_id");
 
+CREATE UNIQUE INDEX ON "donations" ("provider", "payment_intent_id");
+
+CREATE INDEX ON "donations" ("status");
+
+CREATE INDEX ON "sessions" ("user_id");
+
+CREATE INDEX ON "sessions" ("family_id");
+
+CREATE INDEX ON "token_revocations" ("expires_at");
+
+CREATE INDEX ON "verify_emails" ("user_id");
+
+CREATE INDEX ON "password_resets" ("user_id");
+
+CREATE INDEX ON "mfa_recovery_codes" ("user_id");
+
+CREATE UNIQUE INDEX ON "user_identities" ("provider", "subject");
+
+CREATE INDEX ON "user_identities" ("user_id");
+
+CREATE INDEX ON "oauth_states" ("expires_at");
+
+CREATE INDEX ON "api_keys" ("user_id");
+
+CREATE INDEX ON "api_keys" ("organization_id");
+
+CREATE INDEX ON "ledger_accounts" ("goal_id");
+
+CREATE INDEX ON "payouts" ("goal_id");
+
+CREATE INDEX ON "ledger_entries" ("donation_id");
+
+CREATE INDEX ON "ledger_entries" ("payout_id");
+
+CREATE INDEX ON "ledger_postings" ("entry_id");
+
+CREATE INDEX ON "ledger_postings" ("account_id");
+
+CREATE UNIQUE INDEX ON "idempotency_keys" ("scope", "key");
+
+CREATE INDEX ON "idempotency_keys" ("expires_at");
+
+CREATE UNIQUE INDEX ON "payment_events" ("provider", "event_id");
+
+CREATE INDEX ON "payment_events" ("provider", "payment_intent_id");
+
+CREATE INDEX ON "payment_events" ("status");
+
+CREATE INDEX ON "refunds" ("donation_id");
+
+CREATE UNIQUE INDEX ON "refunds" ("provider", "provider_refund_id");
+
+CREATE INDEX ON "ledger_entries" ("refund_id");
+
+CREATE INDEX ON "subscriptions" ("user_id");
+
+CREATE INDEX ON "subscriptions" ("status", "next_charge_at");
+
+CREATE INDEX ON "donations" ("subscription_id");
+
 COMMENT ON COLUMN "goals"."target_amount" IS 'in smallest currency unit, e.g., cents';
 
 COMMENT ON COLUMN "donations"."amount" IS 'must be positive';
 
+COMMENT ON COLUMN "donations"."status" IS 'pending, succeeded, failed or refunded';
+
+COMMENT ON COLUMN "donations"."provider" IS 'payment provider collecting the donation';
+
+COMMENT ON COLUMN "donations"."payment_intent_id" IS 'provider reference of the payment';
+
+COMMENT ON COLUMN "donations"."confirmed_at" IS 'when the provider confirmed the payment';
+
+COMMENT ON COLUMN "donations"."confirmation_token_hash" IS 'SHA-256 of the token that lets the donor of an anonymous donation confirm it';
+
+COMMENT ON COLUMN "sessions"."family_id" IS 'id of the session created at login; shared by every rotation of it';
+
+COMMENT ON COLUMN "token_revocations"."token_id" IS 'revokes a single token; NULL revokes every token issued to the user up to revoked_at';
+
+COMMENT ON COLUMN "token_revocations"."expires_at" IS 'once every affected token has expired the record can be pruned';
+
+COMMENT ON COLUMN "password_resets"."token_hash" IS 'hex-encoded SHA-256 of the token sent by email';
+
+COMMENT ON COLUMN "login_attempts"."key" IS 'what is being throttled, e.g. email:jane@example.com or ip:203.0.113.7';
+
+COMMENT ON COLUMN "user_totps"."secret" IS 'base32-encoded TOTP shared secret';
+
+COMMENT ON COLUMN "user_totps"."last_used_step" IS 'time step of the last accepted code, to reject replays';
+
+COMMENT ON COLUMN "user_totps"."confirmed_at" IS 'null until enrollment is confirmed with a first code';
+
+COMMENT ON COLUMN "user_identities"."subject" IS 'stable user ID assigned by the provider (OIDC sub claim)';
+
+COMMENT ON COLUMN "oauth_states"."state_hash" IS 'hex-encoded SHA-256 of the state parameter';
+
+COMMENT ON COLUMN "oauth_states"."code_verifier" IS 'PKCE code verifier sent with the token request';
+
+COMMENT ON COLUMN "api_keys"."prefix" IS 'public part of the key, used to look it up';
+
+COMMENT ON COLUMN "api_keys"."key_hash" IS 'hex-encoded SHA-256 of the full key';
+
+COMMENT ON COLUMN "api_keys"."expires_at" IS 'null for keys that do not expire';
+
+COMMENT ON COLUMN "ledger_accounts"."code" IS 'e.g. goal:12:USD, clearing:stripe:USD, receivable:12:USD';
+
+COMMENT ON COLUMN "ledger_accounts"."kind" IS 'goal, clearing or receivable';
+
+COMMENT ON COLUMN "ledger_accounts"."goal_id" IS 'set for goal and receivable accounts only';
+
+COMMENT ON COLUMN "payouts"."provider" IS 'clearing account the funds are paid out from';
+
+COMMENT ON COLUMN "payouts"."reference" IS 'bank transfer or provider payout reference';
+
+COMMENT ON COLUMN "ledger_entries"."kind" IS 'donation, refund or payout';
+
+COMMENT ON COLUMN "ledger_postings"."amount" IS 'positive for a debit, negative for a credit; the postings of an entry sum to zero';
+
+COMMENT ON COLUMN "idempotency_keys"."scope" IS 'caller the key belongs to, e.g. user:42';
+
+COMMENT ON COLUMN "idempotency_keys"."request_hash" IS 'hex-encoded SHA-256 of the method, path and body';
+
+COMMENT ON COLUMN "idempotency_keys"."lock_id" IS 'set while the first request is still being processed';
+
+COMMENT ON COLUMN "idempotency_keys"."expires_at" IS 'after which the key may be reused';
+
+COMMENT ON COLUMN "payment_events"."event_id" IS 'id of the event at the provider';
+
+COMMENT ON COLUMN "payment_events"."payload" IS 'raw webhook body, as signed by the provider';
+
+COMMENT ON COLUMN "payment_events"."status" IS 'received, processed, ignored, deferred or failed';
+
+COMMENT ON COLUMN "payment_events"."attempts" IS 'number of times the event was applied';
+
+COMMENT ON COLUMN "refunds"."reason" IS 'duplicate, fraudulent, requested_by_donor, goal_canceled or other';
+
+COMMENT ON COLUMN "refunds"."status" IS 'pending, succeeded or failed; failed refunds are reversed';
+
+COMMENT ON COLUMN "refunds"."provider_refund_id" IS 'provider reference of the refund';
+
+COMMENT ON COLUMN "refunds"."created_by" IS 'null for refunds issued at the provider';
+
+COMMENT ON COLUMN "subscriptions"."interval" IS 'weekly or monthly';
+
+COMMENT ON COLUMN "subscriptions"."payment_method" IS 'provider ID of the card or account charged';
+
+COMMENT ON COLUMN "subscriptions"."status" IS 'active, past_due, paused or canceled';
+
+COMMENT ON COLUMN "subscriptions"."billing_anchor" IS 'first charge, whose weekday or day of the month later charges fall on, or the last day of shorter months';
+
+COMMENT ON COLUMN "subscriptions"."pending_donation_id" IS 'donation of a charge the provider is still processing, which holds off further charges';
+
+COMMENT ON COLUMN "subscriptions"."failed_attempts" IS 'failed charges since the last successful one';
+
+COMMENT ON COLUMN "subscriptions"."locked_until" IS 'set while the scheduler is charging the subscription';
+
+COMMENT ON COLUMN "donations"."subscription_id" IS 'subscription the donation was charged for';
+
 ALTER TABLE "donations" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
 
 ALTER TABLE "donations" ADD FOREIGN KEY ("goal_id") REFERENCES "goals" ("id");
+
+ALTER TABLE "sessions" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "token_revocations" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "verify_emails" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "password_resets" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "user_totps" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "mfa_recovery_codes" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "user_identities" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("organization_id") REFERENCES "organizations" ("id");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "ledger_accounts" ADD FOREIGN KEY ("goal_id") REFERENCES "goals" ("id");
+
+ALTER TABLE "payouts" ADD FOREIGN KEY ("goal_id") REFERENCES "goals" ("id");
+
+ALTER TABLE "payouts" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "ledger_entries" ADD FOREIGN KEY ("donation_id") REFERENCES "donations" ("id");
+
+ALTER TABLE "ledger_entries" ADD FOREIGN KEY ("payout_id") REFERENCES "payouts" ("id");
+
+ALTER TABLE "ledger_postings" ADD FOREIGN KEY ("entry_id") REFERENCES "ledger_entries" ("id");
+
+ALTER TABLE "ledger_postings" ADD FOREIGN KEY ("account_id") REFERENCES "ledger_accounts" ("id");
+
+ALTER TABLE "refunds" ADD FOREIGN KEY ("donation_id") REFERENCES "donations" ("id");
+
+ALTER TABLE "refunds" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "ledger_entries" ADD FOREIGN KEY ("refund_id") REFERENCES "refunds" ("id");
+
+ALTER TABLE "subscriptions" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "subscriptions" ADD FOREIGN KEY ("goal_id") REFERENCES "goals" ("id");
+
+ALTER TABLE "donations" ADD FOREIGN KEY ("subscription_id") REFERENCES "subscriptions" ("id");
+
+ALTER TABLE "subscriptions" ADD FOREIGN KEY ("pending_donation_id") REFERENCES "donations" ("id");
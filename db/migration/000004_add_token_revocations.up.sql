@@ -0,0 +1,16 @@
+CREATE TABLE "token_revocations" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "token_id" uuid,
+  "revoked_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE INDEX ON "token_revocations" ("expires_at");
+
+COMMENT ON COLUMN "token_revocations"."token_id" IS 'revokes a single token; NULL revokes every token issued to the user up to revoked_at';
+
+COMMENT ON COLUMN "token_revocations"."expires_at" IS 'once every affected token has expired the record can be pruned';
+
+ALTER TABLE "token_revocations" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
@@ -0,0 +1,8 @@
+CREATE TABLE "login_attempts" (
+  "key" varchar PRIMARY KEY,
+  "failures" integer NOT NULL,
+  "last_failed_at" timestamptz NOT NULL,
+  "locked_until" timestamptz
+);
+
+COMMENT ON COLUMN "login_attempts"."key" IS 'what is being throttled, e.g. email:jane@example.com or ip:203.0.113.7';
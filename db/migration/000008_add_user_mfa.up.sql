@@ -0,0 +1,27 @@
+CREATE TABLE "user_totps" (
+  "user_id" bigint PRIMARY KEY,
+  "secret" varchar NOT NULL,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "confirmed_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "mfa_recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE INDEX ON "mfa_recovery_codes" ("user_id");
+
+COMMENT ON COLUMN "user_totps"."secret" IS 'base32-encoded TOTP shared secret';
+
+COMMENT ON COLUMN "user_totps"."last_used_step" IS 'time step of the last accepted code, to reject replays';
+
+COMMENT ON COLUMN "user_totps"."confirmed_at" IS 'null until enrollment is confirmed with a first code';
+
+ALTER TABLE "user_totps" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "mfa_recovery_codes" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "oauth_states";
+DROP TABLE IF EXISTS "user_identities";
+
+ALTER TABLE "users" ALTER COLUMN "password" SET NOT NULL;
@@ -0,0 +1,33 @@
+ALTER TABLE "users" ALTER COLUMN "password" DROP NOT NULL;
+
+CREATE TABLE "user_identities" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "provider" varchar NOT NULL,
+  "subject" varchar NOT NULL,
+  "email" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "oauth_states" (
+  "state_hash" varchar PRIMARY KEY,
+  "provider" varchar NOT NULL,
+  "code_verifier" varchar NOT NULL,
+  "nonce" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE UNIQUE INDEX ON "user_identities" ("provider", "subject");
+
+CREATE INDEX ON "user_identities" ("user_id");
+
+CREATE INDEX ON "oauth_states" ("expires_at");
+
+COMMENT ON COLUMN "user_identities"."subject" IS 'stable user ID assigned by the provider (OIDC sub claim)';
+
+COMMENT ON COLUMN "oauth_states"."state_hash" IS 'hex-encoded SHA-256 of the state parameter';
+
+COMMENT ON COLUMN "oauth_states"."code_verifier" IS 'PKCE code verifier sent with the token request';
+
+ALTER TABLE "user_identities" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
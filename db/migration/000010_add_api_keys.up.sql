@@ -0,0 +1,38 @@
+CREATE TABLE "organizations" (
+  "id" bigserial PRIMARY KEY,
+  "name" varchar UNIQUE NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "api_keys" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint,
+  "organization_id" bigint,
+  "name" varchar NOT NULL,
+  "prefix" varchar UNIQUE NOT NULL,
+  "key_hash" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "created_by" bigint NOT NULL,
+  "last_used_at" timestamptz,
+  "expires_at" timestamptz,
+  "revoked_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE INDEX ON "api_keys" ("user_id");
+
+CREATE INDEX ON "api_keys" ("organization_id");
+
+COMMENT ON COLUMN "api_keys"."prefix" IS 'public part of the key, used to look it up';
+
+COMMENT ON COLUMN "api_keys"."key_hash" IS 'hex-encoded SHA-256 of the full key';
+
+COMMENT ON COLUMN "api_keys"."expires_at" IS 'null for keys that do not expire';
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("organization_id") REFERENCES "organizations" ("id");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "api_keys" ADD CONSTRAINT "api_keys_owner_check" CHECK (("user_id" IS NULL) <> ("organization_id" IS NULL));
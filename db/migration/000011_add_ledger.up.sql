@@ -0,0 +1,171 @@
+CREATE TABLE "ledger_accounts" (
+  "id" bigserial PRIMARY KEY,
+  "code" varchar UNIQUE NOT NULL,
+  "kind" varchar NOT NULL,
+  "goal_id" bigint,
+  "currency" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "payouts" (
+  "id" bigserial PRIMARY KEY,
+  "goal_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "provider" varchar NOT NULL,
+  "reference" varchar NOT NULL,
+  "created_by" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "ledger_entries" (
+  "id" bigserial PRIMARY KEY,
+  "kind" varchar NOT NULL,
+  "currency" varchar NOT NULL,
+  "donation_id" bigint,
+  "payout_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE TABLE "ledger_postings" (
+  "id" bigserial PRIMARY KEY,
+  "entry_id" bigint NOT NULL,
+  "account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE INDEX ON "ledger_accounts" ("goal_id");
+
+CREATE INDEX ON "payouts" ("goal_id");
+
+CREATE INDEX ON "ledger_entries" ("donation_id");
+
+CREATE INDEX ON "ledger_entries" ("payout_id");
+
+CREATE INDEX ON "ledger_postings" ("entry_id");
+
+CREATE INDEX ON "ledger_postings" ("account_id");
+
+COMMENT ON COLUMN "ledger_accounts"."code" IS 'e.g. goal:12:USD, clearing:stripe:USD, receivable:12:USD';
+
+COMMENT ON COLUMN "ledger_accounts"."kind" IS 'goal, clearing or receivable';
+
+COMMENT ON COLUMN "ledger_accounts"."goal_id" IS 'set for goal and receivable accounts only';
+
+COMMENT ON COLUMN "payouts"."provider" IS 'clearing account the funds are paid out from';
+
+COMMENT ON COLUMN "payouts"."reference" IS 'bank transfer or provider payout reference';
+
+COMMENT ON COLUMN "ledger_entries"."kind" IS 'donation, refund or payout';
+
+COMMENT ON COLUMN "ledger_postings"."amount" IS 'positive for a debit, negative for a credit; the postings of an entry sum to zero';
+
+ALTER TABLE "ledger_accounts" ADD FOREIGN KEY ("goal_id") REFERENCES "goals" ("id");
+
+ALTER TABLE "payouts" ADD FOREIGN KEY ("goal_id") REFERENCES "goals" ("id");
+
+ALTER TABLE "payouts" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "ledger_entries" ADD FOREIGN KEY ("donation_id") REFERENCES "donations" ("id");
+
+ALTER TABLE "ledger_entries" ADD FOREIGN KEY ("payout_id") REFERENCES "payouts" ("id");
+
+ALTER TABLE "ledger_postings" ADD FOREIGN KEY ("entry_id") REFERENCES "ledger_entries" ("id");
+
+ALTER TABLE "ledger_postings" ADD FOREIGN KEY ("account_id") REFERENCES "ledger_accounts" ("id");
+
+ALTER TABLE "ledger_accounts" ADD CONSTRAINT "ledger_accounts_kind_check" CHECK ("kind" IN ('goal', 'clearing', 'receivable'));
+
+ALTER TABLE "ledger_accounts" ADD CONSTRAINT "ledger_accounts_goal_check" CHECK (("kind" IN ('goal', 'receivable')) = ("goal_id" IS NOT NULL));
+
+ALTER TABLE "payouts" ADD CONSTRAINT "payouts_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "ledger_entries" ADD CONSTRAINT "ledger_entries_kind_check" CHECK ("kind" IN ('donation', 'refund', 'payout'));
+
+ALTER TABLE "ledger_postings" ADD CONSTRAINT "ledger_postings_amount_check" CHECK ("amount" <> 0);
+
+-- Entries must balance per currency. The check is deferred to commit so
+-- that the postings of an entry can be inserted one at a time.
+CREATE FUNCTION "ledger_check_entry"() RETURNS trigger AS $$
+DECLARE
+  entry bigint;
+BEGIN
+  IF TG_TABLE_NAME = 'ledger_entries' THEN
+    entry := NEW.id;
+  ELSE
+    entry := NEW.entry_id;
+  END IF;
+
+  IF (SELECT count(*) FROM "ledger_postings" WHERE "entry_id" = entry) < 2 THEN
+    RAISE EXCEPTION 'ledger entry % needs at least two postings', entry;
+  END IF;
+  IF (SELECT sum("amount") FROM "ledger_postings" WHERE "entry_id" = entry) <> 0 THEN
+    RAISE EXCEPTION 'ledger entry % is not balanced', entry;
+  END IF;
+  IF EXISTS (
+    SELECT 1
+    FROM "ledger_postings" p
+    JOIN "ledger_accounts" a ON a."id" = p."account_id"
+    JOIN "ledger_entries" e ON e."id" = p."entry_id"
+    WHERE p."entry_id" = entry AND a."currency" <> e."currency"
+  ) THEN
+    RAISE EXCEPTION 'ledger entry % posts to an account in another currency', entry;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER "ledger_entries_balanced"
+  AFTER INSERT ON "ledger_entries"
+  DEFERRABLE INITIALLY DEFERRED
+  FOR EACH ROW EXECUTE FUNCTION "ledger_check_entry"();
+
+CREATE CONSTRAINT TRIGGER "ledger_postings_balanced"
+  AFTER INSERT ON "ledger_postings"
+  DEFERRABLE INITIALLY DEFERRED
+  FOR EACH ROW EXECUTE FUNCTION "ledger_check_entry"();
+
+-- The ledger is append-only: mistakes are corrected with new entries.
+CREATE FUNCTION "ledger_reject_change"() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "ledger_entries_immutable"
+  BEFORE UPDATE OR DELETE ON "ledger_entries"
+  FOR EACH ROW EXECUTE FUNCTION "ledger_reject_change"();
+
+CREATE TRIGGER "ledger_postings_immutable"
+  BEFORE UPDATE OR DELETE ON "ledger_postings"
+  FOR EACH ROW EXECUTE FUNCTION "ledger_reject_change"();
+
+-- Open the ledger with the donations recorded so far, as DonationTx would
+-- have posted them, so that it reconciles with goals.collected_amount.
+-- These donations were settled manually.
+INSERT INTO "ledger_accounts" ("code", "kind", "goal_id", "currency")
+SELECT DISTINCT 'goal:' || "goal_id" || ':' || "currency", 'goal', "goal_id", "currency"
+FROM "donations"
+WHERE "amount" > 0;
+
+INSERT INTO "ledger_accounts" ("code", "kind", "currency")
+SELECT DISTINCT 'clearing:manual:' || "currency", 'clearing', "currency"
+FROM "donations"
+WHERE "amount" > 0;
+
+INSERT INTO "ledger_entries" ("kind", "currency", "donation_id", "created_at")
+SELECT 'donation', "currency", "id", "created_at"
+FROM "donations"
+WHERE "amount" > 0;
+
+INSERT INTO "ledger_postings" ("entry_id", "account_id", "amount", "created_at")
+SELECT e."id", a."id", d."amount", d."created_at"
+FROM "ledger_entries" e
+JOIN "donations" d ON d."id" = e."donation_id"
+JOIN "ledger_accounts" a ON a."code" = 'clearing:manual:' || d."currency"
+UNION ALL
+SELECT e."id", a."id", -d."amount", d."created_at"
+FROM "ledger_entries" e
+JOIN "donations" d ON d."id" = e."donation_id"
+JOIN "ledger_accounts" a ON a."code" = 'goal:' || d."goal_id" || ':' || d."currency";
@@ -0,0 +1,23 @@
+CREATE TABLE "idempotency_keys" (
+  "id" bigserial PRIMARY KEY,
+  "scope" varchar NOT NULL,
+  "key" varchar NOT NULL,
+  "request_hash" varchar NOT NULL,
+  "lock_id" uuid,
+  "response_status" integer,
+  "response_body" bytea,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE UNIQUE INDEX ON "idempotency_keys" ("scope", "key");
+
+CREATE INDEX ON "idempotency_keys" ("expires_at");
+
+COMMENT ON COLUMN "idempotency_keys"."scope" IS 'caller the key belongs to, e.g. user:42';
+
+COMMENT ON COLUMN "idempotency_keys"."request_hash" IS 'hex-encoded SHA-256 of the method, path and body';
+
+COMMENT ON COLUMN "idempotency_keys"."lock_id" IS 'set while the first request is still being processed';
+
+COMMENT ON COLUMN "idempotency_keys"."expires_at" IS 'after which the key may be reused';
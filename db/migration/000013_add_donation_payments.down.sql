@@ -0,0 +1,13 @@
+ALTER TABLE "donations" DROP CONSTRAINT IF EXISTS "donations_status_check";
+
+ALTER TABLE "donations" DROP COLUMN IF EXISTS "confirmation_token_hash";
+
+ALTER TABLE "donations" DROP COLUMN IF EXISTS "confirmed_at";
+
+ALTER TABLE "donations" DROP COLUMN IF EXISTS "failure_reason";
+
+ALTER TABLE "donations" DROP COLUMN IF EXISTS "payment_intent_id";
+
+ALTER TABLE "donations" DROP COLUMN IF EXISTS "provider";
+
+ALTER TABLE "donations" DROP COLUMN IF EXISTS "status";
@@ -0,0 +1,31 @@
+-- donations recorded before payments were collected through a provider
+-- were settled manually
+ALTER TABLE "donations" ADD COLUMN "status" varchar NOT NULL DEFAULT 'succeeded';
+
+ALTER TABLE "donations" ALTER COLUMN "status" SET DEFAULT 'pending';
+
+ALTER TABLE "donations" ADD COLUMN "provider" varchar NOT NULL DEFAULT 'manual';
+
+ALTER TABLE "donations" ADD COLUMN "payment_intent_id" varchar;
+
+ALTER TABLE "donations" ADD COLUMN "failure_reason" varchar;
+
+ALTER TABLE "donations" ADD COLUMN "confirmed_at" timestamptz;
+
+ALTER TABLE "donations" ADD COLUMN "confirmation_token_hash" varchar;
+
+CREATE UNIQUE INDEX ON "donations" ("provider", "payment_intent_id");
+
+CREATE INDEX ON "donations" ("status");
+
+COMMENT ON COLUMN "donations"."status" IS 'pending, succeeded, failed or refunded';
+
+COMMENT ON COLUMN "donations"."provider" IS 'payment provider collecting the donation';
+
+COMMENT ON COLUMN "donations"."payment_intent_id" IS 'provider reference of the payment';
+
+COMMENT ON COLUMN "donations"."confirmed_at" IS 'when the provider confirmed the payment';
+
+COMMENT ON COLUMN "donations"."confirmation_token_hash" IS 'SHA-256 of the token that lets the donor of an anonymous donation confirm it';
+
+ALTER TABLE "donations" ADD CONSTRAINT "donations_status_check" CHECK ("status" IN ('pending', 'succeeded', 'failed', 'refunded'));
@@ -0,0 +1,29 @@
+CREATE TABLE "payment_events" (
+  "id" bigserial PRIMARY KEY,
+  "provider" varchar NOT NULL,
+  "event_id" varchar NOT NULL,
+  "event_type" varchar NOT NULL,
+  "payment_intent_id" varchar,
+  "payload" bytea NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'received',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_error" varchar,
+  "processed_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+CREATE UNIQUE INDEX ON "payment_events" ("provider", "event_id");
+
+CREATE INDEX ON "payment_events" ("provider", "payment_intent_id");
+
+CREATE INDEX ON "payment_events" ("status");
+
+COMMENT ON COLUMN "payment_events"."event_id" IS 'id of the event at the provider';
+
+COMMENT ON COLUMN "payment_events"."payload" IS 'raw webhook body, as signed by the provider';
+
+COMMENT ON COLUMN "payment_events"."status" IS 'received, processed, ignored, deferred or failed';
+
+COMMENT ON COLUMN "payment_events"."attempts" IS 'number of times the event was applied';
+
+ALTER TABLE "payment_events" ADD CONSTRAINT "payment_events_status_check" CHECK ("status" IN ('received', 'processed', 'ignored', 'deferred', 'failed'));
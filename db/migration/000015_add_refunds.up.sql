@@ -0,0 +1,41 @@
+CREATE TABLE "refunds" (
+  "id" bigserial PRIMARY KEY,
+  "donation_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "reason" varchar NOT NULL,
+  "note" varchar,
+  "status" varchar NOT NULL,
+  "provider" varchar NOT NULL,
+  "provider_refund_id" varchar,
+  "created_by" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+ALTER TABLE "ledger_entries" ADD COLUMN "refund_id" bigint;
+
+CREATE INDEX ON "refunds" ("donation_id");
+
+CREATE UNIQUE INDEX ON "refunds" ("provider", "provider_refund_id");
+
+CREATE INDEX ON "ledger_entries" ("refund_id");
+
+COMMENT ON COLUMN "refunds"."reason" IS 'duplicate, fraudulent, requested_by_donor, goal_canceled or other';
+
+COMMENT ON COLUMN "refunds"."status" IS 'pending, succeeded or failed; failed refunds are reversed';
+
+COMMENT ON COLUMN "refunds"."provider_refund_id" IS 'provider reference of the refund';
+
+COMMENT ON COLUMN "refunds"."created_by" IS 'null for refunds issued at the provider';
+
+ALTER TABLE "refunds" ADD FOREIGN KEY ("donation_id") REFERENCES "donations" ("id");
+
+ALTER TABLE "refunds" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "ledger_entries" ADD FOREIGN KEY ("refund_id") REFERENCES "refunds" ("id");
+
+ALTER TABLE "refunds" ADD CONSTRAINT "refunds_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "refunds" ADD CONSTRAINT "refunds_status_check" CHECK ("status" IN ('pending', 'succeeded', 'failed'));
+
+ALTER TABLE "refunds" ADD CONSTRAINT "refunds_reason_check" CHECK ("reason" IN ('duplicate', 'fraudulent', 'requested_by_donor', 'goal_canceled', 'other'));
@@ -0,0 +1,3 @@
+ALTER TABLE "donations" DROP COLUMN IF EXISTS "subscription_id";
+
+DROP TABLE IF EXISTS "subscriptions";
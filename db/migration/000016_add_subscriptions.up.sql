@@ -0,0 +1,56 @@
+CREATE TABLE "subscriptions" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "goal_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "interval" varchar NOT NULL,
+  "payment_method" varchar NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "next_charge_at" timestamptz NOT NULL,
+  "billing_anchor" timestamptz NOT NULL,
+  "pending_donation_id" bigint,
+  "failed_attempts" integer NOT NULL DEFAULT 0,
+  "last_error" varchar,
+  "locked_until" timestamptz,
+  "canceled_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT 'now()'
+);
+
+ALTER TABLE "donations" ADD COLUMN "subscription_id" bigint;
+
+CREATE INDEX ON "subscriptions" ("user_id");
+
+CREATE INDEX ON "subscriptions" ("status", "next_charge_at");
+
+CREATE INDEX ON "donations" ("subscription_id");
+
+COMMENT ON COLUMN "subscriptions"."interval" IS 'weekly or monthly';
+
+COMMENT ON COLUMN "subscriptions"."payment_method" IS 'provider ID of the card or account charged';
+
+COMMENT ON COLUMN "subscriptions"."status" IS 'active, past_due, paused or canceled';
+
+COMMENT ON COLUMN "subscriptions"."billing_anchor" IS 'first charge, whose weekday or day of the month later charges fall on, or the last day of shorter months';
+
+COMMENT ON COLUMN "subscriptions"."pending_donation_id" IS 'donation of a charge the provider is still processing, which holds off further charges';
+
+COMMENT ON COLUMN "subscriptions"."failed_attempts" IS 'failed charges since the last successful one';
+
+COMMENT ON COLUMN "subscriptions"."locked_until" IS 'set while the scheduler is charging the subscription';
+
+COMMENT ON COLUMN "donations"."subscription_id" IS 'subscription the donation was charged for';
+
+ALTER TABLE "subscriptions" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+ALTER TABLE "subscriptions" ADD FOREIGN KEY ("goal_id") REFERENCES "goals" ("id");
+
+ALTER TABLE "donations" ADD FOREIGN KEY ("subscription_id") REFERENCES "subscriptions" ("id");
+
+ALTER TABLE "subscriptions" ADD FOREIGN KEY ("pending_donation_id") REFERENCES "donations" ("id");
+
+ALTER TABLE "subscriptions" ADD CONSTRAINT "subscriptions_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "subscriptions" ADD CONSTRAINT "subscriptions_interval_check" CHECK ("interval" IN ('weekly', 'monthly'));
+
+ALTER TABLE "subscriptions" ADD CONSTRAINT "subscriptions_status_check" CHECK ("status" IN ('active', 'past_due', 'paused', 'canceled'));
@@ -0,0 +1,1937 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: charity/db/sqlc (interfaces: Store)
+//
+// Generated by this command:
+//
+//	mockgen -package mockdb -destination db/mock/store.go charity/db/sqlc Store
+//
+
+// Package mockdb is a generated GoMock package.
+package mockdb
+
+import (
+	db "charity/db/sqlc"
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	uuid "github.com/google/uuid"
+	pgtype "github.com/jackc/pgx/v5/pgtype"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// AddToGoalCollectedAmount mocks base method.
+func (m *MockStore) AddToGoalCollectedAmount(ctx context.Context, arg db.AddToGoalCollectedAmountParams) (db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddToGoalCollectedAmount", ctx, arg)
+	ret0, _ := ret[0].(db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddToGoalCollectedAmount indicates an expected call of AddToGoalCollectedAmount.
+func (mr *MockStoreMockRecorder) AddToGoalCollectedAmount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToGoalCollectedAmount", reflect.TypeOf((*MockStore)(nil).AddToGoalCollectedAmount), ctx, arg)
+}
+
+// AdvanceSubscription mocks base method.
+func (m *MockStore) AdvanceSubscription(ctx context.Context, arg db.AdvanceSubscriptionParams) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdvanceSubscription", ctx, arg)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdvanceSubscription indicates an expected call of AdvanceSubscription.
+func (mr *MockStoreMockRecorder) AdvanceSubscription(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdvanceSubscription", reflect.TypeOf((*MockStore)(nil).AdvanceSubscription), ctx, arg)
+}
+
+// ApplyPaymentEventTx mocks base method.
+func (m *MockStore) ApplyPaymentEventTx(ctx context.Context, arg db.ApplyPaymentEventTxParams) (db.ApplyPaymentEventTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyPaymentEventTx", ctx, arg)
+	ret0, _ := ret[0].(db.ApplyPaymentEventTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyPaymentEventTx indicates an expected call of ApplyPaymentEventTx.
+func (mr *MockStoreMockRecorder) ApplyPaymentEventTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyPaymentEventTx", reflect.TypeOf((*MockStore)(nil).ApplyPaymentEventTx), ctx, arg)
+}
+
+// BlockSessionFamily mocks base method.
+func (m *MockStore) BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSessionFamily", ctx, familyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockSessionFamily indicates an expected call of BlockSessionFamily.
+func (mr *MockStoreMockRecorder) BlockSessionFamily(ctx, familyID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSessionFamily", reflect.TypeOf((*MockStore)(nil).BlockSessionFamily), ctx, familyID)
+}
+
+// BlockUserSessions mocks base method.
+func (m *MockStore) BlockUserSessions(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockUserSessions", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockUserSessions indicates an expected call of BlockUserSessions.
+func (mr *MockStoreMockRecorder) BlockUserSessions(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockUserSessions", reflect.TypeOf((*MockStore)(nil).BlockUserSessions), ctx, userID)
+}
+
+// CancelSubscription mocks base method.
+func (m *MockStore) CancelSubscription(ctx context.Context, id int64) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelSubscription", ctx, id)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelSubscription indicates an expected call of CancelSubscription.
+func (mr *MockStoreMockRecorder) CancelSubscription(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSubscription", reflect.TypeOf((*MockStore)(nil).CancelSubscription), ctx, id)
+}
+
+// ClaimDueSubscriptions mocks base method.
+func (m *MockStore) ClaimDueSubscriptions(ctx context.Context, arg db.ClaimDueSubscriptionsParams) ([]db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueSubscriptions", ctx, arg)
+	ret0, _ := ret[0].([]db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueSubscriptions indicates an expected call of ClaimDueSubscriptions.
+func (mr *MockStoreMockRecorder) ClaimDueSubscriptions(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueSubscriptions", reflect.TypeOf((*MockStore)(nil).ClaimDueSubscriptions), ctx, arg)
+}
+
+// ClearSubscriptionPendingCharge mocks base method.
+func (m *MockStore) ClearSubscriptionPendingCharge(ctx context.Context, arg db.ClearSubscriptionPendingChargeParams) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearSubscriptionPendingCharge", ctx, arg)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClearSubscriptionPendingCharge indicates an expected call of ClearSubscriptionPendingCharge.
+func (mr *MockStoreMockRecorder) ClearSubscriptionPendingCharge(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearSubscriptionPendingCharge", reflect.TypeOf((*MockStore)(nil).ClearSubscriptionPendingCharge), ctx, arg)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockStore) CompleteIdempotencyKey(ctx context.Context, arg db.CompleteIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", ctx, arg)
+	ret0, _ := ret[0].(db.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockStoreMockRecorder) CompleteIdempotencyKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockStore)(nil).CompleteIdempotencyKey), ctx, arg)
+}
+
+// ConfirmDonationTx mocks base method.
+func (m *MockStore) ConfirmDonationTx(ctx context.Context, arg db.ConfirmDonationTxParams) (db.ConfirmDonationTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmDonationTx", ctx, arg)
+	ret0, _ := ret[0].(db.ConfirmDonationTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmDonationTx indicates an expected call of ConfirmDonationTx.
+func (mr *MockStoreMockRecorder) ConfirmDonationTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmDonationTx", reflect.TypeOf((*MockStore)(nil).ConfirmDonationTx), ctx, arg)
+}
+
+// ConfirmTOTPTx mocks base method.
+func (m *MockStore) ConfirmTOTPTx(ctx context.Context, arg db.ConfirmTOTPTxParams) (db.ConfirmTOTPTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTPTx", ctx, arg)
+	ret0, _ := ret[0].(db.ConfirmTOTPTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTPTx indicates an expected call of ConfirmTOTPTx.
+func (mr *MockStoreMockRecorder) ConfirmTOTPTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTPTx", reflect.TypeOf((*MockStore)(nil).ConfirmTOTPTx), ctx, arg)
+}
+
+// ConfirmUserTOTP mocks base method.
+func (m *MockStore) ConfirmUserTOTP(ctx context.Context, userID int64) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmUserTOTP", ctx, userID)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmUserTOTP indicates an expected call of ConfirmUserTOTP.
+func (mr *MockStoreMockRecorder) ConfirmUserTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmUserTOTP", reflect.TypeOf((*MockStore)(nil).ConfirmUserTOTP), ctx, userID)
+}
+
+// ConsumeOAuthState mocks base method.
+func (m *MockStore) ConsumeOAuthState(ctx context.Context, arg db.ConsumeOAuthStateParams) (db.OauthState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeOAuthState", ctx, arg)
+	ret0, _ := ret[0].(db.OauthState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeOAuthState indicates an expected call of ConsumeOAuthState.
+func (mr *MockStoreMockRecorder) ConsumeOAuthState(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeOAuthState", reflect.TypeOf((*MockStore)(nil).ConsumeOAuthState), ctx, arg)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockStore) CreateAPIKey(ctx context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, arg)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockStoreMockRecorder) CreateAPIKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockStore)(nil).CreateAPIKey), ctx, arg)
+}
+
+// CreateAnonymousDonation mocks base method.
+func (m *MockStore) CreateAnonymousDonation(ctx context.Context, arg db.CreateAnonymousDonationParams) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAnonymousDonation", ctx, arg)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAnonymousDonation indicates an expected call of CreateAnonymousDonation.
+func (mr *MockStoreMockRecorder) CreateAnonymousDonation(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAnonymousDonation", reflect.TypeOf((*MockStore)(nil).CreateAnonymousDonation), ctx, arg)
+}
+
+// CreateDonation mocks base method.
+func (m *MockStore) CreateDonation(ctx context.Context, arg db.CreateDonationParams) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDonation", ctx, arg)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDonation indicates an expected call of CreateDonation.
+func (mr *MockStoreMockRecorder) CreateDonation(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDonation", reflect.TypeOf((*MockStore)(nil).CreateDonation), ctx, arg)
+}
+
+// CreateGoal mocks base method.
+func (m *MockStore) CreateGoal(ctx context.Context, arg db.CreateGoalParams) (db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGoal", ctx, arg)
+	ret0, _ := ret[0].(db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateGoal indicates an expected call of CreateGoal.
+func (mr *MockStoreMockRecorder) CreateGoal(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGoal", reflect.TypeOf((*MockStore)(nil).CreateGoal), ctx, arg)
+}
+
+// CreateLedgerEntry mocks base method.
+func (m *MockStore) CreateLedgerEntry(ctx context.Context, arg db.CreateLedgerEntryParams) (db.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLedgerEntry", ctx, arg)
+	ret0, _ := ret[0].(db.LedgerEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLedgerEntry indicates an expected call of CreateLedgerEntry.
+func (mr *MockStoreMockRecorder) CreateLedgerEntry(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLedgerEntry", reflect.TypeOf((*MockStore)(nil).CreateLedgerEntry), ctx, arg)
+}
+
+// CreateLedgerPosting mocks base method.
+func (m *MockStore) CreateLedgerPosting(ctx context.Context, arg db.CreateLedgerPostingParams) (db.LedgerPosting, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLedgerPosting", ctx, arg)
+	ret0, _ := ret[0].(db.LedgerPosting)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLedgerPosting indicates an expected call of CreateLedgerPosting.
+func (mr *MockStoreMockRecorder) CreateLedgerPosting(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLedgerPosting", reflect.TypeOf((*MockStore)(nil).CreateLedgerPosting), ctx, arg)
+}
+
+// CreateMFARecoveryCode mocks base method.
+func (m *MockStore) CreateMFARecoveryCode(ctx context.Context, arg db.CreateMFARecoveryCodeParams) (db.MfaRecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMFARecoveryCode", ctx, arg)
+	ret0, _ := ret[0].(db.MfaRecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMFARecoveryCode indicates an expected call of CreateMFARecoveryCode.
+func (mr *MockStoreMockRecorder) CreateMFARecoveryCode(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMFARecoveryCode", reflect.TypeOf((*MockStore)(nil).CreateMFARecoveryCode), ctx, arg)
+}
+
+// CreateOAuthState mocks base method.
+func (m *MockStore) CreateOAuthState(ctx context.Context, arg db.CreateOAuthStateParams) (db.OauthState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthState", ctx, arg)
+	ret0, _ := ret[0].(db.OauthState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOAuthState indicates an expected call of CreateOAuthState.
+func (mr *MockStoreMockRecorder) CreateOAuthState(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthState", reflect.TypeOf((*MockStore)(nil).CreateOAuthState), ctx, arg)
+}
+
+// CreateOrganization mocks base method.
+func (m *MockStore) CreateOrganization(ctx context.Context, name string) (db.Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrganization", ctx, name)
+	ret0, _ := ret[0].(db.Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrganization indicates an expected call of CreateOrganization.
+func (mr *MockStoreMockRecorder) CreateOrganization(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrganization", reflect.TypeOf((*MockStore)(nil).CreateOrganization), ctx, name)
+}
+
+// CreatePasswordReset mocks base method.
+func (m *MockStore) CreatePasswordReset(ctx context.Context, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordReset", ctx, arg)
+	ret0, _ := ret[0].(db.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockStoreMockRecorder) CreatePasswordReset(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockStore)(nil).CreatePasswordReset), ctx, arg)
+}
+
+// CreatePaymentEvent mocks base method.
+func (m *MockStore) CreatePaymentEvent(ctx context.Context, arg db.CreatePaymentEventParams) (db.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePaymentEvent", ctx, arg)
+	ret0, _ := ret[0].(db.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePaymentEvent indicates an expected call of CreatePaymentEvent.
+func (mr *MockStoreMockRecorder) CreatePaymentEvent(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentEvent", reflect.TypeOf((*MockStore)(nil).CreatePaymentEvent), ctx, arg)
+}
+
+// CreatePayout mocks base method.
+func (m *MockStore) CreatePayout(ctx context.Context, arg db.CreatePayoutParams) (db.Payout, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePayout", ctx, arg)
+	ret0, _ := ret[0].(db.Payout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePayout indicates an expected call of CreatePayout.
+func (mr *MockStoreMockRecorder) CreatePayout(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayout", reflect.TypeOf((*MockStore)(nil).CreatePayout), ctx, arg)
+}
+
+// CreateRefund mocks base method.
+func (m *MockStore) CreateRefund(ctx context.Context, arg db.CreateRefundParams) (db.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefund", ctx, arg)
+	ret0, _ := ret[0].(db.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRefund indicates an expected call of CreateRefund.
+func (mr *MockStoreMockRecorder) CreateRefund(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefund", reflect.TypeOf((*MockStore)(nil).CreateRefund), ctx, arg)
+}
+
+// CreateSession mocks base method.
+func (m *MockStore) CreateSession(ctx context.Context, arg db.CreateSessionParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, arg)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockStoreMockRecorder) CreateSession(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStore)(nil).CreateSession), ctx, arg)
+}
+
+// CreateSubscription mocks base method.
+func (m *MockStore) CreateSubscription(ctx context.Context, arg db.CreateSubscriptionParams) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", ctx, arg)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription.
+func (mr *MockStoreMockRecorder) CreateSubscription(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockStore)(nil).CreateSubscription), ctx, arg)
+}
+
+// CreateTokenRevocation mocks base method.
+func (m *MockStore) CreateTokenRevocation(ctx context.Context, arg db.CreateTokenRevocationParams) (db.TokenRevocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTokenRevocation", ctx, arg)
+	ret0, _ := ret[0].(db.TokenRevocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTokenRevocation indicates an expected call of CreateTokenRevocation.
+func (mr *MockStoreMockRecorder) CreateTokenRevocation(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTokenRevocation", reflect.TypeOf((*MockStore)(nil).CreateTokenRevocation), ctx, arg)
+}
+
+// CreateUser mocks base method.
+func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockStoreMockRecorder) CreateUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), ctx, arg)
+}
+
+// CreateUserIdentity mocks base method.
+func (m *MockStore) CreateUserIdentity(ctx context.Context, arg db.CreateUserIdentityParams) (db.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserIdentity", ctx, arg)
+	ret0, _ := ret[0].(db.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUserIdentity indicates an expected call of CreateUserIdentity.
+func (mr *MockStoreMockRecorder) CreateUserIdentity(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserIdentity", reflect.TypeOf((*MockStore)(nil).CreateUserIdentity), ctx, arg)
+}
+
+// CreateUserTx mocks base method.
+func (m *MockStore) CreateUserTx(ctx context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserTx", ctx, arg)
+	ret0, _ := ret[0].(db.CreateUserTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUserTx indicates an expected call of CreateUserTx.
+func (mr *MockStoreMockRecorder) CreateUserTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserTx", reflect.TypeOf((*MockStore)(nil).CreateUserTx), ctx, arg)
+}
+
+// CreateVerifyEmail mocks base method.
+func (m *MockStore) CreateVerifyEmail(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateVerifyEmail indicates an expected call of CreateVerifyEmail.
+func (mr *MockStoreMockRecorder) CreateVerifyEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVerifyEmail", reflect.TypeOf((*MockStore)(nil).CreateVerifyEmail), ctx, arg)
+}
+
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockStore) DeleteExpiredIdempotencyKeys(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockStoreMockRecorder) DeleteExpiredIdempotencyKeys(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockStore)(nil).DeleteExpiredIdempotencyKeys), ctx)
+}
+
+// DeleteExpiredOAuthStates mocks base method.
+func (m *MockStore) DeleteExpiredOAuthStates(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredOAuthStates", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredOAuthStates indicates an expected call of DeleteExpiredOAuthStates.
+func (mr *MockStoreMockRecorder) DeleteExpiredOAuthStates(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredOAuthStates", reflect.TypeOf((*MockStore)(nil).DeleteExpiredOAuthStates), ctx)
+}
+
+// DeleteExpiredTokenRevocations mocks base method.
+func (m *MockStore) DeleteExpiredTokenRevocations(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredTokenRevocations", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredTokenRevocations indicates an expected call of DeleteExpiredTokenRevocations.
+func (mr *MockStoreMockRecorder) DeleteExpiredTokenRevocations(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredTokenRevocations", reflect.TypeOf((*MockStore)(nil).DeleteExpiredTokenRevocations), ctx)
+}
+
+// DeleteLoginAttempt mocks base method.
+func (m *MockStore) DeleteLoginAttempt(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLoginAttempt", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLoginAttempt indicates an expected call of DeleteLoginAttempt.
+func (mr *MockStoreMockRecorder) DeleteLoginAttempt(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLoginAttempt", reflect.TypeOf((*MockStore)(nil).DeleteLoginAttempt), ctx, key)
+}
+
+// DeleteMFARecoveryCodes mocks base method.
+func (m *MockStore) DeleteMFARecoveryCodes(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMFARecoveryCodes", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMFARecoveryCodes indicates an expected call of DeleteMFARecoveryCodes.
+func (mr *MockStoreMockRecorder) DeleteMFARecoveryCodes(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMFARecoveryCodes", reflect.TypeOf((*MockStore)(nil).DeleteMFARecoveryCodes), ctx, userID)
+}
+
+// DeleteUserTOTP mocks base method.
+func (m *MockStore) DeleteUserTOTP(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserTOTP indicates an expected call of DeleteUserTOTP.
+func (mr *MockStoreMockRecorder) DeleteUserTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserTOTP", reflect.TypeOf((*MockStore)(nil).DeleteUserTOTP), ctx, userID)
+}
+
+// DisableTOTPTx mocks base method.
+func (m *MockStore) DisableTOTPTx(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTPTx", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTPTx indicates an expected call of DisableTOTPTx.
+func (mr *MockStoreMockRecorder) DisableTOTPTx(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTPTx", reflect.TypeOf((*MockStore)(nil).DisableTOTPTx), ctx, userID)
+}
+
+// DonationTx mocks base method.
+func (m *MockStore) DonationTx(ctx context.Context, arg db.DonationTxParams) (db.DonationTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DonationTx", ctx, arg)
+	ret0, _ := ret[0].(db.DonationTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DonationTx indicates an expected call of DonationTx.
+func (mr *MockStoreMockRecorder) DonationTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DonationTx", reflect.TypeOf((*MockStore)(nil).DonationTx), ctx, arg)
+}
+
+// FailDonation mocks base method.
+func (m *MockStore) FailDonation(ctx context.Context, arg db.FailDonationParams) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailDonation", ctx, arg)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailDonation indicates an expected call of FailDonation.
+func (mr *MockStoreMockRecorder) FailDonation(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailDonation", reflect.TypeOf((*MockStore)(nil).FailDonation), ctx, arg)
+}
+
+// ForgiveLoginFailure mocks base method.
+func (m *MockStore) ForgiveLoginFailure(ctx context.Context, arg db.ForgiveLoginFailureParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgiveLoginFailure", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgiveLoginFailure indicates an expected call of ForgiveLoginFailure.
+func (mr *MockStoreMockRecorder) ForgiveLoginFailure(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgiveLoginFailure", reflect.TypeOf((*MockStore)(nil).ForgiveLoginFailure), ctx, arg)
+}
+
+// GetAPIKey mocks base method.
+func (m *MockStore) GetAPIKey(ctx context.Context, id int64) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, id)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockStoreMockRecorder) GetAPIKey(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockStore)(nil).GetAPIKey), ctx, id)
+}
+
+// GetAPIKeyByPrefix mocks base method.
+func (m *MockStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (db.GetAPIKeyByPrefixRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByPrefix", ctx, prefix)
+	ret0, _ := ret[0].(db.GetAPIKeyByPrefixRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByPrefix indicates an expected call of GetAPIKeyByPrefix.
+func (mr *MockStoreMockRecorder) GetAPIKeyByPrefix(ctx, prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByPrefix", reflect.TypeOf((*MockStore)(nil).GetAPIKeyByPrefix), ctx, prefix)
+}
+
+// GetDonation mocks base method.
+func (m *MockStore) GetDonation(ctx context.Context, id int64) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDonation", ctx, id)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDonation indicates an expected call of GetDonation.
+func (mr *MockStoreMockRecorder) GetDonation(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDonation", reflect.TypeOf((*MockStore)(nil).GetDonation), ctx, id)
+}
+
+// GetDonationByPaymentIntent mocks base method.
+func (m *MockStore) GetDonationByPaymentIntent(ctx context.Context, arg db.GetDonationByPaymentIntentParams) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDonationByPaymentIntent", ctx, arg)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDonationByPaymentIntent indicates an expected call of GetDonationByPaymentIntent.
+func (mr *MockStoreMockRecorder) GetDonationByPaymentIntent(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDonationByPaymentIntent", reflect.TypeOf((*MockStore)(nil).GetDonationByPaymentIntent), ctx, arg)
+}
+
+// GetDonationByPaymentIntentForUpdate mocks base method.
+func (m *MockStore) GetDonationByPaymentIntentForUpdate(ctx context.Context, arg db.GetDonationByPaymentIntentForUpdateParams) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDonationByPaymentIntentForUpdate", ctx, arg)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDonationByPaymentIntentForUpdate indicates an expected call of GetDonationByPaymentIntentForUpdate.
+func (mr *MockStoreMockRecorder) GetDonationByPaymentIntentForUpdate(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDonationByPaymentIntentForUpdate", reflect.TypeOf((*MockStore)(nil).GetDonationByPaymentIntentForUpdate), ctx, arg)
+}
+
+// GetDonationForUpdate mocks base method.
+func (m *MockStore) GetDonationForUpdate(ctx context.Context, id int64) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDonationForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDonationForUpdate indicates an expected call of GetDonationForUpdate.
+func (mr *MockStoreMockRecorder) GetDonationForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDonationForUpdate", reflect.TypeOf((*MockStore)(nil).GetDonationForUpdate), ctx, id)
+}
+
+// GetDonationRefundedAmount mocks base method.
+func (m *MockStore) GetDonationRefundedAmount(ctx context.Context, donationID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDonationRefundedAmount", ctx, donationID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDonationRefundedAmount indicates an expected call of GetDonationRefundedAmount.
+func (mr *MockStoreMockRecorder) GetDonationRefundedAmount(ctx, donationID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDonationRefundedAmount", reflect.TypeOf((*MockStore)(nil).GetDonationRefundedAmount), ctx, donationID)
+}
+
+// GetGoal mocks base method.
+func (m *MockStore) GetGoal(ctx context.Context, id int64) (db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGoal", ctx, id)
+	ret0, _ := ret[0].(db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGoal indicates an expected call of GetGoal.
+func (mr *MockStoreMockRecorder) GetGoal(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGoal", reflect.TypeOf((*MockStore)(nil).GetGoal), ctx, id)
+}
+
+// GetGoalForUpdate mocks base method.
+func (m *MockStore) GetGoalForUpdate(ctx context.Context, id int64) (db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGoalForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGoalForUpdate indicates an expected call of GetGoalForUpdate.
+func (mr *MockStoreMockRecorder) GetGoalForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGoalForUpdate", reflect.TypeOf((*MockStore)(nil).GetGoalForUpdate), ctx, id)
+}
+
+// GetGoalLedgerFunds mocks base method.
+func (m *MockStore) GetGoalLedgerFunds(ctx context.Context, arg db.GetGoalLedgerFundsParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGoalLedgerFunds", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGoalLedgerFunds indicates an expected call of GetGoalLedgerFunds.
+func (mr *MockStoreMockRecorder) GetGoalLedgerFunds(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGoalLedgerFunds", reflect.TypeOf((*MockStore)(nil).GetGoalLedgerFunds), ctx, arg)
+}
+
+// GetGoalTotalDonations mocks base method.
+func (m *MockStore) GetGoalTotalDonations(ctx context.Context, goalID int64) (any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGoalTotalDonations", ctx, goalID)
+	ret0, _ := ret[0].(any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGoalTotalDonations indicates an expected call of GetGoalTotalDonations.
+func (mr *MockStoreMockRecorder) GetGoalTotalDonations(ctx, goalID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGoalTotalDonations", reflect.TypeOf((*MockStore)(nil).GetGoalTotalDonations), ctx, goalID)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockStore) GetIdempotencyKey(ctx context.Context, arg db.GetIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", ctx, arg)
+	ret0, _ := ret[0].(db.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockStoreMockRecorder) GetIdempotencyKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockStore)(nil).GetIdempotencyKey), ctx, arg)
+}
+
+// GetLedgerAccountBalance mocks base method.
+func (m *MockStore) GetLedgerAccountBalance(ctx context.Context, accountID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedgerAccountBalance", ctx, accountID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedgerAccountBalance indicates an expected call of GetLedgerAccountBalance.
+func (mr *MockStoreMockRecorder) GetLedgerAccountBalance(ctx, accountID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedgerAccountBalance", reflect.TypeOf((*MockStore)(nil).GetLedgerAccountBalance), ctx, accountID)
+}
+
+// GetLedgerAccountByCode mocks base method.
+func (m *MockStore) GetLedgerAccountByCode(ctx context.Context, code string) (db.LedgerAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedgerAccountByCode", ctx, code)
+	ret0, _ := ret[0].(db.LedgerAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedgerAccountByCode indicates an expected call of GetLedgerAccountByCode.
+func (mr *MockStoreMockRecorder) GetLedgerAccountByCode(ctx, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedgerAccountByCode", reflect.TypeOf((*MockStore)(nil).GetLedgerAccountByCode), ctx, code)
+}
+
+// GetLoginAttempt mocks base method.
+func (m *MockStore) GetLoginAttempt(ctx context.Context, key string) (db.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginAttempt", ctx, key)
+	ret0, _ := ret[0].(db.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginAttempt indicates an expected call of GetLoginAttempt.
+func (mr *MockStoreMockRecorder) GetLoginAttempt(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginAttempt", reflect.TypeOf((*MockStore)(nil).GetLoginAttempt), ctx, key)
+}
+
+// GetOrganization mocks base method.
+func (m *MockStore) GetOrganization(ctx context.Context, id int64) (db.Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrganization", ctx, id)
+	ret0, _ := ret[0].(db.Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrganization indicates an expected call of GetOrganization.
+func (mr *MockStoreMockRecorder) GetOrganization(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrganization", reflect.TypeOf((*MockStore)(nil).GetOrganization), ctx, id)
+}
+
+// GetPaymentEvent mocks base method.
+func (m *MockStore) GetPaymentEvent(ctx context.Context, id int64) (db.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentEvent", ctx, id)
+	ret0, _ := ret[0].(db.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentEvent indicates an expected call of GetPaymentEvent.
+func (mr *MockStoreMockRecorder) GetPaymentEvent(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentEvent", reflect.TypeOf((*MockStore)(nil).GetPaymentEvent), ctx, id)
+}
+
+// GetPaymentEventByEventID mocks base method.
+func (m *MockStore) GetPaymentEventByEventID(ctx context.Context, arg db.GetPaymentEventByEventIDParams) (db.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentEventByEventID", ctx, arg)
+	ret0, _ := ret[0].(db.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentEventByEventID indicates an expected call of GetPaymentEventByEventID.
+func (mr *MockStoreMockRecorder) GetPaymentEventByEventID(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentEventByEventID", reflect.TypeOf((*MockStore)(nil).GetPaymentEventByEventID), ctx, arg)
+}
+
+// GetPaymentEventForUpdate mocks base method.
+func (m *MockStore) GetPaymentEventForUpdate(ctx context.Context, id int64) (db.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentEventForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentEventForUpdate indicates an expected call of GetPaymentEventForUpdate.
+func (mr *MockStoreMockRecorder) GetPaymentEventForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentEventForUpdate", reflect.TypeOf((*MockStore)(nil).GetPaymentEventForUpdate), ctx, id)
+}
+
+// GetRefundByProviderRefundID mocks base method.
+func (m *MockStore) GetRefundByProviderRefundID(ctx context.Context, arg db.GetRefundByProviderRefundIDParams) (db.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefundByProviderRefundID", ctx, arg)
+	ret0, _ := ret[0].(db.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefundByProviderRefundID indicates an expected call of GetRefundByProviderRefundID.
+func (mr *MockStoreMockRecorder) GetRefundByProviderRefundID(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefundByProviderRefundID", reflect.TypeOf((*MockStore)(nil).GetRefundByProviderRefundID), ctx, arg)
+}
+
+// GetSession mocks base method.
+func (m *MockStore) GetSession(ctx context.Context, id uuid.UUID) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSession", ctx, id)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSession indicates an expected call of GetSession.
+func (mr *MockStoreMockRecorder) GetSession(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockStore)(nil).GetSession), ctx, id)
+}
+
+// GetSubscription mocks base method.
+func (m *MockStore) GetSubscription(ctx context.Context, id int64) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubscription", ctx, id)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubscription indicates an expected call of GetSubscription.
+func (mr *MockStoreMockRecorder) GetSubscription(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubscription", reflect.TypeOf((*MockStore)(nil).GetSubscription), ctx, id)
+}
+
+// GetUser mocks base method.
+func (m *MockStore) GetUser(ctx context.Context, id int64) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, id)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockStoreMockRecorder) GetUser(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), ctx, id)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockStore) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockStoreMockRecorder) GetUserByEmail(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockStore)(nil).GetUserByEmail), ctx, email)
+}
+
+// GetUserIdentity mocks base method.
+func (m *MockStore) GetUserIdentity(ctx context.Context, arg db.GetUserIdentityParams) (db.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIdentity", ctx, arg)
+	ret0, _ := ret[0].(db.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIdentity indicates an expected call of GetUserIdentity.
+func (mr *MockStoreMockRecorder) GetUserIdentity(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIdentity", reflect.TypeOf((*MockStore)(nil).GetUserIdentity), ctx, arg)
+}
+
+// GetUserRole mocks base method.
+func (m *MockStore) GetUserRole(ctx context.Context, id int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRole", ctx, id)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserRole indicates an expected call of GetUserRole.
+func (mr *MockStoreMockRecorder) GetUserRole(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRole", reflect.TypeOf((*MockStore)(nil).GetUserRole), ctx, id)
+}
+
+// GetUserTOTP mocks base method.
+func (m *MockStore) GetUserTOTP(ctx context.Context, userID int64) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTOTP", ctx, userID)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTOTP indicates an expected call of GetUserTOTP.
+func (mr *MockStoreMockRecorder) GetUserTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTOTP", reflect.TypeOf((*MockStore)(nil).GetUserTOTP), ctx, userID)
+}
+
+// GetUserTotalDonations mocks base method.
+func (m *MockStore) GetUserTotalDonations(ctx context.Context, userID pgtype.Int8) (any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTotalDonations", ctx, userID)
+	ret0, _ := ret[0].(any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTotalDonations indicates an expected call of GetUserTotalDonations.
+func (mr *MockStoreMockRecorder) GetUserTotalDonations(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTotalDonations", reflect.TypeOf((*MockStore)(nil).GetUserTotalDonations), ctx, userID)
+}
+
+// InvalidateUserPasswordResets mocks base method.
+func (m *MockStore) InvalidateUserPasswordResets(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidateUserPasswordResets", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidateUserPasswordResets indicates an expected call of InvalidateUserPasswordResets.
+func (mr *MockStoreMockRecorder) InvalidateUserPasswordResets(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateUserPasswordResets", reflect.TypeOf((*MockStore)(nil).InvalidateUserPasswordResets), ctx, userID)
+}
+
+// ListActiveGoals mocks base method.
+func (m *MockStore) ListActiveGoals(ctx context.Context, arg db.ListActiveGoalsParams) ([]db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListActiveGoals", ctx, arg)
+	ret0, _ := ret[0].([]db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListActiveGoals indicates an expected call of ListActiveGoals.
+func (mr *MockStoreMockRecorder) ListActiveGoals(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListActiveGoals", reflect.TypeOf((*MockStore)(nil).ListActiveGoals), ctx, arg)
+}
+
+// ListDeferredPaymentEvents mocks base method.
+func (m *MockStore) ListDeferredPaymentEvents(ctx context.Context, arg db.ListDeferredPaymentEventsParams) ([]db.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeferredPaymentEvents", ctx, arg)
+	ret0, _ := ret[0].([]db.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeferredPaymentEvents indicates an expected call of ListDeferredPaymentEvents.
+func (mr *MockStoreMockRecorder) ListDeferredPaymentEvents(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeferredPaymentEvents", reflect.TypeOf((*MockStore)(nil).ListDeferredPaymentEvents), ctx, arg)
+}
+
+// ListDonationsByGoal mocks base method.
+func (m *MockStore) ListDonationsByGoal(ctx context.Context, arg db.ListDonationsByGoalParams) ([]db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDonationsByGoal", ctx, arg)
+	ret0, _ := ret[0].([]db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDonationsByGoal indicates an expected call of ListDonationsByGoal.
+func (mr *MockStoreMockRecorder) ListDonationsByGoal(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDonationsByGoal", reflect.TypeOf((*MockStore)(nil).ListDonationsByGoal), ctx, arg)
+}
+
+// ListDonationsByUser mocks base method.
+func (m *MockStore) ListDonationsByUser(ctx context.Context, arg db.ListDonationsByUserParams) ([]db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDonationsByUser", ctx, arg)
+	ret0, _ := ret[0].([]db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDonationsByUser indicates an expected call of ListDonationsByUser.
+func (mr *MockStoreMockRecorder) ListDonationsByUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDonationsByUser", reflect.TypeOf((*MockStore)(nil).ListDonationsByUser), ctx, arg)
+}
+
+// ListGoalDonors mocks base method.
+func (m *MockStore) ListGoalDonors(ctx context.Context, arg db.ListGoalDonorsParams) ([]db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGoalDonors", ctx, arg)
+	ret0, _ := ret[0].([]db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGoalDonors indicates an expected call of ListGoalDonors.
+func (mr *MockStoreMockRecorder) ListGoalDonors(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGoalDonors", reflect.TypeOf((*MockStore)(nil).ListGoalDonors), ctx, arg)
+}
+
+// ListGoals mocks base method.
+func (m *MockStore) ListGoals(ctx context.Context, arg db.ListGoalsParams) ([]db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGoals", ctx, arg)
+	ret0, _ := ret[0].([]db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGoals indicates an expected call of ListGoals.
+func (mr *MockStoreMockRecorder) ListGoals(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGoals", reflect.TypeOf((*MockStore)(nil).ListGoals), ctx, arg)
+}
+
+// ListLedgerPostingsByEntry mocks base method.
+func (m *MockStore) ListLedgerPostingsByEntry(ctx context.Context, entryID int64) ([]db.LedgerPosting, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLedgerPostingsByEntry", ctx, entryID)
+	ret0, _ := ret[0].([]db.LedgerPosting)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLedgerPostingsByEntry indicates an expected call of ListLedgerPostingsByEntry.
+func (mr *MockStoreMockRecorder) ListLedgerPostingsByEntry(ctx, entryID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLedgerPostingsByEntry", reflect.TypeOf((*MockStore)(nil).ListLedgerPostingsByEntry), ctx, entryID)
+}
+
+// ListOrganizationAPIKeys mocks base method.
+func (m *MockStore) ListOrganizationAPIKeys(ctx context.Context, organizationID pgtype.Int8) ([]db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrganizationAPIKeys", ctx, organizationID)
+	ret0, _ := ret[0].([]db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrganizationAPIKeys indicates an expected call of ListOrganizationAPIKeys.
+func (mr *MockStoreMockRecorder) ListOrganizationAPIKeys(ctx, organizationID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrganizationAPIKeys", reflect.TypeOf((*MockStore)(nil).ListOrganizationAPIKeys), ctx, organizationID)
+}
+
+// ListOrganizations mocks base method.
+func (m *MockStore) ListOrganizations(ctx context.Context, arg db.ListOrganizationsParams) ([]db.Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrganizations", ctx, arg)
+	ret0, _ := ret[0].([]db.Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrganizations indicates an expected call of ListOrganizations.
+func (mr *MockStoreMockRecorder) ListOrganizations(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrganizations", reflect.TypeOf((*MockStore)(nil).ListOrganizations), ctx, arg)
+}
+
+// ListPaymentEventsByStatus mocks base method.
+func (m *MockStore) ListPaymentEventsByStatus(ctx context.Context, arg db.ListPaymentEventsByStatusParams) ([]db.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPaymentEventsByStatus", ctx, arg)
+	ret0, _ := ret[0].([]db.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPaymentEventsByStatus indicates an expected call of ListPaymentEventsByStatus.
+func (mr *MockStoreMockRecorder) ListPaymentEventsByStatus(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPaymentEventsByStatus", reflect.TypeOf((*MockStore)(nil).ListPaymentEventsByStatus), ctx, arg)
+}
+
+// ListPayoutsByGoal mocks base method.
+func (m *MockStore) ListPayoutsByGoal(ctx context.Context, arg db.ListPayoutsByGoalParams) ([]db.Payout, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPayoutsByGoal", ctx, arg)
+	ret0, _ := ret[0].([]db.Payout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPayoutsByGoal indicates an expected call of ListPayoutsByGoal.
+func (mr *MockStoreMockRecorder) ListPayoutsByGoal(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPayoutsByGoal", reflect.TypeOf((*MockStore)(nil).ListPayoutsByGoal), ctx, arg)
+}
+
+// ListRefundsByDonation mocks base method.
+func (m *MockStore) ListRefundsByDonation(ctx context.Context, donationID int64) ([]db.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRefundsByDonation", ctx, donationID)
+	ret0, _ := ret[0].([]db.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRefundsByDonation indicates an expected call of ListRefundsByDonation.
+func (mr *MockStoreMockRecorder) ListRefundsByDonation(ctx, donationID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRefundsByDonation", reflect.TypeOf((*MockStore)(nil).ListRefundsByDonation), ctx, donationID)
+}
+
+// ListSettledSubscriptionCharges mocks base method.
+func (m *MockStore) ListSettledSubscriptionCharges(ctx context.Context, limit int32) ([]db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSettledSubscriptionCharges", ctx, limit)
+	ret0, _ := ret[0].([]db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSettledSubscriptionCharges indicates an expected call of ListSettledSubscriptionCharges.
+func (mr *MockStoreMockRecorder) ListSettledSubscriptionCharges(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSettledSubscriptionCharges", reflect.TypeOf((*MockStore)(nil).ListSettledSubscriptionCharges), ctx, limit)
+}
+
+// ListSubscriptionsByUser mocks base method.
+func (m *MockStore) ListSubscriptionsByUser(ctx context.Context, arg db.ListSubscriptionsByUserParams) ([]db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSubscriptionsByUser", ctx, arg)
+	ret0, _ := ret[0].([]db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSubscriptionsByUser indicates an expected call of ListSubscriptionsByUser.
+func (mr *MockStoreMockRecorder) ListSubscriptionsByUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSubscriptionsByUser", reflect.TypeOf((*MockStore)(nil).ListSubscriptionsByUser), ctx, arg)
+}
+
+// ListTokenRevocationsCreatedAfter mocks base method.
+func (m *MockStore) ListTokenRevocationsCreatedAfter(ctx context.Context, createdAfter time.Time) ([]db.TokenRevocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTokenRevocationsCreatedAfter", ctx, createdAfter)
+	ret0, _ := ret[0].([]db.TokenRevocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTokenRevocationsCreatedAfter indicates an expected call of ListTokenRevocationsCreatedAfter.
+func (mr *MockStoreMockRecorder) ListTokenRevocationsCreatedAfter(ctx, createdAfter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTokenRevocationsCreatedAfter", reflect.TypeOf((*MockStore)(nil).ListTokenRevocationsCreatedAfter), ctx, createdAfter)
+}
+
+// ListUnusedMFARecoveryCodes mocks base method.
+func (m *MockStore) ListUnusedMFARecoveryCodes(ctx context.Context, userID int64) ([]db.MfaRecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnusedMFARecoveryCodes", ctx, userID)
+	ret0, _ := ret[0].([]db.MfaRecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnusedMFARecoveryCodes indicates an expected call of ListUnusedMFARecoveryCodes.
+func (mr *MockStoreMockRecorder) ListUnusedMFARecoveryCodes(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnusedMFARecoveryCodes", reflect.TypeOf((*MockStore)(nil).ListUnusedMFARecoveryCodes), ctx, userID)
+}
+
+// ListUserAPIKeys mocks base method.
+func (m *MockStore) ListUserAPIKeys(ctx context.Context, userID pgtype.Int8) ([]db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserAPIKeys", ctx, userID)
+	ret0, _ := ret[0].([]db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserAPIKeys indicates an expected call of ListUserAPIKeys.
+func (mr *MockStoreMockRecorder) ListUserAPIKeys(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserAPIKeys", reflect.TypeOf((*MockStore)(nil).ListUserAPIKeys), ctx, userID)
+}
+
+// ListUserIdentities mocks base method.
+func (m *MockStore) ListUserIdentities(ctx context.Context, userID int64) ([]db.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserIdentities", ctx, userID)
+	ret0, _ := ret[0].([]db.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserIdentities indicates an expected call of ListUserIdentities.
+func (mr *MockStoreMockRecorder) ListUserIdentities(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserIdentities", reflect.TypeOf((*MockStore)(nil).ListUserIdentities), ctx, userID)
+}
+
+// ListUsers mocks base method.
+func (m *MockStore) ListUsers(ctx context.Context, arg db.ListUsersParams) ([]db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, arg)
+	ret0, _ := ret[0].([]db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockStoreMockRecorder) ListUsers(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockStore)(nil).ListUsers), ctx, arg)
+}
+
+// LogoutTx mocks base method.
+func (m *MockStore) LogoutTx(ctx context.Context, arg db.LogoutTxParams) (db.LogoutTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogoutTx", ctx, arg)
+	ret0, _ := ret[0].(db.LogoutTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LogoutTx indicates an expected call of LogoutTx.
+func (mr *MockStoreMockRecorder) LogoutTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogoutTx", reflect.TypeOf((*MockStore)(nil).LogoutTx), ctx, arg)
+}
+
+// MarkDonationSucceeded mocks base method.
+func (m *MockStore) MarkDonationSucceeded(ctx context.Context, id int64) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDonationSucceeded", ctx, id)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkDonationSucceeded indicates an expected call of MarkDonationSucceeded.
+func (mr *MockStoreMockRecorder) MarkDonationSucceeded(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDonationSucceeded", reflect.TypeOf((*MockStore)(nil).MarkDonationSucceeded), ctx, id)
+}
+
+// MarkUserEmailVerified mocks base method.
+func (m *MockStore) MarkUserEmailVerified(ctx context.Context, arg db.MarkUserEmailVerifiedParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkUserEmailVerified", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkUserEmailVerified indicates an expected call of MarkUserEmailVerified.
+func (mr *MockStoreMockRecorder) MarkUserEmailVerified(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkUserEmailVerified", reflect.TypeOf((*MockStore)(nil).MarkUserEmailVerified), ctx, arg)
+}
+
+// OAuthLoginTx mocks base method.
+func (m *MockStore) OAuthLoginTx(ctx context.Context, arg db.OAuthLoginTxParams) (db.OAuthLoginTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OAuthLoginTx", ctx, arg)
+	ret0, _ := ret[0].(db.OAuthLoginTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OAuthLoginTx indicates an expected call of OAuthLoginTx.
+func (mr *MockStoreMockRecorder) OAuthLoginTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OAuthLoginTx", reflect.TypeOf((*MockStore)(nil).OAuthLoginTx), ctx, arg)
+}
+
+// PauseSubscription mocks base method.
+func (m *MockStore) PauseSubscription(ctx context.Context, id int64) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseSubscription", ctx, id)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PauseSubscription indicates an expected call of PauseSubscription.
+func (mr *MockStoreMockRecorder) PauseSubscription(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseSubscription", reflect.TypeOf((*MockStore)(nil).PauseSubscription), ctx, id)
+}
+
+// PayoutTx mocks base method.
+func (m *MockStore) PayoutTx(ctx context.Context, arg db.PayoutTxParams) (db.PayoutTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PayoutTx", ctx, arg)
+	ret0, _ := ret[0].(db.PayoutTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PayoutTx indicates an expected call of PayoutTx.
+func (mr *MockStoreMockRecorder) PayoutTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PayoutTx", reflect.TypeOf((*MockStore)(nil).PayoutTx), ctx, arg)
+}
+
+// ReconcileGoals mocks base method.
+func (m *MockStore) ReconcileGoals(ctx context.Context, arg db.ReconcileGoalsParams) ([]db.ReconcileGoalsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileGoals", ctx, arg)
+	ret0, _ := ret[0].([]db.ReconcileGoalsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconcileGoals indicates an expected call of ReconcileGoals.
+func (mr *MockStoreMockRecorder) ReconcileGoals(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileGoals", reflect.TypeOf((*MockStore)(nil).ReconcileGoals), ctx, arg)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockStore) RecordLoginFailure(ctx context.Context, arg db.RecordLoginFailureParams) (db.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, arg)
+	ret0, _ := ret[0].(db.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockStoreMockRecorder) RecordLoginFailure(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockStore)(nil).RecordLoginFailure), ctx, arg)
+}
+
+// RecordSubscriptionFailure mocks base method.
+func (m *MockStore) RecordSubscriptionFailure(ctx context.Context, arg db.RecordSubscriptionFailureParams) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordSubscriptionFailure", ctx, arg)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordSubscriptionFailure indicates an expected call of RecordSubscriptionFailure.
+func (mr *MockStoreMockRecorder) RecordSubscriptionFailure(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordSubscriptionFailure", reflect.TypeOf((*MockStore)(nil).RecordSubscriptionFailure), ctx, arg)
+}
+
+// RefundTx mocks base method.
+func (m *MockStore) RefundTx(ctx context.Context, arg db.RefundTxParams) (db.RefundTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundTx", ctx, arg)
+	ret0, _ := ret[0].(db.RefundTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundTx indicates an expected call of RefundTx.
+func (mr *MockStoreMockRecorder) RefundTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundTx", reflect.TypeOf((*MockStore)(nil).RefundTx), ctx, arg)
+}
+
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockStore) ReleaseIdempotencyKey(ctx context.Context, arg db.ReleaseIdempotencyKeyParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockStoreMockRecorder) ReleaseIdempotencyKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockStore)(nil).ReleaseIdempotencyKey), ctx, arg)
+}
+
+// RenewSessionTx mocks base method.
+func (m *MockStore) RenewSessionTx(ctx context.Context, arg db.RenewSessionTxParams) (db.RenewSessionTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenewSessionTx", ctx, arg)
+	ret0, _ := ret[0].(db.RenewSessionTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RenewSessionTx indicates an expected call of RenewSessionTx.
+func (mr *MockStoreMockRecorder) RenewSessionTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenewSessionTx", reflect.TypeOf((*MockStore)(nil).RenewSessionTx), ctx, arg)
+}
+
+// ReserveIdempotencyKey mocks base method.
+func (m *MockStore) ReserveIdempotencyKey(ctx context.Context, arg db.ReserveIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", ctx, arg)
+	ret0, _ := ret[0].(db.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockStoreMockRecorder) ReserveIdempotencyKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockStore)(nil).ReserveIdempotencyKey), ctx, arg)
+}
+
+// ResetPasswordTx mocks base method.
+func (m *MockStore) ResetPasswordTx(ctx context.Context, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPasswordTx", ctx, arg)
+	ret0, _ := ret[0].(db.ResetPasswordTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPasswordTx indicates an expected call of ResetPasswordTx.
+func (mr *MockStoreMockRecorder) ResetPasswordTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPasswordTx", reflect.TypeOf((*MockStore)(nil).ResetPasswordTx), ctx, arg)
+}
+
+// ResumeSubscription mocks base method.
+func (m *MockStore) ResumeSubscription(ctx context.Context, arg db.ResumeSubscriptionParams) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeSubscription", ctx, arg)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResumeSubscription indicates an expected call of ResumeSubscription.
+func (mr *MockStoreMockRecorder) ResumeSubscription(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeSubscription", reflect.TypeOf((*MockStore)(nil).ResumeSubscription), ctx, arg)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockStore) RevokeAPIKey(ctx context.Context, id int64) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, id)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockStoreMockRecorder) RevokeAPIKey(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockStore)(nil).RevokeAPIKey), ctx, id)
+}
+
+// RotateSession mocks base method.
+func (m *MockStore) RotateSession(ctx context.Context, id uuid.UUID) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSession", ctx, id)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateSession indicates an expected call of RotateSession.
+func (mr *MockStoreMockRecorder) RotateSession(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSession", reflect.TypeOf((*MockStore)(nil).RotateSession), ctx, id)
+}
+
+// SetSubscriptionPendingCharge mocks base method.
+func (m *MockStore) SetSubscriptionPendingCharge(ctx context.Context, arg db.SetSubscriptionPendingChargeParams) (db.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSubscriptionPendingCharge", ctx, arg)
+	ret0, _ := ret[0].(db.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetSubscriptionPendingCharge indicates an expected call of SetSubscriptionPendingCharge.
+func (mr *MockStoreMockRecorder) SetSubscriptionPendingCharge(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSubscriptionPendingCharge", reflect.TypeOf((*MockStore)(nil).SetSubscriptionPendingCharge), ctx, arg)
+}
+
+// SubtractFromGoalCollectedAmount mocks base method.
+func (m *MockStore) SubtractFromGoalCollectedAmount(ctx context.Context, arg db.SubtractFromGoalCollectedAmountParams) (db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubtractFromGoalCollectedAmount", ctx, arg)
+	ret0, _ := ret[0].(db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubtractFromGoalCollectedAmount indicates an expected call of SubtractFromGoalCollectedAmount.
+func (mr *MockStoreMockRecorder) SubtractFromGoalCollectedAmount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubtractFromGoalCollectedAmount", reflect.TypeOf((*MockStore)(nil).SubtractFromGoalCollectedAmount), ctx, arg)
+}
+
+// TouchAPIKey mocks base method.
+func (m *MockStore) TouchAPIKey(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey.
+func (mr *MockStoreMockRecorder) TouchAPIKey(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockStore)(nil).TouchAPIKey), ctx, id)
+}
+
+// UpdateDonationStatus mocks base method.
+func (m *MockStore) UpdateDonationStatus(ctx context.Context, arg db.UpdateDonationStatusParams) (db.Donation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDonationStatus", ctx, arg)
+	ret0, _ := ret[0].(db.Donation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateDonationStatus indicates an expected call of UpdateDonationStatus.
+func (mr *MockStoreMockRecorder) UpdateDonationStatus(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDonationStatus", reflect.TypeOf((*MockStore)(nil).UpdateDonationStatus), ctx, arg)
+}
+
+// UpdateGoal mocks base method.
+func (m *MockStore) UpdateGoal(ctx context.Context, arg db.UpdateGoalParams) (db.Goal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGoal", ctx, arg)
+	ret0, _ := ret[0].(db.Goal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateGoal indicates an expected call of UpdateGoal.
+func (mr *MockStoreMockRecorder) UpdateGoal(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGoal", reflect.TypeOf((*MockStore)(nil).UpdateGoal), ctx, arg)
+}
+
+// UpdatePaymentEventStatus mocks base method.
+func (m *MockStore) UpdatePaymentEventStatus(ctx context.Context, arg db.UpdatePaymentEventStatusParams) (db.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePaymentEventStatus", ctx, arg)
+	ret0, _ := ret[0].(db.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePaymentEventStatus indicates an expected call of UpdatePaymentEventStatus.
+func (mr *MockStoreMockRecorder) UpdatePaymentEventStatus(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePaymentEventStatus", reflect.TypeOf((*MockStore)(nil).UpdatePaymentEventStatus), ctx, arg)
+}
+
+// UpdateRefundStatus mocks base method.
+func (m *MockStore) UpdateRefundStatus(ctx context.Context, arg db.UpdateRefundStatusParams) (db.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRefundStatus", ctx, arg)
+	ret0, _ := ret[0].(db.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRefundStatus indicates an expected call of UpdateRefundStatus.
+func (mr *MockStoreMockRecorder) UpdateRefundStatus(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRefundStatus", reflect.TypeOf((*MockStore)(nil).UpdateRefundStatus), ctx, arg)
+}
+
+// UpdateUser mocks base method.
+func (m *MockStore) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockStoreMockRecorder) UpdateUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStore)(nil).UpdateUser), ctx, arg)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockStore) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockStoreMockRecorder) UpdateUserPassword(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockStore)(nil).UpdateUserPassword), ctx, arg)
+}
+
+// UpdateUserRole mocks base method.
+func (m *MockStore) UpdateUserRole(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRole", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRole indicates an expected call of UpdateUserRole.
+func (mr *MockStoreMockRecorder) UpdateUserRole(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRole", reflect.TypeOf((*MockStore)(nil).UpdateUserRole), ctx, arg)
+}
+
+// UpdateUserRoleTx mocks base method.
+func (m *MockStore) UpdateUserRoleTx(ctx context.Context, arg db.UpdateUserRoleTxParams) (db.UpdateUserRoleTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRoleTx", ctx, arg)
+	ret0, _ := ret[0].(db.UpdateUserRoleTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRoleTx indicates an expected call of UpdateUserRoleTx.
+func (mr *MockStoreMockRecorder) UpdateUserRoleTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRoleTx", reflect.TypeOf((*MockStore)(nil).UpdateUserRoleTx), ctx, arg)
+}
+
+// UpdateUserTx mocks base method.
+func (m *MockStore) UpdateUserTx(ctx context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTx", ctx, arg)
+	ret0, _ := ret[0].(db.UpdateUserTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserTx indicates an expected call of UpdateUserTx.
+func (mr *MockStoreMockRecorder) UpdateUserTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTx", reflect.TypeOf((*MockStore)(nil).UpdateUserTx), ctx, arg)
+}
+
+// UpdateVerifyEmail mocks base method.
+func (m *MockStore) UpdateVerifyEmail(ctx context.Context, arg db.UpdateVerifyEmailParams) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateVerifyEmail indicates an expected call of UpdateVerifyEmail.
+func (mr *MockStoreMockRecorder) UpdateVerifyEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVerifyEmail", reflect.TypeOf((*MockStore)(nil).UpdateVerifyEmail), ctx, arg)
+}
+
+// UpsertLedgerAccount mocks base method.
+func (m *MockStore) UpsertLedgerAccount(ctx context.Context, arg db.UpsertLedgerAccountParams) (db.LedgerAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertLedgerAccount", ctx, arg)
+	ret0, _ := ret[0].(db.LedgerAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertLedgerAccount indicates an expected call of UpsertLedgerAccount.
+func (mr *MockStoreMockRecorder) UpsertLedgerAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertLedgerAccount", reflect.TypeOf((*MockStore)(nil).UpsertLedgerAccount), ctx, arg)
+}
+
+// UpsertUserTOTP mocks base method.
+func (m *MockStore) UpsertUserTOTP(ctx context.Context, arg db.UpsertUserTOTPParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertUserTOTP", ctx, arg)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertUserTOTP indicates an expected call of UpsertUserTOTP.
+func (mr *MockStoreMockRecorder) UpsertUserTOTP(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertUserTOTP", reflect.TypeOf((*MockStore)(nil).UpsertUserTOTP), ctx, arg)
+}
+
+// UseMFARecoveryCode mocks base method.
+func (m *MockStore) UseMFARecoveryCode(ctx context.Context, id int64) (db.MfaRecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMFARecoveryCode", ctx, id)
+	ret0, _ := ret[0].(db.MfaRecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseMFARecoveryCode indicates an expected call of UseMFARecoveryCode.
+func (mr *MockStoreMockRecorder) UseMFARecoveryCode(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMFARecoveryCode", reflect.TypeOf((*MockStore)(nil).UseMFARecoveryCode), ctx, id)
+}
+
+// UsePasswordReset mocks base method.
+func (m *MockStore) UsePasswordReset(ctx context.Context, tokenHash string) (db.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordReset", ctx, tokenHash)
+	ret0, _ := ret[0].(db.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsePasswordReset indicates an expected call of UsePasswordReset.
+func (mr *MockStoreMockRecorder) UsePasswordReset(ctx, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordReset", reflect.TypeOf((*MockStore)(nil).UsePasswordReset), ctx, tokenHash)
+}
+
+// UseUserTOTPStep mocks base method.
+func (m *MockStore) UseUserTOTPStep(ctx context.Context, arg db.UseUserTOTPStepParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseUserTOTPStep", ctx, arg)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseUserTOTPStep indicates an expected call of UseUserTOTPStep.
+func (mr *MockStoreMockRecorder) UseUserTOTPStep(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseUserTOTPStep", reflect.TypeOf((*MockStore)(nil).UseUserTOTPStep), ctx, arg)
+}
+
+// VerifyEmailTx mocks base method.
+func (m *MockStore) VerifyEmailTx(ctx context.Context, arg db.VerifyEmailTxParams) (db.VerifyEmailTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailTx", ctx, arg)
+	ret0, _ := ret[0].(db.VerifyEmailTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailTx indicates an expected call of VerifyEmailTx.
+func (mr *MockStoreMockRecorder) VerifyEmailTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailTx", reflect.TypeOf((*MockStore)(nil).VerifyEmailTx), ctx, arg)
+}
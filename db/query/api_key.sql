@@ -0,0 +1,64 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (
+  name
+) VALUES (
+  $1
+) RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations
+WHERE id = $1 LIMIT 1;
+
+-- name: ListOrganizations :many
+SELECT * FROM organizations
+ORDER BY id
+LIMIT $1
+OFFSET $2;
+
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  user_id,
+  organization_id,
+  name,
+  prefix,
+  key_hash,
+  scopes,
+  created_by,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetAPIKey :one
+SELECT * FROM api_keys
+WHERE id = $1 LIMIT 1;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT k.*, u.role AS owner_role
+FROM api_keys k
+LEFT JOIN users u ON u.id = k.user_id
+WHERE k.prefix = $1
+LIMIT 1;
+
+-- name: ListUserAPIKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ListOrganizationAPIKeys :many
+SELECT * FROM api_keys
+WHERE organization_id = $1
+ORDER BY id;
+
+-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1
+  AND revoked_at IS NULL
+RETURNING *;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');
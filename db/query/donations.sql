@@ -4,9 +4,12 @@ INSERT INTO donations (
   goal_id,
   amount,
   currency,
-  is_anonymous
+  is_anonymous,
+  provider,
+  payment_intent_id,
+  subscription_id
 ) VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: CreateAnonymousDonation :one
@@ -14,15 +17,57 @@ INSERT INTO donations (
   goal_id,
   amount,
   currency,
-  is_anonymous
+  is_anonymous,
+  provider,
+  payment_intent_id,
+  confirmation_token_hash
 ) VALUES (
-  $1, $2, $3, TRUE
+  $1, $2, $3, TRUE, $4, $5, $6
 ) RETURNING *;
 
 -- name: GetDonation :one
 SELECT * FROM donations
 WHERE id = $1 LIMIT 1;
 
+-- name: GetDonationForUpdate :one
+SELECT * FROM donations
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: GetDonationByPaymentIntent :one
+SELECT * FROM donations
+WHERE provider = $1 AND payment_intent_id = $2 LIMIT 1;
+
+-- name: GetDonationByPaymentIntentForUpdate :one
+SELECT * FROM donations
+WHERE provider = $1 AND payment_intent_id = $2 LIMIT 1
+FOR UPDATE;
+
+-- name: MarkDonationSucceeded :one
+UPDATE donations
+SET
+  status = 'succeeded',
+  failure_reason = NULL,
+  confirmed_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateDonationStatus :one
+UPDATE donations
+SET status = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: FailDonation :one
+-- FailDonation marks a pending donation as failed. No row is returned if
+-- the donation is no longer pending.
+UPDATE donations
+SET
+  status = 'failed',
+  failure_reason = $2
+WHERE id = $1 AND status = 'pending'
+RETURNING *;
+
 -- name: ListDonationsByGoal :many
 SELECT * FROM donations
 WHERE goal_id = $1
@@ -43,4 +43,10 @@ FOR UPDATE;
 UPDATE goals
 SET collected_amount = collected_amount + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: SubtractFromGoalCollectedAmount :one
+UPDATE goals
+SET collected_amount = collected_amount - sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
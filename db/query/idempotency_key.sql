@@ -0,0 +1,48 @@
+-- name: ReserveIdempotencyKey :one
+-- ReserveIdempotencyKey claims a key for a new request. An expired record
+-- for the same key is replaced; a live one is left alone and no row is
+-- returned.
+INSERT INTO idempotency_keys (
+  scope,
+  key,
+  request_hash,
+  lock_id,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+ON CONFLICT (scope, key) DO UPDATE SET
+  request_hash = EXCLUDED.request_hash,
+  lock_id = EXCLUDED.lock_id,
+  response_status = NULL,
+  response_body = NULL,
+  expires_at = EXCLUDED.expires_at,
+  created_at = now()
+WHERE idempotency_keys.expires_at < now()
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE scope = $1 AND key = $2 LIMIT 1;
+
+-- name: CompleteIdempotencyKey :one
+-- CompleteIdempotencyKey stores the response of the request holding
+-- lock_id. No row is returned if the reservation was lost.
+UPDATE idempotency_keys
+SET
+  lock_id = NULL,
+  response_status = sqlc.arg(response_status),
+  response_body = sqlc.arg(response_body),
+  expires_at = sqlc.arg(expires_at)
+WHERE id = sqlc.arg(id) AND lock_id = sqlc.arg(lock_id)
+RETURNING *;
+
+-- name: ReleaseIdempotencyKey :exec
+-- ReleaseIdempotencyKey drops a reservation whose request failed, so the
+-- client may retry it.
+DELETE FROM idempotency_keys
+WHERE id = $1 AND lock_id = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :exec
+DELETE FROM idempotency_keys
+WHERE expires_at <= now();
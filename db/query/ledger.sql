@@ -0,0 +1,104 @@
+-- name: UpsertLedgerAccount :one
+INSERT INTO ledger_accounts (
+  code,
+  kind,
+  goal_id,
+  currency
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (code) DO UPDATE
+SET code = EXCLUDED.code
+RETURNING *;
+
+-- name: GetLedgerAccountByCode :one
+SELECT * FROM ledger_accounts
+WHERE code = $1 LIMIT 1;
+
+-- name: GetLedgerAccountBalance :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS balance
+FROM ledger_postings
+WHERE account_id = $1;
+
+-- name: GetGoalLedgerFunds :one
+-- GetGoalLedgerFunds returns the funds a goal holds in a currency: the
+-- credit balance of its goal account less what its receivable account is
+-- owed.
+SELECT (-COALESCE(SUM(p.amount), 0))::bigint AS funds
+FROM ledger_postings p
+JOIN ledger_accounts a ON a.id = p.account_id
+WHERE a.goal_id = $1
+  AND a.currency = $2
+  AND a.kind IN ('goal', 'receivable');
+
+-- name: CreateLedgerEntry :one
+INSERT INTO ledger_entries (
+  kind,
+  currency,
+  donation_id,
+  payout_id,
+  refund_id
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: CreateLedgerPosting :one
+INSERT INTO ledger_postings (
+  entry_id,
+  account_id,
+  amount
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: ListLedgerPostingsByEntry :many
+SELECT * FROM ledger_postings
+WHERE entry_id = $1
+ORDER BY id;
+
+-- name: CreatePayout :one
+INSERT INTO payouts (
+  goal_id,
+  amount,
+  currency,
+  provider,
+  reference,
+  created_by
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: ListPayoutsByGoal :many
+SELECT * FROM payouts
+WHERE goal_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: ReconcileGoals :many
+-- ReconcileGoals compares each goal's collected_amount with the credits
+-- its ledger accounts received from donations and refunds. Payouts reduce
+-- the ledger balance but not collected_amount. A refund the goal could not
+-- cover is partly posted to its receivable account, so both are included.
+SELECT
+  g.id AS goal_id,
+  g.collected_amount,
+  COALESCE(l.collected, 0)::bigint AS ledger_collected,
+  COALESCE(l.paid_out, 0)::bigint AS ledger_paid_out,
+  COALESCE(l.balance, 0)::bigint AS ledger_balance
+FROM goals g
+LEFT JOIN (
+  SELECT
+    a.goal_id,
+    -SUM(p.amount) FILTER (WHERE e.kind <> 'payout') AS collected,
+    SUM(p.amount) FILTER (WHERE e.kind = 'payout') AS paid_out,
+    -SUM(p.amount) AS balance
+  FROM ledger_postings p
+  JOIN ledger_accounts a ON a.id = p.account_id
+  JOIN ledger_entries e ON e.id = p.entry_id
+  WHERE a.kind IN ('goal', 'receivable')
+  GROUP BY a.goal_id
+) l ON l.goal_id = g.id
+ORDER BY g.id
+LIMIT $1
+OFFSET $2;
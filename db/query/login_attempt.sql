@@ -0,0 +1,55 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts
+WHERE key = $1 LIMIT 1;
+
+-- name: RecordLoginFailure :one
+-- RecordLoginFailure counts a failure for key, starting over from one when
+-- the previous failure is older than window_start. Once max_failures are
+-- counted the key is locked for base_lockout_seconds, doubled for every
+-- further failure up to max_lockout_seconds. No row is returned while the
+-- key is locked.
+INSERT INTO login_attempts (
+  key,
+  failures,
+  last_failed_at,
+  locked_until
+) VALUES (
+  @key, 1, @failed_at,
+  CASE WHEN @max_failures::integer <= 1
+    THEN @failed_at + make_interval(secs => LEAST(@base_lockout_seconds::float8 * power(2, 1 - @max_failures::integer), @max_lockout_seconds::float8))
+  END
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  failures = CASE
+    WHEN login_attempts.last_failed_at < @window_start THEN 1
+    ELSE login_attempts.failures + 1
+  END,
+  last_failed_at = EXCLUDED.last_failed_at,
+  locked_until = CASE
+    WHEN (CASE WHEN login_attempts.last_failed_at < @window_start THEN 1 ELSE login_attempts.failures + 1 END) >= @max_failures::integer
+    THEN @failed_at + make_interval(secs => LEAST(
+      @base_lockout_seconds::float8 * power(2, LEAST((CASE WHEN login_attempts.last_failed_at < @window_start THEN 1 ELSE login_attempts.failures + 1 END) - @max_failures::integer, 30)),
+      @max_lockout_seconds::float8
+    ))
+    ELSE login_attempts.locked_until
+  END
+WHERE login_attempts.locked_until IS NULL
+  OR login_attempts.locked_until <= @failed_at
+RETURNING *;
+
+-- name: ForgiveLoginFailure :exec
+-- ForgiveLoginFailure takes back a failure counted for key, and the lock
+-- it triggered if the key is still locked until locked_until.
+UPDATE login_attempts
+SET
+  failures = GREATEST(failures - 1, 0),
+  locked_until = CASE
+    WHEN locked_until = sqlc.narg(locked_until) THEN NULL
+    ELSE locked_until
+  END
+WHERE key = sqlc.arg(key);
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts
+WHERE key = $1;
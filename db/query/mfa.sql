@@ -0,0 +1,61 @@
+-- name: UpsertUserTOTP :one
+INSERT INTO user_totps (
+  user_id,
+  secret
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0,
+    confirmed_at = NULL,
+    created_at = now()
+WHERE user_totps.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totps
+WHERE user_id = $1 LIMIT 1;
+
+-- name: ConfirmUserTOTP :one
+UPDATE user_totps
+SET confirmed_at = now()
+WHERE user_id = $1
+  AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseUserTOTPStep :one
+UPDATE user_totps
+SET last_used_step = sqlc.arg(step)
+WHERE user_id = sqlc.arg(user_id)
+  AND last_used_step < sqlc.arg(step)
+RETURNING *;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totps
+WHERE user_id = $1;
+
+-- name: CreateMFARecoveryCode :one
+INSERT INTO mfa_recovery_codes (
+  user_id,
+  code_hash
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: ListUnusedMFARecoveryCodes :many
+SELECT * FROM mfa_recovery_codes
+WHERE user_id = $1
+  AND used_at IS NULL
+ORDER BY id;
+
+-- name: UseMFARecoveryCode :one
+UPDATE mfa_recovery_codes
+SET used_at = now()
+WHERE id = $1
+  AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteMFARecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
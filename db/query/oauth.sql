@@ -0,0 +1,42 @@
+-- name: CreateOAuthState :one
+INSERT INTO oauth_states (
+  state_hash,
+  provider,
+  code_verifier,
+  nonce,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: ConsumeOAuthState :one
+DELETE FROM oauth_states
+WHERE state_hash = $1
+  AND provider = $2
+  AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteExpiredOAuthStates :exec
+DELETE FROM oauth_states
+WHERE expires_at <= now();
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (
+  user_id,
+  provider,
+  subject,
+  email
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1
+  AND subject = $2
+LIMIT 1;
+
+-- name: ListUserIdentities :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY id;
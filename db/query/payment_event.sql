@@ -0,0 +1,51 @@
+-- name: CreatePaymentEvent :one
+-- CreatePaymentEvent stores a webhook event. No row is returned if the
+-- provider already delivered an event with the same id.
+INSERT INTO payment_events (
+  provider,
+  event_id,
+  event_type,
+  payment_intent_id,
+  payload
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetPaymentEvent :one
+SELECT * FROM payment_events
+WHERE id = $1 LIMIT 1;
+
+-- name: GetPaymentEventByEventID :one
+SELECT * FROM payment_events
+WHERE provider = $1 AND event_id = $2 LIMIT 1;
+
+-- name: GetPaymentEventForUpdate :one
+SELECT * FROM payment_events
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: UpdatePaymentEventStatus :one
+-- UpdatePaymentEventStatus records the outcome of an attempt to apply an
+-- event.
+UPDATE payment_events
+SET
+  status = sqlc.arg(status),
+  last_error = sqlc.narg(last_error),
+  attempts = attempts + 1,
+  processed_at = CASE WHEN sqlc.arg(status) IN ('processed', 'ignored') THEN now() END
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ListPaymentEventsByStatus :many
+SELECT * FROM payment_events
+WHERE status = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: ListDeferredPaymentEvents :many
+SELECT * FROM payment_events
+WHERE provider = $1 AND payment_intent_id = $2 AND status = 'deferred'
+ORDER BY id;
@@ -0,0 +1,36 @@
+-- name: CreateRefund :one
+INSERT INTO refunds (
+  donation_id,
+  amount,
+  currency,
+  reason,
+  note,
+  status,
+  provider,
+  provider_refund_id,
+  created_by
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING *;
+
+-- name: GetRefundByProviderRefundID :one
+SELECT * FROM refunds
+WHERE provider = $1 AND provider_refund_id = $2 LIMIT 1;
+
+-- name: UpdateRefundStatus :one
+UPDATE refunds
+SET status = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ListRefundsByDonation :many
+SELECT * FROM refunds
+WHERE donation_id = $1
+ORDER BY id;
+
+-- name: GetDonationRefundedAmount :one
+-- GetDonationRefundedAmount sums the refunds of a donation that have not
+-- failed.
+SELECT COALESCE(SUM(amount), 0)::bigint AS refunded_amount
+FROM refunds
+WHERE donation_id = $1 AND status <> 'failed';
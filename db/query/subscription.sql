@@ -0,0 +1,123 @@
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (
+  user_id,
+  goal_id,
+  amount,
+  currency,
+  "interval",
+  payment_method,
+  next_charge_at,
+  billing_anchor
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $7
+) RETURNING *;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE id = $1 LIMIT 1;
+
+-- name: ListSubscriptionsByUser :many
+SELECT * FROM subscriptions
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: ClaimDueSubscriptions :many
+-- ClaimDueSubscriptions reserves up to batch_size subscriptions due at now
+-- until locked_until. Rows reserved by a concurrent scheduler are skipped
+-- rather than waited for.
+UPDATE subscriptions
+SET locked_until = sqlc.arg(locked_until)
+WHERE id IN (
+  SELECT id FROM subscriptions
+  WHERE status IN ('active', 'past_due')
+    AND next_charge_at <= sqlc.arg(now)
+    AND pending_donation_id IS NULL
+    AND (locked_until IS NULL OR locked_until <= sqlc.arg(now))
+  ORDER BY next_charge_at
+  LIMIT sqlc.arg(batch_size)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: AdvanceSubscription :one
+-- AdvanceSubscription records a successful charge. A subscription paused or
+-- canceled while it was being charged keeps its status.
+UPDATE subscriptions
+SET
+  next_charge_at = $2,
+  failed_attempts = 0,
+  last_error = NULL,
+  locked_until = NULL,
+  status = CASE WHEN status = 'past_due' THEN 'active' ELSE status END
+WHERE id = $1
+RETURNING *;
+
+-- name: SetSubscriptionPendingCharge :one
+-- SetSubscriptionPendingCharge holds a subscription whose charge the
+-- provider is still processing until the charge's donation settles.
+UPDATE subscriptions
+SET
+  pending_donation_id = $2,
+  locked_until = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: ClearSubscriptionPendingCharge :one
+-- ClearSubscriptionPendingCharge releases a subscription held for the
+-- given donation. It returns no row if the charge was already settled.
+UPDATE subscriptions
+SET pending_donation_id = NULL
+WHERE id = $1 AND pending_donation_id = $2
+RETURNING *;
+
+-- name: ListSettledSubscriptionCharges :many
+-- ListSettledSubscriptionCharges returns the donations of held charges that
+-- have succeeded or failed since.
+SELECT * FROM donations
+WHERE status <> 'pending'
+  AND id IN (
+    SELECT pending_donation_id FROM subscriptions
+    WHERE pending_donation_id IS NOT NULL
+  )
+ORDER BY id
+LIMIT $1;
+
+-- name: RecordSubscriptionFailure :one
+-- RecordSubscriptionFailure records a failed charge and when to retry it.
+UPDATE subscriptions
+SET
+  next_charge_at = $2,
+  failed_attempts = failed_attempts + 1,
+  last_error = $3,
+  locked_until = NULL,
+  status = CASE WHEN status = 'active' THEN 'past_due' ELSE status END
+WHERE id = $1
+RETURNING *;
+
+-- name: PauseSubscription :one
+UPDATE subscriptions
+SET status = 'paused'
+WHERE id = $1 AND status IN ('active', 'past_due')
+RETURNING *;
+
+-- name: ResumeSubscription :one
+-- ResumeSubscription restarts a paused subscription. A charge that fell due
+-- while it was paused is made at now.
+UPDATE subscriptions
+SET
+  status = 'active',
+  failed_attempts = 0,
+  next_charge_at = GREATEST(next_charge_at, sqlc.arg(now))
+WHERE id = sqlc.arg(id) AND status = 'paused'
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET
+  status = 'canceled',
+  canceled_at = now(),
+  locked_until = NULL
+WHERE id = $1 AND status <> 'canceled'
+RETURNING *;
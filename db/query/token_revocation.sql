@@ -0,0 +1,19 @@
+-- name: CreateTokenRevocation :one
+INSERT INTO token_revocations (
+  user_id,
+  token_id,
+  revoked_at,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListTokenRevocationsCreatedAfter :many
+SELECT * FROM token_revocations
+WHERE created_at > sqlc.arg(created_after)
+  AND expires_at > now()
+ORDER BY created_at;
+
+-- name: DeleteExpiredTokenRevocations :exec
+DELETE FROM token_revocations
+WHERE expires_at <= now();
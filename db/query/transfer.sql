@@ -1,18 +1,18 @@
 -- name: GetGoalTotalDonations :one
 SELECT COALESCE(SUM(amount), 0) AS total_amount
 FROM donations
-WHERE goal_id = $1;
+WHERE goal_id = $1 AND status = 'succeeded';
 
 -- name: GetUserTotalDonations :one
 SELECT COALESCE(SUM(amount), 0) AS total_amount
 FROM donations
-WHERE user_id = $1;
+WHERE user_id = $1 AND status = 'succeeded';
 
 -- name: ListGoalDonors :many
 SELECT u.*
 FROM users u
 JOIN donations d ON d.user_id = u.id
-WHERE d.goal_id = $1
+WHERE d.goal_id = $1 AND d.status = 'succeeded'
 GROUP BY u.id
 ORDER BY u.id
 LIMIT $2
@@ -13,6 +13,10 @@ INSERT INTO users (
 SELECT * FROM users
 WHERE id = $1 LIMIT 1;
 
+-- name: GetUserRole :one
+SELECT role FROM users
+WHERE id = $1 LIMIT 1;
+
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1 LIMIT 1;
@@ -21,4 +25,32 @@ WHERE email = $1 LIMIT 1;
 SELECT * FROM users
 ORDER BY id
 LIMIT $1
-OFFSET $2;
\ No newline at end of file
+OFFSET $2;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET is_email_verified = TRUE
+WHERE id = @id
+  AND email = @email
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET password = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users
+SET
+  name = COALESCE(sqlc.narg(name), name),
+  email = COALESCE(sqlc.narg(email), email),
+  is_email_verified = COALESCE(sqlc.narg(is_email_verified), is_email_verified)
+WHERE id = sqlc.arg(id)
+RETURNING *;
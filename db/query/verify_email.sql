@@ -1 +1,18 @@
--- Intentionally left empty: no verify_email-related tables in current schema.
+-- name: CreateVerifyEmail :one
+INSERT INTO verify_emails (
+  user_id,
+  email,
+  secret_code,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: UpdateVerifyEmail :one
+UPDATE verify_emails
+SET is_used = TRUE
+WHERE id = @id
+  AND secret_code = @secret_code
+  AND is_used = FALSE
+  AND expired_at > now()
+RETURNING *;
@@ -16,20 +16,33 @@ INSERT INTO donations (
   goal_id,
   amount,
   currency,
-  is_anonymous
+  is_anonymous,
+  provider,
+  payment_intent_id,
+  confirmation_token_hash
 ) VALUES (
-  $1, $2, $3, TRUE
-) RETURNING id, user_id, goal_id, amount, currency, is_anonymous, created_at
+  $1, $2, $3, TRUE, $4, $5, $6
+) RETURNING id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id
 `
 
 type CreateAnonymousDonationParams struct {
-	GoalID   int64  `json:"goal_id"`
-	Amount   int64  `json:"amount"`
-	Currency string `json:"currency"`
+	GoalID                int64       `json:"goal_id"`
+	Amount                int64       `json:"amount"`
+	Currency              string      `json:"currency"`
+	Provider              string      `json:"provider"`
+	PaymentIntentID       pgtype.Text `json:"payment_intent_id"`
+	ConfirmationTokenHash pgtype.Text `json:"confirmation_token_hash"`
 }
 
 func (q *Queries) CreateAnonymousDonation(ctx context.Context, arg CreateAnonymousDonationParams) (Donation, error) {
-	row := q.db.QueryRow(ctx, createAnonymousDonation, arg.GoalID, arg.Amount, arg.Currency)
+	row := q.db.QueryRow(ctx, createAnonymousDonation,
+		arg.GoalID,
+		arg.Amount,
+		arg.Currency,
+		arg.Provider,
+		arg.PaymentIntentID,
+		arg.ConfirmationTokenHash,
+	)
 	var i Donation
 	err := row.Scan(
 		&i.ID,
@@ -39,6 +52,13 @@ func (q *Queries) CreateAnonymousDonation(ctx context.Context, arg CreateAnonymo
 		&i.Currency,
 		&i.IsAnonymous,
 		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
 	)
 	return i, err
 }
@@ -49,18 +69,24 @@ INSERT INTO donations (
   goal_id,
   amount,
   currency,
-  is_anonymous
+  is_anonymous,
+  provider,
+  payment_intent_id,
+  subscription_id
 ) VALUES (
-  $1, $2, $3, $4, $5
-) RETURNING id, user_id, goal_id, amount, currency, is_anonymous, created_at
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id
 `
 
 type CreateDonationParams struct {
-	UserID      pgtype.Int8 `json:"user_id"`
-	GoalID      int64       `json:"goal_id"`
-	Amount      int64       `json:"amount"`
-	Currency    string      `json:"currency"`
-	IsAnonymous bool        `json:"is_anonymous"`
+	UserID          pgtype.Int8 `json:"user_id"`
+	GoalID          int64       `json:"goal_id"`
+	Amount          int64       `json:"amount"`
+	Currency        string      `json:"currency"`
+	IsAnonymous     bool        `json:"is_anonymous"`
+	Provider        string      `json:"provider"`
+	PaymentIntentID pgtype.Text `json:"payment_intent_id"`
+	SubscriptionID  pgtype.Int8 `json:"subscription_id"`
 }
 
 func (q *Queries) CreateDonation(ctx context.Context, arg CreateDonationParams) (Donation, error) {
@@ -70,7 +96,48 @@ func (q *Queries) CreateDonation(ctx context.Context, arg CreateDonationParams)
 		arg.Amount,
 		arg.Currency,
 		arg.IsAnonymous,
+		arg.Provider,
+		arg.PaymentIntentID,
+		arg.SubscriptionID,
+	)
+	var i Donation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GoalID,
+		&i.Amount,
+		&i.Currency,
+		&i.IsAnonymous,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
 	)
+	return i, err
+}
+
+const failDonation = `-- name: FailDonation :one
+UPDATE donations
+SET
+  status = 'failed',
+  failure_reason = $2
+WHERE id = $1 AND status = 'pending'
+RETURNING id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id
+`
+
+type FailDonationParams struct {
+	ID            int64       `json:"id"`
+	FailureReason pgtype.Text `json:"failure_reason"`
+}
+
+// FailDonation marks a pending donation as failed. No row is returned if
+// the donation is no longer pending.
+func (q *Queries) FailDonation(ctx context.Context, arg FailDonationParams) (Donation, error) {
+	row := q.db.QueryRow(ctx, failDonation, arg.ID, arg.FailureReason)
 	var i Donation
 	err := row.Scan(
 		&i.ID,
@@ -80,12 +147,19 @@ func (q *Queries) CreateDonation(ctx context.Context, arg CreateDonationParams)
 		&i.Currency,
 		&i.IsAnonymous,
 		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
 	)
 	return i, err
 }
 
 const getDonation = `-- name: GetDonation :one
-SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at FROM donations
+SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id FROM donations
 WHERE id = $1 LIMIT 1
 `
 
@@ -100,12 +174,112 @@ func (q *Queries) GetDonation(ctx context.Context, id int64) (Donation, error) {
 		&i.Currency,
 		&i.IsAnonymous,
 		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
+	)
+	return i, err
+}
+
+const getDonationByPaymentIntent = `-- name: GetDonationByPaymentIntent :one
+SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id FROM donations
+WHERE provider = $1 AND payment_intent_id = $2 LIMIT 1
+`
+
+type GetDonationByPaymentIntentParams struct {
+	Provider        string      `json:"provider"`
+	PaymentIntentID pgtype.Text `json:"payment_intent_id"`
+}
+
+func (q *Queries) GetDonationByPaymentIntent(ctx context.Context, arg GetDonationByPaymentIntentParams) (Donation, error) {
+	row := q.db.QueryRow(ctx, getDonationByPaymentIntent, arg.Provider, arg.PaymentIntentID)
+	var i Donation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GoalID,
+		&i.Amount,
+		&i.Currency,
+		&i.IsAnonymous,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
+	)
+	return i, err
+}
+
+const getDonationByPaymentIntentForUpdate = `-- name: GetDonationByPaymentIntentForUpdate :one
+SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id FROM donations
+WHERE provider = $1 AND payment_intent_id = $2 LIMIT 1
+FOR UPDATE
+`
+
+type GetDonationByPaymentIntentForUpdateParams struct {
+	Provider        string      `json:"provider"`
+	PaymentIntentID pgtype.Text `json:"payment_intent_id"`
+}
+
+func (q *Queries) GetDonationByPaymentIntentForUpdate(ctx context.Context, arg GetDonationByPaymentIntentForUpdateParams) (Donation, error) {
+	row := q.db.QueryRow(ctx, getDonationByPaymentIntentForUpdate, arg.Provider, arg.PaymentIntentID)
+	var i Donation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GoalID,
+		&i.Amount,
+		&i.Currency,
+		&i.IsAnonymous,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
+	)
+	return i, err
+}
+
+const getDonationForUpdate = `-- name: GetDonationForUpdate :one
+SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id FROM donations
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetDonationForUpdate(ctx context.Context, id int64) (Donation, error) {
+	row := q.db.QueryRow(ctx, getDonationForUpdate, id)
+	var i Donation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GoalID,
+		&i.Amount,
+		&i.Currency,
+		&i.IsAnonymous,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
 	)
 	return i, err
 }
 
 const listDonationsByGoal = `-- name: ListDonationsByGoal :many
-SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at FROM donations
+SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id FROM donations
 WHERE goal_id = $1
 ORDER BY created_at DESC
 LIMIT $2
@@ -135,6 +309,13 @@ func (q *Queries) ListDonationsByGoal(ctx context.Context, arg ListDonationsByGo
 			&i.Currency,
 			&i.IsAnonymous,
 			&i.CreatedAt,
+			&i.Status,
+			&i.Provider,
+			&i.PaymentIntentID,
+			&i.FailureReason,
+			&i.ConfirmedAt,
+			&i.ConfirmationTokenHash,
+			&i.SubscriptionID,
 		); err != nil {
 			return nil, err
 		}
@@ -147,7 +328,7 @@ func (q *Queries) ListDonationsByGoal(ctx context.Context, arg ListDonationsByGo
 }
 
 const listDonationsByUser = `-- name: ListDonationsByUser :many
-SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at FROM donations
+SELECT id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id FROM donations
 WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2
@@ -177,6 +358,13 @@ func (q *Queries) ListDonationsByUser(ctx context.Context, arg ListDonationsByUs
 			&i.Currency,
 			&i.IsAnonymous,
 			&i.CreatedAt,
+			&i.Status,
+			&i.Provider,
+			&i.PaymentIntentID,
+			&i.FailureReason,
+			&i.ConfirmedAt,
+			&i.ConfirmationTokenHash,
+			&i.SubscriptionID,
 		); err != nil {
 			return nil, err
 		}
@@ -187,3 +375,69 @@ func (q *Queries) ListDonationsByUser(ctx context.Context, arg ListDonationsByUs
 	}
 	return items, nil
 }
+
+const markDonationSucceeded = `-- name: MarkDonationSucceeded :one
+UPDATE donations
+SET
+  status = 'succeeded',
+  failure_reason = NULL,
+  confirmed_at = now()
+WHERE id = $1
+RETURNING id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id
+`
+
+func (q *Queries) MarkDonationSucceeded(ctx context.Context, id int64) (Donation, error) {
+	row := q.db.QueryRow(ctx, markDonationSucceeded, id)
+	var i Donation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GoalID,
+		&i.Amount,
+		&i.Currency,
+		&i.IsAnonymous,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
+	)
+	return i, err
+}
+
+const updateDonationStatus = `-- name: UpdateDonationStatus :one
+UPDATE donations
+SET status = $2
+WHERE id = $1
+RETURNING id, user_id, goal_id, amount, currency, is_anonymous, created_at, status, provider, payment_intent_id, failure_reason, confirmed_at, confirmation_token_hash, subscription_id
+`
+
+type UpdateDonationStatusParams struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+func (q *Queries) UpdateDonationStatus(ctx context.Context, arg UpdateDonationStatusParams) (Donation, error) {
+	row := q.db.QueryRow(ctx, updateDonationStatus, arg.ID, arg.Status)
+	var i Donation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GoalID,
+		&i.Amount,
+		&i.Currency,
+		&i.IsAnonymous,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Provider,
+		&i.PaymentIntentID,
+		&i.FailureReason,
+		&i.ConfirmedAt,
+		&i.ConfirmationTokenHash,
+		&i.SubscriptionID,
+	)
+	return i, err
+}
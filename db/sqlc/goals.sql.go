@@ -191,6 +191,33 @@ func (q *Queries) ListGoals(ctx context.Context, arg ListGoalsParams) ([]Goal, e
 	return items, nil
 }
 
+const subtractFromGoalCollectedAmount = `-- name: SubtractFromGoalCollectedAmount :one
+UPDATE goals
+SET collected_amount = collected_amount - $1
+WHERE id = $2
+RETURNING id, title, description, target_amount, collected_amount, is_active, created_at
+`
+
+type SubtractFromGoalCollectedAmountParams struct {
+	Amount int64 `json:"amount"`
+	ID     int64 `json:"id"`
+}
+
+func (q *Queries) SubtractFromGoalCollectedAmount(ctx context.Context, arg SubtractFromGoalCollectedAmountParams) (Goal, error) {
+	row := q.db.QueryRow(ctx, subtractFromGoalCollectedAmount, arg.Amount, arg.ID)
+	var i Goal
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Description,
+		&i.TargetAmount,
+		&i.CollectedAmount,
+		&i.IsActive,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const updateGoal = `-- name: UpdateGoal :one
 UPDATE goals
 SET
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrIdempotencyKeyLost is returned when a transaction tries to complete an
+// idempotency key that is no longer reserved by its request, e.g. because
+// the reservation expired and another request claimed the key.
+var ErrIdempotencyKeyLost = errors.New("idempotency key reservation was lost")
+
+// completeIdempotencyKey stores response as the JSON body recorded for a
+// reserved idempotency key. It does nothing when arg.ID is zero.
+func (q *Queries) completeIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams, response any) error {
+	if arg.ID == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	arg.ResponseBody = body
+
+	if _, err := q.CompleteIdempotencyKey(ctx, arg); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrIdempotencyKeyLost
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Ledger account kinds.
+const (
+	// LedgerAccountGoal holds the funds raised for a goal. It is credited
+	// by donations and debited by refunds and payouts.
+	LedgerAccountGoal = "goal"
+	// LedgerAccountClearing holds the funds a payment provider collected
+	// on our behalf and has not paid out yet.
+	LedgerAccountClearing = "clearing"
+	// LedgerAccountReceivable holds what a goal owes back after the provider
+	// refunded more than the goal had left, typically after a payout. It is
+	// debited by such refunds and counts against the goal's funds.
+	LedgerAccountReceivable = "receivable"
+)
+
+// Ledger entry kinds.
+const (
+	LedgerEntryDonation = "donation"
+	LedgerEntryRefund   = "refund"
+	LedgerEntryPayout   = "payout"
+)
+
+// ManualProvider is the clearing account used for donations and payouts
+// recorded without a payment provider.
+const ManualProvider = "manual"
+
+// ErrUnbalancedEntry is returned when the postings of a ledger entry do not
+// sum to zero.
+var ErrUnbalancedEntry = errors.New("ledger entry is not balanced")
+
+// ErrInsufficientFunds is returned by PayoutTx when the goal or the clearing
+// account holds less than the requested amount, and by RefundTx when the
+// goal no longer holds the amount to refund.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+func goalAccount(goalID int64, currency string) UpsertLedgerAccountParams {
+	return UpsertLedgerAccountParams{
+		Code:     fmt.Sprintf("%s:%d:%s", LedgerAccountGoal, goalID, currency),
+		Kind:     LedgerAccountGoal,
+		GoalID:   pgtype.Int8{Int64: goalID, Valid: true},
+		Currency: currency,
+	}
+}
+
+func clearingAccount(provider string, currency string) UpsertLedgerAccountParams {
+	return UpsertLedgerAccountParams{
+		Code:     fmt.Sprintf("%s:%s:%s", LedgerAccountClearing, provider, currency),
+		Kind:     LedgerAccountClearing,
+		Currency: currency,
+	}
+}
+
+func receivableAccount(goalID int64, currency string) UpsertLedgerAccountParams {
+	return UpsertLedgerAccountParams{
+		Code:     fmt.Sprintf("%s:%d:%s", LedgerAccountReceivable, goalID, currency),
+		Kind:     LedgerAccountReceivable,
+		GoalID:   pgtype.Int8{Int64: goalID, Valid: true},
+		Currency: currency,
+	}
+}
+
+// goalFunds returns the funds a goal holds in a currency. The goal must be
+// locked.
+func (q *Queries) goalFunds(ctx context.Context, goalID int64, currency string) (int64, error) {
+	return q.GetGoalLedgerFunds(ctx, GetGoalLedgerFundsParams{
+		GoalID:   pgtype.Int8{Int64: goalID, Valid: true},
+		Currency: currency,
+	})
+}
+
+// ledgerPosting is one side of a ledger entry. Amount is positive for a
+// debit and negative for a credit.
+type ledgerPosting struct {
+	Account UpsertLedgerAccountParams
+	Amount  int64
+}
+
+// postLedgerEntry records a balanced entry, creating the accounts it posts
+// to on first use. It must run inside execTx.
+func (q *Queries) postLedgerEntry(ctx context.Context, entry CreateLedgerEntryParams, postings ...ledgerPosting) (LedgerEntry, error) {
+	var sum int64
+	for _, p := range postings {
+		sum += p.Amount
+	}
+	if sum != 0 || len(postings) < 2 {
+		return LedgerEntry{}, ErrUnbalancedEntry
+	}
+
+	created, err := q.CreateLedgerEntry(ctx, entry)
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+
+	for _, p := range postings {
+		if p.Amount == 0 {
+			continue
+		}
+		account, err := q.UpsertLedgerAccount(ctx, p.Account)
+		if err != nil {
+			return LedgerEntry{}, err
+		}
+		if _, err := q.CreateLedgerPosting(ctx, CreateLedgerPostingParams{
+			EntryID:   created.ID,
+			AccountID: account.ID,
+			Amount:    p.Amount,
+		}); err != nil {
+			return LedgerEntry{}, err
+		}
+	}
+
+	return created, nil
+}
+
+type PayoutTxParams struct {
+	GoalID   int64  `json:"goal_id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// Provider names the clearing account the funds leave from.
+	Provider  string `json:"provider"`
+	Reference string `json:"reference"`
+	CreatedBy int64  `json:"created_by"`
+
+	// IdempotencyKey, when its ID is set, is completed with the JSON-encoded
+	// payout in the same transaction as the payout.
+	IdempotencyKey CompleteIdempotencyKeyParams `json:"-"`
+}
+
+type PayoutTxResult struct {
+	Payout Payout      `json:"payout"`
+	Entry  LedgerEntry `json:"entry"`
+}
+
+// PayoutTx records funds paid out to a goal's beneficiary. It returns
+// ErrInsufficientFunds when the goal's funds in the payout currency, net of
+// what its receivable account is owed, or the provider's clearing account
+// hold less than the amount.
+func (store *SQLStore) PayoutTx(ctx context.Context, arg PayoutTxParams) (PayoutTxResult, error) {
+	var result PayoutTxResult
+
+	err := store.execTx(ctx, "PayoutTx", financialTxOptions, func(ctx context.Context, q *Queries) error {
+		// serialize with donations and refunds to the same goal
+		if _, err := q.GetGoalForUpdate(ctx, arg.GoalID); err != nil {
+			return err
+		}
+
+		funds, err := q.goalFunds(ctx, arg.GoalID, arg.Currency)
+		if err != nil {
+			return err
+		}
+		if funds < arg.Amount {
+			return fmt.Errorf("goal %d: %w", arg.GoalID, ErrInsufficientFunds)
+		}
+
+		// the upsert locks the clearing account row, serializing payouts
+		// from the same provider
+		clearing, err := q.UpsertLedgerAccount(ctx, clearingAccount(arg.Provider, arg.Currency))
+		if err != nil {
+			return err
+		}
+		balance, err := q.GetLedgerAccountBalance(ctx, clearing.ID)
+		if err != nil {
+			return err
+		}
+		// clearing accounts are debit accounts, so funds are a positive balance
+		if balance < arg.Amount {
+			return fmt.Errorf("clearing account %s: %w", clearing.Code, ErrInsufficientFunds)
+		}
+
+		payout, err := q.CreatePayout(ctx, CreatePayoutParams{
+			GoalID:    arg.GoalID,
+			Amount:    arg.Amount,
+			Currency:  arg.Currency,
+			Provider:  arg.Provider,
+			Reference: arg.Reference,
+			CreatedBy: arg.CreatedBy,
+		})
+		if err != nil {
+			return err
+		}
+
+		entry, err := q.postLedgerEntry(ctx, CreateLedgerEntryParams{
+			Kind:     LedgerEntryPayout,
+			Currency: arg.Currency,
+			PayoutID: pgtype.Int8{Int64: payout.ID, Valid: true},
+		},
+			ledgerPosting{Account: goalAccount(arg.GoalID, arg.Currency), Amount: arg.Amount},
+			ledgerPosting{Account: clearingAccount(arg.Provider, arg.Currency), Amount: -arg.Amount},
+		)
+		if err != nil {
+			return err
+		}
+
+		result = PayoutTxResult{Payout: payout, Entry: entry}
+		return q.completeIdempotencyKey(ctx, arg.IdempotencyKey, payout)
+	})
+
+	return result, err
+}
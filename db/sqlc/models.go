@@ -7,9 +7,28 @@ package db
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiKey struct {
+	ID             int64       `json:"id"`
+	UserID         pgtype.Int8 `json:"user_id"`
+	OrganizationID pgtype.Int8 `json:"organization_id"`
+	Name           string      `json:"name"`
+	// public part of the key, used to look it up
+	Prefix string `json:"prefix"`
+	// hex-encoded SHA-256 of the full key
+	KeyHash    string             `json:"key_hash"`
+	Scopes     []string           `json:"scopes"`
+	CreatedBy  int64              `json:"created_by"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	// null for keys that do not expire
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
 type Donation struct {
 	ID     int64       `json:"id"`
 	UserID pgtype.Int8 `json:"user_id"`
@@ -19,6 +38,19 @@ type Donation struct {
 	Currency    string    `json:"currency"`
 	IsAnonymous bool      `json:"is_anonymous"`
 	CreatedAt   time.Time `json:"created_at"`
+	// pending, succeeded, failed or refunded
+	Status string `json:"status"`
+	// payment provider collecting the donation
+	Provider string `json:"provider"`
+	// provider reference of the payment
+	PaymentIntentID pgtype.Text `json:"payment_intent_id"`
+	FailureReason   pgtype.Text `json:"failure_reason"`
+	// when the provider confirmed the payment
+	ConfirmedAt pgtype.Timestamptz `json:"confirmed_at"`
+	// SHA-256 of the token that lets the donor of an anonymous donation confirm it
+	ConfirmationTokenHash pgtype.Text `json:"-"`
+	// subscription the donation was charged for
+	SubscriptionID pgtype.Int8 `json:"subscription_id"`
 }
 
 type Goal struct {
@@ -32,10 +64,230 @@ type Goal struct {
 	CreatedAt       time.Time   `json:"created_at"`
 }
 
-type User struct {
-	ID        int64       `json:"id"`
-	Email     string      `json:"email"`
-	Name      pgtype.Text `json:"name"`
-	Password  pgtype.Text `json:"password"`
+type IdempotencyKey struct {
+	ID int64 `json:"id"`
+	// caller the key belongs to, e.g. user:42
+	Scope string `json:"scope"`
+	Key   string `json:"key"`
+	// hex-encoded SHA-256 of the method, path and body
+	RequestHash string `json:"request_hash"`
+	// set while the first request is still being processed
+	LockID         pgtype.UUID `json:"lock_id"`
+	ResponseStatus pgtype.Int4 `json:"response_status"`
+	ResponseBody   []byte      `json:"response_body"`
+	// after which the key may be reused
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LedgerAccount struct {
+	ID int64 `json:"id"`
+	// e.g. goal:12:USD, clearing:stripe:USD, receivable:12:USD
+	Code string `json:"code"`
+	// goal, clearing or receivable
+	Kind string `json:"kind"`
+	// set for goal and receivable accounts only
+	GoalID    pgtype.Int8 `json:"goal_id"`
+	Currency  string      `json:"currency"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type LedgerEntry struct {
+	ID int64 `json:"id"`
+	// donation, refund or payout
+	Kind       string      `json:"kind"`
+	Currency   string      `json:"currency"`
+	DonationID pgtype.Int8 `json:"donation_id"`
+	PayoutID   pgtype.Int8 `json:"payout_id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	RefundID   pgtype.Int8 `json:"refund_id"`
+}
+
+type LedgerPosting struct {
+	ID        int64     `json:"id"`
+	EntryID   int64     `json:"entry_id"`
+	AccountID int64     `json:"account_id"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginAttempt struct {
+	// what is being throttled, e.g. email:jane@example.com or ip:203.0.113.7
+	Key          string             `json:"key"`
+	Failures     int32              `json:"failures"`
+	LastFailedAt time.Time          `json:"last_failed_at"`
+	LockedUntil  pgtype.Timestamptz `json:"locked_until"`
+}
+
+type MfaRecoveryCode struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type OauthState struct {
+	// hex-encoded SHA-256 of the state parameter
+	StateHash string `json:"state_hash"`
+	Provider  string `json:"provider"`
+	// PKCE code verifier sent with the token request
+	CodeVerifier string    `json:"code_verifier"`
+	Nonce        string    `json:"nonce"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Organization struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PasswordReset struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// hex-encoded SHA-256 of the token sent by email
+	TokenHash string    `json:"token_hash"`
+	IsUsed    bool      `json:"is_used"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+type PaymentEvent struct {
+	ID       int64  `json:"id"`
+	Provider string `json:"provider"`
+	// id of the event at the provider
+	EventID         string      `json:"event_id"`
+	EventType       string      `json:"event_type"`
+	PaymentIntentID pgtype.Text `json:"payment_intent_id"`
+	// raw webhook body, as signed by the provider
+	Payload []byte `json:"payload"`
+	// received, processed, ignored, deferred or failed
+	Status string `json:"status"`
+	// number of times the event was applied
+	Attempts    int32              `json:"attempts"`
+	LastError   pgtype.Text        `json:"last_error"`
+	ProcessedAt pgtype.Timestamptz `json:"processed_at"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+type Payout struct {
+	ID       int64  `json:"id"`
+	GoalID   int64  `json:"goal_id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// clearing account the funds are paid out from
+	Provider string `json:"provider"`
+	// bank transfer or provider payout reference
+	Reference string    `json:"reference"`
+	CreatedBy int64     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Refund struct {
+	ID         int64  `json:"id"`
+	DonationID int64  `json:"donation_id"`
+	Amount     int64  `json:"amount"`
+	Currency   string `json:"currency"`
+	// duplicate, fraudulent, requested_by_donor, goal_canceled or other
+	Reason   string      `json:"reason"`
+	Note     pgtype.Text `json:"note"`
+	Status   string      `json:"status"`
+	Provider string      `json:"provider"`
+	// provider reference of the refund
+	ProviderRefundID pgtype.Text `json:"provider_refund_id"`
+	// null for refunds issued at the provider
+	CreatedBy pgtype.Int8 `json:"created_by"`
 	CreatedAt time.Time   `json:"created_at"`
 }
+
+type Session struct {
+	ID           uuid.UUID          `json:"id"`
+	UserID       int64              `json:"user_id"`
+	FamilyID     uuid.UUID          `json:"family_id"`
+	RefreshToken string             `json:"refresh_token"`
+	UserAgent    string             `json:"user_agent"`
+	ClientIp     string             `json:"client_ip"`
+	IsBlocked    bool               `json:"is_blocked"`
+	RotatedAt    pgtype.Timestamptz `json:"rotated_at"`
+	ExpiresAt    time.Time          `json:"expires_at"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+type Subscription struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"user_id"`
+	GoalID   int64  `json:"goal_id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// weekly or monthly
+	Interval string `json:"interval"`
+	// provider ID of the card or account charged
+	PaymentMethod string `json:"payment_method"`
+	// active, past_due, paused or canceled
+	Status       string    `json:"status"`
+	NextChargeAt time.Time `json:"next_charge_at"`
+	// first charge, whose weekday or day of the month later charges fall on, or the last day of shorter months
+	BillingAnchor time.Time `json:"billing_anchor"`
+	// donation of a charge the provider is still processing, which holds off further charges
+	PendingDonationID pgtype.Int8 `json:"pending_donation_id"`
+	// failed charges since the last successful one
+	FailedAttempts int32       `json:"failed_attempts"`
+	LastError      pgtype.Text `json:"last_error"`
+	// set while the scheduler is charging the subscription
+	LockedUntil pgtype.Timestamptz `json:"locked_until"`
+	CanceledAt  pgtype.Timestamptz `json:"canceled_at"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+type TokenRevocation struct {
+	ID        int64       `json:"id"`
+	UserID    int64       `json:"user_id"`
+	TokenID   pgtype.UUID `json:"token_id"`
+	RevokedAt time.Time   `json:"revoked_at"`
+	// once every affected token has expired the record can be pruned
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type User struct {
+	ID              int64       `json:"id"`
+	Email           string      `json:"email"`
+	Name            pgtype.Text `json:"name"`
+	Password        pgtype.Text `json:"password"`
+	CreatedAt       time.Time   `json:"created_at"`
+	Role            string      `json:"role"`
+	IsEmailVerified bool        `json:"is_email_verified"`
+}
+
+type UserIdentity struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"user_id"`
+	Provider string `json:"provider"`
+	// stable user ID assigned by the provider (OIDC sub claim)
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserTotp struct {
+	UserID int64 `json:"user_id"`
+	// base32-encoded TOTP shared secret
+	Secret string `json:"secret"`
+	// time step of the last accepted code, to reject replays
+	LastUsedStep int64 `json:"last_used_step"`
+	// null until enrollment is confirmed with a first code
+	ConfirmedAt pgtype.Timestamptz `json:"confirmed_at"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+type VerifyEmail struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Email      string    `json:"email"`
+	SecretCode string    `json:"secret_code"`
+	IsUsed     bool      `json:"is_used"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
+}
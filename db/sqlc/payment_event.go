@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Payment event statuses. Events that are deferred wait for an earlier
+// event they depend on; failed events are kept for manual replay.
+const (
+	PaymentEventReceived  = "received"
+	PaymentEventProcessed = "processed"
+	PaymentEventIgnored   = "ignored"
+	PaymentEventDeferred  = "deferred"
+	PaymentEventFailed    = "failed"
+)
+
+type ApplyPaymentEventTxParams struct {
+	EventID int64 `json:"event_id"`
+	// DonationStatus is what the event reports about the donation paid by
+	// its payment intent: succeeded, failed or refunded. Events that do
+	// not concern a donation leave it empty and are ignored.
+	DonationStatus string `json:"donation_status"`
+	// Amount and Currency are what the provider collected.
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	FailureReason string `json:"failure_reason"`
+	// RefundID, RefundStatus and RefundReason describe the refund reported
+	// by a refunded event.
+	RefundID     string `json:"refund_id"`
+	RefundStatus string `json:"refund_status"`
+	RefundReason string `json:"refund_reason"`
+}
+
+type ApplyPaymentEventTxResult struct {
+	Event PaymentEvent `json:"event"`
+	// Donation is set when the event concerned a known donation.
+	Donation *Donation `json:"donation,omitempty"`
+}
+
+// ApplyPaymentEventTx applies a stored webhook event to the donation paid
+// by its payment intent and records the outcome on the event. The event row
+// is locked, so an event delivered or replayed several times is applied at
+// most once. Events that arrive before the donation or payment they refer
+// to are deferred rather than failed.
+func (store *SQLStore) ApplyPaymentEventTx(ctx context.Context, arg ApplyPaymentEventTxParams) (ApplyPaymentEventTxResult, error) {
+	var result ApplyPaymentEventTxResult
+
+	err := store.execTx(ctx, "ApplyPaymentEventTx", financialTxOptions, func(ctx context.Context, q *Queries) error {
+		result = ApplyPaymentEventTxResult{}
+
+		event, err := q.GetPaymentEventForUpdate(ctx, arg.EventID)
+		if err != nil {
+			return err
+		}
+		if event.Status == PaymentEventProcessed || event.Status == PaymentEventIgnored {
+			result.Event = event
+			return nil
+		}
+
+		if arg.DonationStatus == "" || !event.PaymentIntentID.Valid {
+			result.Event, err = q.setPaymentEventStatus(ctx, event.ID, PaymentEventIgnored, "")
+			return err
+		}
+
+		donation, err := q.GetDonationByPaymentIntentForUpdate(ctx, GetDonationByPaymentIntentForUpdateParams{
+			Provider:        event.Provider,
+			PaymentIntentID: event.PaymentIntentID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// the event may have overtaken the request recording the donation
+			result.Event, err = q.setPaymentEventStatus(ctx, event.ID, PaymentEventDeferred, "no donation for the payment intent")
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		status, reason := PaymentEventProcessed, ""
+		switch arg.DonationStatus {
+		case DonationSucceeded:
+			confirmed, err := q.confirmDonation(ctx, ConfirmDonationTxParams{
+				DonationID: donation.ID,
+				Amount:     arg.Amount,
+				Currency:   arg.Currency,
+			})
+			if err != nil {
+				return err
+			}
+			donation = confirmed.Donation
+		case DonationFailed:
+			// a failure reported after the payment succeeded is stale
+			if donation.Status == DonationPending {
+				donation, err = q.FailDonation(ctx, FailDonationParams{
+					ID:            donation.ID,
+					FailureReason: pgtype.Text{String: arg.FailureReason, Valid: arg.FailureReason != ""},
+				})
+				if err != nil {
+					return err
+				}
+			}
+		case DonationRefunded:
+			// a refund can overtake the notification that the payment
+			// succeeded; it is applied once the donation has been credited
+			if donation.Status != DonationSucceeded && donation.Status != DonationRefunded {
+				status, reason = PaymentEventDeferred, "donation has not succeeded yet"
+				break
+			}
+			donation, err = q.applyProviderRefund(ctx, donation, arg)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported donation status %q", arg.DonationStatus)
+		}
+
+		result.Donation = &donation
+		result.Event, err = q.setPaymentEventStatus(ctx, event.ID, status, reason)
+		return err
+	})
+
+	return result, err
+}
+
+// setPaymentEventStatus records the outcome of an attempt to apply an event.
+func (q *Queries) setPaymentEventStatus(ctx context.Context, id int64, status string, reason string) (PaymentEvent, error) {
+	return q.UpdatePaymentEventStatus(ctx, UpdatePaymentEventStatusParams{
+		ID:        id,
+		Status:    status,
+		LastError: pgtype.Text{String: reason, Valid: reason != ""},
+	})
+}
@@ -6,30 +6,168 @@ package db
 
 import (
 	"context"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
 	AddToGoalCollectedAmount(ctx context.Context, arg AddToGoalCollectedAmountParams) (Goal, error)
+	// AdvanceSubscription records a successful charge. A subscription paused or
+	// canceled while it was being charged keeps its status.
+	AdvanceSubscription(ctx context.Context, arg AdvanceSubscriptionParams) (Subscription, error)
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	BlockUserSessions(ctx context.Context, userID int64) error
+	CancelSubscription(ctx context.Context, id int64) (Subscription, error)
+	// ClaimDueSubscriptions reserves up to batch_size subscriptions due at now
+	// until locked_until. Rows reserved by a concurrent scheduler are skipped
+	// rather than waited for.
+	ClaimDueSubscriptions(ctx context.Context, arg ClaimDueSubscriptionsParams) ([]Subscription, error)
+	// ClearSubscriptionPendingCharge releases a subscription held for the
+	// given donation. It returns no row if the charge was already settled.
+	ClearSubscriptionPendingCharge(ctx context.Context, arg ClearSubscriptionPendingChargeParams) (Subscription, error)
+	// CompleteIdempotencyKey stores the response of the request holding
+	// lock_id. No row is returned if the reservation was lost.
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) (IdempotencyKey, error)
+	ConfirmUserTOTP(ctx context.Context, userID int64) (UserTotp, error)
+	ConsumeOAuthState(ctx context.Context, arg ConsumeOAuthStateParams) (OauthState, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
 	CreateAnonymousDonation(ctx context.Context, arg CreateAnonymousDonationParams) (Donation, error)
 	CreateDonation(ctx context.Context, arg CreateDonationParams) (Donation, error)
 	CreateGoal(ctx context.Context, arg CreateGoalParams) (Goal, error)
+	CreateLedgerEntry(ctx context.Context, arg CreateLedgerEntryParams) (LedgerEntry, error)
+	CreateLedgerPosting(ctx context.Context, arg CreateLedgerPostingParams) (LedgerPosting, error)
+	CreateMFARecoveryCode(ctx context.Context, arg CreateMFARecoveryCodeParams) (MfaRecoveryCode, error)
+	CreateOAuthState(ctx context.Context, arg CreateOAuthStateParams) (OauthState, error)
+	CreateOrganization(ctx context.Context, name string) (Organization, error)
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error)
+	// CreatePaymentEvent stores a webhook event. No row is returned if the
+	// provider already delivered an event with the same id.
+	CreatePaymentEvent(ctx context.Context, arg CreatePaymentEventParams) (PaymentEvent, error)
+	CreatePayout(ctx context.Context, arg CreatePayoutParams) (Payout, error)
+	CreateRefund(ctx context.Context, arg CreateRefundParams) (Refund, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error)
+	CreateTokenRevocation(ctx context.Context, arg CreateTokenRevocationParams) (TokenRevocation, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) error
+	DeleteExpiredOAuthStates(ctx context.Context) error
+	DeleteExpiredTokenRevocations(ctx context.Context) error
+	DeleteLoginAttempt(ctx context.Context, key string) error
+	DeleteMFARecoveryCodes(ctx context.Context, userID int64) error
+	DeleteUserTOTP(ctx context.Context, userID int64) error
+	// FailDonation marks a pending donation as failed. No row is returned if
+	// the donation is no longer pending.
+	FailDonation(ctx context.Context, arg FailDonationParams) (Donation, error)
+	// ForgiveLoginFailure takes back a failure counted for key, and the lock
+	// it triggered if the key is still locked until locked_until.
+	ForgiveLoginFailure(ctx context.Context, arg ForgiveLoginFailureParams) error
+	GetAPIKey(ctx context.Context, id int64) (ApiKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (GetAPIKeyByPrefixRow, error)
 	GetDonation(ctx context.Context, id int64) (Donation, error)
+	GetDonationByPaymentIntent(ctx context.Context, arg GetDonationByPaymentIntentParams) (Donation, error)
+	GetDonationByPaymentIntentForUpdate(ctx context.Context, arg GetDonationByPaymentIntentForUpdateParams) (Donation, error)
+	GetDonationForUpdate(ctx context.Context, id int64) (Donation, error)
+	// GetDonationRefundedAmount sums the refunds of a donation that have not
+	// failed.
+	GetDonationRefundedAmount(ctx context.Context, donationID int64) (int64, error)
 	GetGoal(ctx context.Context, id int64) (Goal, error)
 	GetGoalForUpdate(ctx context.Context, id int64) (Goal, error)
+	// GetGoalLedgerFunds returns the funds a goal holds in a currency: the
+	// credit balance of its goal account less what its receivable account is
+	// owed.
+	GetGoalLedgerFunds(ctx context.Context, arg GetGoalLedgerFundsParams) (int64, error)
 	GetGoalTotalDonations(ctx context.Context, goalID int64) (interface{}, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetLedgerAccountBalance(ctx context.Context, accountID int64) (int64, error)
+	GetLedgerAccountByCode(ctx context.Context, code string) (LedgerAccount, error)
+	GetLoginAttempt(ctx context.Context, key string) (LoginAttempt, error)
+	GetOrganization(ctx context.Context, id int64) (Organization, error)
+	GetPaymentEvent(ctx context.Context, id int64) (PaymentEvent, error)
+	GetPaymentEventByEventID(ctx context.Context, arg GetPaymentEventByEventIDParams) (PaymentEvent, error)
+	GetPaymentEventForUpdate(ctx context.Context, id int64) (PaymentEvent, error)
+	GetRefundByProviderRefundID(ctx context.Context, arg GetRefundByProviderRefundIDParams) (Refund, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetSubscription(ctx context.Context, id int64) (Subscription, error)
 	GetUser(ctx context.Context, id int64) (User, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error)
+	GetUserRole(ctx context.Context, id int64) (string, error)
+	GetUserTOTP(ctx context.Context, userID int64) (UserTotp, error)
 	GetUserTotalDonations(ctx context.Context, userID pgtype.Int8) (interface{}, error)
+	InvalidateUserPasswordResets(ctx context.Context, userID int64) error
 	ListActiveGoals(ctx context.Context, arg ListActiveGoalsParams) ([]Goal, error)
+	ListDeferredPaymentEvents(ctx context.Context, arg ListDeferredPaymentEventsParams) ([]PaymentEvent, error)
 	ListDonationsByGoal(ctx context.Context, arg ListDonationsByGoalParams) ([]Donation, error)
 	ListDonationsByUser(ctx context.Context, arg ListDonationsByUserParams) ([]Donation, error)
 	ListGoalDonors(ctx context.Context, arg ListGoalDonorsParams) ([]User, error)
 	ListGoals(ctx context.Context, arg ListGoalsParams) ([]Goal, error)
+	ListLedgerPostingsByEntry(ctx context.Context, entryID int64) ([]LedgerPosting, error)
+	ListOrganizationAPIKeys(ctx context.Context, organizationID pgtype.Int8) ([]ApiKey, error)
+	ListOrganizations(ctx context.Context, arg ListOrganizationsParams) ([]Organization, error)
+	ListPaymentEventsByStatus(ctx context.Context, arg ListPaymentEventsByStatusParams) ([]PaymentEvent, error)
+	ListPayoutsByGoal(ctx context.Context, arg ListPayoutsByGoalParams) ([]Payout, error)
+	ListRefundsByDonation(ctx context.Context, donationID int64) ([]Refund, error)
+	// ListSettledSubscriptionCharges returns the donations of held charges that
+	// have succeeded or failed since.
+	ListSettledSubscriptionCharges(ctx context.Context, limit int32) ([]Donation, error)
+	ListSubscriptionsByUser(ctx context.Context, arg ListSubscriptionsByUserParams) ([]Subscription, error)
+	ListTokenRevocationsCreatedAfter(ctx context.Context, createdAfter time.Time) ([]TokenRevocation, error)
+	ListUnusedMFARecoveryCodes(ctx context.Context, userID int64) ([]MfaRecoveryCode, error)
+	ListUserAPIKeys(ctx context.Context, userID pgtype.Int8) ([]ApiKey, error)
+	ListUserIdentities(ctx context.Context, userID int64) ([]UserIdentity, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	MarkDonationSucceeded(ctx context.Context, id int64) (Donation, error)
+	MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (User, error)
+	PauseSubscription(ctx context.Context, id int64) (Subscription, error)
+	// ReconcileGoals compares each goal's collected_amount with the credits
+	// its ledger accounts received from donations and refunds. Payouts reduce
+	// the ledger balance but not collected_amount. A refund the goal could not
+	// cover is partly posted to its receivable account, so both are included.
+	ReconcileGoals(ctx context.Context, arg ReconcileGoalsParams) ([]ReconcileGoalsRow, error)
+	// RecordLoginFailure counts a failure for key, starting over from one when
+	// the previous failure is older than window_start. Once max_failures are
+	// counted the key is locked for base_lockout_seconds, doubled for every
+	// further failure up to max_lockout_seconds. No row is returned while the
+	// key is locked.
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginAttempt, error)
+	// RecordSubscriptionFailure records a failed charge and when to retry it.
+	RecordSubscriptionFailure(ctx context.Context, arg RecordSubscriptionFailureParams) (Subscription, error)
+	// ReleaseIdempotencyKey drops a reservation whose request failed, so the
+	// client may retry it.
+	ReleaseIdempotencyKey(ctx context.Context, arg ReleaseIdempotencyKeyParams) error
+	// ReserveIdempotencyKey claims a key for a new request. An expired record
+	// for the same key is replaced; a live one is left alone and no row is
+	// returned.
+	ReserveIdempotencyKey(ctx context.Context, arg ReserveIdempotencyKeyParams) (IdempotencyKey, error)
+	// ResumeSubscription restarts a paused subscription. A charge that fell due
+	// while it was paused is made at now.
+	ResumeSubscription(ctx context.Context, arg ResumeSubscriptionParams) (Subscription, error)
+	RevokeAPIKey(ctx context.Context, id int64) (ApiKey, error)
+	RotateSession(ctx context.Context, id uuid.UUID) (Session, error)
+	// SetSubscriptionPendingCharge holds a subscription whose charge the
+	// provider is still processing until the charge's donation settles.
+	SetSubscriptionPendingCharge(ctx context.Context, arg SetSubscriptionPendingChargeParams) (Subscription, error)
+	SubtractFromGoalCollectedAmount(ctx context.Context, arg SubtractFromGoalCollectedAmountParams) (Goal, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+	UpdateDonationStatus(ctx context.Context, arg UpdateDonationStatusParams) (Donation, error)
 	UpdateGoal(ctx context.Context, arg UpdateGoalParams) (Goal, error)
+	// UpdatePaymentEventStatus records the outcome of an attempt to apply an
+	// event.
+	UpdatePaymentEventStatus(ctx context.Context, arg UpdatePaymentEventStatusParams) (PaymentEvent, error)
+	UpdateRefundStatus(ctx context.Context, arg UpdateRefundStatusParams) (Refund, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	UpdateVerifyEmail(ctx context.Context, arg UpdateVerifyEmailParams) (VerifyEmail, error)
+	UpsertLedgerAccount(ctx context.Context, arg UpsertLedgerAccountParams) (LedgerAccount, error)
+	UpsertUserTOTP(ctx context.Context, arg UpsertUserTOTPParams) (UserTotp, error)
+	UseMFARecoveryCode(ctx context.Context, id int64) (MfaRecoveryCode, error)
+	UsePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error)
+	UseUserTOTPStep(ctx context.Context, arg UseUserTOTPStepParams) (UserTotp, error)
 }
 
 var _ Querier = (*Queries)(nil)
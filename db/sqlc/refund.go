@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Refund reason codes.
+const (
+	RefundReasonDuplicate        = "duplicate"
+	RefundReasonFraudulent       = "fraudulent"
+	RefundReasonRequestedByDonor = "requested_by_donor"
+	RefundReasonGoalCanceled     = "goal_canceled"
+	RefundReasonOther            = "other"
+)
+
+// Refund statuses. A refund reduces its goal as soon as it is recorded; a
+// refund the provider later reports as failed is reversed.
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+var (
+	// ErrDonationNotRefundable is returned by RefundTx for a donation whose
+	// payment has not succeeded.
+	ErrDonationNotRefundable = errors.New("donation has not been paid")
+	// ErrRefundExceedsDonation is returned by RefundTx when the refund is
+	// larger than what is left of the donation after earlier refunds.
+	ErrRefundExceedsDonation = errors.New("refund exceeds the amount left to refund")
+)
+
+type RefundTxParams struct {
+	DonationID int64 `json:"donation_id"`
+	// Amount is the amount to refund; zero refunds whatever is left.
+	Amount    int64       `json:"amount"`
+	Reason    string      `json:"reason"`
+	Note      pgtype.Text `json:"note"`
+	CreatedBy pgtype.Int8 `json:"created_by"`
+	// ProviderRefundID and Status describe the refund at the donation's
+	// payment provider. A refund already recorded with the same
+	// ProviderRefundID is returned unchanged.
+	ProviderRefundID string `json:"provider_refund_id"`
+	Status           string `json:"status"`
+
+	// IdempotencyKey, when its ID is set, is completed with the JSON-encoded
+	// result in the same transaction as the refund.
+	IdempotencyKey CompleteIdempotencyKeyParams `json:"-"`
+}
+
+type RefundTxResult struct {
+	Refund   Refund   `json:"refund"`
+	Donation Donation `json:"donation"`
+}
+
+// RefundTx records a full or partial refund of a donation. Like
+// ConfirmDonationTx it locks the goal, so the goal's collected_amount is
+// reduced and the ledger entry reversed without racing other donations.
+// A donation refunded in full is marked as refunded. A refund without a
+// ProviderRefundID fails with ErrInsufficientFunds when the goal, after its
+// payouts, no longer holds the amount; one the provider has already paid is
+// recorded and the shortfall posted to the goal's receivable account.
+func (store *SQLStore) RefundTx(ctx context.Context, arg RefundTxParams) (RefundTxResult, error) {
+	var result RefundTxResult
+
+	err := store.execTx(ctx, "RefundTx", financialTxOptions, func(ctx context.Context, q *Queries) error {
+		var err error
+		result, err = q.refund(ctx, arg)
+		if err != nil {
+			return err
+		}
+		return q.completeIdempotencyKey(ctx, arg.IdempotencyKey, result)
+	})
+
+	return result, err
+}
+
+// refund implements RefundTx within a transaction.
+func (q *Queries) refund(ctx context.Context, arg RefundTxParams) (RefundTxResult, error) {
+	// locking the donation serializes refunds of it
+	donation, err := q.GetDonationForUpdate(ctx, arg.DonationID)
+	if err != nil {
+		return RefundTxResult{}, err
+	}
+
+	providerRefundID := pgtype.Text{String: arg.ProviderRefundID, Valid: arg.ProviderRefundID != ""}
+	if providerRefundID.Valid {
+		existing, err := q.GetRefundByProviderRefundID(ctx, GetRefundByProviderRefundIDParams{
+			Provider:         donation.Provider,
+			ProviderRefundID: providerRefundID,
+		})
+		if err == nil {
+			return RefundTxResult{Refund: existing, Donation: donation}, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return RefundTxResult{}, err
+		}
+	}
+
+	if donation.Status != DonationSucceeded && donation.Status != DonationRefunded {
+		return RefundTxResult{}, ErrDonationNotRefundable
+	}
+	refunded, err := q.GetDonationRefundedAmount(ctx, donation.ID)
+	if err != nil {
+		return RefundTxResult{}, err
+	}
+	remaining := donation.Amount - refunded
+	amount := arg.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return RefundTxResult{}, ErrRefundExceedsDonation
+	}
+
+	// lock the goal row as donations do
+	if _, err := q.GetGoalForUpdate(ctx, donation.GoalID); err != nil {
+		return RefundTxResult{}, err
+	}
+	funds, err := q.goalFunds(ctx, donation.GoalID, donation.Currency)
+	if err != nil {
+		return RefundTxResult{}, err
+	}
+	// a refund the provider has already paid cannot be rejected, so what
+	// the goal no longer holds is owed to its receivable account instead
+	covered := min(amount, max(funds, 0))
+	if covered < amount && !providerRefundID.Valid {
+		return RefundTxResult{}, fmt.Errorf("goal %d: %w", donation.GoalID, ErrInsufficientFunds)
+	}
+	if _, err := q.SubtractFromGoalCollectedAmount(ctx, SubtractFromGoalCollectedAmountParams{
+		ID:     donation.GoalID,
+		Amount: amount,
+	}); err != nil {
+		return RefundTxResult{}, err
+	}
+
+	refund, err := q.CreateRefund(ctx, CreateRefundParams{
+		DonationID:       donation.ID,
+		Amount:           amount,
+		Currency:         donation.Currency,
+		Reason:           arg.Reason,
+		Note:             arg.Note,
+		Status:           arg.Status,
+		Provider:         donation.Provider,
+		ProviderRefundID: providerRefundID,
+		CreatedBy:        arg.CreatedBy,
+	})
+	if err != nil {
+		return RefundTxResult{}, err
+	}
+
+	// the money goes back to the donor from the provider's clearing account
+	if _, err := q.postLedgerEntry(ctx, CreateLedgerEntryParams{
+		Kind:       LedgerEntryRefund,
+		Currency:   donation.Currency,
+		DonationID: pgtype.Int8{Int64: donation.ID, Valid: true},
+		RefundID:   pgtype.Int8{Int64: refund.ID, Valid: true},
+	},
+		ledgerPosting{Account: goalAccount(donation.GoalID, donation.Currency), Amount: covered},
+		ledgerPosting{Account: receivableAccount(donation.GoalID, donation.Currency), Amount: amount - covered},
+		ledgerPosting{Account: clearingAccount(donation.Provider, donation.Currency), Amount: -amount},
+	); err != nil {
+		return RefundTxResult{}, err
+	}
+
+	if amount == remaining {
+		donation, err = q.UpdateDonationStatus(ctx, UpdateDonationStatusParams{
+			ID:     donation.ID,
+			Status: DonationRefunded,
+		})
+		if err != nil {
+			return RefundTxResult{}, err
+		}
+	}
+
+	return RefundTxResult{Refund: refund, Donation: donation}, nil
+}
+
+// reverseRefund marks a refund the provider failed to pay as failed and
+// credits its amount back to the goal. The donation must be locked.
+func (q *Queries) reverseRefund(ctx context.Context, refund Refund, donation Donation) (Donation, error) {
+	if _, err := q.GetGoalForUpdate(ctx, donation.GoalID); err != nil {
+		return Donation{}, err
+	}
+	if _, err := q.AddToGoalCollectedAmount(ctx, AddToGoalCollectedAmountParams{
+		ID:     donation.GoalID,
+		Amount: refund.Amount,
+	}); err != nil {
+		return Donation{}, err
+	}
+
+	if _, err := q.UpdateRefundStatus(ctx, UpdateRefundStatusParams{ID: refund.ID, Status: RefundFailed}); err != nil {
+		return Donation{}, err
+	}
+
+	// the ledger is append-only, so the refund entry is offset by a new one;
+	// any part the refund posted to the goal's receivable account is
+	// credited to the goal instead, which nets to the same funds
+	if _, err := q.postLedgerEntry(ctx, CreateLedgerEntryParams{
+		Kind:       LedgerEntryRefund,
+		Currency:   refund.Currency,
+		DonationID: pgtype.Int8{Int64: donation.ID, Valid: true},
+		RefundID:   pgtype.Int8{Int64: refund.ID, Valid: true},
+	},
+		ledgerPosting{Account: clearingAccount(refund.Provider, refund.Currency), Amount: refund.Amount},
+		ledgerPosting{Account: goalAccount(donation.GoalID, refund.Currency), Amount: -refund.Amount},
+	); err != nil {
+		return Donation{}, err
+	}
+
+	if donation.Status == DonationRefunded {
+		return q.UpdateDonationStatus(ctx, UpdateDonationStatusParams{
+			ID:     donation.ID,
+			Status: DonationSucceeded,
+		})
+	}
+	return donation, nil
+}
+
+// applyProviderRefund records a refund reported by the provider, which may
+// have been issued through the API or directly at the provider, and
+// reverses it if it failed. The donation must be locked.
+func (q *Queries) applyProviderRefund(ctx context.Context, donation Donation, arg ApplyPaymentEventTxParams) (Donation, error) {
+	existing, err := q.GetRefundByProviderRefundID(ctx, GetRefundByProviderRefundIDParams{
+		Provider:         donation.Provider,
+		ProviderRefundID: pgtype.Text{String: arg.RefundID, Valid: true},
+	})
+	switch {
+	case err == nil:
+		// a refund only moves on from pending; an event arriving late or
+		// out of order must not take it back
+		if existing.Status != RefundPending || arg.RefundStatus == RefundPending {
+			return donation, nil
+		}
+		if arg.RefundStatus == RefundFailed {
+			return q.reverseRefund(ctx, existing, donation)
+		}
+		_, err = q.UpdateRefundStatus(ctx, UpdateRefundStatusParams{ID: existing.ID, Status: arg.RefundStatus})
+		return donation, err
+	case errors.Is(err, pgx.ErrNoRows):
+		if arg.RefundStatus == RefundFailed {
+			return donation, nil
+		}
+		if arg.Currency != donation.Currency {
+			return Donation{}, ErrPaymentMismatch
+		}
+		result, err := q.refund(ctx, RefundTxParams{
+			DonationID:       donation.ID,
+			Amount:           arg.Amount,
+			Reason:           arg.RefundReason,
+			ProviderRefundID: arg.RefundID,
+			Status:           arg.RefundStatus,
+		})
+		return result.Donation, err
+	default:
+		return Donation{}, err
+	}
+}
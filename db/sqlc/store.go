@@ -3,139 +3,673 @@ package db
 import (
 	"context"
 	"errors"
-	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type Store struct {
+// Store provides all queries and transactions.
+type Store interface {
+	Querier
+	DonationTx(ctx context.Context, arg DonationTxParams) (DonationTxResult, error)
+	ConfirmDonationTx(ctx context.Context, arg ConfirmDonationTxParams) (ConfirmDonationTxResult, error)
+	ApplyPaymentEventTx(ctx context.Context, arg ApplyPaymentEventTxParams) (ApplyPaymentEventTxResult, error)
+	PayoutTx(ctx context.Context, arg PayoutTxParams) (PayoutTxResult, error)
+	RefundTx(ctx context.Context, arg RefundTxParams) (RefundTxResult, error)
+	RenewSessionTx(ctx context.Context, arg RenewSessionTxParams) (RenewSessionTxResult, error)
+	LogoutTx(ctx context.Context, arg LogoutTxParams) (LogoutTxResult, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error)
+	UpdateUserRoleTx(ctx context.Context, arg UpdateUserRoleTxParams) (UpdateUserRoleTxResult, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error)
+	ConfirmTOTPTx(ctx context.Context, arg ConfirmTOTPTxParams) (ConfirmTOTPTxResult, error)
+	DisableTOTPTx(ctx context.Context, userID int64) error
+	OAuthLoginTx(ctx context.Context, arg OAuthLoginTxParams) (OAuthLoginTxResult, error)
+}
+
+// SQLStore implements Store on top of a connection pool, so it is safe
+// for concurrent use.
+type SQLStore struct {
 	*Queries
-	db *pgx.Conn
+	db *pgxpool.Pool
 }
 
-func NewStore(db *pgx.Conn) *Store {
-	return &Store{
+func NewStore(db *pgxpool.Pool) Store {
+	return &SQLStore{
 		db:      db,
 		Queries: New(db),
 	}
 }
 
+// Donation statuses. A donation is pending until the payment provider
+// confirms or rejects the payment; only succeeded donations count towards
+// their goal.
 const (
-	maxTxRetries     = 3
-	txRetryBaseDelay = 10 * time.Millisecond
+	DonationPending   = "pending"
+	DonationSucceeded = "succeeded"
+	DonationFailed    = "failed"
+	DonationRefunded  = "refunded"
 )
 
-func isRetryableTxError(err error) bool {
-	var pgErr *pgconn.PgError
-	if !errors.As(err, &pgErr) {
-		return false
+type DonationTxParams struct {
+	UserID      pgtype.Int8 `json:"user_id"`
+	GoalID      int64       `json:"goal_id"`
+	Amount      int64       `json:"amount"`
+	Currency    string      `json:"currency"`
+	IsAnonymous bool        `json:"is_anonymous"`
+	// Provider and PaymentIntentID identify the payment collecting the
+	// donation.
+	Provider        string `json:"provider"`
+	PaymentIntentID string `json:"payment_intent_id"`
+	// SubscriptionID is set for donations charged for a subscription.
+	SubscriptionID pgtype.Int8 `json:"subscription_id"`
+	// ClientSecret is handed back in the result so the donor can complete
+	// the payment with the provider. It is not stored.
+	ClientSecret string `json:"-"`
+	// ConfirmationTokenHash is stored on donations without a donor; the
+	// token lets whoever created the donation confirm it.
+	ConfirmationTokenHash pgtype.Text `json:"-"`
+
+	// IdempotencyKey, when its ID is set, is completed with the JSON-encoded
+	// result, less the client secret, in the same transaction as the
+	// donation.
+	IdempotencyKey CompleteIdempotencyKeyParams `json:"-"`
+}
+
+type DonationTxResult struct {
+	Donation     Donation `json:"donation"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+}
+
+// DonationTx records a pending donation. The goal is only credited by
+// ConfirmDonationTx once the provider confirms the payment.
+func (store *SQLStore) DonationTx(ctx context.Context, arg DonationTxParams) (DonationTxResult, error) {
+	var result DonationTxResult
+
+	err := store.execTx(ctx, "DonationTx", financialTxOptions, func(ctx context.Context, q *Queries) error {
+		paymentIntentID := pgtype.Text{String: arg.PaymentIntentID, Valid: arg.PaymentIntentID != ""}
+
+		// donations without a donor are recorded with a NULL user_id
+		var donation Donation
+		var err error
+		if arg.UserID.Valid {
+			donation, err = q.CreateDonation(ctx, CreateDonationParams{
+				UserID:          arg.UserID,
+				GoalID:          arg.GoalID,
+				Amount:          arg.Amount,
+				Currency:        arg.Currency,
+				IsAnonymous:     arg.IsAnonymous,
+				Provider:        arg.Provider,
+				PaymentIntentID: paymentIntentID,
+				SubscriptionID:  arg.SubscriptionID,
+			})
+		} else {
+			donation, err = q.CreateAnonymousDonation(ctx, CreateAnonymousDonationParams{
+				GoalID:                arg.GoalID,
+				Amount:                arg.Amount,
+				Currency:              arg.Currency,
+				Provider:              arg.Provider,
+				PaymentIntentID:       paymentIntentID,
+				ConfirmationTokenHash: arg.ConfirmationTokenHash,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		result = DonationTxResult{Donation: donation, ClientSecret: arg.ClientSecret}
+		// the client secret is only handed out once, never replayed
+		return q.completeIdempotencyKey(ctx, arg.IdempotencyKey, DonationTxResult{Donation: donation})
+	})
+
+	return result, err
+}
+
+// ErrPaymentMismatch is returned by ConfirmDonationTx when the confirmed
+// payment is not for the amount and currency of the donation.
+var ErrPaymentMismatch = errors.New("payment does not match the donation")
+
+type ConfirmDonationTxParams struct {
+	DonationID int64 `json:"donation_id"`
+	// Amount and Currency are what the provider collected.
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type ConfirmDonationTxResult struct {
+	Donation Donation `json:"donation"`
+	// Credited is false when the donation had already been confirmed, so
+	// the goal was left unchanged.
+	Credited bool `json:"credited"`
+}
+
+// ConfirmDonationTx marks a donation as succeeded once its payment has been
+// collected, credits its goal and records the money movement in the ledger.
+// Confirming a donation again is a no-op, so it is safe to call for
+// repeated provider notifications.
+func (store *SQLStore) ConfirmDonationTx(ctx context.Context, arg ConfirmDonationTxParams) (ConfirmDonationTxResult, error) {
+	var result ConfirmDonationTxResult
+
+	err := store.execTx(ctx, "ConfirmDonationTx", financialTxOptions, func(ctx context.Context, q *Queries) error {
+		var err error
+		result, err = q.confirmDonation(ctx, arg)
+		return err
+	})
+
+	return result, err
+}
+
+// confirmDonation implements ConfirmDonationTx within a transaction.
+func (q *Queries) confirmDonation(ctx context.Context, arg ConfirmDonationTxParams) (ConfirmDonationTxResult, error) {
+	donation, err := q.GetDonationForUpdate(ctx, arg.DonationID)
+	if err != nil {
+		return ConfirmDonationTxResult{}, err
+	}
+	if arg.Amount != donation.Amount || arg.Currency != donation.Currency {
+		return ConfirmDonationTxResult{}, ErrPaymentMismatch
+	}
+
+	// a failed donation can still succeed if the donor retries the
+	// payment with another method
+	if donation.Status != DonationPending && donation.Status != DonationFailed {
+		return ConfirmDonationTxResult{Donation: donation}, nil
 	}
 
-	switch pgErr.Code {
-	case "40P01", // deadlock_detected
-		"40001": // serialization_failure
-		return true
-	default:
-		return false
+	// lock the goal row for this donation. Under serializable isolation a
+	// concurrent donation to the same goal waits here and then fails with
+	// a serialization error, which execTx retries.
+	if _, err := q.GetGoalForUpdate(ctx, donation.GoalID); err != nil {
+		return ConfirmDonationTxResult{}, err
 	}
+
+	// increment collected_amount atomically for the locked goal
+	if _, err := q.AddToGoalCollectedAmount(ctx, AddToGoalCollectedAmountParams{
+		ID:     donation.GoalID,
+		Amount: donation.Amount,
+	}); err != nil {
+		return ConfirmDonationTxResult{}, err
+	}
+
+	donation, err = q.MarkDonationSucceeded(ctx, donation.ID)
+	if err != nil {
+		return ConfirmDonationTxResult{}, err
+	}
+
+	// the provider now holds the money on behalf of the goal
+	if _, err := q.postLedgerEntry(ctx, CreateLedgerEntryParams{
+		Kind:       LedgerEntryDonation,
+		Currency:   donation.Currency,
+		DonationID: pgtype.Int8{Int64: donation.ID, Valid: true},
+	},
+		ledgerPosting{Account: clearingAccount(donation.Provider, donation.Currency), Amount: donation.Amount},
+		ledgerPosting{Account: goalAccount(donation.GoalID, donation.Currency), Amount: -donation.Amount},
+	); err != nil {
+		return ConfirmDonationTxResult{}, err
+	}
+
+	return ConfirmDonationTxResult{Donation: donation, Credited: true}, nil
 }
 
-func (store *Store) execTx(ctx context.Context, fn func(*Queries) error) error {
-	var lastErr error
+// ErrSessionReused is returned by RenewSessionTx when the session being
+// rotated was already rotated or blocked by a concurrent request.
+var ErrSessionReused = errors.New("session has already been rotated")
 
-	for attempt := 0; attempt <= maxTxRetries; attempt++ {
-		if attempt > 0 {
-			// simple exponential backoff between retries
-			delay := txRetryBaseDelay * time.Duration(1<<uint(attempt-1))
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(delay):
+type RenewSessionTxParams struct {
+	SessionID  uuid.UUID           `json:"session_id"`
+	NewSession CreateSessionParams `json:"new_session"`
+}
+
+type RenewSessionTxResult struct {
+	Session Session `json:"session"`
+}
+
+// RenewSessionTx marks the current session as rotated and creates its
+// successor in the same family within a single transaction.
+func (store *SQLStore) RenewSessionTx(ctx context.Context, arg RenewSessionTxParams) (RenewSessionTxResult, error) {
+	var result RenewSessionTxResult
+
+	err := store.execTx(ctx, "RenewSessionTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		if _, err := q.RotateSession(ctx, arg.SessionID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrSessionReused
 			}
+			return err
 		}
 
-		tx, err := store.db.BeginTx(ctx, pgx.TxOptions{})
+		session, err := q.CreateSession(ctx, arg.NewSession)
 		if err != nil {
-			if isRetryableTxError(err) && attempt < maxTxRetries {
-				lastErr = err
-				continue
-			}
 			return err
 		}
 
-		q := New(tx)
-		err = fn(q)
+		result = RenewSessionTxResult{Session: session}
+		return nil
+	})
+
+	return result, err
+}
+
+type LogoutTxParams struct {
+	UserID int64 `json:"user_id"`
+	// FamilyID selects the login to end. When it is not valid every
+	// session belonging to UserID is blocked instead.
+	FamilyID pgtype.UUID `json:"family_id"`
+	// TokenID revokes a single access token. When it is not valid every
+	// token issued to the user up to RevokedAt is revoked.
+	TokenID   pgtype.UUID `json:"token_id"`
+	RevokedAt time.Time   `json:"revoked_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+type LogoutTxResult struct {
+	Revocation TokenRevocation `json:"revocation"`
+}
+
+// LogoutTx blocks the sessions being logged out and records the matching
+// token revocation within a single transaction.
+func (store *SQLStore) LogoutTx(ctx context.Context, arg LogoutTxParams) (LogoutTxResult, error) {
+	var result LogoutTxResult
+
+	err := store.execTx(ctx, "LogoutTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		var err error
+		if arg.FamilyID.Valid {
+			err = q.BlockSessionFamily(ctx, arg.FamilyID.Bytes)
+		} else {
+			err = q.BlockUserSessions(ctx, arg.UserID)
+		}
 		if err != nil {
-			if rbErr := tx.Rollback(ctx); rbErr != nil {
-				return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
-			}
+			return err
+		}
 
-			if isRetryableTxError(err) && attempt < maxTxRetries {
-				lastErr = err
-				continue
-			}
+		revocation, err := q.CreateTokenRevocation(ctx, CreateTokenRevocationParams{
+			UserID:    arg.UserID,
+			TokenID:   arg.TokenID,
+			RevokedAt: arg.RevokedAt,
+			ExpiresAt: arg.ExpiresAt,
+		})
+		if err != nil {
 			return err
 		}
 
-		if err = tx.Commit(ctx); err != nil {
-			if isRetryableTxError(err) && attempt < maxTxRetries {
-				lastErr = err
-				continue
-			}
+		result = LogoutTxResult{Revocation: revocation}
+		return nil
+	})
+
+	return result, err
+}
+
+type CreateUserTxParams struct {
+	CreateUserParams
+	SecretCode           string    `json:"secret_code"`
+	VerifyEmailExpiresAt time.Time `json:"verify_email_expires_at"`
+}
+
+type CreateUserTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// CreateUserTx creates a user together with the verification record for
+// their email address.
+func (store *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error) {
+	var result CreateUserTxResult
+
+	err := store.execTx(ctx, "CreateUserTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		user, err := q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
 			return err
 		}
 
-		// success
+		verifyEmail, err := q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			UserID:     user.ID,
+			Email:      user.Email,
+			SecretCode: arg.SecretCode,
+			ExpiredAt:  arg.VerifyEmailExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		result = CreateUserTxResult{User: user, VerifyEmail: verifyEmail}
 		return nil
-	}
+	})
 
-	if lastErr != nil {
-		return lastErr
-	}
+	return result, err
+}
 
-	return fmt.Errorf("transaction failed after %d retries", maxTxRetries)
+type VerifyEmailTxParams struct {
+	EmailID    int64  `json:"email_id"`
+	SecretCode string `json:"secret_code"`
 }
 
-type DonationTxParams struct {
-	UserID      pgtype.Int8 `json:"user_id"`
-	GoalID      int64       `json:"goal_id"`
-	Amount      int64       `json:"amount"`
-	Currency    string      `json:"currency"`
-	IsAnonymous bool        `json:"is_anonymous"`
+type VerifyEmailTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
 }
 
-type DonationTxResult struct {
-	Donation Donation `json:"donation"`
+// VerifyEmailTx consumes a verification code and marks the user's email as
+// verified. It returns pgx.ErrNoRows when the code is unknown, used or
+// expired, or when the user has since changed their email address.
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error) {
+	var result VerifyEmailTxResult
+
+	err := store.execTx(ctx, "VerifyEmailTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		verifyEmail, err := q.UpdateVerifyEmail(ctx, UpdateVerifyEmailParams{
+			ID:         arg.EmailID,
+			SecretCode: arg.SecretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		user, err := q.MarkUserEmailVerified(ctx, MarkUserEmailVerifiedParams{
+			ID:    verifyEmail.UserID,
+			Email: verifyEmail.Email,
+		})
+		if err != nil {
+			return err
+		}
+
+		result = VerifyEmailTxResult{User: user, VerifyEmail: verifyEmail}
+		return nil
+	})
+
+	return result, err
 }
 
-func (store *Store) DonationTx(ctx context.Context, arg DonationTxParams) (DonationTxResult, error) {
-	var result DonationTxResult
+type ResetPasswordTxParams struct {
+	TokenHash      string `json:"token_hash"`
+	HashedPassword string `json:"hashed_password"`
+	// RevokedAt and RevocationExpiresAt describe the user-wide token
+	// revocation recorded alongside the new password.
+	RevokedAt           time.Time `json:"revoked_at"`
+	RevocationExpiresAt time.Time `json:"revocation_expires_at"`
+}
+
+type ResetPasswordTxResult struct {
+	User       User            `json:"user"`
+	Revocation TokenRevocation `json:"revocation"`
+}
+
+// ResetPasswordTx consumes a password reset token, stores the new password
+// hash and revokes every existing session of the user. It returns
+// pgx.ErrNoRows when the token is unknown, used or expired.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error) {
+	var result ResetPasswordTxResult
+
+	err := store.execTx(ctx, "ResetPasswordTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		reset, err := q.UsePasswordReset(ctx, arg.TokenHash)
+		if err != nil {
+			return err
+		}
+
+		user, err := q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			ID:       reset.UserID,
+			Password: pgtype.Text{String: arg.HashedPassword, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		// any other outstanding reset link is now stale
+		if err := q.InvalidateUserPasswordResets(ctx, user.ID); err != nil {
+			return err
+		}
+
+		if err := q.BlockUserSessions(ctx, user.ID); err != nil {
+			return err
+		}
+
+		revocation, err := q.CreateTokenRevocation(ctx, CreateTokenRevocationParams{
+			UserID:    user.ID,
+			RevokedAt: arg.RevokedAt,
+			ExpiresAt: arg.RevocationExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		result = ResetPasswordTxResult{User: user, Revocation: revocation}
+		return nil
+	})
+
+	return result, err
+}
+
+type UpdateUserRoleTxParams struct {
+	UpdateUserRoleParams
+	// RevokedAt and RevocationExpiresAt describe the user-wide token
+	// revocation recorded alongside the new role.
+	RevokedAt           time.Time `json:"revoked_at"`
+	RevocationExpiresAt time.Time `json:"revocation_expires_at"`
+}
 
-	err := store.execTx(ctx, func(q *Queries) error {
-		// lock the goal row for this donation
-		if _, err := q.GetGoalForUpdate(ctx, arg.GoalID); err != nil {
+type UpdateUserRoleTxResult struct {
+	User       User            `json:"user"`
+	Revocation TokenRevocation `json:"revocation"`
+}
+
+// UpdateUserRoleTx changes a user's role and, as LogoutTx does for a logout
+// from every device, blocks all of the user's sessions and revokes the
+// tokens issued so far, so none carrying the old role stays usable. It
+// returns pgx.ErrNoRows when the user is unknown.
+func (store *SQLStore) UpdateUserRoleTx(ctx context.Context, arg UpdateUserRoleTxParams) (UpdateUserRoleTxResult, error) {
+	var result UpdateUserRoleTxResult
+
+	err := store.execTx(ctx, "UpdateUserRoleTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		user, err := q.UpdateUserRole(ctx, arg.UpdateUserRoleParams)
+		if err != nil {
 			return err
 		}
 
-		// increment collected_amount atomically for the locked goal
-		if _, err := q.AddToGoalCollectedAmount(ctx, AddToGoalCollectedAmountParams{
-			ID:     arg.GoalID,
-			Amount: arg.Amount,
-		}); err != nil {
+		if err := q.BlockUserSessions(ctx, user.ID); err != nil {
 			return err
 		}
 
-		donation, err := q.CreateDonation(ctx, CreateDonationParams(arg))
+		revocation, err := q.CreateTokenRevocation(ctx, CreateTokenRevocationParams{
+			UserID:    user.ID,
+			RevokedAt: arg.RevokedAt,
+			ExpiresAt: arg.RevocationExpiresAt,
+		})
 		if err != nil {
 			return err
 		}
 
-		result = DonationTxResult{Donation: donation}
+		result = UpdateUserRoleTxResult{User: user, Revocation: revocation}
 		return nil
 	})
 
 	return result, err
 }
+
+type UpdateUserTxParams struct {
+	UpdateUserParams
+	// SecretCode and VerifyEmailExpiresAt are used to create a new
+	// verification record when the email address changes.
+	SecretCode           string    `json:"secret_code"`
+	VerifyEmailExpiresAt time.Time `json:"verify_email_expires_at"`
+}
+
+type UpdateUserTxResult struct {
+	User User `json:"user"`
+	// VerifyEmail is only set when the email address changed.
+	VerifyEmail *VerifyEmail `json:"verify_email,omitempty"`
+}
+
+// UpdateUserTx updates a user's profile. Changing the email address marks
+// it as unverified and creates a verification record for the new address.
+func (store *SQLStore) UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error) {
+	var result UpdateUserTxResult
+
+	err := store.execTx(ctx, "UpdateUserTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		params := arg.UpdateUserParams
+		if params.Email.Valid {
+			params.IsEmailVerified = pgtype.Bool{Bool: false, Valid: true}
+		}
+
+		user, err := q.UpdateUser(ctx, params)
+		if err != nil {
+			return err
+		}
+		result = UpdateUserTxResult{User: user}
+
+		if !params.Email.Valid {
+			return nil
+		}
+
+		verifyEmail, err := q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			UserID:     user.ID,
+			Email:      user.Email,
+			SecretCode: arg.SecretCode,
+			ExpiredAt:  arg.VerifyEmailExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		result.VerifyEmail = &verifyEmail
+
+		return nil
+	})
+
+	return result, err
+}
+
+type ConfirmTOTPTxParams struct {
+	UserID int64 `json:"user_id"`
+	// RecoveryCodeHashes replace any recovery codes the user had before.
+	RecoveryCodeHashes []string `json:"-"`
+}
+
+type ConfirmTOTPTxResult struct {
+	TOTP          UserTotp          `json:"totp"`
+	RecoveryCodes []MfaRecoveryCode `json:"recovery_codes"`
+}
+
+// ConfirmTOTPTx completes a TOTP enrollment and stores a fresh set of
+// recovery codes. It returns pgx.ErrNoRows when there is no enrollment
+// waiting for confirmation.
+func (store *SQLStore) ConfirmTOTPTx(ctx context.Context, arg ConfirmTOTPTxParams) (ConfirmTOTPTxResult, error) {
+	var result ConfirmTOTPTxResult
+
+	err := store.execTx(ctx, "ConfirmTOTPTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		totp, err := q.ConfirmUserTOTP(ctx, arg.UserID)
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteMFARecoveryCodes(ctx, arg.UserID); err != nil {
+			return err
+		}
+
+		codes := make([]MfaRecoveryCode, 0, len(arg.RecoveryCodeHashes))
+		for _, hash := range arg.RecoveryCodeHashes {
+			code, err := q.CreateMFARecoveryCode(ctx, CreateMFARecoveryCodeParams{
+				UserID:   arg.UserID,
+				CodeHash: hash,
+			})
+			if err != nil {
+				return err
+			}
+			codes = append(codes, code)
+		}
+
+		result = ConfirmTOTPTxResult{TOTP: totp, RecoveryCodes: codes}
+		return nil
+	})
+
+	return result, err
+}
+
+// DisableTOTPTx removes the TOTP secret and recovery codes of a user.
+func (store *SQLStore) DisableTOTPTx(ctx context.Context, userID int64) error {
+	return store.execTx(ctx, "DisableTOTPTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		if err := q.DeleteMFARecoveryCodes(ctx, userID); err != nil {
+			return err
+		}
+		return q.DeleteUserTOTP(ctx, userID)
+	})
+}
+
+// ErrUnverifiedAccount is returned by OAuthLoginTx when the provider's email
+// belongs to a local account whose email was never verified. Linking it
+// would hand the account to whoever created it.
+var ErrUnverifiedAccount = errors.New("account email is not verified")
+
+type OAuthLoginTxParams struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	// Email must have been verified by the provider.
+	Email string      `json:"email"`
+	Name  pgtype.Text `json:"name"`
+}
+
+type OAuthLoginTxResult struct {
+	User     User         `json:"user"`
+	Identity UserIdentity `json:"identity"`
+	// Created is set when a new user was registered.
+	Created bool `json:"created"`
+}
+
+// OAuthLoginTx resolves a provider identity to a user. A known identity
+// returns its user; otherwise the identity is linked to the user with the
+// same email, or to a new passwordless user with a verified email.
+func (store *SQLStore) OAuthLoginTx(ctx context.Context, arg OAuthLoginTxParams) (OAuthLoginTxResult, error) {
+	var result OAuthLoginTxResult
+
+	err := store.execTx(ctx, "OAuthLoginTx", defaultTxOptions, func(ctx context.Context, q *Queries) error {
+		result = OAuthLoginTxResult{}
+
+		identity, err := q.GetUserIdentity(ctx, GetUserIdentityParams{
+			Provider: arg.Provider,
+			Subject:  arg.Subject,
+		})
+		if err == nil {
+			result.Identity = identity
+			result.User, err = q.GetUser(ctx, identity.UserID)
+			return err
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		user, err := q.GetUserByEmail(ctx, arg.Email)
+		switch {
+		case err == nil:
+			if !user.IsEmailVerified {
+				return ErrUnverifiedAccount
+			}
+		case errors.Is(err, pgx.ErrNoRows):
+			user, err = q.CreateUser(ctx, CreateUserParams{
+				Email: arg.Email,
+				Name:  arg.Name,
+			})
+			if err != nil {
+				return err
+			}
+			user, err = q.MarkUserEmailVerified(ctx, MarkUserEmailVerifiedParams{
+				ID:    user.ID,
+				Email: user.Email,
+			})
+			if err != nil {
+				return err
+			}
+			result.Created = true
+		default:
+			return err
+		}
+
+		result.User = user
+		result.Identity, err = q.CreateUserIdentity(ctx, CreateUserIdentityParams{
+			UserID:   user.ID,
+			Provider: arg.Provider,
+			Subject:  arg.Subject,
+			Email:    arg.Email,
+		})
+		return err
+	})
+
+	return result, err
+}
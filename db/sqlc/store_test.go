@@ -2,14 +2,23 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func newTestStore(t *testing.T) *Store {
+func newTestStore(t *testing.T) Store {
 	t.Helper()
 
 	dsn := os.Getenv("TEST_DATABASE_URL")
@@ -21,25 +30,44 @@ func newTestStore(t *testing.T) *Store {
 	}
 
 	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, dsn)
+	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
 		t.Fatalf("failed to connect to test database: %v", err)
 	}
+	t.Cleanup(pool.Close)
 
-	// Clean tables that are relevant for these tests
-	_, err = conn.Exec(ctx, "DELETE FROM donations")
+	// Clean tables that are relevant for these tests. The ledger is
+	// append-only, so it can only be emptied with TRUNCATE.
+	_, err = pool.Exec(ctx, "TRUNCATE payment_events, idempotency_keys, ledger_postings, ledger_entries, refunds, payouts, ledger_accounts, donations, subscriptions, goals CASCADE")
 	if err != nil {
-		conn.Close(ctx)
-		t.Fatalf("failed to clean donations table: %v", err)
+		t.Fatalf("failed to clean tables: %v", err)
 	}
-	_, err = conn.Exec(ctx, "DELETE FROM goals")
+
+	store := NewStore(pool)
+	return store
+}
+
+// donate records an anonymous donation and confirms its payment, the way
+// the API does once the payment provider has collected it.
+func donate(ctx context.Context, store Store, goalID int64, amount int64) (Donation, error) {
+	pending, err := store.DonationTx(ctx, DonationTxParams{
+		GoalID:          goalID,
+		Amount:          amount,
+		Currency:        "USD",
+		IsAnonymous:     true,
+		Provider:        "fake",
+		PaymentIntentID: uuid.NewString(),
+	})
 	if err != nil {
-		conn.Close(ctx)
-		t.Fatalf("failed to clean goals table: %v", err)
+		return Donation{}, err
 	}
 
-	store := NewStore(conn)
-	return store
+	result, err := store.ConfirmDonationTx(ctx, ConfirmDonationTxParams{
+		DonationID: pending.Donation.ID,
+		Amount:     amount,
+		Currency:   "USD",
+	})
+	return result.Donation, err
 }
 
 func TestDonationTxUpdatesCollectedAmount(t *testing.T) {
@@ -52,15 +80,43 @@ func TestDonationTxUpdatesCollectedAmount(t *testing.T) {
 	}
 
 	amount := int64(100)
-	_, err = store.DonationTx(ctx, DonationTxParams{
-		GoalID:      goal.ID,
-		Amount:      amount,
-		Currency:    "USD",
-		IsAnonymous: true,
+	pending, err := store.DonationTx(ctx, DonationTxParams{
+		GoalID:          goal.ID,
+		Amount:          amount,
+		Currency:        "USD",
+		IsAnonymous:     true,
+		Provider:        "fake",
+		PaymentIntentID: "pi_1",
 	})
 	if err != nil {
 		t.Fatalf("DonationTx failed: %v", err)
 	}
+	if pending.Donation.Status != DonationPending {
+		t.Fatalf("unexpected status %q", pending.Donation.Status)
+	}
+
+	// nothing is collected until the payment is confirmed
+	unchanged, err := store.GetGoal(ctx, goal.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch goal: %v", err)
+	}
+	if unchanged.CollectedAmount != 0 {
+		t.Fatalf("pending donation was credited: collected_amount %d", unchanged.CollectedAmount)
+	}
+
+	confirm := ConfirmDonationTxParams{DonationID: pending.Donation.ID, Amount: amount, Currency: "USD"}
+	if _, err := store.ConfirmDonationTx(ctx, ConfirmDonationTxParams{DonationID: pending.Donation.ID, Amount: amount + 1, Currency: "USD"}); !errors.Is(err, ErrPaymentMismatch) {
+		t.Fatalf("expected ErrPaymentMismatch, got %v", err)
+	}
+	for i, wantCredited := range []bool{true, false} {
+		result, err := store.ConfirmDonationTx(ctx, confirm)
+		if err != nil {
+			t.Fatalf("ConfirmDonationTx failed: %v", err)
+		}
+		if result.Credited != wantCredited || result.Donation.Status != DonationSucceeded {
+			t.Fatalf("confirmation %d: unexpected result %+v", i+1, result)
+		}
+	}
 
 	updated, err := store.GetGoal(ctx, goal.ID)
 	if err != nil {
@@ -72,6 +128,602 @@ func TestDonationTxUpdatesCollectedAmount(t *testing.T) {
 	}
 }
 
+func TestDonationTxCompletesIdempotencyKey(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+
+	reserve := ReserveIdempotencyKeyParams{
+		Scope:       "user:1",
+		Key:         "retry-1",
+		RequestHash: "hash",
+		LockID:      pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}
+	key, err := store.ReserveIdempotencyKey(ctx, reserve)
+	if err != nil {
+		t.Fatalf("ReserveIdempotencyKey failed: %v", err)
+	}
+
+	result, err := store.DonationTx(ctx, DonationTxParams{
+		GoalID:          goal.ID,
+		Amount:          100,
+		Currency:        "USD",
+		IsAnonymous:     true,
+		Provider:        "fake",
+		PaymentIntentID: "pi_1",
+		ClientSecret:    "pi_1_secret",
+		IdempotencyKey: CompleteIdempotencyKeyParams{
+			ID:             key.ID,
+			LockID:         key.LockID,
+			ResponseStatus: pgtype.Int4{Int32: 200, Valid: true},
+			ExpiresAt:      time.Now().Add(time.Hour),
+		},
+	})
+	if err != nil {
+		t.Fatalf("DonationTx failed: %v", err)
+	}
+
+	stored, err := store.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{Scope: reserve.Scope, Key: reserve.Key})
+	if err != nil {
+		t.Fatalf("GetIdempotencyKey failed: %v", err)
+	}
+	if stored.LockID.Valid || stored.ResponseStatus.Int32 != 200 {
+		t.Fatalf("idempotency key was not completed: %+v", stored)
+	}
+	var replay DonationTxResult
+	if err := json.Unmarshal(stored.ResponseBody, &replay); err != nil {
+		t.Fatalf("failed to decode stored response: %v", err)
+	}
+	if replay.Donation.ID != result.Donation.ID {
+		t.Fatalf("stored response is for donation %d, want %d", replay.Donation.ID, result.Donation.ID)
+	}
+	if result.ClientSecret != "pi_1_secret" || replay.ClientSecret != "" {
+		t.Fatalf("client secret must be returned but not stored, got %q and %q", result.ClientSecret, replay.ClientSecret)
+	}
+
+	// the key stays taken until it expires
+	reserve.LockID = pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	if _, err := store.ReserveIdempotencyKey(ctx, reserve); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected the key to be taken, got %v", err)
+	}
+}
+
+func TestApplyPaymentEventTxOutOfOrder(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+
+	storeEvent := func(eventID string) PaymentEvent {
+		t.Helper()
+		event, err := store.CreatePaymentEvent(ctx, CreatePaymentEventParams{
+			Provider:        "fake",
+			EventID:         eventID,
+			EventType:       "test",
+			PaymentIntentID: pgtype.Text{String: "pi_1", Valid: true},
+			Payload:         []byte("{}"),
+		})
+		if err != nil {
+			t.Fatalf("failed to store event %s: %v", eventID, err)
+		}
+		return event
+	}
+	apply := func(arg ApplyPaymentEventTxParams, wantStatus string) {
+		t.Helper()
+		result, err := store.ApplyPaymentEventTx(ctx, arg)
+		if err != nil {
+			t.Fatalf("ApplyPaymentEventTx failed: %v", err)
+		}
+		if result.Event.Status != wantStatus {
+			t.Fatalf("unexpected event status: got %q, want %q", result.Event.Status, wantStatus)
+		}
+	}
+
+	// the payment succeeds before the donation is recorded
+	succeeded := storeEvent("evt_succeeded")
+	succeededParams := ApplyPaymentEventTxParams{EventID: succeeded.ID, DonationStatus: DonationSucceeded, Amount: 100, Currency: "USD"}
+	apply(succeededParams, PaymentEventDeferred)
+
+	if _, err := store.DonationTx(ctx, DonationTxParams{
+		GoalID:          goal.ID,
+		Amount:          100,
+		Currency:        "USD",
+		IsAnonymous:     true,
+		Provider:        "fake",
+		PaymentIntentID: "pi_1",
+	}); err != nil {
+		t.Fatalf("DonationTx failed: %v", err)
+	}
+
+	// a refund overtakes the redelivered success
+	refund := storeEvent("evt_refund")
+	apply(ApplyPaymentEventTxParams{EventID: refund.ID, DonationStatus: DonationRefunded, Amount: 100, Currency: "USD"}, PaymentEventDeferred)
+
+	apply(succeededParams, PaymentEventProcessed)
+	apply(succeededParams, PaymentEventProcessed)
+
+	// a failure reported after the payment succeeded is stale
+	failed := storeEvent("evt_failed")
+	apply(ApplyPaymentEventTxParams{EventID: failed.ID, DonationStatus: DonationFailed, FailureReason: "declined"}, PaymentEventProcessed)
+
+	deferred, err := store.ListDeferredPaymentEvents(ctx, ListDeferredPaymentEventsParams{
+		Provider:        "fake",
+		PaymentIntentID: pgtype.Text{String: "pi_1", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("failed to list deferred events: %v", err)
+	}
+	if len(deferred) != 1 || deferred[0].ID != refund.ID {
+		t.Fatalf("unexpected deferred events: %+v", deferred)
+	}
+	apply(ApplyPaymentEventTxParams{EventID: refund.ID, DonationStatus: DonationRefunded, Amount: 100, Currency: "USD"}, PaymentEventProcessed)
+
+	updated, err := store.GetGoal(ctx, goal.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch goal: %v", err)
+	}
+	if updated.CollectedAmount != 100 {
+		t.Fatalf("unexpected collected_amount: got %d, want 100", updated.CollectedAmount)
+	}
+
+	if _, err := store.CreatePaymentEvent(ctx, CreatePaymentEventParams{
+		Provider:  "fake",
+		EventID:   "evt_succeeded",
+		EventType: "test",
+		Payload:   []byte("{}"),
+	}); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected a redelivered event to be skipped, got %v", err)
+	}
+}
+
+func TestRefundTx(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+	donation, err := donate(ctx, store, goal.ID, 500)
+	if err != nil {
+		t.Fatalf("donation failed: %v", err)
+	}
+
+	requireCollected := func(want int64) {
+		t.Helper()
+		updated, err := store.GetGoal(ctx, goal.ID)
+		if err != nil {
+			t.Fatalf("failed to fetch goal: %v", err)
+		}
+		if updated.CollectedAmount != want {
+			t.Fatalf("unexpected collected_amount: got %d, want %d", updated.CollectedAmount, want)
+		}
+	}
+
+	partial, err := store.RefundTx(ctx, RefundTxParams{
+		DonationID:       donation.ID,
+		Amount:           200,
+		Reason:           RefundReasonRequestedByDonor,
+		ProviderRefundID: "re_1",
+		Status:           RefundPending,
+	})
+	if err != nil {
+		t.Fatalf("RefundTx failed: %v", err)
+	}
+	if partial.Donation.Status != DonationSucceeded || partial.Refund.Amount != 200 {
+		t.Fatalf("unexpected partial refund: %+v", partial)
+	}
+	requireCollected(300)
+
+	// recording the same provider refund again changes nothing
+	again, err := store.RefundTx(ctx, RefundTxParams{
+		DonationID:       donation.ID,
+		Amount:           200,
+		Reason:           RefundReasonRequestedByDonor,
+		ProviderRefundID: "re_1",
+		Status:           RefundPending,
+	})
+	if err != nil || again.Refund.ID != partial.Refund.ID {
+		t.Fatalf("expected the existing refund, got %+v, %v", again, err)
+	}
+	requireCollected(300)
+
+	if _, err := store.RefundTx(ctx, RefundTxParams{
+		DonationID: donation.ID,
+		Amount:     400,
+		Reason:     RefundReasonOther,
+		Status:     RefundSucceeded,
+	}); !errors.Is(err, ErrRefundExceedsDonation) {
+		t.Fatalf("expected ErrRefundExceedsDonation, got %v", err)
+	}
+
+	rest, err := store.RefundTx(ctx, RefundTxParams{
+		DonationID: donation.ID,
+		Reason:     RefundReasonGoalCanceled,
+		Status:     RefundSucceeded,
+	})
+	if err != nil {
+		t.Fatalf("RefundTx failed: %v", err)
+	}
+	if rest.Refund.Amount != 300 || rest.Donation.Status != DonationRefunded {
+		t.Fatalf("unexpected refund of the rest: %+v", rest)
+	}
+	requireCollected(0)
+
+	// the provider reports that the first refund failed
+	event, err := store.CreatePaymentEvent(ctx, CreatePaymentEventParams{
+		Provider:        "fake",
+		EventID:         "evt_refund_failed",
+		EventType:       "refund.updated",
+		PaymentIntentID: donation.PaymentIntentID,
+		Payload:         []byte("{}"),
+	})
+	if err != nil {
+		t.Fatalf("failed to store event: %v", err)
+	}
+	applied, err := store.ApplyPaymentEventTx(ctx, ApplyPaymentEventTxParams{
+		EventID:        event.ID,
+		DonationStatus: DonationRefunded,
+		Amount:         200,
+		Currency:       "USD",
+		RefundID:       "re_1",
+		RefundStatus:   RefundFailed,
+	})
+	if err != nil {
+		t.Fatalf("ApplyPaymentEventTx failed: %v", err)
+	}
+	if applied.Donation == nil || applied.Donation.Status != DonationSucceeded {
+		t.Fatalf("expected the donation to be succeeded again, got %+v", applied.Donation)
+	}
+	requireCollected(200)
+
+	// a stale pending event does not take a succeeded refund back
+	if _, err := store.RefundTx(ctx, RefundTxParams{
+		DonationID:       donation.ID,
+		Amount:           100,
+		Reason:           RefundReasonRequestedByDonor,
+		ProviderRefundID: "re_2",
+		Status:           RefundSucceeded,
+	}); err != nil {
+		t.Fatalf("RefundTx failed: %v", err)
+	}
+	stale, err := store.CreatePaymentEvent(ctx, CreatePaymentEventParams{
+		Provider:        "fake",
+		EventID:         "evt_refund_stale",
+		EventType:       "refund.updated",
+		PaymentIntentID: donation.PaymentIntentID,
+		Payload:         []byte("{}"),
+	})
+	if err != nil {
+		t.Fatalf("failed to store event: %v", err)
+	}
+	if _, err := store.ApplyPaymentEventTx(ctx, ApplyPaymentEventTxParams{
+		EventID:        stale.ID,
+		DonationStatus: DonationRefunded,
+		Amount:         100,
+		Currency:       "USD",
+		RefundID:       "re_2",
+		RefundStatus:   RefundPending,
+	}); err != nil {
+		t.Fatalf("ApplyPaymentEventTx failed: %v", err)
+	}
+	refund, err := store.GetRefundByProviderRefundID(ctx, GetRefundByProviderRefundIDParams{
+		Provider:         "fake",
+		ProviderRefundID: pgtype.Text{String: "re_2", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("GetRefundByProviderRefundID failed: %v", err)
+	}
+	if refund.Status != RefundSucceeded {
+		t.Fatalf("expected the refund to stay succeeded, got %s", refund.Status)
+	}
+	requireCollected(100)
+
+	rows, err := store.ReconcileGoals(ctx, ReconcileGoalsParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("ReconcileGoals failed: %v", err)
+	}
+	for _, row := range rows {
+		if row.GoalID == goal.ID && (row.LedgerCollected != 100 || row.CollectedAmount != 100) {
+			t.Fatalf("ledger does not match the goal: %+v", row)
+		}
+	}
+}
+
+// TestClaimDueSubscriptionsConcurrent checks that schedulers claiming at
+// the same time never get the same subscription.
+func TestClaimDueSubscriptionsConcurrent(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+	user, err := store.CreateUser(ctx, CreateUserParams{Email: uuid.NewString() + "@example.com"})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	now := time.Now()
+	const subscriptions = 20
+	for i := 0; i < subscriptions; i++ {
+		if _, err := store.CreateSubscription(ctx, CreateSubscriptionParams{
+			UserID:        user.ID,
+			GoalID:        goal.ID,
+			Amount:        500,
+			Currency:      "USD",
+			Interval:      SubscriptionMonthly,
+			PaymentMethod: "pm_card_visa",
+			NextChargeAt:  now.Add(-time.Duration(i) * time.Minute),
+		}); err != nil {
+			t.Fatalf("failed to create subscription: %v", err)
+		}
+	}
+	// not due yet
+	if _, err := store.CreateSubscription(ctx, CreateSubscriptionParams{
+		UserID:        user.ID,
+		GoalID:        goal.ID,
+		Amount:        500,
+		Currency:      "USD",
+		Interval:      SubscriptionWeekly,
+		PaymentMethod: "pm_card_visa",
+		NextChargeAt:  now.Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	const workers = 4
+	var (
+		mu      sync.Mutex
+		claimed = map[int64]int{}
+		wg      sync.WaitGroup
+	)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				batch, err := store.ClaimDueSubscriptions(ctx, ClaimDueSubscriptionsParams{
+					LockedUntil: pgtype.Timestamptz{Time: now.Add(10 * time.Minute), Valid: true},
+					Now:         now,
+					BatchSize:   3,
+				})
+				if err != nil {
+					t.Errorf("failed to claim subscriptions: %v", err)
+					return
+				}
+				if len(batch) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, subscription := range batch {
+					claimed[subscription.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != subscriptions {
+		t.Fatalf("unexpected number of claimed subscriptions: got %d, want %d", len(claimed), subscriptions)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Fatalf("subscription %d claimed %d times", id, n)
+		}
+	}
+
+	// a subscription whose lease expired without being charged is claimed again
+	batch, err := store.ClaimDueSubscriptions(ctx, ClaimDueSubscriptionsParams{
+		LockedUntil: pgtype.Timestamptz{Time: now.Add(30 * time.Minute), Valid: true},
+		Now:         now.Add(20 * time.Minute),
+		BatchSize:   subscriptions,
+	})
+	if err != nil {
+		t.Fatalf("failed to claim subscriptions: %v", err)
+	}
+	if len(batch) != subscriptions {
+		t.Fatalf("unexpected number of reclaimed subscriptions: got %d, want %d", len(batch), subscriptions)
+	}
+}
+
+func TestLedgerReconcilesAfterDonationAndPayout(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+
+	for _, amount := range []int64{100, 250} {
+		if _, err := donate(ctx, store, goal.ID, amount); err != nil {
+			t.Fatalf("donation failed: %v", err)
+		}
+	}
+	// a donation that is never paid does not reach the ledger
+	if _, err := store.DonationTx(ctx, DonationTxParams{
+		GoalID:          goal.ID,
+		Amount:          500,
+		Currency:        "USD",
+		IsAnonymous:     true,
+		Provider:        "fake",
+		PaymentIntentID: "pi_unpaid",
+	}); err != nil {
+		t.Fatalf("DonationTx failed: %v", err)
+	}
+
+	_, err = store.PayoutTx(ctx, PayoutTxParams{
+		GoalID:    goal.ID,
+		Amount:    300,
+		Currency:  "USD",
+		Provider:  "fake",
+		Reference: "wire-1",
+	})
+	if err != nil {
+		t.Fatalf("PayoutTx failed: %v", err)
+	}
+
+	_, err = store.PayoutTx(ctx, PayoutTxParams{
+		GoalID:    goal.ID,
+		Amount:    100,
+		Currency:  "USD",
+		Provider:  "fake",
+		Reference: "wire-2",
+	})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+
+	rows, err := store.ReconcileGoals(ctx, ReconcileGoalsParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("ReconcileGoals failed: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("unexpected reconciliation rows: %+v", rows)
+	}
+	row := rows[0]
+	if row.CollectedAmount != 350 || row.LedgerCollected != 350 {
+		t.Fatalf("goal does not reconcile: %+v", row)
+	}
+	if row.LedgerPaidOut != 300 || row.LedgerBalance != 50 {
+		t.Fatalf("unexpected payout balance: %+v", row)
+	}
+}
+
+func TestPayoutTxChecksClearingAccount(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+	if _, err := donate(ctx, store, goal.ID, 300); err != nil {
+		t.Fatalf("donation failed: %v", err)
+	}
+
+	// the goal holds the funds but the provider named never collected them
+	_, err = store.PayoutTx(ctx, PayoutTxParams{
+		GoalID:    goal.ID,
+		Amount:    300,
+		Currency:  "USD",
+		Provider:  "unused-" + uuid.NewString(),
+		Reference: "wire-1",
+	})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+}
+
+func TestRefundTxAfterPayout(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+	donation, err := donate(ctx, store, goal.ID, 500)
+	if err != nil {
+		t.Fatalf("donation failed: %v", err)
+	}
+	if _, err := store.PayoutTx(ctx, PayoutTxParams{
+		GoalID:    goal.ID,
+		Amount:    400,
+		Currency:  "USD",
+		Provider:  "fake",
+		Reference: "wire-1",
+	}); err != nil {
+		t.Fatalf("PayoutTx failed: %v", err)
+	}
+
+	requireFunds := func(want int64) {
+		t.Helper()
+		funds, err := store.GetGoalLedgerFunds(ctx, GetGoalLedgerFundsParams{
+			GoalID:   pgtype.Int8{Int64: goal.ID, Valid: true},
+			Currency: "USD",
+		})
+		if err != nil {
+			t.Fatalf("GetGoalLedgerFunds failed: %v", err)
+		}
+		if funds != want {
+			t.Fatalf("unexpected goal funds: got %d, want %d", funds, want)
+		}
+	}
+	requireFunds(100)
+
+	// a refund we would issue is rejected
+	if _, err := store.RefundTx(ctx, RefundTxParams{
+		DonationID: donation.ID,
+		Amount:     200,
+		Reason:     RefundReasonOther,
+		Status:     RefundSucceeded,
+	}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+	requireFunds(100)
+
+	// a refund the provider already paid leaves the goal owing the rest
+	if _, err := store.RefundTx(ctx, RefundTxParams{
+		DonationID:       donation.ID,
+		Amount:           300,
+		Reason:           RefundReasonRequestedByDonor,
+		ProviderRefundID: "re_" + uuid.NewString(),
+		Status:           RefundSucceeded,
+	}); err != nil {
+		t.Fatalf("RefundTx failed: %v", err)
+	}
+	requireFunds(-200)
+
+	account, err := store.GetLedgerAccountByCode(ctx, goalAccount(goal.ID, "USD").Code)
+	if err != nil {
+		t.Fatalf("failed to fetch goal account: %v", err)
+	}
+	balance, err := store.GetLedgerAccountBalance(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("GetLedgerAccountBalance failed: %v", err)
+	}
+	if balance != 0 {
+		t.Fatalf("goal account balance must not go positive, got %d", balance)
+	}
+
+	// what the goal owes is netted against later donations
+	if _, err := donate(ctx, store, goal.ID, 250); err != nil {
+		t.Fatalf("donation failed: %v", err)
+	}
+	_, err = store.PayoutTx(ctx, PayoutTxParams{
+		GoalID:    goal.ID,
+		Amount:    100,
+		Currency:  "USD",
+		Provider:  "fake",
+		Reference: "wire-2",
+	})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+
+	rows, err := store.ReconcileGoals(ctx, ReconcileGoalsParams{Limit: 1000})
+	if err != nil {
+		t.Fatalf("ReconcileGoals failed: %v", err)
+	}
+	for _, row := range rows {
+		if row.GoalID == goal.ID && (row.CollectedAmount != 450 || row.LedgerCollected != 450 || row.LedgerBalance != 50) {
+			t.Fatalf("ledger does not match the goal: %+v", row)
+		}
+	}
+}
+
 func TestDonationTxConcurrent(t *testing.T) {
 	store := newTestStore(t)
 	ctx := context.Background()
@@ -92,14 +744,9 @@ func TestDonationTxConcurrent(t *testing.T) {
 	for i := 0; i < workers; i++ {
 		go func() {
 			defer wg.Done()
-			if _, err := store.DonationTx(ctx, DonationTxParams{
-				GoalID:      goal.ID,
-				Amount:      amount,
-				Currency:    "USD",
-				IsAnonymous: true,
-			}); err != nil {
+			if _, err := donate(ctx, store, goal.ID, amount); err != nil {
 				// Best-effort logging inside tests; we fail at the end if needed
-				t.Errorf("DonationTx failed in goroutine: %v", err)
+				t.Errorf("donation failed in goroutine: %v", err)
 			}
 		}()
 	}
@@ -116,3 +763,71 @@ func TestDonationTxConcurrent(t *testing.T) {
 		t.Fatalf("unexpected collected_amount after concurrent donations: got %d, want %d", updated.CollectedAmount, want)
 	}
 }
+
+// TestDonationTxConcurrentHTTP drives DonationTx from parallel HTTP requests,
+// the way the API server does, so that every handler goroutine shares the
+// store's connection pool.
+func TestDonationTxConcurrentHTTP(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	goal, err := store.CreateGoal(ctx, CreateGoalParams{})
+	if err != nil {
+		t.Fatalf("failed to create goal: %v", err)
+	}
+
+	const (
+		requests = 50
+		amount   = int64(100)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// mix reads on the shared pool with the transactions
+		if _, err := store.GetGoal(r.Context(), goal.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := donate(r.Context(), store, goal.ID, amount); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	wg.Add(requests)
+
+	for i := 0; i < requests; i++ {
+		go func() {
+			defer wg.Done()
+			rsp, err := http.Post(server.URL, "application/json", nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer rsp.Body.Close()
+			if rsp.StatusCode != http.StatusOK {
+				errs <- fmt.Errorf("unexpected status %d", rsp.StatusCode)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("donation request failed: %v", err)
+	}
+
+	updated, err := store.GetGoal(ctx, goal.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch updated goal: %v", err)
+	}
+
+	want := int64(requests) * amount
+	if updated.CollectedAmount != want {
+		t.Fatalf("unexpected collected_amount after concurrent requests: got %d, want %d", updated.CollectedAmount, want)
+	}
+}
@@ -0,0 +1,16 @@
+package db
+
+// Subscription statuses. A past_due subscription is being retried after a
+// failed charge; only active and past_due subscriptions are charged.
+const (
+	SubscriptionActive   = "active"
+	SubscriptionPastDue  = "past_due"
+	SubscriptionPaused   = "paused"
+	SubscriptionCanceled = "canceled"
+)
+
+// Subscription intervals.
+const (
+	SubscriptionWeekly  = "weekly"
+	SubscriptionMonthly = "monthly"
+)
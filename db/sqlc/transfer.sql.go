@@ -14,7 +14,7 @@ import (
 const getGoalTotalDonations = `-- name: GetGoalTotalDonations :one
 SELECT COALESCE(SUM(amount), 0) AS total_amount
 FROM donations
-WHERE goal_id = $1
+WHERE goal_id = $1 AND status = 'succeeded'
 `
 
 func (q *Queries) GetGoalTotalDonations(ctx context.Context, goalID int64) (interface{}, error) {
@@ -27,7 +27,7 @@ func (q *Queries) GetGoalTotalDonations(ctx context.Context, goalID int64) (inte
 const getUserTotalDonations = `-- name: GetUserTotalDonations :one
 SELECT COALESCE(SUM(amount), 0) AS total_amount
 FROM donations
-WHERE user_id = $1
+WHERE user_id = $1 AND status = 'succeeded'
 `
 
 func (q *Queries) GetUserTotalDonations(ctx context.Context, userID pgtype.Int8) (interface{}, error) {
@@ -38,10 +38,10 @@ func (q *Queries) GetUserTotalDonations(ctx context.Context, userID pgtype.Int8)
 }
 
 const listGoalDonors = `-- name: ListGoalDonors :many
-SELECT u.id, u.email, u.name, u.password, u.created_at
+SELECT u.id, u.email, u.name, u.password, u.created_at, u.role, u.is_email_verified
 FROM users u
 JOIN donations d ON d.user_id = u.id
-WHERE d.goal_id = $1
+WHERE d.goal_id = $1 AND d.status = 'succeeded'
 GROUP BY u.id
 ORDER BY u.id
 LIMIT $2
@@ -69,6 +69,8 @@ func (q *Queries) ListGoalDonors(ctx context.Context, arg ListGoalDonorsParams)
 			&i.Name,
 			&i.Password,
 			&i.CreatedAt,
+			&i.Role,
+			&i.IsEmailVerified,
 		); err != nil {
 			return nil, err
 		}
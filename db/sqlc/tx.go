@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// txOptions controls how execTx runs a transaction.
+type txOptions struct {
+	IsoLevel pgx.TxIsoLevel
+	ReadOnly bool
+	// MaxAttempts is how many times the transaction is tried when it fails
+	// with a serialization failure or a deadlock before it commits.
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt. It doubles for
+	// every further attempt up to MaxDelay, and the actual wait is drawn
+	// uniformly between zero and that value so that conflicting
+	// transactions do not retry in lockstep.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AttemptTimeout bounds a single attempt, including the wait for a
+	// pooled connection. Zero means no limit beyond the caller's context.
+	// An attempt that times out is not retried, since it may have timed out
+	// while committing.
+	AttemptTimeout time.Duration
+}
+
+var (
+	// defaultTxOptions suit transactions whose consistency is guaranteed by
+	// row locks and constraints.
+	defaultTxOptions = txOptions{
+		IsoLevel:       pgx.ReadCommitted,
+		MaxAttempts:    4,
+		BaseDelay:      10 * time.Millisecond,
+		MaxDelay:       200 * time.Millisecond,
+		AttemptTimeout: 5 * time.Second,
+	}
+
+	// financialTxOptions are used for every transaction that moves money.
+	// Serializable isolation makes Postgres abort conflicting transactions
+	// instead of relying on each of them taking the right locks, so they
+	// are given more attempts.
+	financialTxOptions = txOptions{
+		IsoLevel:       pgx.Serializable,
+		MaxAttempts:    8,
+		BaseDelay:      10 * time.Millisecond,
+		MaxDelay:       500 * time.Millisecond,
+		AttemptTimeout: 5 * time.Second,
+	}
+)
+
+// Transaction metrics, published by expvar under "db_tx" and keyed by
+// transaction name.
+var (
+	txMetrics  = expvar.NewMap("db_tx")
+	txCommits  = new(expvar.Map).Init()
+	txRetries  = new(expvar.Map).Init()
+	txFailures = new(expvar.Map).Init()
+)
+
+func init() {
+	txMetrics.Set("commits", txCommits)
+	txMetrics.Set("retries", txRetries)
+	txMetrics.Set("failures", txFailures)
+}
+
+// commitError wraps an error returned by COMMIT. Postgres rolls back a
+// transaction whose COMMIT fails with a conflict, but any other failure,
+// such as a lost connection, leaves it unknown whether the transaction was
+// applied.
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string {
+	return "commit: " + e.err.Error()
+}
+
+func (e *commitError) Unwrap() error {
+	return e.err
+}
+
+// isRetryableTxError reports whether a transaction that failed with err
+// was certainly rolled back by a conflict and may be run again. This holds
+// for conflicts reported by COMMIT too, which is where serializable
+// transactions usually fail.
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		// includes commit errors without a server response
+		return false
+	}
+
+	switch pgErr.Code {
+	case "40P01", // deadlock_detected
+		"40001": // serialization_failure
+		return true
+	default:
+		return false
+	}
+}
+
+// txBackoff returns how long to wait before the given attempt, counting
+// from 1 for the first retry.
+func txBackoff(opts txOptions, retry int) time.Duration {
+	delay := opts.BaseDelay
+	for i := 1; i < retry && delay < opts.MaxDelay; i++ {
+		delay *= 2
+	}
+	if opts.MaxDelay > 0 && delay > opts.MaxDelay {
+		delay = opts.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay + 1)
+}
+
+// execTx runs fn in a transaction, retrying it with a fresh transaction as
+// allowed by opts. fn must use the context it is given and may run several
+// times. name identifies the transaction in logs and metrics.
+func (store *SQLStore) execTx(ctx context.Context, name string, opts txOptions, fn func(context.Context, *Queries) error) error {
+	return retryTx(ctx, name, opts, func(ctx context.Context) error {
+		return store.runTx(ctx, opts, fn)
+	})
+}
+
+// retryTx calls run until it succeeds, fails with an error that is not
+// retryable, or has been tried opts.MaxAttempts times.
+func retryTx(ctx context.Context, name string, opts txOptions, run func(context.Context) error) error {
+	attempts := max(opts.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := run(ctx)
+		if err == nil {
+			txCommits.Add(name, 1)
+			return nil
+		}
+
+		if !isRetryableTxError(err) {
+			txFailures.Add(name, 1)
+			return err
+		}
+		if attempt >= attempts {
+			txFailures.Add(name, 1)
+			log.Printf("%s failed after %d attempts: %v", name, attempt, err)
+			return fmt.Errorf("transaction failed after %d attempts: %w", attempt, err)
+		}
+
+		delay := txBackoff(opts, attempt)
+		txRetries.Add(name, 1)
+		log.Printf("%s attempt %d failed, retrying in %v: %v", name, attempt, delay, err)
+
+		select {
+		case <-ctx.Done():
+			txFailures.Add(name, 1)
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runTx runs fn in a single transaction on a connection acquired from the
+// pool for its duration.
+func (store *SQLStore) runTx(ctx context.Context, opts txOptions, fn func(context.Context, *Queries) error) error {
+	if opts.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.AttemptTimeout)
+		defer cancel()
+	}
+
+	conn, err := store.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	accessMode := pgx.ReadWrite
+	if opts.ReadOnly {
+		accessMode = pgx.ReadOnly
+	}
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   opts.IsoLevel,
+		AccessMode: accessMode,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ctx, New(tx)); err != nil {
+		// the attempt context may be done; roll back regardless
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil {
+			return fmt.Errorf("tx err: %w, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return &commitError{err: err}
+	}
+	return nil
+}
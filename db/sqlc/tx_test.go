@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTxBackoff(t *testing.T) {
+	opts := txOptions{
+		BaseDelay: 10 * time.Millisecond,
+		MaxDelay:  50 * time.Millisecond,
+	}
+
+	testCases := []struct {
+		retry int
+		limit time.Duration
+	}{
+		{retry: 1, limit: 10 * time.Millisecond},
+		{retry: 2, limit: 20 * time.Millisecond},
+		{retry: 3, limit: 40 * time.Millisecond},
+		{retry: 4, limit: 50 * time.Millisecond},
+		{retry: 30, limit: 50 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		for i := 0; i < 100; i++ {
+			delay := txBackoff(opts, tc.retry)
+			if delay < 0 || delay > tc.limit {
+				t.Fatalf("retry %d: delay %v outside [0, %v]", tc.retry, delay, tc.limit)
+			}
+		}
+	}
+
+	if delay := txBackoff(txOptions{}, 1); delay != 0 {
+		t.Fatalf("expected no delay without a base delay, got %v", delay)
+	}
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want bool
+	}{
+		{err: &pgconn.PgError{Code: "40001"}, want: true},
+		{err: &pgconn.PgError{Code: "40P01"}, want: true},
+		{err: &pgconn.PgError{Code: "23505"}, want: false},
+		{err: errors.New("connection reset"), want: false},
+		{err: context.DeadlineExceeded, want: false},
+		{err: &commitError{err: &pgconn.PgError{Code: "40001"}}, want: true},
+		{err: &commitError{err: &pgconn.PgError{Code: "23505"}}, want: false},
+		{err: &commitError{err: errors.New("unexpected EOF")}, want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := isRetryableTxError(tc.err); got != tc.want {
+			t.Errorf("isRetryableTxError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryTx(t *testing.T) {
+	opts := txOptions{MaxAttempts: 4}
+	serializationFailure := &pgconn.PgError{Code: "40001"}
+
+	testCases := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "Committed",
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "RetriedOnSerializationFailure",
+			errs:      []error{serializationFailure, &pgconn.PgError{Code: "40P01"}, nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "GivesUpAfterMaxAttempts",
+			errs:      []error{serializationFailure, serializationFailure, serializationFailure, serializationFailure},
+			wantCalls: 4,
+			wantErr:   serializationFailure,
+		},
+		{
+			name:      "RetriedAfterCommitConflict",
+			errs:      []error{&commitError{err: serializationFailure}, nil},
+			wantCalls: 2,
+		},
+		{
+			// the commit may have been applied before the connection broke
+			name:      "NotRetriedAfterAmbiguousCommitError",
+			errs:      []error{&commitError{err: io.ErrUnexpectedEOF}, nil},
+			wantCalls: 1,
+			wantErr:   io.ErrUnexpectedEOF,
+		},
+		{
+			name:      "NotRetriedAfterTimeout",
+			errs:      []error{context.DeadlineExceeded, nil},
+			wantCalls: 1,
+			wantErr:   context.DeadlineExceeded,
+		},
+		{
+			name:      "NotRetriedOnOtherErrors",
+			errs:      []error{&pgconn.PgError{Code: "23505"}, nil},
+			wantCalls: 1,
+			wantErr:   &pgconn.PgError{Code: "23505"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := retryTx(context.Background(), "TestTx", opts, func(context.Context) error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+
+			if calls != tc.wantCalls {
+				t.Fatalf("expected %d attempts, got %d", tc.wantCalls, calls)
+			}
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var pgErr *pgconn.PgError
+			if errors.As(tc.wantErr, &pgErr) {
+				var got *pgconn.PgError
+				if !errors.As(err, &got) || got.Code != pgErr.Code {
+					t.Fatalf("expected SQLSTATE %s, got %v", pgErr.Code, err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRetryTxCountsFailures(t *testing.T) {
+	opts := txOptions{MaxAttempts: 2}
+	failures := func(name string) int64 {
+		if v, ok := txFailures.Get(name).(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+
+	testCases := []struct {
+		name string
+		err  error
+	}{
+		{name: "TestTxExhausted", err: &pgconn.PgError{Code: "40001"}},
+		{name: "TestTxNotRetryable", err: &pgconn.PgError{Code: "23505"}},
+		{name: "TestTxAmbiguousCommit", err: &commitError{err: io.ErrUnexpectedEOF}},
+	}
+
+	for _, tc := range testCases {
+		before := failures(tc.name)
+		err := retryTx(context.Background(), tc.name, opts, func(context.Context) error {
+			return tc.err
+		})
+		if err == nil {
+			t.Fatalf("%s: expected an error", tc.name)
+		}
+		if got := failures(tc.name) - before; got != 1 {
+			t.Errorf("%s: counted %d failures, want 1", tc.name, got)
+		}
+	}
+}
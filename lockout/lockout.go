@@ -0,0 +1,126 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// State is the failed-attempt record of a single key.
+type State struct {
+	Failures     int32
+	LastFailedAt time.Time
+	LockedUntil  time.Time
+}
+
+// Store persists failed-attempt counters. A key with no record must be
+// reported as the zero State.
+type Store interface {
+	// Get returns the current state of key.
+	Get(ctx context.Context, key string) (State, error)
+	// RecordFailure counts a failure for key, starting over from one when
+	// the previous failure is older than policy.Window, and locks key for
+	// as long as policy decides in the same atomic step. It returns false
+	// and the current state, without counting the failure, if key is
+	// locked at failedAt.
+	RecordFailure(ctx context.Context, key string, failedAt time.Time, policy Policy) (State, bool, error)
+	// ForgiveFailure takes back a failure counted for key, and the lock
+	// it triggered if key is still locked until lockedUntil.
+	ForgiveFailure(ctx context.Context, key string, lockedUntil time.Time) error
+	// Reset forgets every failure recorded for key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy decides when and for how long a key is locked.
+type Policy struct {
+	// MaxFailures is the number of consecutive failures that triggers
+	// the first lockout.
+	MaxFailures int32
+	// BaseLockout is the length of the first lockout. Every further
+	// failure doubles it, up to MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window is how long a failure is remembered when no further
+	// failures follow.
+	Window time.Duration
+}
+
+// lockoutFor returns how long a key with the given number of consecutive
+// failures stays locked.
+func (p Policy) lockoutFor(failures int32) time.Duration {
+	if failures < p.MaxFailures {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for i := p.MaxFailures; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, p.MaxLockout)
+}
+
+// Limiter applies a Policy to the counters kept in a Store.
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+// NewLimiter creates a new Limiter
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Attempt is an attempt that was counted as a failure before its outcome
+// was known. It must be settled with Fail or Release.
+type Attempt struct {
+	limiter *Limiter
+	key     string
+	// lockedUntil is set when counting the attempt locked its key.
+	lockedUntil time.Time
+}
+
+// Begin counts an attempt for key as a failure up front, so that attempts
+// made concurrently cannot all get in before any of them has failed. If key
+// is locked, Begin returns how long it remains locked and the attempt must
+// be rejected.
+func (l *Limiter) Begin(ctx context.Context, key string) (*Attempt, time.Duration, error) {
+	now := l.now()
+	state, counted, err := l.store.RecordFailure(ctx, key, now, l.policy)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !counted {
+		// the lock may have been lifted since; the client can retry soon
+		return nil, max(state.LockedUntil.Sub(now), time.Second), nil
+	}
+
+	attempt := &Attempt{limiter: l, key: key}
+	if state.LockedUntil.After(now) {
+		attempt.lockedUntil = state.LockedUntil
+	}
+	return attempt, 0, nil
+}
+
+// Fail settles a failed attempt and returns how long its key is now locked,
+// or zero if further attempts are still allowed.
+func (a *Attempt) Fail() time.Duration {
+	if a.lockedUntil.IsZero() {
+		return 0
+	}
+	return max(a.lockedUntil.Sub(a.limiter.now()), 0)
+}
+
+// Release takes back an attempt that did not fail, e.g. because it
+// succeeded or could not be completed.
+func (a *Attempt) Release(ctx context.Context) error {
+	return a.limiter.store.ForgiveFailure(ctx, a.key, a.lockedUntil)
+}
+
+// Reset clears the failures recorded for key, unlocking it.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
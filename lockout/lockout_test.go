@@ -0,0 +1,246 @@
+package lockout
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	db "charity/db/sqlc"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var testPolicy = Policy{
+	MaxFailures: 3,
+	BaseLockout: time.Minute,
+	MaxLockout:  10 * time.Minute,
+	Window:      15 * time.Minute,
+}
+
+// testStores runs test against a MemoryStore and, when a test database is
+// configured, a PostgresStore.
+func testStores(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("Memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("Postgres", func(t *testing.T) {
+		test(t, newTestPostgresStore(t))
+	})
+}
+
+func newTestPostgresStore(t *testing.T) Store {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		dsn = os.Getenv("DATABASE_URL")
+	}
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL or DATABASE_URL must be set for integration tests")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(ctx, "TRUNCATE login_attempts"); err != nil {
+		t.Fatalf("failed to clean tables: %v", err)
+	}
+	return NewPostgresStore(db.New(pool))
+}
+
+// newTestLimiter returns a limiter whose clock is read from now.
+func newTestLimiter(store Store, now *time.Time) *Limiter {
+	limiter := NewLimiter(store, testPolicy)
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestPolicyLockoutFor(t *testing.T) {
+	testCases := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Minute},
+		{failures: 4, want: 2 * time.Minute},
+		{failures: 6, want: 8 * time.Minute},
+		{failures: 7, want: 10 * time.Minute},
+		{failures: 1000, want: 10 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		if got := testPolicy.lockoutFor(tc.failures); got != tc.want {
+			t.Errorf("lockoutFor(%d) = %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
+
+// fail begins an attempt for key and fails it, returning how long key is
+// locked afterwards.
+func fail(t *testing.T, limiter *Limiter, key string) time.Duration {
+	t.Helper()
+
+	attempt, wait, err := limiter.Begin(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if wait > 0 {
+		t.Fatalf("attempt was rejected, locked for %v", wait)
+	}
+	return attempt.Fail()
+}
+
+func requireLocked(t *testing.T, limiter *Limiter, key string, want time.Duration) {
+	t.Helper()
+
+	attempt, wait, err := limiter.Begin(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if attempt != nil || wait != want {
+		t.Fatalf("expected the key to be locked for %v, got attempt %v and wait %v", want, attempt, wait)
+	}
+}
+
+func TestLimiterLocksOutWithBackoff(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		now := time.Now().Truncate(time.Second)
+		limiter := newTestLimiter(store, &now)
+		const key = "email:user@example.com"
+
+		for i := 0; i < 2; i++ {
+			if wait := fail(t, limiter, key); wait != 0 {
+				t.Fatalf("failure %d locked the key for %v", i+1, wait)
+			}
+		}
+		if wait := fail(t, limiter, key); wait != time.Minute {
+			t.Fatalf("third failure locked the key for %v, want 1m", wait)
+		}
+
+		now = now.Add(30 * time.Second)
+		requireLocked(t, limiter, key, 30*time.Second)
+
+		// attempts made while locked are not counted, so the next lockout
+		// only doubles once
+		now = now.Add(30 * time.Second)
+		if wait := fail(t, limiter, key); wait != 2*time.Minute {
+			t.Fatalf("failure after the lockout locked the key for %v, want 2m", wait)
+		}
+	})
+}
+
+func TestLimiterForgetsOldFailures(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		now := time.Now().Truncate(time.Second)
+		limiter := newTestLimiter(store, &now)
+		const key = "ip:192.0.2.1"
+
+		fail(t, limiter, key)
+		fail(t, limiter, key)
+
+		now = now.Add(testPolicy.Window + time.Second)
+		if wait := fail(t, limiter, key); wait != 0 {
+			t.Fatalf("failure after the window locked the key for %v", wait)
+		}
+	})
+}
+
+func TestLimiterRelease(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		now := time.Now().Truncate(time.Second)
+		limiter := newTestLimiter(store, &now)
+		const key = "email:user@example.com"
+
+		fail(t, limiter, key)
+		fail(t, limiter, key)
+
+		// a released attempt neither counts nor keeps the lock it triggered
+		attempt, wait, err := limiter.Begin(ctx, key)
+		if err != nil || wait != 0 {
+			t.Fatalf("Begin failed: %v, wait %v", err, wait)
+		}
+		if err := attempt.Release(ctx); err != nil {
+			t.Fatalf("Release failed: %v", err)
+		}
+
+		state, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if state.Failures != 2 || !state.LockedUntil.IsZero() {
+			t.Fatalf("unexpected state after release: %+v", state)
+		}
+		if wait := fail(t, limiter, key); wait != time.Minute {
+			t.Fatalf("third failure locked the key for %v, want 1m", wait)
+		}
+	})
+}
+
+func TestLimiterReset(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		now := time.Now().Truncate(time.Second)
+		limiter := newTestLimiter(store, &now)
+		const key = "email:user@example.com"
+
+		for i := int32(0); i < testPolicy.MaxFailures; i++ {
+			fail(t, limiter, key)
+		}
+		if err := limiter.Reset(ctx, key); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if wait := fail(t, limiter, key); wait != 0 {
+			t.Fatalf("failure after reset locked the key for %v", wait)
+		}
+	})
+}
+
+func TestLimiterConcurrentAttempts(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		now := time.Now().Truncate(time.Second)
+		limiter := newTestLimiter(store, &now)
+		const key = "email:user@example.com"
+		const n = 20
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int32
+		)
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				attempt, _, err := limiter.Begin(context.Background(), key)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if attempt != nil {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Fatalf("Begin failed: %v", err)
+		}
+		// concurrent guesses cannot get in before the lockout triggers
+		if allowed != testPolicy.MaxFailures {
+			t.Fatalf("%d concurrent attempts were allowed, want %d", allowed, testPolicy.MaxFailures)
+		}
+	})
+}
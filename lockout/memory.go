@@ -0,0 +1,95 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how many failures are recorded between sweeps of
+// stale entries.
+const memorySweepInterval = 1024
+
+// MemoryStore keeps counters in process memory. Lockouts do not survive a
+// restart and are not shared between server instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	states   map[string]State
+	failures int
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() Store {
+	return &MemoryStore{
+		states: make(map[string]State),
+	}
+}
+
+func (store *MemoryStore) Get(_ context.Context, key string) (State, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.states[key], nil
+}
+
+func (store *MemoryStore) RecordFailure(_ context.Context, key string, failedAt time.Time, policy Policy) (State, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	state, ok := store.states[key]
+	if state.LockedUntil.After(failedAt) {
+		return state, false, nil
+	}
+
+	windowStart := failedAt.Add(-policy.Window)
+	if !ok || state.LastFailedAt.Before(windowStart) {
+		state.Failures = 0
+	}
+	state.Failures++
+	state.LastFailedAt = failedAt
+	if lockout := policy.lockoutFor(state.Failures); lockout > 0 {
+		state.LockedUntil = failedAt.Add(lockout)
+	}
+	store.states[key] = state
+
+	store.failures++
+	if store.failures%memorySweepInterval == 0 {
+		store.sweep(windowStart, failedAt)
+	}
+
+	return state, true, nil
+}
+
+func (store *MemoryStore) ForgiveFailure(_ context.Context, key string, lockedUntil time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	state, ok := store.states[key]
+	if !ok {
+		return nil
+	}
+	state.Failures = max(state.Failures-1, 0)
+	if !lockedUntil.IsZero() && state.LockedUntil.Equal(lockedUntil) {
+		state.LockedUntil = time.Time{}
+	}
+	store.states[key] = state
+	return nil
+}
+
+func (store *MemoryStore) Reset(_ context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.states, key)
+	return nil
+}
+
+// sweep drops entries whose failures have aged out of the window and
+// which are no longer locked.
+func (store *MemoryStore) sweep(windowStart time.Time, now time.Time) {
+	for key, state := range store.states {
+		if state.LastFailedAt.Before(windowStart) && !state.LockedUntil.After(now) {
+			delete(store.states, key)
+		}
+	}
+}
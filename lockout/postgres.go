@@ -0,0 +1,76 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "charity/db/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PostgresStore keeps counters in the login_attempts table so that
+// lockouts survive restarts and are shared by every server instance.
+type PostgresStore struct {
+	queries db.Querier
+}
+
+// NewPostgresStore creates a new PostgresStore
+func NewPostgresStore(queries db.Querier) Store {
+	return &PostgresStore{queries: queries}
+}
+
+func (store *PostgresStore) Get(ctx context.Context, key string) (State, error) {
+	attempt, err := store.queries.GetLoginAttempt(ctx, key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return State{}, nil
+		}
+		return State{}, err
+	}
+	return newState(attempt), nil
+}
+
+func (store *PostgresStore) RecordFailure(ctx context.Context, key string, failedAt time.Time, policy Policy) (State, bool, error) {
+	attempt, err := store.queries.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:                key,
+		FailedAt:           failedAt,
+		WindowStart:        failedAt.Add(-policy.Window),
+		MaxFailures:        policy.MaxFailures,
+		BaseLockoutSeconds: policy.BaseLockout.Seconds(),
+		MaxLockoutSeconds:  policy.MaxLockout.Seconds(),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// the key is locked
+			state, err := store.Get(ctx, key)
+			return state, false, err
+		}
+		return State{}, false, err
+	}
+	return newState(attempt), true, nil
+}
+
+func (store *PostgresStore) ForgiveFailure(ctx context.Context, key string, lockedUntil time.Time) error {
+	return store.queries.ForgiveLoginFailure(ctx, db.ForgiveLoginFailureParams{
+		Key:         key,
+		LockedUntil: pgtype.Timestamptz{Time: lockedUntil, Valid: !lockedUntil.IsZero()},
+	})
+}
+
+func (store *PostgresStore) Reset(ctx context.Context, key string) error {
+	return store.queries.DeleteLoginAttempt(ctx, key)
+}
+
+func newState(attempt db.LoginAttempt) State {
+	state := State{
+		Failures:     attempt.Failures,
+		LastFailedAt: attempt.LastFailedAt,
+	}
+	if attempt.LockedUntil.Valid {
+		state.LockedUntil = attempt.LockedUntil.Time
+	}
+	return state
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileSender writes every email to a file in dir instead of delivering it.
+// It is meant for local development and tests.
+type FileSender struct {
+	dir   string
+	count atomic.Int64
+}
+
+// NewFileSender creates a new FileSender, creating dir if needed
+func NewFileSender(dir string) (EmailSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail dir: %w", err)
+	}
+	return &FileSender{dir: dir}, nil
+}
+
+// SendEmail writes the email to a new .eml file
+func (sender *FileSender) SendEmail(subject string, content string, to []string) error {
+	name := fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), sender.count.Add(1))
+	msg := buildMessage("Charity <noreply@localhost>", subject, content, to)
+	if err := os.WriteFile(filepath.Join(sender.dir, name), msg, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
+
+// LogSender writes every email to the standard logger instead of
+// delivering it.
+type LogSender struct{}
+
+// NewLogSender creates a new LogSender
+func NewLogSender() EmailSender {
+	return LogSender{}
+}
+
+// SendEmail logs the email
+func (LogSender) SendEmail(subject string, content string, to []string) error {
+	log.Printf("email to %v: %s\n%s", to, subject, content)
+	return nil
+}
@@ -0,0 +1,7 @@
+package mail
+
+// EmailSender delivers transactional emails such as account verification
+// links. Implementations must be safe for concurrent use.
+type EmailSender interface {
+	SendEmail(subject string, content string, to []string) error
+}
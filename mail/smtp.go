@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// SMTPSender sends HTML emails through an SMTP server using PLAIN auth.
+type SMTPSender struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     mail.Address
+}
+
+// NewSMTPSender creates a new SMTPSender
+func NewSMTPSender(host string, port int, username, password, fromName, fromAddress string) (EmailSender, error) {
+	if host == "" {
+		return nil, fmt.Errorf("smtp host is required")
+	}
+	if fromAddress == "" {
+		return nil, fmt.Errorf("sender address is required")
+	}
+
+	sender := &SMTPSender{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     mail.Address{Name: fromName, Address: fromAddress},
+	}
+
+	return sender, nil
+}
+
+// SendEmail sends an HTML email to every recipient in to
+func (sender *SMTPSender) SendEmail(subject string, content string, to []string) error {
+	if len(to) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	var auth smtp.Auth
+	if sender.username != "" {
+		auth = smtp.PlainAuth("", sender.username, sender.password, sender.host)
+	}
+
+	addr := net.JoinHostPort(sender.host, strconv.Itoa(sender.port))
+	msg := buildMessage(sender.from.String(), subject, content, to)
+	if err := smtp.SendMail(addr, auth, sender.from.Address, to, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// buildMessage renders a minimal RFC 5322 message with an HTML body.
+func buildMessage(from string, subject string, content string, to []string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(content)
+	return buf.Bytes()
+}
@@ -2,15 +2,29 @@ package charity
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"charity/api"
+	"charity/billing"
 	"charity/config"
 	db "charity/db/sqlc"
+	"charity/lockout"
+	"charity/mail"
+	"charity/oauth"
+	"charity/payments"
 	"charity/token"
+	"charity/util"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
@@ -22,22 +36,167 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	conn, err := pgx.Connect(ctx, cfg.DatabaseURL)
+	pool, err := newDBPool(ctx, cfg)
 	if err != nil {
 		log.Fatalf("cannot connect to db: %v", err)
 	}
-	defer conn.Close(context.Background())
+	defer pool.Close()
 
-	store := db.NewStore(conn)
+	store := db.NewStore(pool)
 
-	tokenMaker, err := token.NewPasetoMaker(cfg.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(cfg)
 	if err != nil {
 		log.Fatalf("cannot create token maker: %v", err)
 	}
 
-	server := api.NewServer(store, tokenMaker, cfg.AccessTokenDuration, cfg.RefreshTokenDuration)
+	passwordPolicy, err := util.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordDenylist)
+	if err != nil {
+		log.Fatalf("cannot load password policy: %v", err)
+	}
+
+	oauthProviders, err := newOAuthProviders(cfg)
+	if err != nil {
+		log.Fatalf("cannot create oauth providers: %v", err)
+	}
+
+	mailer, err := newEmailSender(cfg)
+	if err != nil {
+		log.Fatalf("cannot create email sender: %v", err)
+	}
+
+	paymentProvider, err := payments.NewProvider(payments.Config{
+		Type:             cfg.PaymentProvider,
+		APIKey:           cfg.StripeAPIKey,
+		APIURL:           cfg.StripeAPIURL,
+		WebhookSecret:    cfg.PaymentWebhookSecret,
+		WebhookTolerance: cfg.PaymentWebhookTolerance,
+	})
+	if err != nil {
+		log.Fatalf("cannot create payment provider: %v", err)
+	}
 
-	if err := server.Start(cfg.ServerAddress); err != nil {
+	scheduler := billing.NewScheduler(store, paymentProvider, billing.Policy{
+		BatchSize:  cfg.SubscriptionBatchSize,
+		Lease:      cfg.SubscriptionChargeLease,
+		RetryDelay: cfg.SubscriptionRetryDelay,
+		MaxRetries: cfg.SubscriptionMaxRetries,
+	})
+
+	// stop on SIGINT or SIGTERM, letting a subscription charge in
+	// progress finish before the process exits
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scheduler.Run(runCtx, cfg.SubscriptionScheduleInterval)
+	}()
+
+	server := api.NewServer(*cfg, store, tokenMaker, mailer, newLoginAttemptStore(cfg, store), newPasswordHasher(cfg), passwordPolicy, oauthProviders, paymentProvider, scheduler)
+
+	err = server.Start(runCtx, cfg.ServerAddress)
+	stop()
+	wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("cannot start server: %v", err)
 	}
 }
+
+func newDBPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.MaxConns = cfg.DBMaxConns
+	poolConfig.MinConns = cfg.DBMinConns
+	poolConfig.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	// pgxpool connects lazily; fail at startup if the database is unreachable
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+func newTokenMaker(cfg *config.Config) (token.Maker, error) {
+	if cfg.TokenKeySet == "" {
+		if cfg.TokenFormat == "jwt" {
+			return token.NewJWTMaker(cfg.TokenSymmetricKey)
+		}
+		return token.NewPasetoMaker(cfg.TokenSymmetricKey)
+	}
+
+	keys, err := token.LoadKeySet(cfg.TokenKeySet)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TokenFormat == "jwt" {
+		return token.NewJWTEdDSAMaker(keys)
+	}
+	return token.NewPasetoPublicMaker(keys)
+}
+
+func newPasswordHasher(cfg *config.Config) util.PasswordHasher {
+	if cfg.PasswordHasher == "argon2id" {
+		return util.Argon2idHasher{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		}
+	}
+	return util.BcryptHasher{Cost: cfg.BcryptCost}
+}
+
+func newOAuthProviders(cfg *config.Config) (map[string]oauth.Provider, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	providers := make(map[string]oauth.Provider, len(cfg.OAuthProviders))
+	for name, provider := range cfg.OAuthProviders {
+		p, err := oauth.NewProvider(ctx, oauth.Config{
+			Type:         provider.Type,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  strings.TrimSuffix(cfg.AppBaseURL, "/") + "/oauth/" + name + "/callback",
+			Scopes:       provider.Scopes,
+			IssuerURL:    provider.IssuerURL,
+			AuthURL:      provider.AuthURL,
+			TokenURL:     provider.TokenURL,
+			JWKSURL:      provider.JWKSURL,
+			APIURL:       provider.APIURL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+func newEmailSender(cfg *config.Config) (mail.EmailSender, error) {
+	switch cfg.EmailSender {
+	case "smtp":
+		return mail.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.EmailSenderName, cfg.EmailSenderAddress)
+	case "file":
+		return mail.NewFileSender(cfg.MailDir)
+	default:
+		return mail.NewLogSender(), nil
+	}
+}
+
+func newLoginAttemptStore(cfg *config.Config, store db.Store) lockout.Store {
+	if cfg.LoginLockoutStore == "memory" {
+		return lockout.NewMemoryStore()
+	}
+	return lockout.NewPostgresStore(store)
+}
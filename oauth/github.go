@@ -0,0 +1,124 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	gitHubAuthURL  = "https://github.com/login/oauth/authorize"
+	gitHubTokenURL = "https://github.com/login/oauth/access_token"
+	gitHubAPIURL   = "https://api.github.com"
+)
+
+// GitHubProvider signs users in with GitHub, which does not implement OIDC.
+// The identity is read from the REST API with the access token.
+type GitHubProvider struct {
+	oauth2 oauth2.Config
+	apiURL string
+}
+
+// NewGitHubProvider creates a new GitHubProvider
+func NewGitHubProvider(cfg Config) Provider {
+	authURL := cfg.AuthURL
+	if authURL == "" {
+		authURL = gitHubAuthURL
+	}
+	tokenURL := cfg.TokenURL
+	if tokenURL == "" {
+		tokenURL = gitHubTokenURL
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = gitHubAPIURL
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+
+	return &GitHubProvider{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     oauth2.Endpoint{AuthURL: authURL, TokenURL: tokenURL},
+			Scopes:       scopes,
+		},
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+	}
+}
+
+// AuthCodeURL ignores nonce: without an ID token there is nothing to bind
+// it to, and PKCE already ties the code to this login attempt.
+func (p *GitHubProvider) AuthCodeURL(state string, codeVerifier string, _ string) string {
+	return p.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier))
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code string, codeVerifier string, _ string) (Identity, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("cannot exchange code: %w", err)
+	}
+	client := p.oauth2.Client(ctx, token)
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(ctx, client, "/user", &user); err != nil {
+		return Identity{}, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, client, "/user/emails", &emails); err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{
+		Subject: strconv.FormatInt(user.ID, 10),
+		Name:    user.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *GitHubProvider) get(ctx context.Context, client *http.Client, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github %s: unexpected status %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,170 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// mockOIDCServer is a minimal identity provider: its token endpoint returns
+// an ID token for a fixed user, signed with a key published at /jwks.
+type mockOIDCServer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	nonce  string
+	claims map[string]any
+	// verifier is the PKCE code verifier received by the token endpoint.
+	verifier string
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	m := &mockOIDCServer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.verifier = r.PostForm.Get("code_verifier")
+
+		claims := map[string]any{
+			"iss":   m.URL,
+			"aud":   "client",
+			"sub":   "user-1",
+			"nonce": m.nonce,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     m.sign(t, claims),
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+func (m *mockOIDCServer) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: m.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	payload, _ := json.Marshal(claims)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	return token
+}
+
+func (m *mockOIDCServer) provider(t *testing.T) Provider {
+	t.Helper()
+
+	provider, err := NewOIDCProvider(context.Background(), Config{
+		Type:        "oidc",
+		ClientID:    "client",
+		RedirectURL: "http://localhost/callback",
+		IssuerURL:   m.URL,
+		AuthURL:     m.URL + "/auth",
+		TokenURL:    m.URL + "/token",
+		JWKSURL:     m.URL + "/jwks",
+	})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	return provider
+}
+
+func TestOIDCProviderAuthCodeURL(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := m.provider(t)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state", "verifier-verifier-verifier-verifier-verifier", "nonce"))
+	if err != nil {
+		t.Fatalf("invalid auth url: %v", err)
+	}
+
+	query := authURL.Query()
+	if query.Get("state") != "state" || query.Get("nonce") != "nonce" {
+		t.Fatalf("state or nonce missing from %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("PKCE challenge missing from %s", authURL)
+	}
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := m.provider(t)
+
+	m.nonce = "nonce"
+	m.claims = map[string]any{"email": "jane@example.com", "email_verified": true, "name": "Jane"}
+
+	identity, err := provider.Exchange(context.Background(), "code", "verifier", "nonce")
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if m.verifier != "verifier" {
+		t.Errorf("token request code_verifier = %q, want %q", m.verifier, "verifier")
+	}
+
+	want := Identity{Subject: "user-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+	if identity != want {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+}
+
+func TestOIDCProviderExchangeRejectsWrongNonce(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := m.provider(t)
+
+	m.nonce = "other"
+
+	if _, err := provider.Exchange(context.Background(), "code", "verifier", "nonce"); err == nil {
+		t.Fatal("expected an error for a mismatched nonce")
+	}
+}
+
+func TestOIDCProviderExchangeRejectsWrongAudience(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := m.provider(t)
+
+	m.nonce = "nonce"
+	m.claims = map[string]any{"aud": "another-client"}
+
+	if _, err := provider.Exchange(context.Background(), "code", "verifier", "nonce"); err == nil {
+		t.Fatal("expected an error for an ID token issued to another client")
+	}
+}
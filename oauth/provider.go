@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+)
+
+// Identity is what a provider asserts about the user who signed in.
+type Identity struct {
+	// Subject is the provider's stable ID for the user.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider drives the authorization code flow with PKCE against one
+// identity provider.
+type Provider interface {
+	// AuthCodeURL returns the provider URL the user is sent to in order to
+	// sign in.
+	AuthCodeURL(state string, codeVerifier string, nonce string) string
+	// Exchange redeems the authorization code returned to the callback and
+	// returns the identity of the user.
+	Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (Identity, error)
+}
+
+// Config describes an identity provider. Endpoints left empty are
+// discovered from IssuerURL (OIDC) or default to the public GitHub ones.
+type Config struct {
+	// Type is "oidc" or "github".
+	Type         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	IssuerURL string
+	AuthURL   string
+	TokenURL  string
+	// JWKSURL is where an OIDC provider publishes its ID token keys.
+	JWKSURL string
+	// APIURL is the base URL of the GitHub REST API.
+	APIURL string
+}
+
+// NewProvider creates a Provider for cfg
+func NewProvider(ctx context.Context, cfg Config) (Provider, error) {
+	switch cfg.Type {
+	case "oidc":
+		return NewOIDCProvider(ctx, cfg)
+	case "github":
+		return NewGitHubProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported oauth provider type %q", cfg.Type)
+	}
+}
@@ -0,0 +1,255 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Test payment methods understood by FakeProvider. They mirror the Stripe
+// test payment methods of the same names. Payments with
+// FakeBankAccountProcessing stay processing, as a bank debit does until it
+// settles.
+const (
+	FakeCardSucceeds               = "pm_card_visa"
+	FakeCardDeclined               = "pm_card_chargeDeclined"
+	FakeCardAuthenticationRequired = "pm_card_authenticationRequired"
+	FakeBankAccountProcessing      = "pm_usBankAccount_processing"
+)
+
+// FakeProvider is an in-process Provider for tests and local development.
+// Payments succeed or fail depending on the test payment method used, and
+// webhook events for its intents can be generated with IntentEvent and
+// RefundEvent.
+type FakeProvider struct {
+	webhookSecret    string
+	webhookTolerance time.Duration
+	// ManualCapture leaves confirmed intents in requires_capture.
+	ManualCapture bool
+
+	mu      sync.Mutex
+	nextID  int
+	intents map[string]*stripeIntent
+	refunds map[string]*stripeRefund
+	// idempotency maps "intent:<key>" and "refund:<key>" to the object
+	// created by the first request with that idempotency key.
+	idempotency map[string]string
+}
+
+// NewFakeProvider creates a new FakeProvider
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{
+		webhookSecret:    webhookSecret,
+		webhookTolerance: DefaultWebhookTolerance,
+		intents:          make(map[string]*stripeIntent),
+		refunds:          make(map[string]*stripeRefund),
+		idempotency:      make(map[string]string),
+	}
+}
+
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (f *FakeProvider) CreateIntent(_ context.Context, arg CreateIntentParams) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.idempotency["intent:"+arg.IdempotencyKey]; ok && arg.IdempotencyKey != "" {
+		return f.intents[id].intent(), nil
+	}
+	if arg.Amount <= 0 {
+		return Intent{}, invalidRequest("amount must be positive")
+	}
+
+	f.nextID++
+	id := fmt.Sprintf("pi_fake_%d", f.nextID)
+	intent := &stripeIntent{
+		ID:           id,
+		Amount:       arg.Amount,
+		Currency:     strings.ToLower(arg.Currency),
+		Status:       string(IntentRequiresPaymentMethod),
+		ClientSecret: id + "_secret",
+		Metadata:     arg.Metadata,
+	}
+	f.intents[id] = intent
+	if arg.IdempotencyKey != "" {
+		f.idempotency["intent:"+arg.IdempotencyKey] = id
+	}
+	return intent.intent(), nil
+}
+
+func (f *FakeProvider) ConfirmIntent(_ context.Context, intentID string, paymentMethod string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return Intent{}, invalidRequest("no such payment intent: " + intentID)
+	}
+	switch IntentStatus(intent.Status) {
+	case IntentRequiresPaymentMethod, IntentRequiresConfirmation, IntentRequiresAction:
+	default:
+		return Intent{}, invalidRequest("payment intent cannot be confirmed in status " + intent.Status)
+	}
+
+	intent.LastPaymentError = nil
+	switch paymentMethod {
+	case FakeCardSucceeds:
+		if f.ManualCapture {
+			intent.Status = string(IntentRequiresCapture)
+		} else {
+			intent.Status = string(IntentSucceeded)
+		}
+	case FakeCardAuthenticationRequired:
+		intent.Status = string(IntentRequiresAction)
+	case FakeBankAccountProcessing:
+		intent.Status = string(IntentProcessing)
+	case FakeCardDeclined:
+		intent.Status = string(IntentRequiresPaymentMethod)
+		intent.LastPaymentError = &struct {
+			Message string `json:"message"`
+		}{Message: "Your card was declined."}
+		return Intent{}, &Error{
+			StatusCode:  http.StatusPaymentRequired,
+			Type:        "card_error",
+			Code:        "card_declined",
+			DeclineCode: "generic_decline",
+			Message:     "Your card was declined.",
+		}
+	default:
+		return Intent{}, invalidRequest("no such payment method: " + paymentMethod)
+	}
+	return intent.intent(), nil
+}
+
+func (f *FakeProvider) CaptureIntent(_ context.Context, intentID string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return Intent{}, invalidRequest("no such payment intent: " + intentID)
+	}
+	if IntentStatus(intent.Status) != IntentRequiresCapture {
+		return Intent{}, invalidRequest("payment intent cannot be captured in status " + intent.Status)
+	}
+	intent.Status = string(IntentSucceeded)
+	return intent.intent(), nil
+}
+
+func (f *FakeProvider) Refund(_ context.Context, arg RefundParams) (Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.idempotency["refund:"+arg.IdempotencyKey]; ok && arg.IdempotencyKey != "" {
+		return f.refunds[id].refund(), nil
+	}
+	intent, ok := f.intents[arg.IntentID]
+	if !ok {
+		return Refund{}, invalidRequest("no such payment intent: " + arg.IntentID)
+	}
+	if IntentStatus(intent.Status) != IntentSucceeded {
+		return Refund{}, invalidRequest("payment intent has not succeeded")
+	}
+
+	var refunded int64
+	for _, refund := range f.refunds {
+		if refund.PaymentIntent == intent.ID && RefundStatus(refund.Status) != RefundFailed {
+			refunded += refund.Amount
+		}
+	}
+	amount := arg.Amount
+	if amount == 0 {
+		amount = intent.Amount - refunded
+	}
+	if amount <= 0 || refunded+amount > intent.Amount {
+		return Refund{}, invalidRequest("refund amount exceeds the amount left to refund")
+	}
+
+	f.nextID++
+	refund := &stripeRefund{
+		ID:            fmt.Sprintf("re_fake_%d", f.nextID),
+		Amount:        amount,
+		Currency:      intent.Currency,
+		PaymentIntent: intent.ID,
+		Status:        string(RefundSucceeded),
+		Reason:        arg.Reason,
+	}
+	f.refunds[refund.ID] = refund
+	if arg.IdempotencyKey != "" {
+		f.idempotency["refund:"+arg.IdempotencyKey] = refund.ID
+	}
+	return refund.refund(), nil
+}
+
+func (f *FakeProvider) ParseWebhook(payload []byte, signature string) (Event, error) {
+	if err := verifySignature(f.webhookSecret, payload, signature, f.webhookTolerance, time.Now()); err != nil {
+		return Event{}, err
+	}
+	return parseEvent(payload)
+}
+
+func (f *FakeProvider) ParseEvent(payload []byte) (Event, error) {
+	return parseEvent(payload)
+}
+
+// IntentEvent returns a signed webhook payload reporting the current state
+// of an intent.
+func (f *FakeProvider) IntentEvent(eventID string, eventType EventType, intentID string) ([]byte, string, error) {
+	f.mu.Lock()
+	intent, ok := f.intents[intentID]
+	var object stripeIntent
+	if ok {
+		object = *intent
+	}
+	f.mu.Unlock()
+	if !ok {
+		return nil, "", invalidRequest("no such payment intent: " + intentID)
+	}
+	return f.signedEvent(eventID, eventType, object)
+}
+
+// RefundEvent returns a signed webhook payload reporting the current state
+// of a refund.
+func (f *FakeProvider) RefundEvent(eventID string, eventType EventType, refundID string) ([]byte, string, error) {
+	f.mu.Lock()
+	refund, ok := f.refunds[refundID]
+	var object stripeRefund
+	if ok {
+		object = *refund
+	}
+	f.mu.Unlock()
+	if !ok {
+		return nil, "", invalidRequest("no such refund: " + refundID)
+	}
+	return f.signedEvent(eventID, eventType, object)
+}
+
+func (f *FakeProvider) signedEvent(eventID string, eventType EventType, object any) ([]byte, string, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+
+	event := stripeEvent{ID: eventID, Type: string(eventType), Created: now.Unix()}
+	event.Data.Object = data
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, SignPayload(f.webhookSecret, payload, now), nil
+}
+
+func invalidRequest(message string) *Error {
+	return &Error{
+		StatusCode: http.StatusBadRequest,
+		Type:       "invalid_request_error",
+		Message:    message,
+	}
+}
@@ -0,0 +1,173 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IntentStatus is the state of a payment intent at the provider. The values
+// follow the Stripe API.
+type IntentStatus string
+
+const (
+	IntentRequiresPaymentMethod IntentStatus = "requires_payment_method"
+	IntentRequiresConfirmation  IntentStatus = "requires_confirmation"
+	IntentRequiresAction        IntentStatus = "requires_action"
+	IntentProcessing            IntentStatus = "processing"
+	IntentRequiresCapture       IntentStatus = "requires_capture"
+	IntentCanceled              IntentStatus = "canceled"
+	IntentSucceeded             IntentStatus = "succeeded"
+)
+
+// Intent is a payment the provider is collecting from a donor.
+type Intent struct {
+	ID       string
+	Amount   int64
+	Currency string
+	Status   IntentStatus
+	// ClientSecret lets the donor's browser complete the payment directly
+	// with the provider.
+	ClientSecret string
+	// FailureMessage explains why the last payment attempt failed.
+	FailureMessage string
+	Metadata       map[string]string
+}
+
+type CreateIntentParams struct {
+	Amount   int64
+	Currency string
+	Metadata map[string]string
+	// IdempotencyKey makes retried requests return the same intent.
+	IdempotencyKey string
+}
+
+// RefundStatus is the state of a refund at the provider.
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+	RefundCanceled  RefundStatus = "canceled"
+)
+
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   int64
+	Currency string
+	Status   RefundStatus
+	// Reason is one of duplicate, fraudulent or requested_by_customer, or
+	// empty.
+	Reason string
+}
+
+type RefundParams struct {
+	IntentID string
+	// Amount is the amount to refund; zero refunds whatever is left.
+	Amount int64
+	// Reason is one of duplicate, fraudulent or requested_by_customer.
+	Reason         string
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+// EventType is the type of a webhook event sent by the provider.
+type EventType string
+
+const (
+	EventIntentSucceeded EventType = "payment_intent.succeeded"
+	EventIntentFailed    EventType = "payment_intent.payment_failed"
+	EventIntentCanceled  EventType = "payment_intent.canceled"
+	EventRefundCreated   EventType = "refund.created"
+	EventRefundUpdated   EventType = "refund.updated"
+)
+
+// Event is a webhook notification about a change at the provider. Events
+// of other types are returned with neither Intent nor Refund set.
+type Event struct {
+	ID      string
+	Type    EventType
+	Created time.Time
+	Intent  *Intent
+	Refund  *Refund
+}
+
+// Provider collects and refunds donations through a payment service.
+// Implementations must be safe for concurrent use.
+type Provider interface {
+	// Name identifies the provider in donation records and ledger
+	// accounts.
+	Name() string
+	// CreateIntent starts collecting a payment.
+	CreateIntent(ctx context.Context, arg CreateIntentParams) (Intent, error)
+	// ConfirmIntent attempts the payment with the given payment method.
+	ConfirmIntent(ctx context.Context, intentID string, paymentMethod string) (Intent, error)
+	// CaptureIntent collects the funds of an intent in requires_capture.
+	CaptureIntent(ctx context.Context, intentID string) (Intent, error)
+	// Refund returns all or part of a succeeded payment.
+	Refund(ctx context.Context, arg RefundParams) (Refund, error)
+	// ParseWebhook verifies the signature of a webhook request and parses
+	// its payload.
+	ParseWebhook(payload []byte, signature string) (Event, error)
+	// ParseEvent parses a webhook payload whose signature was verified
+	// when it was received, e.g. one stored for replay.
+	ParseEvent(payload []byte) (Event, error)
+}
+
+// Error is returned when the provider rejects a request.
+type Error struct {
+	StatusCode int
+	// Type is e.g. card_error or invalid_request_error.
+	Type        string
+	Code        string
+	DeclineCode string
+	Message     string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("payment provider: %s (%s)", e.Message, e.Code)
+	}
+	return "payment provider: " + e.Message
+}
+
+// IsDeclined reports whether err is a payment that was declined, as opposed
+// to a failure to talk to the provider.
+func IsDeclined(err error) bool {
+	var providerErr *Error
+	return errors.As(err, &providerErr) && providerErr.Type == "card_error"
+}
+
+// Config describes the payment provider to use.
+type Config struct {
+	// Type is "stripe" or "fake".
+	Type   string
+	APIKey string
+	// APIURL overrides the Stripe API base URL, e.g. to use stripe-mock.
+	APIURL        string
+	WebhookSecret string
+	// WebhookTolerance is how old a signed webhook may be.
+	WebhookTolerance time.Duration
+}
+
+// NewProvider creates a Provider for cfg.
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Type {
+	case "stripe":
+		return NewStripeProvider(cfg)
+	case "fake":
+		if cfg.WebhookSecret == "" {
+			return nil, fmt.Errorf("fake webhook secret is required")
+		}
+		fake := NewFakeProvider(cfg.WebhookSecret)
+		if cfg.WebhookTolerance != 0 {
+			fake.webhookTolerance = cfg.WebhookTolerance
+		}
+		return fake, nil
+	default:
+		return nil, fmt.Errorf("unsupported payment provider type %q", cfg.Type)
+	}
+}
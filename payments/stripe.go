@@ -0,0 +1,175 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeAPIURL = "https://api.stripe.com"
+
+// StripeProvider talks to the Stripe REST API, or to any service that
+// implements its payment intent and refund endpoints.
+type StripeProvider struct {
+	apiKey           string
+	apiURL           string
+	webhookSecret    string
+	webhookTolerance time.Duration
+	client           *http.Client
+}
+
+// NewStripeProvider creates a new StripeProvider
+func NewStripeProvider(cfg Config) (Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("stripe api key is required")
+	}
+	if cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("stripe webhook secret is required")
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = stripeAPIURL
+	}
+	tolerance := cfg.WebhookTolerance
+	if tolerance == 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+
+	return &StripeProvider{
+		apiKey:           cfg.APIKey,
+		apiURL:           strings.TrimSuffix(apiURL, "/"),
+		webhookSecret:    cfg.WebhookSecret,
+		webhookTolerance: tolerance,
+		client:           &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+func (p *StripeProvider) CreateIntent(ctx context.Context, arg CreateIntentParams) (Intent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(arg.Amount, 10))
+	form.Set("currency", strings.ToLower(arg.Currency))
+	setMetadata(form, arg.Metadata)
+
+	var intent stripeIntent
+	if err := p.post(ctx, "/v1/payment_intents", form, arg.IdempotencyKey, &intent); err != nil {
+		return Intent{}, err
+	}
+	return intent.intent(), nil
+}
+
+func (p *StripeProvider) ConfirmIntent(ctx context.Context, intentID string, paymentMethod string) (Intent, error) {
+	form := url.Values{}
+	if paymentMethod != "" {
+		form.Set("payment_method", paymentMethod)
+	}
+
+	var intent stripeIntent
+	if err := p.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/confirm", form, "", &intent); err != nil {
+		return Intent{}, err
+	}
+	return intent.intent(), nil
+}
+
+func (p *StripeProvider) CaptureIntent(ctx context.Context, intentID string) (Intent, error) {
+	var intent stripeIntent
+	if err := p.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/capture", url.Values{}, "", &intent); err != nil {
+		return Intent{}, err
+	}
+	return intent.intent(), nil
+}
+
+func (p *StripeProvider) Refund(ctx context.Context, arg RefundParams) (Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", arg.IntentID)
+	if arg.Amount > 0 {
+		form.Set("amount", strconv.FormatInt(arg.Amount, 10))
+	}
+	if arg.Reason != "" {
+		form.Set("reason", arg.Reason)
+	}
+	setMetadata(form, arg.Metadata)
+
+	var refund stripeRefund
+	if err := p.post(ctx, "/v1/refunds", form, arg.IdempotencyKey, &refund); err != nil {
+		return Refund{}, err
+	}
+	return refund.refund(), nil
+}
+
+func (p *StripeProvider) ParseWebhook(payload []byte, signature string) (Event, error) {
+	if err := verifySignature(p.webhookSecret, payload, signature, p.webhookTolerance, time.Now()); err != nil {
+		return Event{}, err
+	}
+	return parseEvent(payload)
+}
+
+func (p *StripeProvider) ParseEvent(payload []byte) (Event, error) {
+	return parseEvent(payload)
+}
+
+func setMetadata(form url.Values, metadata map[string]string) {
+	for key, value := range metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+}
+
+// post sends a form-encoded request and decodes the JSON response into v.
+// Error responses are returned as *Error.
+func (p *StripeProvider) post(ctx context.Context, path string, form url.Values, idempotencyKey string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("stripe %s: %w", path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error struct {
+				Type        string `json:"type"`
+				Code        string `json:"code"`
+				DeclineCode string `json:"decline_code"`
+				Message     string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error.Message == "" {
+			return &Error{StatusCode: resp.StatusCode, Message: fmt.Sprintf("stripe %s returned status %d", path, resp.StatusCode)}
+		}
+		return &Error{
+			StatusCode:  resp.StatusCode,
+			Type:        errResp.Error.Type,
+			Code:        errResp.Error.Code,
+			DeclineCode: errResp.Error.DeclineCode,
+			Message:     errResp.Error.Message,
+		}
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("stripe %s: cannot decode response: %w", path, err)
+	}
+	return nil
+}
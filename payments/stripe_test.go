@@ -0,0 +1,194 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStripeProvider(t *testing.T, handler http.HandlerFunc) Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewStripeProvider(Config{
+		Type:          "stripe",
+		APIKey:        "sk_test",
+		APIURL:        server.URL,
+		WebhookSecret: "whsec_test",
+	})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	return provider
+}
+
+func TestStripeCreateIntent(t *testing.T) {
+	provider := newTestStripeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/payment_intents" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk_test" {
+			t.Errorf("unexpected authorization header %q", got)
+		}
+		if got := r.Header.Get("Idempotency-Key"); got != "key-1" {
+			t.Errorf("unexpected idempotency key %q", got)
+		}
+		r.ParseForm()
+		if r.PostForm.Get("amount") != "500" || r.PostForm.Get("currency") != "usd" || r.PostForm.Get("metadata[goal_id]") != "7" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":            "pi_1",
+			"amount":        500,
+			"currency":      "usd",
+			"status":        "requires_payment_method",
+			"client_secret": "pi_1_secret",
+			"metadata":      map[string]string{"goal_id": "7"},
+		})
+	})
+
+	intent, err := provider.CreateIntent(context.Background(), CreateIntentParams{
+		Amount:         500,
+		Currency:       "USD",
+		Metadata:       map[string]string{"goal_id": "7"},
+		IdempotencyKey: "key-1",
+	})
+	if err != nil {
+		t.Fatalf("CreateIntent failed: %v", err)
+	}
+	if intent.ID != "pi_1" || intent.Currency != "USD" || intent.Status != IntentRequiresPaymentMethod || intent.ClientSecret != "pi_1_secret" {
+		t.Fatalf("unexpected intent: %+v", intent)
+	}
+}
+
+func TestStripeConfirmDeclined(t *testing.T) {
+	provider := newTestStripeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/payment_intents/pi_1/confirm" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusPaymentRequired)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error": map[string]any{
+				"type":         "card_error",
+				"code":         "card_declined",
+				"decline_code": "insufficient_funds",
+				"message":      "Your card has insufficient funds.",
+			},
+		})
+	})
+
+	_, err := provider.ConfirmIntent(context.Background(), "pi_1", "pm_card")
+	if !IsDeclined(err) {
+		t.Fatalf("expected a declined payment, got %v", err)
+	}
+	var providerErr *Error
+	if !errors.As(err, &providerErr) || providerErr.DeclineCode != "insufficient_funds" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStripeServerError(t *testing.T) {
+	provider := newTestStripeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	_, err := provider.CaptureIntent(context.Background(), "pi_1")
+	if err == nil || IsDeclined(err) {
+		t.Fatalf("expected a provider error, got %v", err)
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	provider := NewFakeProvider("whsec_test")
+	intent, err := provider.CreateIntent(context.Background(), CreateIntentParams{Amount: 500, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("CreateIntent failed: %v", err)
+	}
+	if _, err := provider.ConfirmIntent(context.Background(), intent.ID, FakeCardSucceeds); err != nil {
+		t.Fatalf("ConfirmIntent failed: %v", err)
+	}
+	payload, signature, err := provider.IntentEvent("evt_1", EventIntentSucceeded, intent.ID)
+	if err != nil {
+		t.Fatalf("IntentEvent failed: %v", err)
+	}
+
+	event, err := provider.ParseWebhook(payload, signature)
+	if err != nil {
+		t.Fatalf("ParseWebhook failed: %v", err)
+	}
+	if event.ID != "evt_1" || event.Type != EventIntentSucceeded || event.Intent == nil {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if event.Intent.ID != intent.ID || event.Intent.Status != IntentSucceeded || event.Intent.Amount != 500 || event.Intent.Currency != "USD" {
+		t.Fatalf("unexpected intent: %+v", event.Intent)
+	}
+
+	if _, err := provider.ParseWebhook(append(payload, ' '), signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a modified payload, got %v", err)
+	}
+
+	old := SignPayload("whsec_test", payload, time.Now().Add(-time.Hour))
+	if _, err := provider.ParseWebhook(payload, old); !errors.Is(err, ErrExpiredSignature) {
+		t.Fatalf("expected ErrExpiredSignature, got %v", err)
+	}
+
+	// a header signed with both the old and the new secret is accepted
+	_, current, _ := strings.Cut(signature, ",v1=")
+	rolled := SignPayload("whsec_old", payload, time.Now()) + ",v1=" + current
+	if _, err := provider.ParseWebhook(payload, rolled); err != nil {
+		t.Fatalf("expected a rolled secret to be accepted, got %v", err)
+	}
+
+	// without a secret every signature is rejected, including one made
+	// with the empty key
+	unsigned := NewFakeProvider("")
+	if _, err := unsigned.ParseWebhook(payload, SignPayload("", payload, time.Now())); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature without a secret, got %v", err)
+	}
+	if _, err := NewProvider(Config{Type: "fake"}); err == nil {
+		t.Fatalf("expected the fake provider to require a webhook secret")
+	}
+}
+
+func TestFakeRefund(t *testing.T) {
+	provider := NewFakeProvider("whsec_test")
+	ctx := context.Background()
+
+	intent, err := provider.CreateIntent(ctx, CreateIntentParams{Amount: 500, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("CreateIntent failed: %v", err)
+	}
+	if _, err := provider.Refund(ctx, RefundParams{IntentID: intent.ID}); err == nil {
+		t.Fatalf("expected refunding an unpaid intent to fail")
+	}
+	if _, err := provider.ConfirmIntent(ctx, intent.ID, FakeCardSucceeds); err != nil {
+		t.Fatalf("ConfirmIntent failed: %v", err)
+	}
+
+	refund, err := provider.Refund(ctx, RefundParams{IntentID: intent.ID, Amount: 200})
+	if err != nil {
+		t.Fatalf("Refund failed: %v", err)
+	}
+	if refund.Amount != 200 || refund.Status != RefundSucceeded || refund.IntentID != intent.ID {
+		t.Fatalf("unexpected refund: %+v", refund)
+	}
+
+	rest, err := provider.Refund(ctx, RefundParams{IntentID: intent.ID})
+	if err != nil {
+		t.Fatalf("Refund failed: %v", err)
+	}
+	if rest.Amount != 300 {
+		t.Fatalf("expected the remaining 300 to be refunded, got %d", rest.Amount)
+	}
+	if _, err := provider.Refund(ctx, RefundParams{IntentID: intent.ID, Amount: 1}); err == nil {
+		t.Fatalf("expected over-refunding to fail")
+	}
+}
@@ -0,0 +1,174 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the request header carrying the webhook signature.
+const SignatureHeader = "Stripe-Signature"
+
+// DefaultWebhookTolerance is how old a signed webhook may be when no
+// tolerance is configured.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("webhook signature is invalid")
+	ErrExpiredSignature = errors.New("webhook signature has expired")
+)
+
+// SignPayload returns the signature header for a webhook payload sent at
+// t, in the format used by Stripe: "t=<unix time>,v1=<hex HMAC-SHA256>".
+func SignPayload(secret string, payload []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + computeSignature(secret, timestamp, payload)
+}
+
+func computeSignature(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks that header holds a valid signature of payload
+// made no more than tolerance before now. Any of several v1 signatures may
+// match, which lets the secret be rolled.
+func verifySignature(secret string, payload []byte, header string, tolerance time.Duration, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	// an empty key would accept signatures anyone can compute
+	if secret == "" || timestamp == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	expected := computeSignature(secret, timestamp, payload)
+	valid := false
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if now.Sub(time.Unix(sec, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+type stripeIntent struct {
+	ID               string            `json:"id"`
+	Amount           int64             `json:"amount"`
+	Currency         string            `json:"currency"`
+	Status           string            `json:"status"`
+	ClientSecret     string            `json:"client_secret"`
+	Metadata         map[string]string `json:"metadata"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+func (i stripeIntent) intent() Intent {
+	intent := Intent{
+		ID:           i.ID,
+		Amount:       i.Amount,
+		Currency:     strings.ToUpper(i.Currency),
+		Status:       IntentStatus(i.Status),
+		ClientSecret: i.ClientSecret,
+		Metadata:     i.Metadata,
+	}
+	if i.LastPaymentError != nil {
+		intent.FailureMessage = i.LastPaymentError.Message
+	}
+	return intent
+}
+
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	PaymentIntent string `json:"payment_intent"`
+	Status        string `json:"status"`
+	Reason        string `json:"reason"`
+}
+
+func (r stripeRefund) refund() Refund {
+	return Refund{
+		ID:       r.ID,
+		IntentID: r.PaymentIntent,
+		Amount:   r.Amount,
+		Currency: strings.ToUpper(r.Currency),
+		Status:   RefundStatus(r.Status),
+		Reason:   r.Reason,
+	}
+}
+
+// parseEvent decodes a webhook payload in the Stripe event format.
+func parseEvent(payload []byte) (Event, error) {
+	var raw stripeEvent
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return Event{}, fmt.Errorf("cannot decode webhook event: %w", err)
+	}
+	if raw.ID == "" || raw.Type == "" {
+		return Event{}, fmt.Errorf("webhook event has no id or type")
+	}
+
+	event := Event{
+		ID:      raw.ID,
+		Type:    EventType(raw.Type),
+		Created: time.Unix(raw.Created, 0),
+	}
+	switch event.Type {
+	case EventIntentSucceeded, EventIntentFailed, EventIntentCanceled:
+		var intent stripeIntent
+		if err := json.Unmarshal(raw.Data.Object, &intent); err != nil {
+			return Event{}, fmt.Errorf("cannot decode payment intent: %w", err)
+		}
+		i := intent.intent()
+		event.Intent = &i
+	case EventRefundCreated, EventRefundUpdated:
+		var refund stripeRefund
+		if err := json.Unmarshal(raw.Data.Object, &refund); err != nil {
+			return Event{}, fmt.Errorf("cannot decode refund: %w", err)
+		}
+		r := refund.refund()
+		event.Refund = &r
+	}
+	return event, nil
+}
@@ -0,0 +1,171 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const minJWTSecretKeySize = 32
+
+// jwtClaims maps a Payload onto the registered JWT claims: jti is the token
+// ID, sub the user ID, iat and exp the validity period. Version 1 tokens
+// carried the user name in sub and had no ver claim.
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Version   int       `json:"ver,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	SessionID string    `json:"sid,omitempty"`
+	Role      string    `json:"role"`
+	TokenType TokenType `json:"token_type"`
+}
+
+// JWTMaker is a JSON Web Token maker
+type JWTMaker struct {
+	method     jwt.SigningMethod
+	signingKey any
+	keyID      string
+	keyFunc    jwt.Keyfunc
+}
+
+// jwtPublicMaker is a JWTMaker whose tokens are verified with the public
+// keys of a KeySet.
+type jwtPublicMaker struct {
+	*JWTMaker
+	keys *KeySet
+}
+
+// NewJWTMaker creates a new JWTMaker signing HS256 tokens with secretKey
+func NewJWTMaker(secretKey string) (Maker, error) {
+	if len(secretKey) < minJWTSecretKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minJWTSecretKeySize)
+	}
+
+	key := []byte(secretKey)
+	maker := &JWTMaker{
+		method:     jwt.SigningMethodHS256,
+		signingKey: key,
+		keyFunc: func(*jwt.Token) (any, error) {
+			return key, nil
+		},
+	}
+
+	return maker, nil
+}
+
+// NewJWTEdDSAMaker creates a new JWTMaker signing EdDSA tokens with the
+// active key of keys. The key ID is sent in the kid header.
+func NewJWTEdDSAMaker(keys *KeySet) (Maker, error) {
+	active := keys.Active()
+	maker := &jwtPublicMaker{
+		JWTMaker: &JWTMaker{
+			method:     jwt.SigningMethodEdDSA,
+			signingKey: active.PrivateKey,
+			keyID:      active.ID,
+			keyFunc: func(token *jwt.Token) (any, error) {
+				keyID, _ := token.Header["kid"].(string)
+				publicKey, ok := keys.VerificationKey(keyID)
+				if !ok {
+					return nil, ErrInvalidToken
+				}
+				return publicKey, nil
+			},
+		},
+		keys: keys,
+	}
+
+	return maker, nil
+}
+
+// CreateToken creates a new token for a specific user, session and duration
+func (maker *JWTMaker) CreateToken(userID int64, username string, role string, sessionID uuid.UUID, duration time.Duration, tokenType TokenType) (string, *Payload, error) {
+	payload, err := NewPayload(userID, username, role, sessionID, duration, tokenType)
+	if err != nil {
+		return "", payload, err
+	}
+
+	// JWT timestamps have a precision of one second; truncate so the
+	// returned payload matches the one VerifyToken will produce.
+	payload.IssuedAt = payload.IssuedAt.Truncate(time.Second)
+	payload.ExpiredAt = payload.ExpiredAt.Truncate(time.Second)
+
+	claims := jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        payload.ID.String(),
+			Subject:   strconv.FormatInt(payload.UserID, 10),
+			IssuedAt:  jwt.NewNumericDate(payload.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(payload.ExpiredAt),
+		},
+		Version:   payload.Version,
+		Name:      payload.Name,
+		SessionID: payload.SessionID.String(),
+		Role:      payload.Role,
+		TokenType: payload.Type,
+	}
+
+	jwtToken := jwt.NewWithClaims(maker.method, claims)
+	if maker.keyID != "" {
+		jwtToken.Header["kid"] = maker.keyID
+	}
+
+	token, err := jwtToken.SignedString(maker.signingKey)
+	return token, payload, err
+}
+
+// VerifyToken checks if the token is valid or not
+func (maker *JWTMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	claims := &jwtClaims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, maker.keyFunc,
+		jwt.WithValidMethods([]string{maker.method.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil || claims.IssuedAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{
+		ID:        tokenID,
+		Version:   claims.Version,
+		Type:      claims.TokenType,
+		Name:      claims.Subject,
+		Role:      claims.Role,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiredAt: claims.ExpiresAt.Time,
+	}
+	if claims.Version >= PayloadVersion {
+		payload.Name = claims.Name
+		payload.UserID, err = strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		payload.SessionID, err = uuid.Parse(claims.SessionID)
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	err = payload.Valid(tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// PublicKeys returns the keys whose tokens are currently accepted
+func (maker *jwtPublicMaker) PublicKeys() []PublicKey {
+	return maker.keys.PublicKeys()
+}
@@ -0,0 +1,204 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testJWTSecret = "12345678901234567890123456789012"
+
+func newTestJWTMaker(t *testing.T, secretKey string) Maker {
+	t.Helper()
+
+	maker, err := NewJWTMaker(secretKey)
+	if err != nil {
+		t.Fatalf("failed to create maker: %v", err)
+	}
+	return maker
+}
+
+func TestNewJWTMakerKeySize(t *testing.T) {
+	if _, err := NewJWTMaker(testJWTSecret[:31]); err == nil {
+		t.Fatal("expected an error for a short secret key")
+	}
+}
+
+func TestJWTMaker(t *testing.T) {
+	maker := newTestJWTMaker(t, testJWTSecret)
+	sessionID := uuid.New()
+
+	token, payload, err := maker.CreateToken(42, "user@example.com", "donor", sessionID, time.Minute, TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+
+	got, err := maker.VerifyToken(token, TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("VerifyToken failed: %v", err)
+	}
+	// the returned payload already has the precision of the JWT claims
+	if *got != *payload {
+		t.Fatalf("payload changed in the token:\n got %+v\nwant %+v", got, payload)
+	}
+	if got.UserID != 42 || got.SessionID != sessionID || got.Version != PayloadVersion {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+}
+
+// signJWT signs claims as they would be signed by another issuer.
+func signJWT(t *testing.T, method jwt.SigningMethod, key any, claims jwt.Claims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTMakerRejects(t *testing.T) {
+	maker := newTestJWTMaker(t, testJWTSecret)
+
+	create := func(duration time.Duration, tokenType TokenType) string {
+		token, _, err := maker.CreateToken(42, "user@example.com", "donor", uuid.New(), duration, tokenType)
+		if err != nil {
+			t.Fatalf("CreateToken failed: %v", err)
+		}
+		return token
+	}
+	claims := func(expiresAt *jwt.NumericDate) jwtClaims {
+		return jwtClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Subject:   "42",
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: expiresAt,
+			},
+			Version:   PayloadVersion,
+			SessionID: uuid.NewString(),
+			Role:      "donor",
+			TokenType: TokenTypeAccessToken,
+		}
+	}
+	inAMinute := jwt.NewNumericDate(time.Now().Add(time.Minute))
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:    "Expired",
+			token:   create(-time.Minute, TokenTypeAccessToken),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "WrongType",
+			token:   create(time.Minute, TokenTypeRefreshToken),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "OtherSecret",
+			token:   signJWT(t, jwt.SigningMethodHS256, []byte("abcdefghijabcdefghijabcdefghijab"), claims(inAMinute)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "AlgNone",
+			token:   signJWT(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(inAMinute)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "OtherHMACAlgorithm",
+			token:   signJWT(t, jwt.SigningMethodHS512, []byte(testJWTSecret), claims(inAMinute)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "NoExpiry",
+			token:   signJWT(t, jwt.SigningMethodHS256, []byte(testJWTSecret), claims(nil)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Malformed",
+			token:   "not.a.jwt",
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := maker.VerifyToken(tc.token, TokenTypeAccessToken)
+			if !errors.Is(err, tc.wantErr) || payload != nil {
+				t.Fatalf("VerifyToken() = %v, %v, want error %v", payload, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTEdDSAMaker(t *testing.T) {
+	oldKey := newTestSigningKey(t, "2026-09", KeyStatusActive)
+	newKey := newTestSigningKey(t, "2026-10", KeyStatusActive)
+
+	before, err := NewJWTEdDSAMaker(newTestKeySet(t, oldKey))
+	if err != nil {
+		t.Fatalf("failed to create maker: %v", err)
+	}
+	token, _, err := before.CreateToken(42, "user@example.com", "donor", uuid.New(), time.Minute, TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwtClaims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if parsed.Header["alg"] != "EdDSA" || parsed.Header["kid"] != "2026-09" {
+		t.Fatalf("unexpected header: %v", parsed.Header)
+	}
+
+	oldKey.Status = KeyStatusVerify
+	rotated, err := NewJWTEdDSAMaker(newTestKeySet(t, newKey, oldKey))
+	if err != nil {
+		t.Fatalf("failed to create maker: %v", err)
+	}
+	if _, err := rotated.VerifyToken(token, TokenTypeAccessToken); err != nil {
+		t.Fatalf("token signed with the previous key is rejected: %v", err)
+	}
+	if keys := rotated.(PublicKeyProvider).PublicKeys(); len(keys) != 2 {
+		t.Fatalf("unexpected public keys: %+v", keys)
+	}
+
+	oldKey.Status = KeyStatusRetired
+	retired, err := NewJWTEdDSAMaker(newTestKeySet(t, newKey, oldKey))
+	if err != nil {
+		t.Fatalf("failed to create maker: %v", err)
+	}
+	if _, err := retired.VerifyToken(token, TokenTypeAccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token signed with a retired key: got %v, want %v", err, ErrInvalidToken)
+	}
+
+	// an HMAC token keyed with the public key must not pass as EdDSA
+	forgery := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   "1",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Version:   PayloadVersion,
+		SessionID: uuid.NewString(),
+		Role:      "admin",
+		TokenType: TokenTypeAccessToken,
+	})
+	forgery.Header["kid"] = newKey.ID
+	forged, err := forgery.SignedString([]byte(newKey.PublicKey))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if _, err := retired.VerifyToken(forged, TokenTypeAccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("HS256 token signed with the public key: got %v, want %v", err, ErrInvalidToken)
+	}
+}
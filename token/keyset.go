@@ -0,0 +1,178 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// KeyStatus tells how a key in a KeySet may be used.
+type KeyStatus string
+
+const (
+	// KeyStatusActive marks the single key used to sign new tokens.
+	KeyStatusActive KeyStatus = "active"
+	// KeyStatusVerify marks a key that is no longer (or not yet) used for
+	// signing but whose tokens are still accepted.
+	KeyStatusVerify KeyStatus = "verify"
+	// KeyStatusRetired marks a key whose tokens are rejected.
+	KeyStatusRetired KeyStatus = "retired"
+)
+
+// SigningKey is an Ed25519 key pair identified by a key ID. PrivateKey is
+// only required for the active key.
+type SigningKey struct {
+	ID         string
+	Status     KeyStatus
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+// KeySet holds the keys used to sign and verify asymmetric tokens.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// PublicKey is a verification key published to other services.
+type PublicKey struct {
+	ID  string
+	Key ed25519.PublicKey
+}
+
+// PublicKeyProvider is implemented by makers whose tokens can be verified
+// with published public keys.
+type PublicKeyProvider interface {
+	// PublicKeys returns the keys whose tokens are currently accepted.
+	PublicKeys() []PublicKey
+}
+
+type keySetFile struct {
+	Keys []struct {
+		ID         string    `json:"kid"`
+		Status     KeyStatus `json:"status"`
+		PrivateKey string    `json:"private_key"`
+		PublicKey  string    `json:"public_key"`
+	} `json:"keys"`
+}
+
+// LoadKeySet reads a JSON key set file of the form
+//
+//	{"keys": [{"kid": "2026-10", "status": "active", "private_key": "<base64>"}]}
+//
+// Private keys are base64 encoded 32 byte seeds or 64 byte Ed25519 private
+// keys. Keys that only verify tokens may give a base64 public_key instead.
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key set: %w", err)
+	}
+
+	var file keySetFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot parse key set: %w", err)
+	}
+
+	keys := make([]SigningKey, 0, len(file.Keys))
+	for _, k := range file.Keys {
+		key := SigningKey{ID: k.ID, Status: k.Status}
+
+		if k.PrivateKey != "" {
+			raw, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid private key encoding", k.ID)
+			}
+			switch len(raw) {
+			case ed25519.SeedSize:
+				key.PrivateKey = ed25519.NewKeyFromSeed(raw)
+			case ed25519.PrivateKeySize:
+				key.PrivateKey = ed25519.PrivateKey(raw)
+			default:
+				return nil, fmt.Errorf("key %q: invalid private key size", k.ID)
+			}
+			key.PublicKey = key.PrivateKey.Public().(ed25519.PublicKey)
+		} else if k.PublicKey != "" {
+			raw, err := base64.StdEncoding.DecodeString(k.PublicKey)
+			if err != nil || len(raw) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("key %q: invalid public key", k.ID)
+			}
+			key.PublicKey = ed25519.PublicKey(raw)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys)
+}
+
+// NewKeySet checks that keys contain exactly one active key with a private
+// key and builds a KeySet from them.
+func NewKeySet(keys []SigningKey) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*SigningKey, len(keys))}
+
+	for i := range keys {
+		key := &keys[i]
+		if key.ID == "" {
+			return nil, fmt.Errorf("key set contains a key without kid")
+		}
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("key %q: duplicate kid", key.ID)
+		}
+		if len(key.PublicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %q: missing public key", key.ID)
+		}
+
+		switch key.Status {
+		case KeyStatusActive:
+			if set.active != nil {
+				return nil, fmt.Errorf("key set has more than one active key")
+			}
+			if len(key.PrivateKey) != ed25519.PrivateKeySize {
+				return nil, fmt.Errorf("key %q: active key needs a private key", key.ID)
+			}
+			set.active = key
+		case KeyStatusVerify, KeyStatusRetired:
+		default:
+			return nil, fmt.Errorf("key %q: unknown status %q", key.ID, key.Status)
+		}
+
+		set.keys[key.ID] = key
+	}
+
+	if set.active == nil {
+		return nil, fmt.Errorf("key set has no active key")
+	}
+
+	return set, nil
+}
+
+// Active returns the key used to sign new tokens.
+func (set *KeySet) Active() *SigningKey {
+	return set.active
+}
+
+// VerificationKey returns the public key with the given ID, unless it is
+// unknown or retired.
+func (set *KeySet) VerificationKey(id string) (ed25519.PublicKey, bool) {
+	key, ok := set.keys[id]
+	if !ok || key.Status == KeyStatusRetired {
+		return nil, false
+	}
+	return key.PublicKey, true
+}
+
+// PublicKeys returns every key that is not retired, active key first.
+func (set *KeySet) PublicKeys() []PublicKey {
+	var verify []PublicKey
+	for _, key := range set.keys {
+		if key.Status == KeyStatusVerify {
+			verify = append(verify, PublicKey{ID: key.ID, Key: key.PublicKey})
+		}
+	}
+	sort.Slice(verify, func(i, j int) bool { return verify[i].ID < verify[j].ID })
+
+	return append([]PublicKey{{ID: set.active.ID, Key: set.active.PublicKey}}, verify...)
+}
@@ -0,0 +1,154 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSigningKey(t *testing.T, id string, status KeyStatus) SigningKey {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return SigningKey{ID: id, Status: status, PrivateKey: privateKey, PublicKey: publicKey}
+}
+
+func newTestKeySet(t *testing.T, keys ...SigningKey) *KeySet {
+	t.Helper()
+
+	set, err := NewKeySet(keys)
+	if err != nil {
+		t.Fatalf("failed to create key set: %v", err)
+	}
+	return set
+}
+
+func TestNewKeySet(t *testing.T) {
+	active := newTestSigningKey(t, "a", KeyStatusActive)
+	verify := newTestSigningKey(t, "b", KeyStatusVerify)
+	retired := newTestSigningKey(t, "c", KeyStatusRetired)
+
+	publicOnly := newTestSigningKey(t, "d", KeyStatusActive)
+	publicOnly.PrivateKey = nil
+	otherActive := newTestSigningKey(t, "e", KeyStatusActive)
+	noKID := newTestSigningKey(t, "", KeyStatusVerify)
+	unknownStatus := newTestSigningKey(t, "f", "pending")
+
+	testCases := []struct {
+		name    string
+		keys    []SigningKey
+		wantErr bool
+	}{
+		{name: "OK", keys: []SigningKey{active, verify, retired}},
+		{name: "NoActiveKey", keys: []SigningKey{verify, retired}, wantErr: true},
+		{name: "TwoActiveKeys", keys: []SigningKey{active, otherActive}, wantErr: true},
+		{name: "ActiveKeyWithoutPrivateKey", keys: []SigningKey{publicOnly}, wantErr: true},
+		{name: "DuplicateKID", keys: []SigningKey{active, active}, wantErr: true},
+		{name: "MissingKID", keys: []SigningKey{active, noKID}, wantErr: true},
+		{name: "UnknownStatus", keys: []SigningKey{active, unknownStatus}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewKeySet(tc.keys)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewKeySet() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeySetVerificationKeys(t *testing.T) {
+	set := newTestKeySet(t,
+		newTestSigningKey(t, "2026-10", KeyStatusActive),
+		newTestSigningKey(t, "2026-09", KeyStatusVerify),
+		newTestSigningKey(t, "2026-08", KeyStatusRetired),
+	)
+
+	for _, id := range []string{"2026-10", "2026-09"} {
+		if _, ok := set.VerificationKey(id); !ok {
+			t.Errorf("key %s does not verify tokens", id)
+		}
+	}
+	for _, id := range []string{"2026-08", "unknown"} {
+		if _, ok := set.VerificationKey(id); ok {
+			t.Errorf("key %s verifies tokens", id)
+		}
+	}
+
+	// retired keys are no longer published, the active key comes first
+	keys := set.PublicKeys()
+	if len(keys) != 2 || keys[0].ID != "2026-10" || keys[1].ID != "2026-09" {
+		t.Fatalf("unexpected public keys: %+v", keys)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatalf("failed to generate seed: %v", err)
+	}
+	oldKey := newTestSigningKey(t, "old", KeyStatusVerify)
+	encode := base64.StdEncoding.EncodeToString
+
+	testCases := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{
+			name: "OK",
+			file: fmt.Sprintf(`{"keys": [
+				{"kid": "new", "status": "active", "private_key": %q},
+				{"kid": "old", "status": "verify", "public_key": %q}
+			]}`, encode(seed), encode(oldKey.PublicKey)),
+		},
+		{
+			name:    "InvalidPrivateKeySize",
+			file:    fmt.Sprintf(`{"keys": [{"kid": "new", "status": "active", "private_key": %q}]}`, encode(seed[:16])),
+			wantErr: true,
+		},
+		{
+			name:    "InvalidPublicKey",
+			file:    `{"keys": [{"kid": "new", "status": "active", "public_key": "not base64"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidJSON",
+			file:    `{"keys": [`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+				t.Fatalf("failed to write key set: %v", err)
+			}
+
+			set, err := LoadKeySet(path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("LoadKeySet() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			active := set.Active()
+			if active.ID != "new" || !active.PublicKey.Equal(ed25519.NewKeyFromSeed(seed).Public()) {
+				t.Fatalf("unexpected active key: %+v", active)
+			}
+			if publicKey, ok := set.VerificationKey("old"); !ok || !publicKey.Equal(oldKey.PublicKey) {
+				t.Fatalf("old key was not loaded")
+			}
+		})
+	}
+}
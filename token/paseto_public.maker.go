@@ -0,0 +1,132 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// pasetoV4PublicHeader prefixes every PASETO v4.public token.
+const pasetoV4PublicHeader = "v4.public."
+
+// pasetoFooter is the unencrypted footer of the tokens issued by
+// PasetoPublicMaker. It names the key that signed the token.
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// PasetoPublicMaker is a PASETO v4.public token maker. Tokens are signed
+// with Ed25519 so that other services can verify them with the public keys
+// alone.
+type PasetoPublicMaker struct {
+	keys *KeySet
+}
+
+// NewPasetoPublicMaker creates a new PasetoPublicMaker
+func NewPasetoPublicMaker(keys *KeySet) (Maker, error) {
+	return &PasetoPublicMaker{keys: keys}, nil
+}
+
+// CreateToken creates a new token for a specific user, session and duration
+func (maker *PasetoPublicMaker) CreateToken(userID int64, username string, role string, sessionID uuid.UUID, duration time.Duration, tokenType TokenType) (string, *Payload, error) {
+	payload, err := NewPayload(userID, username, role, sessionID, duration, tokenType)
+	if err != nil {
+		return "", payload, err
+	}
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", payload, err
+	}
+
+	key := maker.keys.Active()
+	footer, err := json.Marshal(pasetoFooter{KeyID: key.ID})
+	if err != nil {
+		return "", payload, err
+	}
+
+	signature := ed25519.Sign(key.PrivateKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil))
+
+	body := append(message, signature...)
+	token := pasetoV4PublicHeader +
+		base64.RawURLEncoding.EncodeToString(body) + "." +
+		base64.RawURLEncoding.EncodeToString(footer)
+
+	return token, payload, nil
+}
+
+// VerifyToken checks if the token is valid or not
+func (maker *PasetoPublicMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	if !strings.HasPrefix(token, pasetoV4PublicHeader) {
+		return nil, ErrInvalidToken
+	}
+
+	encodedBody, encodedFooter, ok := strings.Cut(strings.TrimPrefix(token, pasetoV4PublicHeader), ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(encodedBody)
+	if err != nil || len(body) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+	footer, err := base64.RawURLEncoding.DecodeString(encodedFooter)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var f pasetoFooter
+	if err := json.Unmarshal(footer, &f); err != nil {
+		return nil, ErrInvalidToken
+	}
+	publicKey, ok := maker.keys.VerificationKey(f.KeyID)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	message := body[:len(body)-ed25519.SignatureSize]
+	signature := body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(publicKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err := json.Unmarshal(message, payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	err = payload.Valid(tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// PublicKeys returns the keys whose tokens are currently accepted
+func (maker *PasetoPublicMaker) PublicKeys() []PublicKey {
+	return maker.keys.PublicKeys()
+}
+
+// pae is the PASETO pre-authentication encoding of pieces.
+func pae(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	writeLE64 := func(n int) {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(n)&(1<<63-1))
+		buf.Write(b[:])
+	}
+
+	writeLE64(len(pieces))
+	for _, piece := range pieces {
+		writeLE64(len(piece))
+		buf.Write(piece)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,158 @@
+package token
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestPasetoPublicMaker(t *testing.T, keys *KeySet) Maker {
+	t.Helper()
+
+	maker, err := NewPasetoPublicMaker(keys)
+	if err != nil {
+		t.Fatalf("failed to create maker: %v", err)
+	}
+	return maker
+}
+
+func TestPasetoPublicMaker(t *testing.T) {
+	maker := newTestPasetoPublicMaker(t, newTestKeySet(t, newTestSigningKey(t, "k1", KeyStatusActive)))
+	sessionID := uuid.New()
+
+	token, payload, err := maker.CreateToken(42, "user@example.com", "donor", sessionID, time.Minute, TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token, pasetoV4PublicHeader) {
+		t.Fatalf("token is not a v4.public token: %s", token)
+	}
+
+	got, err := maker.VerifyToken(token, TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("VerifyToken failed: %v", err)
+	}
+	if got.ID != payload.ID || got.UserID != 42 || got.SessionID != sessionID ||
+		got.Name != "user@example.com" || got.Role != "donor" || got.Version != PayloadVersion {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+}
+
+func TestPasetoPublicMakerRejects(t *testing.T) {
+	maker := newTestPasetoPublicMaker(t, newTestKeySet(t, newTestSigningKey(t, "k1", KeyStatusActive)))
+	otherMaker := newTestPasetoPublicMaker(t, newTestKeySet(t, newTestSigningKey(t, "k1", KeyStatusActive)))
+
+	create := func(maker Maker, duration time.Duration, tokenType TokenType) string {
+		token, _, err := maker.CreateToken(42, "user@example.com", "donor", uuid.New(), duration, tokenType)
+		if err != nil {
+			t.Fatalf("CreateToken failed: %v", err)
+		}
+		return token
+	}
+
+	valid := create(maker, time.Minute, TokenTypeAccessToken)
+	body, footer, _ := strings.Cut(strings.TrimPrefix(valid, pasetoV4PublicHeader), ".")
+	rawBody, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		t.Fatalf("failed to decode token: %v", err)
+	}
+	tampered := bytes.Clone(rawBody)
+	tampered[0] ^= 1
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:    "Expired",
+			token:   create(maker, -time.Minute, TokenTypeAccessToken),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "WrongType",
+			token:   create(maker, time.Minute, TokenTypeRefreshToken),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "OtherKeyWithSameID",
+			token:   create(otherMaker, time.Minute, TokenTypeAccessToken),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "TamperedMessage",
+			token:   pasetoV4PublicHeader + base64.RawURLEncoding.EncodeToString(tampered) + "." + footer,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "UnknownKeyID",
+			token:   pasetoV4PublicHeader + body + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"k2"}`)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "LocalToken",
+			token:   "v4.local." + body,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "MissingFooter",
+			token:   pasetoV4PublicHeader + body,
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := maker.VerifyToken(tc.token, TokenTypeAccessToken)
+			if !errors.Is(err, tc.wantErr) || payload != nil {
+				t.Fatalf("VerifyToken() = %v, %v, want error %v", payload, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasetoPublicMakerKeyRotation(t *testing.T) {
+	oldKey := newTestSigningKey(t, "2026-09", KeyStatusActive)
+	newKey := newTestSigningKey(t, "2026-10", KeyStatusActive)
+
+	before := newTestPasetoPublicMaker(t, newTestKeySet(t, oldKey))
+	token, _, err := before.CreateToken(42, "user@example.com", "donor", uuid.New(), time.Minute, TokenTypeAccessToken)
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+
+	oldKey.Status = KeyStatusVerify
+	rotated := newTestPasetoPublicMaker(t, newTestKeySet(t, newKey, oldKey))
+	if _, err := rotated.VerifyToken(token, TokenTypeAccessToken); err != nil {
+		t.Fatalf("token signed with the previous key is rejected: %v", err)
+	}
+
+	oldKey.Status = KeyStatusRetired
+	retired := newTestPasetoPublicMaker(t, newTestKeySet(t, newKey, oldKey))
+	if _, err := retired.VerifyToken(token, TokenTypeAccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token signed with a retired key: got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestPAE(t *testing.T) {
+	// test vectors from the PASETO specification
+	testCases := []struct {
+		pieces [][]byte
+		want   string
+	}{
+		{pieces: nil, want: "\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{pieces: [][]byte{{}}, want: "\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{pieces: [][]byte{[]byte("test")}, want: "\x01\x00\x00\x00\x00\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00test"},
+	}
+
+	for _, tc := range testCases {
+		if got := pae(tc.pieces...); string(got) != tc.want {
+			t.Errorf("pae(%q) = %q, want %q", tc.pieces, got, tc.want)
+		}
+	}
+}
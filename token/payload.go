@@ -11,27 +11,42 @@ import (
 var (
 	ErrInvalidToken = errors.New("token is invalid")
 	ErrExpiredToken = errors.New("token has expired")
+	ErrRevokedToken = errors.New("token has been revoked")
+	// ErrOutdatedToken is returned for access tokens issued in a payload
+	// format older than PayloadVersion. Clients should renew them.
+	ErrOutdatedToken = errors.New("token payload version is outdated")
 )
 
+// PayloadVersion is the version of the payload format issued by NewPayload.
+// Version 1 payloads carried no version field and identified the user by
+// email only; they are still accepted as refresh tokens.
+const PayloadVersion = 2
+
 type TokenType byte
 
 const (
 	TokenTypeAccessToken  = 1
 	TokenTypeRefreshToken = 2
+	// TokenTypeMFAPendingToken is issued after a correct password when the
+	// user still has to present a second factor.
+	TokenTypeMFAPendingToken = 3
 )
 
 // Payload contains the payload data of the token
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
+	Version   int       `json:"version"`
 	Type      TokenType `json:"token_type"`
+	UserID    int64     `json:"user_id"`
+	SessionID uuid.UUID `json:"session_id"`
 	Name      string    `json:"name"`
 	Role      string    `json:"role"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-// NewPayload creates a new token payload with a specific username and duration
-func NewPayload(username string, role string, duration time.Duration, tokenType TokenType) (*Payload, error) {
+// NewPayload creates a new token payload for a specific user, session and duration
+func NewPayload(userID int64, username string, role string, sessionID uuid.UUID, duration time.Duration, tokenType TokenType) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -39,7 +54,10 @@ func NewPayload(username string, role string, duration time.Duration, tokenType
 
 	payload := &Payload{
 		ID:        tokenID,
+		Version:   PayloadVersion,
 		Type:      tokenType,
+		UserID:    userID,
+		SessionID: sessionID,
 		Name:      username,
 		Role:      role,
 		IssuedAt:  time.Now(),
@@ -56,5 +74,10 @@ func (payload *Payload) Valid(tokenType TokenType) error {
 	if time.Now().After(payload.ExpiredAt) {
 		return ErrExpiredToken
 	}
+	// old access tokens lack the user ID the auth path relies on, while old
+	// refresh tokens are still good for renewing them
+	if payload.Version < PayloadVersion && tokenType == TokenTypeAccessToken {
+		return ErrOutdatedToken
+	}
 	return nil
 }
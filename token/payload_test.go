@@ -0,0 +1,170 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/o1egl/paseto"
+)
+
+func TestPayloadValid(t *testing.T) {
+	testCases := []struct {
+		name      string
+		version   int
+		tokenType TokenType
+		expiredAt time.Time
+		wantType  TokenType
+		wantErr   error
+	}{
+		{
+			name:      "AccessToken",
+			version:   PayloadVersion,
+			tokenType: TokenTypeAccessToken,
+			expiredAt: time.Now().Add(time.Minute),
+			wantType:  TokenTypeAccessToken,
+		},
+		{
+			name:      "WrongType",
+			version:   PayloadVersion,
+			tokenType: TokenTypeMFAPendingToken,
+			expiredAt: time.Now().Add(time.Minute),
+			wantType:  TokenTypeAccessToken,
+			wantErr:   ErrInvalidToken,
+		},
+		{
+			name:      "Expired",
+			version:   PayloadVersion,
+			tokenType: TokenTypeAccessToken,
+			expiredAt: time.Now().Add(-time.Minute),
+			wantType:  TokenTypeAccessToken,
+			wantErr:   ErrExpiredToken,
+		},
+		{
+			name:      "Version1AccessToken",
+			version:   1,
+			tokenType: TokenTypeAccessToken,
+			expiredAt: time.Now().Add(time.Minute),
+			wantType:  TokenTypeAccessToken,
+			wantErr:   ErrOutdatedToken,
+		},
+		{
+			name:      "Version1RefreshToken",
+			version:   1,
+			tokenType: TokenTypeRefreshToken,
+			expiredAt: time.Now().Add(time.Minute),
+			wantType:  TokenTypeRefreshToken,
+		},
+		{
+			name:      "UnversionedAccessToken",
+			version:   0,
+			tokenType: TokenTypeAccessToken,
+			expiredAt: time.Now().Add(time.Minute),
+			wantType:  TokenTypeAccessToken,
+			wantErr:   ErrOutdatedToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := &Payload{Version: tc.version, Type: tc.tokenType, ExpiredAt: tc.expiredAt}
+			if err := payload.Valid(tc.wantType); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Valid() = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// legacyPayload is the version 1 payload, before user and session IDs were
+// added.
+type legacyPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      TokenType `json:"token_type"`
+	Username  string    `json:"name"`
+	Role      string    `json:"role"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+func TestPasetoMakerVersion1Tokens(t *testing.T) {
+	const symmetricKey = "12345678901234567890123456789012"
+	maker, err := NewPasetoMaker(symmetricKey)
+	if err != nil {
+		t.Fatalf("failed to create maker: %v", err)
+	}
+
+	encrypt := func(tokenType TokenType) string {
+		token, err := paseto.NewV2().Encrypt([]byte(symmetricKey), legacyPayload{
+			ID:        uuid.New(),
+			Type:      tokenType,
+			Username:  "user@example.com",
+			Role:      "donor",
+			IssuedAt:  time.Now(),
+			ExpiredAt: time.Now().Add(time.Minute),
+		}, nil)
+		if err != nil {
+			t.Fatalf("failed to encrypt token: %v", err)
+		}
+		return token
+	}
+
+	if _, err := maker.VerifyToken(encrypt(TokenTypeAccessToken), TokenTypeAccessToken); !errors.Is(err, ErrOutdatedToken) {
+		t.Fatalf("version 1 access token: got %v, want %v", err, ErrOutdatedToken)
+	}
+
+	payload, err := maker.VerifyToken(encrypt(TokenTypeRefreshToken), TokenTypeRefreshToken)
+	if err != nil {
+		t.Fatalf("version 1 refresh token is rejected: %v", err)
+	}
+	if payload.Version >= PayloadVersion || payload.UserID != 0 || payload.Name != "user@example.com" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestJWTMakerVersion1Tokens(t *testing.T) {
+	maker := newTestJWTMaker(t, testJWTSecret)
+
+	// version 1 tokens had the user name in sub and no ver claim
+	sign := func(tokenType TokenType) string {
+		return signJWT(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwtClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Subject:   "user@example.com",
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Role:      "donor",
+			TokenType: tokenType,
+		})
+	}
+
+	if _, err := maker.VerifyToken(sign(TokenTypeAccessToken), TokenTypeAccessToken); !errors.Is(err, ErrOutdatedToken) {
+		t.Fatalf("version 1 access token: got %v, want %v", err, ErrOutdatedToken)
+	}
+
+	payload, err := maker.VerifyToken(sign(TokenTypeRefreshToken), TokenTypeRefreshToken)
+	if err != nil {
+		t.Fatalf("version 1 refresh token is rejected: %v", err)
+	}
+	if payload.UserID != 0 || payload.SessionID != uuid.Nil || payload.Name != "user@example.com" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	// a current token must carry a numeric user ID
+	invalid := signJWT(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   "user@example.com",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Version:   PayloadVersion,
+		SessionID: uuid.NewString(),
+		TokenType: TokenTypeAccessToken,
+	})
+	if _, err := maker.VerifyToken(invalid, TokenTypeAccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("version 2 token without a user ID: got %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,37 @@
+package token
+
+// RevocationChecker reports whether an otherwise valid token was revoked
+// before it expired.
+type RevocationChecker interface {
+	IsRevoked(payload *Payload) bool
+}
+
+// revocationMaker wraps a Maker so that VerifyToken also consults a
+// RevocationChecker.
+type revocationMaker struct {
+	Maker
+	checker RevocationChecker
+}
+
+// NewRevocationMaker returns a Maker that rejects tokens reported as revoked
+// by checker with ErrRevokedToken.
+func NewRevocationMaker(maker Maker, checker RevocationChecker) Maker {
+	return &revocationMaker{
+		Maker:   maker,
+		checker: checker,
+	}
+}
+
+// VerifyToken checks if the token is valid and has not been revoked
+func (maker *revocationMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	payload, err := maker.Maker.VerifyToken(token, tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	if maker.checker.IsRevoked(payload) {
+		return nil, ErrRevokedToken
+	}
+
+	return payload, nil
+}
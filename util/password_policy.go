@@ -0,0 +1,66 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MaxPasswordBytes is the longest password accepted. bcrypt ignores
+// everything past 72 bytes, so longer passwords would give a false sense of
+// strength and stop verifying if the hasher is switched back to bcrypt.
+const MaxPasswordBytes = 72
+
+// PasswordPolicy decides which new passwords are acceptable.
+type PasswordPolicy struct {
+	MinLength int
+	denylist  map[string]struct{}
+}
+
+// NewPasswordPolicy creates a PasswordPolicy. If denylistPath is set, the
+// file is read as one common or breached password per line; blank lines and
+// lines starting with # are ignored.
+func NewPasswordPolicy(minLength int, denylistPath string) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength: minLength,
+		denylist:  make(map[string]struct{}),
+	}
+	if denylistPath == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(denylistPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open password denylist: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read password denylist: %w", err)
+	}
+
+	return policy, nil
+}
+
+// Validate returns an error describing why password is not acceptable.
+func (policy *PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", policy.MinLength)
+	}
+	if len(password) > MaxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes long", MaxPasswordBytes)
+	}
+	if _, ok := policy.denylist[strings.ToLower(password)]; ok {
+		return fmt.Errorf("password is too common, choose another one")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	denylistPath := filepath.Join(t.TempDir(), "denylist.txt")
+	denylist := "# common passwords\n\npassword123\n  Qwertyuiop  \n"
+	if err := os.WriteFile(denylistPath, []byte(denylist), 0o600); err != nil {
+		t.Fatalf("failed to write denylist: %v", err)
+	}
+
+	policy, err := NewPasswordPolicy(10, denylistPath)
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy failed: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "OK", password: "correct-horse-battery", wantErr: false},
+		{name: "TooShort", password: "short", wantErr: true},
+		// length is counted in characters, not bytes
+		{name: "MultibyteTooShort", password: "ééééééééé", wantErr: true},
+		{name: "MultibyteLongEnough", password: "éééééééééé", wantErr: false},
+		{name: "TooLong", password: strings.Repeat("a", MaxPasswordBytes+1), wantErr: true},
+		{name: "LongestAccepted", password: strings.Repeat("a", MaxPasswordBytes), wantErr: false},
+		{name: "Denylisted", password: "password123", wantErr: true},
+		{name: "DenylistedIgnoresCase", password: "QWERTYUIOP", wantErr: true},
+		{name: "CommentIsNotDenylisted", password: "# common passwords", wantErr: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.password)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Validate(%q) = %v, wantErr %v", tc.password, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPasswordPolicyMissingDenylist(t *testing.T) {
+	if _, err := NewPasswordPolicy(10, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("expected an error for a missing denylist")
+	}
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2idHasher keeps hashing cheap.
+var testArgon2idHasher = Argon2idHasher{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasherFormat(t *testing.T) {
+	hashedPassword, err := HashPassword(testArgon2idHasher, "secret-password")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	phc := regexp.MustCompile(`^\$argon2id\$v=19\$m=1024,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+	if !phc.MatchString(hashedPassword) {
+		t.Fatalf("hash is not in the PHC format: %s", hashedPassword)
+	}
+
+	// every hash gets a new salt
+	other, err := HashPassword(testArgon2idHasher, "secret-password")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if other == hashedPassword {
+		t.Fatal("two hashes of the same password are equal")
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hashers := []struct {
+		name   string
+		hasher PasswordHasher
+	}{
+		{name: "Bcrypt", hasher: BcryptHasher{Cost: bcrypt.MinCost}},
+		{name: "Argon2id", hasher: testArgon2idHasher},
+	}
+
+	for _, h := range hashers {
+		t.Run(h.name, func(t *testing.T) {
+			hashedPassword, err := HashPassword(h.hasher, "secret-password")
+			if err != nil {
+				t.Fatalf("HashPassword failed: %v", err)
+			}
+			if err := CheckPassword("secret-password", hashedPassword); err != nil {
+				t.Fatalf("CheckPassword rejected the right password: %v", err)
+			}
+			if err := CheckPassword("wrong-password", hashedPassword); !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				t.Fatalf("CheckPassword with the wrong password: got %v, want %v", err, bcrypt.ErrMismatchedHashAndPassword)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordMalformedArgon2idHash(t *testing.T) {
+	hashedPassword, err := HashPassword(testArgon2idHasher, "secret-password")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	parts := strings.Split(hashedPassword, "$")
+
+	testCases := []struct {
+		name           string
+		hashedPassword string
+	}{
+		{name: "MissingKey", hashedPassword: strings.Join(parts[:5], "$")},
+		{name: "EmptyKey", hashedPassword: strings.Join(append(parts[:5:5], ""), "$")},
+		{name: "WrongVersion", hashedPassword: strings.Replace(hashedPassword, "v=19", "v=16", 1)},
+		{name: "MalformedParams", hashedPassword: strings.Replace(hashedPassword, "m=1024,t=1,p=1", "m=1024", 1)},
+		{name: "MalformedSalt", hashedPassword: strings.Join([]string{"", parts[1], parts[2], parts[3], "!!!", parts[5]}, "$")},
+		{name: "MalformedKey", hashedPassword: strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], "!!!"}, "$")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := CheckPassword("secret-password", tc.hashedPassword); !errors.Is(err, errInvalidArgon2idHash) {
+				t.Fatalf("got %v, want %v", err, errInvalidArgon2idHash)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := HashPassword(BcryptHasher{Cost: bcrypt.MinCost}, "secret-password")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	argon2idHash, err := HashPassword(testArgon2idHasher, "secret-password")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	stronger := testArgon2idHasher
+	stronger.Iterations = 2
+	moreMemory := testArgon2idHasher
+	moreMemory.Memory = 2048
+	longerKey := testArgon2idHasher
+	longerKey.KeyLength = 64
+
+	testCases := []struct {
+		name           string
+		hasher         PasswordHasher
+		hashedPassword string
+		want           bool
+	}{
+		{name: "SameBcryptCost", hasher: BcryptHasher{Cost: bcrypt.MinCost}, hashedPassword: bcryptHash, want: false},
+		{name: "BcryptCostChanged", hasher: BcryptHasher{Cost: bcrypt.MinCost + 1}, hashedPassword: bcryptHash, want: true},
+		{name: "BcryptToArgon2id", hasher: testArgon2idHasher, hashedPassword: bcryptHash, want: true},
+		{name: "Argon2idToBcrypt", hasher: BcryptHasher{Cost: bcrypt.MinCost}, hashedPassword: argon2idHash, want: true},
+		{name: "SameArgon2idParams", hasher: testArgon2idHasher, hashedPassword: argon2idHash, want: false},
+		{name: "Argon2idIterationsChanged", hasher: stronger, hashedPassword: argon2idHash, want: true},
+		{name: "Argon2idMemoryChanged", hasher: moreMemory, hashedPassword: argon2idHash, want: true},
+		{name: "Argon2idKeyLengthChanged", hasher: longerKey, hashedPassword: argon2idHash, want: true},
+		{name: "MalformedHash", hasher: testArgon2idHasher, hashedPassword: "$argon2id$v=19$", want: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.hasher.NeedsRehash(tc.hashedPassword); got != tc.want {
+				t.Fatalf("NeedsRehash() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
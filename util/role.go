@@ -0,0 +1,18 @@
+package util
+
+// Roles a user can hold. They are stored in users.role and copied into
+// access tokens at login.
+const (
+	AdminRole       = "admin"
+	GoalManagerRole = "goal_manager"
+	DonorRole       = "donor"
+)
+
+// IsSupportedRole reports whether role is one of the roles above.
+func IsSupportedRole(role string) bool {
+	switch role {
+	case AdminRole, GoalManagerRole, DonorRole:
+		return true
+	}
+	return false
+}
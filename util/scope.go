@@ -0,0 +1,41 @@
+package util
+
+// Scopes an API key can be granted. They name the resource and the access
+// the key has to it.
+const (
+	GoalsWriteScope     = "goals:write"
+	DonationsReadScope  = "donations:read"
+	DonationsWriteScope = "donations:write"
+)
+
+// scopeRoles lists the user roles allowed to hold each scope. A nil entry
+// means every role.
+var scopeRoles = map[string][]string{
+	GoalsWriteScope:     {AdminRole, GoalManagerRole},
+	DonationsReadScope:  nil,
+	DonationsWriteScope: {AdminRole},
+}
+
+// IsSupportedScope reports whether scope is one of the scopes above.
+func IsSupportedScope(scope string) bool {
+	_, ok := scopeRoles[scope]
+	return ok
+}
+
+// RoleAllowsScope reports whether a user with role may use an API key with
+// scope. Keys cannot give their owner more access than the owner has.
+func RoleAllowsScope(role string, scope string) bool {
+	roles, ok := scopeRoles[scope]
+	if !ok {
+		return false
+	}
+	if roles == nil {
+		return true
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
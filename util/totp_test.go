@@ -0,0 +1,131 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// the RFC vectors have 8 digits, codes are their last 6
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tc := range testCases {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+
+	// secrets are accepted in lower case too
+	got, err := TOTPCode(strings.ToLower(rfc6238Secret), TOTPStep(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Fatalf("lower case secret: got %s, %v", got, err)
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := TOTPStep(now)
+
+	code := func(step int64) string {
+		c, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name     string
+		code     string
+		skew     int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "Current", code: code(current), skew: 1, wantStep: current, wantOK: true},
+		{name: "PreviousStep", code: code(current - 1), skew: 1, wantStep: current - 1, wantOK: true},
+		{name: "NextStep", code: code(current + 1), skew: 1, wantStep: current + 1, wantOK: true},
+		{name: "BeyondSkew", code: code(current - 2), skew: 1},
+		{name: "NoSkew", code: code(current - 1), skew: 0},
+		{name: "WrongCode", code: "000000", skew: 1},
+		{name: "TooShort", code: code(current)[:5], skew: 1},
+		{name: "TooLong", code: code(current) + "0", skew: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, tc.code, now, tc.skew)
+			if ok != tc.wantOK || step != tc.wantStep {
+				t.Fatalf("ValidateTOTP() = (%d, %v), want (%d, %v)", step, ok, tc.wantStep, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret failed: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretBytes {
+		t.Fatalf("secret %q does not decode to %d bytes: %v", secret, totpSecretBytes, err)
+	}
+
+	uri, err := url.Parse(TOTPURI("Charity", "user@example.com", secret))
+	if err != nil {
+		t.Fatalf("invalid otpauth uri: %v", err)
+	}
+	query := uri.Query()
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Charity:user@example.com" ||
+		query.Get("secret") != secret || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Fatalf("unexpected otpauth uri: %s", uri)
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := RandomRecoveryCode()
+	if err != nil {
+		t.Fatalf("RandomRecoveryCode failed: %v", err)
+	}
+	if len(code) != 9 || code[4] != '-' || NormalizeRecoveryCode(code) != code {
+		t.Fatalf("unexpected recovery code %q", code)
+	}
+
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "abcd-efgh", want: "abcd-efgh"},
+		{input: "ABCD-EFGH", want: "abcd-efgh"},
+		{input: "abcdefgh", want: "abcd-efgh"},
+		{input: " abcd efgh ", want: "abcd-efgh"},
+		{input: "abc", want: "abc"},
+	}
+	for _, tc := range testCases {
+		if got := NormalizeRecoveryCode(tc.input); got != tc.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}